@@ -0,0 +1,15 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/internal/catalog"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+)
+
+// catalogEndpoints lists the product lookup endpoints
+func catalogEndpoints(h *catalog.Handler) []Endpoint {
+	return []Endpoint{
+		Typed(http.MethodGet, "/products/search", "Search products by name, barcode or category", h.Search).Require(logins.RightMakeSales),
+	}
+}
@@ -1,36 +1,74 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"log"
-	"net/http"
+	"strings"
 
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
 	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
 )
 
-func ConfigsGet(w http.ResponseWriter, r *http.Request) {
+// Empty is a request without parameters
+type Empty struct{}
+
+type ConfigsResponse struct {
+	Response string                `json:"response"`
+	Values   variables.SysSettings `json:"values"`
+	Profile  variables.Profile     `json:"profile"`
+}
+
+// ConfigsGet fetches the system settings
+func ConfigsGet(ctx context.Context, user logins.Users, req Empty) (ConfigsResponse, error) {
 	fmt.Println("configs get")
 
-	// get configs
 	settings, err := variables.FetchDefaults()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return ConfigsResponse{}, err
 	}
-	fmt.Println("settings =", settings)
 
-	respMap := make(map[string]interface{})
-	respMap["response"] = "success"
-	respMap["values"] = settings
-
-	jstr, err := json.Marshal(respMap)
+	// the features on for the user's branch
+	profile, err := variables.BranchProfile(ctx, user.Branch)
 	if err != nil {
-		log.Println("error failed to marshall into json   err =", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return ConfigsResponse{}, err
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(jstr)
+	return ConfigsResponse{Response: "success", Values: settings, Profile: profile}, nil
+}
+
+// ProfileRequest selects the industry mode of the user's branch, an empty mode follows the server's
+// Branch defaults to the user's and can't name another
+type ProfileRequest struct {
+	Branch string `json:"branch"`
+	Mode   string `json:"mode"`
+}
+
+func (r ProfileRequest) Validate() error {
+	return nil
+}
+
+type ProfileResponse struct {
+	Response string            `json:"response"`
+	Profile  variables.Profile `json:"profile"`
+}
+
+// ProfileSet switches the branch to another industry profile
+func ProfileSet(ctx context.Context, user logins.Users, req ProfileRequest) (ProfileResponse, error) {
+	if req.Branch = strings.TrimSpace(req.Branch); req.Branch == "" {
+		req.Branch = user.Branch
+	}
+	if req.Branch != user.Branch {
+		return ProfileResponse{}, apperr.New(apperr.Forbidden, "only your own branch's profile can be changed")
+	}
+
+	if err := variables.SetBranchMode(ctx, req.Branch, req.Mode); err != nil {
+		return ProfileResponse{}, err
+	}
+
+	profile, err := variables.BranchProfile(ctx, req.Branch)
+	if err != nil {
+		return ProfileResponse{}, err
+	}
+	return ProfileResponse{Response: "success", Profile: profile}, nil
 }
@@ -0,0 +1,16 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/internal/customer"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+)
+
+// customerEndpoints lists the customer master endpoints
+func customerEndpoints(h *customer.Handler) []Endpoint {
+	return []Endpoint{
+		Typed(http.MethodGet, "/customers/search", "Search customers by name, phone, email or KRA PIN", h.Search).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/customers", "Quick-create a customer or update their details", h.Save).Require(logins.RightMakeSales),
+	}
+}
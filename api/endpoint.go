@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+)
+
+// Endpoint is a typed API operation served by the router
+// Request and Response describe the payloads in the openapi document
+// Stream endpoints send Response values as server sent events
+// Rights lists the user rights allowed to call endpoints that aren't Public
+type Endpoint struct {
+	Method   string
+	Path     string
+	Summary  string
+	Public   bool
+	Stream   bool
+	Rights   []logins.Right
+	Request  reflect.Type
+	Response reflect.Type
+	Handler  http.HandlerFunc
+}
+
+// Validator is implemented by requests that check their own fields
+type Validator interface {
+	Validate() error
+}
+
+// ErrorResponse is the envelope returned with every failed request
+type ErrorResponse struct {
+	Response string      `json:"response"`
+	Code     apperr.Code `json:"code"`
+	Message  string      `json:"message"`
+	Trace    string      `json:"trace,omitempty"`
+}
+
+// Typed builds an endpoint from a handler taking a decoded request
+// GET requests are decoded from the url query and the rest from the json body
+func Typed[Req, Resp any](method, path, summary string, fn func(ctx context.Context, user logins.Users, req Req) (Resp, error)) Endpoint {
+	return Endpoint{
+		Method:   method,
+		Path:     path,
+		Summary:  summary,
+		Request:  reflect.TypeFor[Req](),
+		Response: reflect.TypeFor[Resp](),
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			user, err := requestUser(r)
+			if err != nil {
+				WriteError(w, err)
+				return
+			}
+
+			var req Req
+			if method == http.MethodGet {
+				err = decodeQuery(r, &req)
+			} else {
+				err = decodeBody(r, &req)
+			}
+			if err != nil {
+				WriteError(w, err)
+				return
+			}
+
+			if v, ok := any(&req).(Validator); ok {
+				if err := v.Validate(); err != nil {
+					WriteError(w, err)
+					return
+				}
+			}
+
+			resp, err := fn(r.Context(), user, req)
+			if err != nil {
+				WriteError(w, err)
+				return
+			}
+
+			WriteJSON(w, http.StatusOK, resp)
+		},
+	}
+}
+
+// WriteJSON writes v as the json response body
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	jStr, err := json.Marshal(v)
+	if err != nil {
+		log.Println("failed to marshal response    err =", err)
+		status = http.StatusInternalServerError
+		jStr = []byte(`{"response": "error", "code": "INTERNAL", "message": "failed to marshal response"}`)
+	}
+
+	EnableCors(&w)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jStr)
+}
+
+// WriteError writes err in the error envelope with its code's status
+func WriteError(w http.ResponseWriter, err error) {
+	e := apperr.From(err)
+	if e.Status() >= http.StatusInternalServerError {
+		log.Println("request failed    err =", err)
+	}
+
+	WriteJSON(w, e.Status(), ErrorResponse{
+		Response: "error",
+		Code:     e.Code,
+		Message:  e.Message,
+		Trace:    e.Trace(),
+	})
+}
+
+// requestUser reads the user details set by the jwt middleware
+func requestUser(r *http.Request) (logins.Users, error) {
+	user := logins.Users{}
+
+	userStr := r.Header.Get("user_details")
+	if userStr == "" {
+		return user, apperr.New(apperr.Unauthorized, "user details not found")
+	}
+
+	if err := json.Unmarshal([]byte(userStr), &user); err != nil {
+		return user, apperr.Wrap(apperr.Unauthorized, "invalid user details", err)
+	}
+	return user, nil
+}
+
+// decodeBody unmarshals the json body into v
+// an empty body leaves v as its zero value
+func decodeBody(r *http.Request, v any) error {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return apperr.Wrap(apperr.BadRequest, "failed to read request body", err)
+	}
+	if len(b) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return apperr.Wrap(apperr.BadRequest, "request body is not valid json", err)
+	}
+	return nil
+}
+
+// decodeQuery sets v's fields tagged `query` from the url query
+func decodeQuery(r *http.Request, v any) error {
+	rv := reflect.ValueOf(v).Elem()
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	q := r.URL.Query()
+	for i := 0; i < rv.NumField(); i++ {
+		name := rv.Type().Field(i).Tag.Get("query")
+		val := q.Get(name)
+		if name == "" || val == "" {
+			continue
+		}
+
+		if err := setField(rv.Field(i), val); err != nil {
+			return apperr.Wrap(apperr.ValidationFailed, fmt.Sprintf("invalid value for %v", name), err)
+		}
+	}
+	return nil
+}
+
+func setField(f reflect.Value, val string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(val)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	default:
+		return errors.New("unsupported query field type " + f.Kind().String())
+	}
+	return nil
+}
+
+// routeNotFound answers requests to unknown routes with the error envelope
+func routeNotFound(w http.ResponseWriter, r *http.Request) {
+	WriteError(w, apperr.New(apperr.NotFound, fmt.Sprintf("route %v %v not found", r.Method, r.URL.Path)))
+}
+
+// methodNotAllowed answers requests with an unsupported method
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		EnableCors(&w)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	WriteError(w, apperr.New(apperr.MethodNotAllowed, fmt.Sprintf("method %v not allowed on %v", r.Method, r.URL.Path)))
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/events"
+)
+
+// keepAlive is how often an idle event stream is pinged
+const keepAlive = 15 * time.Second
+
+// EventsRequest narrows the stream to a till or a receipt
+// events are always limited to the user's branch
+type EventsRequest struct {
+	TillNum    int64 `query:"till_num"`
+	ReceiptNum int64 `query:"receipt_num"`
+}
+
+// eventsEndpoint streams cart, bill and order changes as server sent events
+func eventsEndpoint(hub *events.Hub) Endpoint {
+	return Endpoint{
+		Method:   http.MethodGet,
+		Path:     "/events",
+		Summary:  "Stream cart, bill and order updates",
+		Stream:   true,
+		Request:  reflect.TypeFor[EventsRequest](),
+		Response: reflect.TypeFor[events.Event](),
+		Handler:  EventsGet(hub),
+	}
+}
+
+// EventsGet streams the hub's events within the user's scope
+func EventsGet(hub *events.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := requestUser(r)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		var req EventsRequest
+		if err := decodeQuery(r, &req); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			WriteError(w, apperr.New(apperr.Internal, "streaming is not supported"))
+			return
+		}
+
+		ch, cancel := hub.Subscribe(events.Scope{
+			Branch:     user.Branch,
+			TillNum:    req.TillNum,
+			ReceiptNum: req.ReceiptNum,
+		})
+		defer cancel()
+
+		EnableCors(&w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprint(w, ": connected\n\n")
+		flusher.Flush()
+
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+
+			case e := <-ch:
+				jStr, err := json.Marshal(e)
+				if err != nil {
+					log.Println("failed to marshal event    err =", err)
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, jStr)
+				flusher.Flush()
+
+			case <-ticker.C:
+				fmt.Fprint(w, ": ping\n\n")
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/internal/floor"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+)
+
+// floorEndpoints lists the restaurant floor and table endpoints
+func floorEndpoints(h *floor.Handler) []Endpoint {
+	return []Endpoint{
+		Typed(http.MethodGet, "/floor/plans", "List the branch's floors and tables", h.Plans).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/floor/plan", "Create or update a floor", h.SaveFloor).Require(logins.RightApproveSales),
+		Typed(http.MethodPost, "/floor/table", "Create or update a table", h.SaveTable).Require(logins.RightApproveSales),
+		Typed(http.MethodGet, "/floor/state", "Show each table's status and elapsed time", h.State).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/floor/table/open", "Seat guests and start a bill at a table", h.OpenTable).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/floor/table/transfer", "Hand a table to another waiter", h.Transfer).Require(logins.RightMakeSales),
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/idempotency"
+)
+
+// IdempotencyHeader is the request header carrying a client's idempotency key
+const IdempotencyHeader = "Idempotency-Key"
+
+// defaultIdempotencyTTL is used when IDEMPOTENCY_TTL is not set
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyLease is how long a key is held while its request runs
+// a request that dies without storing its response frees the key once the lease runs out
+const idempotencyLease = time.Minute
+
+// idempotencyTTL reads how long responses are kept from IDEMPOTENCY_TTL
+func idempotencyTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL"))
+	if err != nil || ttl <= 0 {
+		return defaultIdempotencyTTL
+	}
+	return ttl
+}
+
+// Idempotent replays the stored response when a request is retried with the same Idempotency-Key
+// keys are scoped to the user, claimed for a short lease and kept for ttl once the response is stored
+// server errors release the key so the request can be retried
+func Idempotent(store idempotency.Store, ttl time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > 255 {
+			WriteError(w, apperr.New(apperr.ValidationFailed, "idempotency key is too long"))
+			return
+		}
+
+		user, err := requestUser(r)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			WriteError(w, apperr.Wrap(apperr.BadRequest, "failed to read request body", err))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(b))
+
+		// the same key must be retried with the same request
+		h := sha256.New()
+		h.Write([]byte(r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery + "\n"))
+		h.Write(b)
+
+		rec := idempotency.Record{
+			Key:     "idempotency:" + user.Username + ":" + key,
+			ReqHash: hex.EncodeToString(h.Sum(nil)),
+		}
+		reqHash := rec.ReqHash
+
+		claimed, err := store.Reserve(r.Context(), &rec, min(idempotencyLease, ttl))
+		if err != nil {
+			WriteError(w, apperr.Wrap(apperr.Internal, "failed to reserve idempotency key", err))
+			return
+		}
+
+		if !claimed {
+			switch {
+			case rec.ReqHash != reqHash:
+				WriteError(w, apperr.New(apperr.IdempotencyKeyReuse, "idempotency key was used for a different request"))
+			case rec.Status == 0:
+				WriteError(w, apperr.New(apperr.RequestInProgress, "a request with this idempotency key is in progress"))
+			default:
+				EnableCors(&w)
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(rec.Status)
+				w.Write([]byte(rec.Body))
+			}
+			return
+		}
+
+		rw := &recorder{ResponseWriter: w, status: http.StatusOK}
+		next(rw, r)
+
+		// keep the claim only for responses that must not be repeated
+		if rw.status >= http.StatusInternalServerError {
+			if err := store.Release(r.Context(), rec.Key); err != nil {
+				log.Println("failed to release idempotency key    err =", err)
+			}
+			return
+		}
+
+		rec.Status = rw.status
+		rec.Body = rw.body.String()
+		if err := store.Save(r.Context(), rec, ttl); err != nil {
+			log.Println("failed to save idempotent response    err =", err)
+		}
+	}
+}
+
+// recorder captures the status and body written to a response
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recorder) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recorder) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
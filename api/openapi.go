@@ -0,0 +1,262 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+)
+
+// OpenAPI builds an openapi 3 document describing the endpoints
+func OpenAPI(endpoints []Endpoint) map[string]any {
+	g := schemaGen{schemas: map[string]any{}}
+	errRef := g.schema(reflect.TypeFor[ErrorResponse]())
+
+	paths := map[string]any{}
+	for _, ep := range endpoints {
+		success := map[string]any{
+			"description": "success",
+			"content":     jsonContent(g.schema(ep.Response)),
+		}
+		if ep.Stream {
+			success = map[string]any{
+				"description": "server sent events, each data line holds the schema",
+				"content":     map[string]any{"text/event-stream": map[string]any{"schema": g.schema(ep.Response)}},
+			}
+		}
+
+		op := map[string]any{
+			"summary":     ep.Summary,
+			"operationId": operationID(ep),
+			"responses": map[string]any{
+				"200": success,
+				"default": map[string]any{
+					"description": "error",
+					"content":     jsonContent(errRef),
+				},
+			},
+		}
+
+		if !ep.Public {
+			op["security"] = []any{map[string]any{"token": []string{}}}
+			op["x-required-rights"] = ep.Rights
+		}
+
+		if ep.Method == http.MethodGet {
+			if params := queryParams(ep.Request); len(params) > 0 {
+				op["parameters"] = params
+			}
+		} else {
+			op["parameters"] = []any{map[string]any{
+				"name":        IdempotencyHeader,
+				"in":          "header",
+				"description": "retries with the same key replay the first response",
+				"schema":      map[string]any{"type": "string", "maxLength": 255},
+			}}
+			if ep.Request != nil && ep.Request.Kind() == reflect.Struct && ep.Request.NumField() > 0 {
+				op["requestBody"] = map[string]any{
+					"required": true,
+					"content":  jsonContent(g.schema(ep.Request)),
+				}
+			}
+		}
+
+		item, ok := paths[ep.Path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[ep.Path] = item
+		}
+		item[strings.ToLower(ep.Method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "SpeedSales POS API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]any{
+				"token": map[string]any{"type": "apiKey", "in": "header", "name": "token"},
+			},
+		},
+	}
+}
+
+// OpenAPIGet serves the openapi document for the endpoints
+func OpenAPIGet(endpoints []Endpoint) http.HandlerFunc {
+	doc := OpenAPI(endpoints)
+	return func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, doc)
+	}
+}
+
+func operationID(ep Endpoint) string {
+	parts := strings.FieldsFunc(ep.Path, func(r rune) bool {
+		return r == '/' || r == '-' || r == '_' || r == '{' || r == '}'
+	})
+
+	id := strings.ToLower(ep.Method)
+	for _, p := range parts {
+		id += strings.ToUpper(p[:1]) + p[1:]
+	}
+	return id
+}
+
+func jsonContent(schema any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// queryParams lists the `query` tagged fields of a request type
+func queryParams(t reflect.Type) []any {
+	var params []any
+	if t == nil || t.Kind() != reflect.Struct {
+		return params
+	}
+
+	g := schemaGen{schemas: map[string]any{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("query")
+		if name == "" {
+			continue
+		}
+		params = append(params, map[string]any{
+			"name":     name,
+			"in":       "query",
+			"required": f.Tag.Get("validate") == "required",
+			"schema":   g.schema(f.Type),
+		})
+	}
+	return params
+}
+
+// schemaGen collects named struct schemas as components
+type schemaGen struct {
+	schemas map[string]any
+}
+
+var (
+	timeType  = reflect.TypeFor[time.Time]()
+	moneyType = reflect.TypeFor[money.Amount]()
+)
+
+func (g schemaGen) schema(t reflect.Type) any {
+	if t == nil {
+		return map[string]any{}
+	}
+
+	if t == moneyType {
+		// amounts are written as decimals with two places
+		return map[string]any{"type": "number", "format": "decimal"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		return g.structRef(t)
+	default:
+		return map[string]any{}
+	}
+}
+
+// structRef registers a struct's schema and returns a reference to it
+func (g schemaGen) structRef(t reflect.Type) any {
+	name := schemaName(t)
+	if name == "" {
+		return g.object(t)
+	}
+
+	ref := map[string]any{"$ref": "#/components/schemas/" + name}
+	if _, ok := g.schemas[name]; ok {
+		return ref
+	}
+
+	// placeholder guards against recursive types
+	g.schemas[name] = map[string]any{}
+	g.schemas[name] = g.object(t)
+	return ref
+}
+
+func (g schemaGen) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+
+	g.fields(t, props, &required)
+
+	obj := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
+
+// fields adds a struct's json fields, flattening embedded structs
+func (g schemaGen) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag := f.Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.fields(f.Type, props, required)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		props[name] = g.schema(f.Type)
+		if f.Tag.Get("validate") == "required" {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func schemaName(t reflect.Type) string {
+	if t.Name() == "" {
+		return ""
+	}
+
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	if pkg == "" {
+		return t.Name()
+	}
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + t.Name()
+}
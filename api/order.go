@@ -1,78 +1,27 @@
 package api
 
 import (
-	"encoding/json"
-	"log"
 	"net/http"
 
 	"github.com/JohnnyKahiu/speedsales/poserver/internal/order"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
 )
 
-func OrderSalesGet(w http.ResponseWriter, r *http.Request) {
-	respMap := order.Get(w, r)
-
-	jStr, err := json.Marshal(respMap)
-	if err != nil {
-		log.Println("failed to marshal cashSalesGet()  err =", err)
-	}
-
-	EnableCors(&w)
-	// write status code headers
-	if respMap["response"] == "forbidden" {
-		w.WriteHeader(http.StatusForbidden)
-	}
-	if respMap["response"] == "error" {
-		w.WriteHeader(http.StatusInternalServerError)
-	}
-	if respMap["response"] == "success" {
-		w.WriteHeader(http.StatusOK)
-	}
-
-	w.Write(jStr)
-}
-
-func OrderSalesPost(w http.ResponseWriter, r *http.Request) {
-	respMap := order.Post(w, r)
-
-	jStr, err := json.Marshal(respMap)
-	if err != nil {
-		log.Println("failed to marshal cashSalesGet()  err =", err)
-	}
-
-	EnableCors(&w)
-	// write status code headers
-	if respMap["response"] == "forbidden" {
-		w.WriteHeader(http.StatusForbidden)
+// orderEndpoints lists the sales order endpoints
+func orderEndpoints(h *order.Handler) []Endpoint {
+	return []Endpoint{
+		Typed(http.MethodGet, "/sales/order/orders_in_bill", "List the orders in a bill", h.OrdersInBill).Require(logins.RightMakeSales),
+		Typed(http.MethodGet, "/sales/order/cart", "Fetch the items in an order", h.Cart).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/sales/order/add-cart", "Add an item to the bill's open order", h.AddCart).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/sales/order/complete", "Send an order's first course to the kitchen", h.Complete).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/sales/order/fire", "Fire an order's held courses to the kitchen", h.Fire).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/sales/order/merge", "Merge bills into a bill", h.Merge).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/sales/order/split", "Split a bill into new receipts by item, seat or equal shares", h.Split).Require(logins.RightMakeSales),
+		Typed(http.MethodDelete, "/sales/order/order-item", "Delete a pending order item", h.DeleteItem).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/sales/order/delivery", "Book a bill for delivery to the customer's address", h.BookDelivery).Require(logins.RightMakeSales),
+		Typed(http.MethodGet, "/sales/order/deliveries", "List the deliveries on the road or with cash to settle", h.Deliveries).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/sales/order/delivery/rider", "Assign a rider to a delivery", h.AssignRider).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/sales/order/delivery/status", "Track a delivery as picked up, delivered or returned", h.DeliveryStatus).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/sales/order/delivery/settle", "Settle a rider's cash on delivery into the till", h.SettleDelivery).Require(logins.RightAcceptPayment),
 	}
-	if respMap["response"] == "error" {
-		w.WriteHeader(http.StatusInternalServerError)
-	}
-	if respMap["response"] == "success" {
-		w.WriteHeader(http.StatusOK)
-	}
-
-	w.Write(jStr)
-}
-
-func OrderSalesDel(w http.ResponseWriter, r *http.Request) {
-	respMap := order.Delete(w, r)
-
-	jStr, err := json.Marshal(respMap)
-	if err != nil {
-		log.Println("failed to marshal orderSales respon()  err =", err)
-	}
-
-	EnableCors(&w)
-	// write status code headers
-	if respMap["response"] == "forbidden" {
-		w.WriteHeader(http.StatusForbidden)
-	}
-	if respMap["response"] == "error" {
-		w.WriteHeader(http.StatusInternalServerError)
-	}
-	if respMap["response"] == "success" {
-		w.WriteHeader(http.StatusOK)
-	}
-
-	w.Write(jStr)
 }
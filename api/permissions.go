@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+)
+
+// Require declares the rights allowed to call the endpoint
+// a user needs at least one of them
+func (ep Endpoint) Require(rights ...logins.Right) Endpoint {
+	ep.Rights = append(append([]logins.Right{}, ep.Rights...), rights...)
+	return ep
+}
+
+// RequireRights answers 403 unless the user holds one of the rights
+func RequireRights(rights []logins.Right, next http.HandlerFunc) http.HandlerFunc {
+	names := make([]string, len(rights))
+	for i, r := range rights {
+		names[i] = string(r)
+	}
+	msg := fmt.Sprintf("forbidden, requires %v", strings.Join(names, " or "))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := requestUser(r)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		if !user.HasAnyRight(rights...) {
+			WriteError(w, apperr.New(apperr.Forbidden, msg))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ValidatePermissions checks every authenticated endpoint declares known rights
+func ValidatePermissions(endpoints []Endpoint) error {
+	var missing []string
+	for _, ep := range endpoints {
+		if ep.Public {
+			continue
+		}
+		if len(ep.Rights) == 0 {
+			missing = append(missing, ep.Method+" "+ep.Path)
+			continue
+		}
+		for _, r := range ep.Rights {
+			if !logins.ValidRight(r) {
+				return fmt.Errorf("route %v %v requires unknown right %q", ep.Method, ep.Path, r)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("routes without declared permissions: %v", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"reflect"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/internal/quotation"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
+)
+
+// quotationEndpoints lists the quotation endpoints
+func quotationEndpoints(h *quotation.Handler) []Endpoint {
+	return []Endpoint{
+		Typed(http.MethodPost, "/quotations", "Start a quotation for a customer", h.Create).Require(logins.RightMakeSales),
+		Typed(http.MethodGet, "/quotations", "Fetch a quotation with its lines", h.Fetch).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/quotations/items", "Price an item into a quotation", h.AddItem).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/quotations/items/delete", "Take a line off a quotation", h.DeleteItem).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/quotations/cancel", "Withdraw an open quotation", h.Cancel).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/quotations/convert", "Open a receipt on the till from a quotation", h.Convert).Require(logins.RightMakeSales),
+		Endpoint{
+			Method:   http.MethodGet,
+			Path:     "/quotations/print",
+			Summary:  "Print a quotation as an html document",
+			Request:  reflect.TypeFor[quotation.FetchRequest](),
+			Response: reflect.TypeFor[sales.Quotation](),
+			Handler:  QuotationPrint(h),
+		}.Require(logins.RightMakeSales),
+	}
+}
+
+// QuotationPrint writes the quotation as a printable html document under the company's heading
+func QuotationPrint(h *quotation.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := requestUser(r)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		var req quotation.FetchRequest
+		if err := decodeQuery(r, &req); err != nil {
+			WriteError(w, err)
+			return
+		}
+		if err := req.Validate(); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		resp, err := h.Fetch(r.Context(), user, req)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		// the document prints without a heading when the settings can't be read
+		settings, err := variables.FetchDefaults()
+		if err != nil {
+			log.Println("failed to fetch document heading    err =", err)
+		}
+
+		EnableCors(&w)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		if err := sales.WriteQuotation(w, resp.Quotation, settings.DocHead); err != nil {
+			log.Println("failed to write quotation    err =", err)
+		}
+	}
+}
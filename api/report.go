@@ -0,0 +1,62 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"reflect"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/internal/report"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+)
+
+// reportEndpoints lists the register endpoints
+func reportEndpoints(h *report.Handler) []Endpoint {
+	return []Endpoint{
+		Typed(http.MethodGet, "/reports/dispensing", "List the batches and prescriptions dispensed at the branch", h.Dispensing).Require(logins.RightApproveSales),
+		Typed(http.MethodGet, "/reports/age-restricted", "Sum each teller's age restricted sales and overrides", h.AgeRestricted).Require(logins.RightApproveSales),
+		Endpoint{
+			Method:   http.MethodGet,
+			Path:     "/reports/dispensing.csv",
+			Summary:  "Export the dispensing register as csv",
+			Request:  reflect.TypeFor[report.RegisterRequest](),
+			Response: reflect.TypeFor[[]sales.Dispensed](),
+			Handler:  DispensingCSV(h),
+		}.Require(logins.RightApproveSales),
+	}
+}
+
+// DispensingCSV writes the user's branch's dispensing register as a csv download
+func DispensingCSV(h *report.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := requestUser(r)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		var req report.RegisterRequest
+		if err := decodeQuery(r, &req); err != nil {
+			WriteError(w, err)
+			return
+		}
+		if err := req.Validate(); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		resp, err := h.Dispensing(r.Context(), user, req)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		EnableCors(&w)
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="dispensing-`+req.From+`-`+req.To+`.csv"`)
+		w.WriteHeader(http.StatusOK)
+		if err := sales.WriteRegister(w, resp.Register); err != nil {
+			log.Println("failed to write dispensing register    err =", err)
+		}
+	}
+}
@@ -5,47 +5,139 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
-
+	"reflect"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/internal/cash"
+	"github.com/JohnnyKahiu/speedsales/poserver/internal/catalog"
+	"github.com/JohnnyKahiu/speedsales/poserver/internal/customer"
+	"github.com/JohnnyKahiu/speedsales/poserver/internal/floor"
+	"github.com/JohnnyKahiu/speedsales/poserver/internal/order"
+	"github.com/JohnnyKahiu/speedsales/poserver/internal/quotation"
+	"github.com/JohnnyKahiu/speedsales/poserver/internal/report"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
 	"github.com/JohnnyKahiu/speedsales/poserver/pkg/authentication"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/events"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/grpc"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/idempotency"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
 	"github.com/gorilla/mux"
 )
 
-var mySigningKey = []byte(os.Getenv("SPEEDSALESJWTKEY"))
-
 func EnableCors(w *http.ResponseWriter) {
 	(*w).Header().Set("Access-Control-Allow-Origin", "*")
 	(*w).Header().Set("Access-Control-Allow-Origin", "*")
 	(*w).Header().Set("Access-Control-Allow-Headers", "*")
 }
 
-func NewRouter() *mux.Router {
+// NewRouter routes the endpoints behind jwt authentication and their declared rights
+// returns an error when an endpoint declares no permission
+func NewRouter(svc *sales.Service) (*mux.Router, error) {
 	fmt.Println("http routing")
 
+	endpoints := Endpoints(svc)
+	if err := ValidatePermissions(endpoints); err != nil {
+		return nil, err
+	}
+
 	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(routeNotFound)
+	r.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowed)
 
-	r.HandleFunc("/status", AppStatus).Methods("GET", "OPTIONS")
+	// public routes go ahead of the authenticated subrouter, which matches every path
+	for _, ep := range endpoints {
+		if ep.Public {
+			r.HandleFunc(ep.Path, ep.Handler).Methods(ep.Method)
+		}
+	}
 
 	// Subrouter for routes requiring authentication
 	api := r.PathPrefix("/").Subrouter()
+	api.NotFoundHandler = r.NotFoundHandler
+	api.MethodNotAllowedHandler = r.MethodNotAllowedHandler
 	api.Use(JwtMiddleware)
 
-	api.HandleFunc("/configs", ConfigsGet).Methods("GET", "OPTIONS")
-	api.HandleFunc("/sales/cash/{module}", CashSalesGet).Methods("GET", "OPTIONS")
-	api.HandleFunc("/sales/order/{module}", OrderSalesGet).Methods("GET", "OPTIONS")
+	// retried mutations replay their first response
+	store := idempotency.NewStore()
+	ttl := idempotencyTTL()
 
-	api.HandleFunc("/sales/cash/{module}", CashSalesPost).Methods("POST", "OPTIONS")
-	api.HandleFunc("/sales/order/{module}", OrderSalesPost).Methods("POST", "OPTIONS")
+	for _, ep := range endpoints {
+		if ep.Public {
+			continue
+		}
+		handler := ep.Handler
+		if ep.Method != http.MethodGet {
+			handler = Idempotent(store, ttl, handler)
+		}
+		api.HandleFunc(ep.Path, RequireRights(ep.Rights, handler)).Methods(ep.Method)
+	}
+
+	return r, nil
+}
 
-	api.HandleFunc("/sales/order/{module}", OrderSalesDel).Methods("DELETE", "OPTIONS")
+// Endpoints lists the typed endpoints served by the router
+func Endpoints(svc *sales.Service) []Endpoint {
+	if svc.Events == nil {
+		svc.Events = events.NewHub()
+	}
+
+	status := StatusGet(grpc.Default())
+	endpoints := []Endpoint{
+		{Method: http.MethodGet, Path: "/status", Summary: "Report whether the server and its grpc services are up", Public: true, Response: reflect.TypeFor[StatusResponse](), Handler: status},
+		{Method: http.MethodOptions, Path: "/status", Summary: "Allow cross origin status checks", Public: true, Handler: status},
+		Typed("GET", "/configs", "Fetch the system settings and the branch's active features", ConfigsGet).Require(logins.RightAuthenticated),
+		Typed("POST", "/branch/profile", "Select the industry profile a branch runs in", ProfileSet).Require(logins.RightPosSettings),
+		eventsEndpoint(svc.Events).Require(logins.RightAuthenticated),
+	}
+	endpoints = append(endpoints, cashEndpoints(cash.NewHandler(svc))...)
+	endpoints = append(endpoints, orderEndpoints(order.NewHandler(svc))...)
+	endpoints = append(endpoints, catalogEndpoints(catalog.NewHandler(svc))...)
+	endpoints = append(endpoints, floorEndpoints(floor.NewHandler(svc))...)
+	endpoints = append(endpoints, reportEndpoints(report.NewHandler(svc))...)
+	endpoints = append(endpoints, customerEndpoints(customer.NewHandler(svc))...)
+	endpoints = append(endpoints, quotationEndpoints(quotation.NewHandler(svc))...)
+
+	// the document describes the endpoints listed before it
+	return append(endpoints, Endpoint{
+		Method:   http.MethodGet,
+		Path:     "/openapi.json",
+		Summary:  "Describe the api as an openapi 3 document",
+		Public:   true,
+		Response: reflect.TypeFor[map[string]any](),
+		Handler:  OpenAPIGet(endpoints),
+	})
+}
 
-	return r
+// StatusResponse reports the server and the health of its grpc services
+type StatusResponse struct {
+	Response string                 `json:"response"`
+	Status   string                 `json:"status"`
+	Services map[string]grpc.Health `json:"services"`
 }
 
-func AppStatus(w http.ResponseWriter, r *http.Request) {
-	EnableCors(&w)
-	w.WriteHeader(200)
-	w.Write([]byte(`Server is running`))
+// StatusGet reports whether the server and the services it calls are up
+// the status is degraded while any service is unavailable
+func StatusGet(reg *grpc.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			EnableCors(&w)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		resp := StatusResponse{
+			Response: "success",
+			Status:   "running",
+			Services: reg.Health(r.Context()),
+		}
+		for _, h := range resp.Services {
+			if h.Breaker == grpc.BreakerOpen || h.Serving == "NOT_SERVING" {
+				resp.Status = "degraded"
+			}
+		}
+
+		WriteJSON(w, http.StatusOK, resp)
+	}
 }
 
 func JwtMiddleware(next http.Handler) http.Handler {
@@ -56,16 +148,14 @@ func JwtMiddleware(next http.Handler) http.Handler {
 		if tokenString == "" {
 			log.Println("\n\t token string not provided")
 
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte(`{"response": "error", "message": "unauthorized"}`))
+			WriteError(w, apperr.New(apperr.Unauthorized, "unauthorized"))
 			return
 		}
 
 		user, authentic := authentication.ValidateJWT(tokenString)
 		if !authentic {
 			log.Println("\n\t token string is not valid")
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte(`{"response": "error", "message": "unauthorized"}`))
+			WriteError(w, apperr.New(apperr.Unauthorized, "unauthorized"))
 			return
 		}
 
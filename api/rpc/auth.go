@@ -0,0 +1,156 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/authentication"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TokenKey is the metadata key carrying the jwt, as the http token header
+const TokenKey = "token"
+
+// Authenticator resolves the user a token was issued to
+type Authenticator func(ctx context.Context, token string) (logins.Users, error)
+
+// TokenAuthenticator verifies tokens the same way as the http jwt middleware
+func TokenAuthenticator(ctx context.Context, token string) (logins.Users, error) {
+	user := logins.Users{}
+
+	usr, authentic := authentication.ValidateJWT(token)
+	if !authentic {
+		return user, apperr.New(apperr.Unauthorized, "unauthorized")
+	}
+
+	jStr, _ := json.Marshal(usr)
+	if err := json.Unmarshal(jStr, &user); err != nil {
+		return user, apperr.Wrap(apperr.Unauthorized, "invalid user details", err)
+	}
+	return user, nil
+}
+
+type userKey struct{}
+
+// userFrom returns the user set by the auth interceptors
+func userFrom(ctx context.Context) (logins.Users, error) {
+	user, ok := ctx.Value(userKey{}).(logins.Users)
+	if !ok {
+		return user, apperr.New(apperr.Unauthorized, "user details not found")
+	}
+	return user, nil
+}
+
+// authorize authenticates the caller and checks the method's declared rights
+func authorize(ctx context.Context, auth Authenticator, method string) (context.Context, error) {
+	rights, ok := methodRights[method]
+	if !ok {
+		return ctx, apperr.New(apperr.Forbidden, fmt.Sprintf("method %v declares no permission", method))
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	tokens := md.Get(TokenKey)
+	if len(tokens) == 0 || tokens[0] == "" {
+		return ctx, apperr.New(apperr.Unauthorized, "unauthorized")
+	}
+
+	user, err := auth(ctx, tokens[0])
+	if err != nil {
+		return ctx, err
+	}
+
+	if !user.HasAnyRight(rights...) {
+		names := make([]string, len(rights))
+		for i, r := range rights {
+			names[i] = string(r)
+		}
+		return ctx, apperr.New(apperr.Forbidden, fmt.Sprintf("forbidden, requires %v", strings.Join(names, " or ")))
+	}
+
+	return context.WithValue(ctx, userKey{}, user), nil
+}
+
+// UnaryInterceptor authorizes unary calls and maps their errors to grpc statuses
+func UnaryInterceptor(auth Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authorize(ctx, auth, info.FullMethod)
+		if err != nil {
+			return nil, Status(err)
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, Status(err)
+		}
+		return resp, nil
+	}
+}
+
+// StreamInterceptor authorizes streaming calls and maps their errors to grpc statuses
+func StreamInterceptor(auth Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authorize(ss.Context(), auth, info.FullMethod)
+		if err != nil {
+			return Status(err)
+		}
+
+		if err := handler(srv, &userStream{ServerStream: ss, ctx: ctx}); err != nil {
+			return Status(err)
+		}
+		return nil
+	}
+}
+
+// userStream carries the authorized user in the stream's context
+type userStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *userStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcCodes maps each http status of the error codes to a grpc code
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.FailedPrecondition,
+	http.StatusUnprocessableEntity: codes.InvalidArgument,
+	http.StatusBadGateway:          codes.Unavailable,
+}
+
+// Status converts err to a grpc status error
+// the message is prefixed with the error code returned by the http api
+func Status(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var e *apperr.Error
+	if !errors.As(err, &e) {
+		log.Println("rpc request failed    err =", err)
+	}
+	e = apperr.From(err)
+
+	code, ok := grpcCodes[e.Status()]
+	if !ok {
+		code = codes.Internal
+	}
+	if e.Code == apperr.RequestInProgress {
+		code = codes.Aborted
+	}
+	return status.Error(code, fmt.Sprintf("%v: %v", e.Code, e.Message))
+}
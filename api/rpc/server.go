@@ -0,0 +1,267 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/internal/cash"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/events"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	pb "github.com/JohnnyKahiu/speedsales/poserver/proto/pos"
+	"google.golang.org/grpc"
+)
+
+// methodRights declares the rights allowed to call each pos method
+// a user needs at least one of them, as on the http routes
+var methodRights = map[string][]logins.Right{
+	pb.PosService_OpenTill_FullMethodName:         {logins.RightMakeSales, logins.RightAcceptPayment},
+	pb.PosService_CreateReceipt_FullMethodName:    {logins.RightMakeSales},
+	pb.PosService_AddLine_FullMethodName:          {logins.RightMakeSales},
+	pb.PosService_ApplyPayment_FullMethodName:     {logins.RightAcceptPayment},
+	pb.PosService_PostReceipt_FullMethodName:      {logins.RightAcceptPayment},
+	pb.PosService_FetchReceipt_FullMethodName:     {logins.RightMakeSales, logins.RightAcceptPayment},
+	pb.PosService_StreamTillEvents_FullMethodName: {logins.RightAuthenticated},
+}
+
+// ValidatePermissions checks every pos method declares known rights
+func ValidatePermissions() error {
+	methods := make([]string, 0, len(methodRights))
+	for m := range methodRights {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+
+	for _, m := range methods {
+		if len(methodRights[m]) == 0 {
+			return fmt.Errorf("method %v declares no permission", m)
+		}
+		for _, r := range methodRights[m] {
+			if !logins.ValidRight(r) {
+				return fmt.Errorf("method %v requires unknown right %q", m, r)
+			}
+		}
+	}
+	return nil
+}
+
+// Server serves the pos operations over grpc on the cash sale handlers
+type Server struct {
+	pb.UnimplementedPosServiceServer
+
+	Cash   *cash.Handler
+	Events *events.Hub
+}
+
+// NewServer creates the pos service on the sales service
+func NewServer(svc *sales.Service) *Server {
+	if svc.Events == nil {
+		svc.Events = events.NewHub()
+	}
+	return &Server{Cash: cash.NewHandler(svc), Events: svc.Events}
+}
+
+// NewGRPCServer registers the pos service behind token authentication and the declared rights
+// returns an error when a method declares no permission
+func NewGRPCServer(svc *sales.Service, auth Authenticator, opts ...grpc.ServerOption) (*grpc.Server, error) {
+	if err := ValidatePermissions(); err != nil {
+		return nil, err
+	}
+
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(UnaryInterceptor(auth)),
+		grpc.ChainStreamInterceptor(StreamInterceptor(auth)),
+	)
+
+	s := grpc.NewServer(opts...)
+	pb.RegisterPosServiceServer(s, NewServer(svc))
+	return s, nil
+}
+
+// OpenTill opens a till for the caller
+func (s *Server) OpenTill(ctx context.Context, in *pb.OpenTillRequest) (*pb.OpenTillResponse, error) {
+	user, err := userFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := cash.OpenTillRequest{Approver: in.Approver, ApToken: in.ApToken}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Cash.OpenTill(ctx, user, req)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.OpenTillResponse{TillNum: resp.TillNum}, nil
+}
+
+// CreateReceipt returns the till's pending receipt or starts a new one
+func (s *Server) CreateReceipt(ctx context.Context, in *pb.CreateReceiptRequest) (*pb.Receipt, error) {
+	user, err := userFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Cash.NewReceipt(ctx, user, cash.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	return s.fetch(ctx, user, resp.ReceiptNum)
+}
+
+// AddLine adds an item to the receipt
+func (s *Server) AddLine(ctx context.Context, in *pb.AddLineRequest) (*pb.AddLineResponse, error) {
+	user, err := userFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := cash.AddCartRequest{ReceiptNum: in.ReceiptNum, ItemCode: in.ItemCode, Quantity: in.Quantity}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Cash.AddCart(ctx, user, req)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AddLineResponse{Line: toLine(resp.Cart)}, nil
+}
+
+// ApplyPayment tenders a payment against the receipt
+func (s *Server) ApplyPayment(ctx context.Context, in *pb.ApplyPaymentRequest) (*pb.ApplyPaymentResponse, error) {
+	user, err := userFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := cash.PayRequest{ReceiptNum: in.ReceiptNum, Paymode: in.Paymode, Amount: money.New(in.Amount), Reference: in.Reference}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Cash.Pay(ctx, user, req)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ApplyPaymentResponse{ReceiptNum: resp.ReceiptNum, Balance: resp.Balance.Float()}, nil
+}
+
+// PostReceipt completes a fully paid receipt
+func (s *Server) PostReceipt(ctx context.Context, in *pb.PostReceiptRequest) (*pb.Receipt, error) {
+	user, err := userFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := cash.PostRequest{ReceiptNum: in.ReceiptNum}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Cash.Post(ctx, user, req)
+	if err != nil {
+		return nil, err
+	}
+	return toReceipt(resp.Receipt), nil
+}
+
+// FetchReceipt fetches a receipt in the caller's branch
+func (s *Server) FetchReceipt(ctx context.Context, in *pb.FetchReceiptRequest) (*pb.Receipt, error) {
+	user, err := userFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.fetch(ctx, user, in.ReceiptNum)
+}
+
+// StreamTillEvents streams cart, bill and order updates within the caller's branch
+func (s *Server) StreamTillEvents(in *pb.StreamTillEventsRequest, stream pb.PosService_StreamTillEventsServer) error {
+	ctx := stream.Context()
+	user, err := userFrom(ctx)
+	if err != nil {
+		return err
+	}
+
+	ch, cancel := s.Events.Subscribe(events.Scope{
+		Branch:     user.Branch,
+		TillNum:    in.TillNum,
+		ReceiptNum: in.ReceiptNum,
+	})
+	defer cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case e := <-ch:
+			data, err := json.Marshal(e.Data)
+			if err != nil {
+				log.Println("failed to marshal event    err =", err)
+				continue
+			}
+
+			err = stream.Send(&pb.TillEvent{
+				Type:       e.Type,
+				Branch:     e.Branch,
+				TillNum:    e.TillNum,
+				ReceiptNum: e.ReceiptNum,
+				OrderNum:   e.OrderNum,
+				State:      e.State,
+				Data:       string(data),
+				Time:       e.Time.Unix(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) fetch(ctx context.Context, user logins.Users, receiptNum int64) (*pb.Receipt, error) {
+	req := cash.ReceiptRequest{ReceiptNum: receiptNum}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Cash.Receipt(ctx, user, req)
+	if err != nil {
+		return nil, err
+	}
+	return toReceipt(resp.Receipt), nil
+}
+
+func toReceipt(r sales.ReceiptLog) *pb.Receipt {
+	rcpt := &pb.Receipt{
+		ReceiptNum: r.ReceiptNum,
+		TillNum:    r.TillNum,
+		Branch:     r.Branch,
+		Poster:     r.Poster,
+		State:      r.State,
+		Total:      r.Total.Float(),
+		Tendered:   r.Cash.Float(),
+		Change:     r.Change.Float(),
+	}
+	for _, item := range r.Cart {
+		rcpt.Lines = append(rcpt.Lines, toLine(item))
+	}
+	return rcpt
+}
+
+func toLine(item sales.Sales) *pb.Line {
+	return &pb.Line{
+		ItemCode:    item.ItemCode,
+		ItemName:    item.ItemName,
+		Quantity:    item.Quantity,
+		Price:       item.Price.Float(),
+		Total:       item.Total.Float(),
+		ReceiptItem: item.ReceiptItem,
+	}
+}
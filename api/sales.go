@@ -1,59 +1,34 @@
 package api
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
 	"net/http"
 
 	"github.com/JohnnyKahiu/speedsales/poserver/internal/cash"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
 )
 
-func CashSalesGet(w http.ResponseWriter, r *http.Request) {
-	respMap := cash.Get(w, r)
-
-	jStr, err := json.Marshal(respMap)
-	if err != nil {
-		log.Println("failed to marshal cashSalesGet()  err =", err)
-	}
-
-	EnableCors(&w)
-	// write status code headers
-	if respMap["response"] == "forbidden" {
-		w.WriteHeader(http.StatusForbidden)
-	}
-	if respMap["response"] == "error" {
-		w.WriteHeader(http.StatusInternalServerError)
-	}
-	if respMap["response"] == "success" {
-		w.WriteHeader(http.StatusOK)
-	}
-
-	w.Write(jStr)
-}
-
-func CashSalesPost(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("cash sales post")
-	respMap := cash.Post(w, r)
-
-	jStr, err := json.Marshal(respMap)
-	if err != nil {
-		log.Println("failed to marshal cashSalesPost()  err =", err)
-	}
-
-	EnableCors(&w)
-
-	// write status code headers
-	if respMap["response"] == "forbidden" {
-		w.WriteHeader(http.StatusForbidden)
-	}
-	if respMap["response"] == "error" {
-		w.WriteHeader(http.StatusInternalServerError)
+// cashEndpoints lists the cash sale endpoints
+func cashEndpoints(h *cash.Handler) []Endpoint {
+	return []Endpoint{
+		Typed(http.MethodGet, "/sales/cash/cart", "Fetch the till's cart", h.Cart).Require(logins.RightMakeSales),
+		Typed(http.MethodGet, "/sales/cash/active-carts", "List the till's open receipts", h.ActiveCarts).Require(logins.RightMakeSales, logins.RightAcceptPayment),
+		Typed(http.MethodPost, "/sales/cash/open-till", "Open a till for the user", h.OpenTill).Require(logins.RightMakeSales, logins.RightAcceptPayment),
+		Typed(http.MethodPost, "/sales/cash/new_receipt", "Start a new receipt", h.NewReceipt).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/sales/cash/new_bill", "Suspend open bills and start a new one", h.NewBill).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/sales/cash/suspend", "Suspend the pending receipt", h.Suspend).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/sales/cash/add-cart", "Add an item to the receipt", h.AddCart).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/sales/cash/close_bill", "Close a bill for payment", h.CloseBill).Require(logins.RightMakeSales),
+		Typed(http.MethodGet, "/sales/cash/receipt", "Fetch a receipt", h.Receipt).Require(logins.RightMakeSales, logins.RightAcceptPayment),
+		Typed(http.MethodPost, "/sales/cash/pay", "Apply a payment to a receipt", h.Pay).Require(logins.RightAcceptPayment),
+		Typed(http.MethodPost, "/sales/cash/post", "Post a fully paid receipt", h.Post).Require(logins.RightAcceptPayment),
+		Typed(http.MethodPost, "/sales/cash/void", "Void an open receipt", h.Void).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/sales/cash/price-override", "Change the price of a line", h.OverridePrice).Require(logins.RightPriceChange),
+		Typed(http.MethodPost, "/sales/cash/line-discount", "Discount a line", h.DiscountLine).Require(logins.RightPriceChange),
+		Typed(http.MethodPost, "/sales/cash/receipt-discount", "Discount the whole receipt", h.DiscountReceipt).Require(logins.RightPriceChange),
+		Typed(http.MethodGet, "/sales/serials/lookup", "Find the receipt a serial or IMEI was sold on", h.LookupSerial).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/sales/returns/check-serials", "Check returned serials against the original receipt", h.CheckReturn).Require(logins.RightSalesReturns),
+		Typed(http.MethodPost, "/sales/returns", "Return lines of a posted receipt and refund them from the till", h.ReturnSale).Require(logins.RightSalesReturns),
+		Typed(http.MethodPost, "/sales/cash/customer", "Attach a customer to the receipt", h.AttachCustomer).Require(logins.RightMakeSales),
+		Typed(http.MethodPost, "/sales/cash/vat-exempt", "Exempt the receipt's customer from vat", h.VatExempt).Require(logins.RightMakeSales, logins.RightAcceptPayment),
 	}
-	if respMap["response"] == "success" {
-		w.WriteHeader(http.StatusOK)
-	}
-
-	// return response text
-	w.Write(jStr)
 }
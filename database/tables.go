@@ -79,5 +79,30 @@ func CreateFromStruct(tblStruct any) error {
 
 	}
 
+	// Convert float columns now declared NUMERIC, rounding old values to the column's scale
+	for i := 0; i < val.Type().NumField(); i++ {
+		if val.Type().Field(i).Tag.Get("type") != "field" {
+			continue
+		}
+		fieldName := val.Type().Field(i).Tag.Get("json") + ""
+		sqlDef := val.Type().Field(i).Tag.Get("sql") + ""
+		if fieldName == "" || !strings.HasPrefix(sqlDef, "NUMERIC") {
+			continue
+		}
+
+		colType := strings.Fields(sqlDef)[0]
+		sqlAlter := fmt.Sprintf(`DO $$
+			BEGIN
+				IF EXISTS (SELECT 1 FROM information_schema.columns
+						WHERE table_name = '%v' AND column_name = '%v' AND data_type IN ('double precision', 'real')) THEN
+					ALTER TABLE %v ALTER COLUMN %v TYPE %v USING round(%v::numeric, 2);
+				END IF;
+			END $$`, tblName, fieldName, tblName, fieldName, colType, fieldName)
+		_, err := PgPool.Exec(context.Background(), sqlAlter)
+		if err != nil {
+			fmt.Printf("\nerror converting %v.%v to %v\n \t%v", tblName, fieldName, colType, err.Error())
+		}
+	}
+
 	return nil
 }
@@ -2,483 +2,416 @@ package cash
 
 import (
 	"context"
-	"encoding/json"
-
 	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"strconv"
 	"time"
 
-	"github.com/JohnnyKahiu/speedsales/poserver/database"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
 	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
 	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
-	"github.com/gorilla/mux"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
 )
 
-func Get(w http.ResponseWriter, r *http.Request) map[string]interface{} {
-	respMap := make(map[string]interface{})
-
-	userStr := r.Header.Get("user_details")
-	if userStr == "" {
-		respMap["response"] = "error"
-		respMap["message"] = "user details not found"
-		return respMap
+// Handler serves cash sale requests
+type Handler struct {
+	Sales *sales.Service
+}
+
+// NewHandler creates a cash sales handler on the given sales service
+func NewHandler(svc *sales.Service) *Handler {
+	return &Handler{Sales: svc}
+}
+
+// Empty is a request without parameters
+type Empty struct{}
+
+// CartRequest selects the receipt whose cart to fetch
+// the till's pending receipt is used when none is given
+type CartRequest struct {
+	Receipt int64 `query:"receipt"`
+}
+
+type CartResponse struct {
+	Response string                `json:"response"`
+	Receipt  string                `json:"receipt"`
+	Values   []sales.Sales         `json:"values"`
+	Total    money.Amount          `json:"total"`
+	Rollup   bool                  `json:"rollup"`
+	Stage    string                `json:"stage"`
+	Settings variables.PosSettings `json:"settings"`
+}
+
+// Cart fetches the cart for the till's receipt
+func (h *Handler) Cart(ctx context.Context, user logins.Users, req CartRequest) (CartResponse, error) {
+	fmt.Println("\t  == timing get cart request ==")
+	start := time.Now()
+
+	a := sales.ReceiptLog{
+		ReceiptNum: req.Receipt,
+		Poster:     user.Username,
+		Branch:     user.Branch,
+		CompanyID:  user.CompanyID,
+		TillNum:    user.TillNum,
+		SaleType:   "Cash Sale",
 	}
 
-	details := logins.Users{}
-	json.Unmarshal([]byte(userStr), &details)
+	reqRollup, poSett, err := h.Sales.Cart(ctx, &a)
+	if err != nil {
+		fmt.Println("error\t", err)
+		return CartResponse{}, err
+	}
+
+	fmt.Printf("\nget cart for user %v \t time elapsed = %v\n", user.Username, time.Since(start))
+	return CartResponse{
+		Response: "success",
+		Receipt:  fmt.Sprintf("%v", a.ReceiptNum),
+		Values:   a.Cart,
+		Total:    a.Total,
+		Rollup:   reqRollup,
+		Stage:    a.State,
+		Settings: poSett,
+	}, nil
+}
+
+type ActiveCartsResponse struct {
+	Response string             `json:"response"`
+	TillNum  int64              `json:"till_num"`
+	Values   []sales.ReceiptLog `json:"values"`
+}
 
-	vars := mux.Vars(r)
-	m := vars["module"]
+// ActiveCarts lists the till's open receipts
+func (h *Handler) ActiveCarts(ctx context.Context, user logins.Users, req Empty) (ActiveCartsResponse, error) {
+	if user.TillNum == 0 {
+		return ActiveCartsResponse{}, sales.ErrTillNotOpen
+	}
 
-	switch m {
+	receipts, err := h.Sales.Receipts.ActiveCarts(ctx, user.TillNum)
+	if err != nil {
+		return ActiveCartsResponse{}, err
+	}
 
-	case "cart":
-		fmt.Println("\t  == timing get cart request ==")
-		start := time.Now()
+	return ActiveCartsResponse{Response: "success", TillNum: user.TillNum, Values: receipts}, nil
+}
 
-		if !details.MakeSales {
-			respMap["response"] = "forbidden"
-			respMap["message"] = "forbidden"
-			return respMap
-		}
+// OpenTillRequest holds the supervisor approving the till
+type OpenTillRequest struct {
+	Approver string `json:"approver" validate:"required"`
+	ApToken  string `json:"ap_token"`
+}
 
-		fmt.Println("till num =", details.TillNum)
+func (r *OpenTillRequest) Validate() error {
+	if r.Approver == "" {
+		return apperr.New(apperr.ValidationFailed, "approver is required")
+	}
+	return nil
+}
 
-		rcpt := r.URL.Query().Get("receipt")
-		var receiptNum int64
-		var err error
+type OpenTillResponse struct {
+	Response string `json:"response"`
+	TillNum  int64  `json:"till_num"`
+}
 
-		var a sales.ReceiptLog
-		if rcpt == "" {
-			a.Poster = details.Username
-			a.Branch = details.Branch
-			a.CompanyID = details.CompanyID
-			a.TillNum = details.TillNum
-			a.SaleType = "Cash Sale"
+// OpenTill opens a till for the user
+func (h *Handler) OpenTill(ctx context.Context, user logins.Users, req OpenTillRequest) (OpenTillResponse, error) {
+	fmt.Printf("\n\t Open till \n\t make_sales = %v \n\t accept_payments = %v \n\t, username = %v \n ", user.MakeSales, user.AcceptPayment, user.Username)
 
-			a.GenReceipt()
-		} else {
-			a.ReceiptNum, _ = strconv.ParseInt(rcpt, 10, 64)
-		}
-		fmt.Println("receipt num =", a.ReceiptNum)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
 
-		err = a.Fetch()
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "failed fetching sales cart"
-			respMap["trace"] = err
-			fmt.Println("error\t", err)
+	till, err := h.Sales.OpenTill(ctx, user, req.Approver, req.ApToken)
+	if err != nil {
+		return OpenTillResponse{}, err
+	}
 
-			return respMap
-		}
+	return OpenTillResponse{Response: "success", TillNum: till.TillNO}, nil
+}
 
-		reqRollup := false
+type NewReceiptResponse struct {
+	Response   string             `json:"response"`
+	Carts      []sales.ReceiptLog `json:"carts"`
+	ReceiptNum int64              `json:"receipt_num"`
+}
 
-		// fetch system defaults
-		poSett, _ := sales.FetchSettings()
-		if a.Total <= 0 {
-			// fetch current cash in till
-			cashInTill, _ := sales.CashInTill(details.TillNum)
+// NewReceipt starts a receipt and lists the till's active carts
+func (h *Handler) NewReceipt(ctx context.Context, user logins.Users, req Empty) (NewReceiptResponse, error) {
+	fmt.Println("\t new bill")
+	receipt := sales.ReceiptLog{
+		TillNum:   user.TillNum,
+		Poster:    user.Username,
+		SaleType:  "Cash Sale",
+		Branch:    user.Branch,
+		CompanyID: user.CompanyID,
+		AcNum:     "0",
+	}
 
-			if poSett.Rollup <= cashInTill {
-				reqRollup = true
-			}
-		}
+	receipts, err := h.Sales.NewReceipt(ctx, &receipt)
+	if err != nil {
+		return NewReceiptResponse{}, err
+	}
 
-		respMap["response"] = "success"
-		respMap["receipt"] = fmt.Sprintf("%v", receiptNum)
-		respMap["values"] = a.Cart
-		respMap["total"] = a.Total
-		respMap["rollup"] = reqRollup
-		respMap["stage"] = a.State
-		respMap["settings"] = poSett
+	return NewReceiptResponse{Response: "success", Carts: receipts, ReceiptNum: receipt.ReceiptNum}, nil
+}
 
-		elapsed := time.Since(start)
-		fmt.Printf("\nget cart for user %v \t time elapsed = %v\n", details.Username, elapsed)
-		return respMap
+type NewBillResponse struct {
+	Response   string             `json:"response"`
+	Bills      []sales.ReceiptLog `json:"bills"`
+	ReceiptNum int64              `json:"receipt_num"`
+}
 
-	case "active-carts":
-		fmt.Println("\t fetching all active carts")
-		rcpt := sales.ReceiptLog{TillNum: details.TillNum}
+// NewBill suspends the till's open bills and starts a new one
+func (h *Handler) NewBill(ctx context.Context, user logins.Users, req Empty) (NewBillResponse, error) {
+	receipt := sales.ReceiptLog{
+		TillNum:  user.TillNum,
+		Branch:   user.Branch,
+		Poster:   user.Username,
+		SaleType: "Cash Sale",
+	}
 
-		receipts, err := rcpt.GetActiveCarts()
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "error getting active carts"
-			respMap["trace"] = err
+	receipts, err := h.Sales.NewBill(ctx, &receipt)
+	if err != nil {
+		return NewBillResponse{}, err
+	}
 
-			return respMap
-		}
+	return NewBillResponse{Response: "success", Bills: receipts, ReceiptNum: receipt.ReceiptNum}, nil
+}
 
-		respMap["response"] = "success"
-		respMap["till_num"] = details.TillNum
-		respMap["values"] = receipts
+type SuspendResponse struct {
+	Response   string `json:"response"`
+	ReceiptNum int64  `json:"receipt_num"`
+}
 
-		return respMap
+// Suspend suspends the till's pending receipt
+func (h *Handler) Suspend(ctx context.Context, user logins.Users, req Empty) (SuspendResponse, error) {
+	rcpt := sales.ReceiptLog{
+		TillNum:   user.TillNum,
+		Poster:    user.Username,
+		Branch:    user.Branch,
+		CompanyID: user.CompanyID,
+		SaleType:  "Cash Sale",
+	}
 
-	default:
-		return respMap
+	if err := h.Sales.Suspend(ctx, &rcpt); err != nil {
+		return SuspendResponse{}, err
 	}
 
+	return SuspendResponse{Response: "success", ReceiptNum: rcpt.ReceiptNum}, nil
+}
+
+// AddCartRequest holds an item to add to the receipt
+// the till's pending receipt is used when none is given
+type AddCartRequest struct {
+	ReceiptNum int64   `json:"receipt_num"`
+	ItemCode   string  `json:"item_code" validate:"required"`
+	Quantity   float64 `json:"quantity" validate:"required"`
+	// Approver and ApToken allow selling beyond the stock balance
+	Approver string `json:"approver"`
+	ApToken  string `json:"ap_token"`
+	// Batch, Expiry and Prescription are captured for pharmacy items
+	Batch        string              `json:"batch"`
+	Expiry       *time.Time          `json:"expiry"`
+	Prescription *sales.Prescription `json:"prescription"`
+	// Serials are required for each unit of a serialized product
+	Serials []string `json:"serials"`
+	// BirthDate confirms the customer's age for age restricted items, AgeApprover and AgeApToken override the check
+	BirthDate   *time.Time `json:"birth_date"`
+	AgeApprover string     `json:"age_approver"`
+	AgeApToken  string     `json:"age_ap_token"`
+}
+
+func (r *AddCartRequest) Validate() error {
+	if r.ItemCode == "" {
+		return apperr.New(apperr.ValidationFailed, "item_code is required")
+	}
+	if r.Quantity <= 0 {
+		return apperr.New(apperr.ValidationFailed, "quantity must be greater than zero")
+	}
+	return nil
 }
 
-func Post(w http.ResponseWriter, r *http.Request) map[string]interface{} {
+type AddCartResponse struct {
+	Response string      `json:"response"`
+	Cart     sales.Sales `json:"cart"`
+}
 
-	respMap := make(map[string]interface{})
+// AddCart adds an item to the user's receipt
+func (h *Handler) AddCart(ctx context.Context, user logins.Users, req AddCartRequest) (AddCartResponse, error) {
+	cart := sales.Sales{
+		ReceiptNum:    req.ReceiptNum,
+		ItemCode:      req.ItemCode,
+		Quantity:      req.Quantity,
+		StockApprover: req.Approver,
+		ApToken:       req.ApToken,
+		Batch:         req.Batch,
+		Expiry:        req.Expiry,
+		Prescription:  req.Prescription,
+		Serials:       req.Serials,
+	}
+	if req.BirthDate != nil || req.AgeApprover != "" {
+		cart.AgeCheck = &sales.AgeCheck{BirthDate: req.BirthDate, Approver: req.AgeApprover, ApToken: req.AgeApToken}
+	}
 
-	userStr := r.Header.Get("user_details")
-	if userStr == "" {
-		respMap["response"] = "error"
-		respMap["message"] = "user details not found"
-		return respMap
+	if err := h.Sales.AddCart(ctx, user, &cart); err != nil {
+		return AddCartResponse{}, err
 	}
 
-	details := logins.Users{}
-	json.Unmarshal([]byte(userStr), &details)
+	return AddCartResponse{Response: "success", Cart: cart}, nil
+}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
+// CloseBillRequest selects the bill to close
+type CloseBillRequest struct {
+	ReceiptNum int64 `json:"receipt_num" validate:"required"`
+}
+
+func (r *CloseBillRequest) Validate() error {
+	if r.ReceiptNum == 0 {
+		return sales.ErrNullReceipt
+	}
+	return nil
+}
 
-	vars := mux.Vars(r)
-	m := vars["module"]
+type CloseBillResponse struct {
+	Response string           `json:"response"`
+	Sales    sales.ReceiptLog `json:"sales"`
+}
 
-	fmt.Println("cash post module = ", m)
+// CloseBill joins the bill's dispatched orders for payment, the bill must be at the user's branch
+func (h *Handler) CloseBill(ctx context.Context, user logins.Users, req CloseBillRequest) (CloseBillResponse, error) {
+	if _, err := h.Receipt(ctx, user, ReceiptRequest{ReceiptNum: req.ReceiptNum}); err != nil {
+		return CloseBillResponse{}, err
+	}
+
+	receipt := sales.ReceiptLog{ReceiptNum: req.ReceiptNum, Branch: user.Branch}
+	if err := h.Sales.CloseBill(ctx, &receipt); err != nil {
+		return CloseBillResponse{}, err
+	}
+
+	return CloseBillResponse{Response: "success", Sales: receipt}, nil
+}
+
+// ReceiptRequest selects the receipt to fetch
+type ReceiptRequest struct {
+	ReceiptNum int64 `query:"receipt_num" validate:"required"`
+}
+
+func (r *ReceiptRequest) Validate() error {
+	if r.ReceiptNum == 0 {
+		return sales.ErrNullReceipt
+	}
+	return nil
+}
+
+type ReceiptResponse struct {
+	Response string           `json:"response"`
+	Receipt  sales.ReceiptLog `json:"receipt"`
+}
+
+// Receipt fetches a receipt in the user's branch
+func (h *Handler) Receipt(ctx context.Context, user logins.Users, req ReceiptRequest) (ReceiptResponse, error) {
+	rcpt := sales.ReceiptLog{ReceiptNum: req.ReceiptNum}
+	if err := h.Sales.Receipt(ctx, &rcpt); err != nil {
+		return ReceiptResponse{}, err
+	}
+	if rcpt.Branch != user.Branch {
+		return ReceiptResponse{}, apperr.New(apperr.NotFound, fmt.Sprintf("receipt %v not found", req.ReceiptNum))
+	}
+
+	return ReceiptResponse{Response: "success", Receipt: rcpt}, nil
+}
+
+// PayRequest holds a tender to apply to the receipt
+type PayRequest struct {
+	ReceiptNum int64        `json:"receipt_num" validate:"required"`
+	Paymode    string       `json:"paymode" validate:"required"`
+	Amount     money.Amount `json:"amount" validate:"required"`
+	Reference  string       `json:"reference"`
+}
+
+func (r *PayRequest) Validate() error {
+	if r.ReceiptNum == 0 {
+		return sales.ErrNullReceipt
+	}
+	if r.Paymode == "" {
+		return apperr.New(apperr.ValidationFailed, "paymode is required")
+	}
+	if r.Amount <= 0 {
+		return apperr.New(apperr.ValidationFailed, "amount must be greater than zero")
+	}
+	return nil
+}
+
+type PayResponse struct {
+	Response   string       `json:"response"`
+	ReceiptNum int64        `json:"receipt_num"`
+	Balance    money.Amount `json:"balance"`
+}
+
+// Pay applies a payment to the receipt
+func (h *Handler) Pay(ctx context.Context, user logins.Users, req PayRequest) (PayResponse, error) {
+	if _, err := h.Receipt(ctx, user, ReceiptRequest{ReceiptNum: req.ReceiptNum}); err != nil {
+		return PayResponse{}, err
+	}
+
+	rcpt := sales.ReceiptLog{ReceiptNum: req.ReceiptNum}
+	balance, err := h.Sales.ApplyPayment(ctx, &rcpt, sales.Payment{
+		Paymode:   req.Paymode,
+		Amount:    req.Amount,
+		Reference: req.Reference,
+	})
+	if err != nil {
+		return PayResponse{}, err
+	}
+
+	return PayResponse{Response: "success", ReceiptNum: rcpt.ReceiptNum, Balance: balance}, nil
+}
+
+// PostRequest selects the paid receipt to post
+type PostRequest struct {
+	ReceiptNum int64 `json:"receipt_num" validate:"required"`
+}
+
+func (r *PostRequest) Validate() error {
+	if r.ReceiptNum == 0 {
+		return sales.ErrNullReceipt
+	}
+	return nil
+}
+
+// Post completes a fully paid receipt
+func (h *Handler) Post(ctx context.Context, user logins.Users, req PostRequest) (ReceiptResponse, error) {
+	if _, err := h.Receipt(ctx, user, ReceiptRequest{ReceiptNum: req.ReceiptNum}); err != nil {
+		return ReceiptResponse{}, err
+	}
+
+	rcpt := sales.ReceiptLog{ReceiptNum: req.ReceiptNum}
+	if err := h.Sales.PostReceipt(ctx, &rcpt); err != nil {
+		return ReceiptResponse{}, err
+	}
+
+	return ReceiptResponse{Response: "success", Receipt: rcpt}, nil
+}
+
+// VoidRequest selects the open receipt to void
+type VoidRequest struct {
+	ReceiptNum int64 `json:"receipt_num" validate:"required"`
+}
+
+func (r *VoidRequest) Validate() error {
+	if r.ReceiptNum == 0 {
+		return sales.ErrNullReceipt
+	}
+	return nil
+}
+
+// Void voids an open receipt and returns its reserved stock
+func (h *Handler) Void(ctx context.Context, user logins.Users, req VoidRequest) (ReceiptResponse, error) {
+	if _, err := h.Receipt(ctx, user, ReceiptRequest{ReceiptNum: req.ReceiptNum}); err != nil {
+		return ReceiptResponse{}, err
+	}
+
+	rcpt := sales.ReceiptLog{ReceiptNum: req.ReceiptNum}
+	if err := h.Sales.VoidReceipt(ctx, &rcpt); err != nil {
+		return ReceiptResponse{}, err
+	}
 
-	switch m {
-	case "open-till":
-		fmt.Printf("\n\t Open till \n\t make_sales = %v \n\t accept_payments = %v \n\t, username = %v \n ", details.MakeSales, details.AcceptPayment, details.Username)
-
-		if !details.MakeSales && !details.AcceptPayment {
-			respMap["response"] = "error"
-			respMap["message"] = "forbidden"
-
-			return respMap
-		}
-
-		b, err := io.ReadAll(r.Body)
-		fmt.Println("body =", r.Body)
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "bad request"
-
-			return respMap
-		}
-
-		// Unmarshal into login_info map
-		var entry map[string]string
-		err = json.Unmarshal(b, &entry)
-
-		authDetails := logins.Users{Username: entry["approver"]}
-
-		// fetch authorizer's details
-		err = authDetails.FetchUser(ctx)
-		fmt.Printf("\nauthorizer details = %v\n", authDetails)
-		if err != nil {
-			log.Printf("\t error fetching user %v\t error = %v\n\n", entry["approver"], err)
-
-			respMap["response"] = "error"
-			respMap["message"] = "failed to get approver"
-
-			return respMap
-		}
-
-		// authDetail := authDetails
-		// companyID := fmt.Sprintf("%v", details.CompanyID)
-		poSett, _ := sales.FetchSettings()
-
-		fmt.Println("Approve sales = ", poSett.ApproveSales)
-		log.Println("Cash rollups = ", authDetails.CashRollups)
-
-		if !authDetails.CashRollups && poSett.ApproveSales {
-			respMap["response"] = "error"
-			respMap["message"] = "approval error \n approver is forbidden from opening till \n ensure you have 'Cash Rollups' rights to continue"
-
-			return respMap
-		}
-		if authDetails.Token != entry["ap_token"] && poSett.ApproveSales {
-			respMap["response"] = "error"
-			respMap["message"] = "incorrect user or password \n ensure you have the correct approval token \n or you have selected the right user"
-
-			return respMap
-		}
-		// get today's date and compare if token is expired
-		today := time.Now()
-
-		// check if token is expired
-		if today.After(authDetails.TokenDate) && poSett.ApproveSales {
-			respMap["response"] = "error"
-			respMap["message"] = "approval error \n Token Expired \n Please renew your token to continue"
-
-			return respMap
-		}
-
-		till := sales.Till{
-			Teller:     details.Username,
-			Branch:     details.Branch,
-			Supervisor: entry["approver"],
-		}
-
-		// open sales till
-		err = till.OpenTill(database.PgPool)
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "error\n failed while creating till"
-			respMap["trace"] = err
-
-			return respMap
-		}
-
-		respMap["response"] = "success"
-		respMap["till_num"] = till.TillNO
-		// respMap["token"] = newToken
-
-		return respMap
-
-	case "new_receipt":
-		fmt.Println("\t new bill")
-		if !details.MakeSales {
-			respMap["response"] = "error"
-			respMap["message"] = "forbidden"
-			return respMap
-		}
-
-		receipt := sales.ReceiptLog{
-			TillNum:   details.TillNum,
-			Poster:    details.Username,
-			SaleType:  "Cash Sale",
-			Branch:    details.Branch,
-			CompanyID: details.CompanyID,
-			AcNum:     "0",
-		}
-
-		err := receipt.GenReceipt()
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "receipt number is null"
-			respMap["trace"] = err
-
-			return respMap
-		}
-
-		// get active carts
-		receipts, err := receipt.GetActiveCarts()
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "error getting active carts"
-			respMap["trace"] = err
-
-			return respMap
-		}
-
-		respMap["response"] = "success"
-		respMap["carts"] = receipts
-		respMap["receipt_num"] = receipt.ReceiptNum
-		return respMap
-
-	case "new_bill":
-		if !details.MakeSales {
-			respMap["response"] = "forbidden"
-			respMap["message"] = "forbidden"
-			return respMap
-		}
-
-		receipt := sales.ReceiptLog{
-			TillNum:   details.TillNum,
-			Branch:    details.Branch,
-			Poster:    details.Username,
-			SaleType:  "Cash Sale",
-			CompanyID: 0,
-		}
-
-		// ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-		// defer cancel()
-
-		err := receipt.NewBill()
-		if err != nil {
-			log.Println("error requesting new bill     err =", err)
-			respMap["response"] = "error"
-			respMap["message"] = "failed to create new bill"
-			return respMap
-		}
-
-		err = receipt.GenReceipt()
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "receipt number is null"
-			respMap["trace"] = err
-
-			return respMap
-		}
-
-		// get active carts
-		receipts, err := receipt.GetActiveCarts()
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "error getting active carts"
-			respMap["trace"] = err
-
-			return respMap
-		}
-
-		respMap["response"] = "success"
-		respMap["bills"] = receipts
-		respMap["receipt_num"] = receipt.ReceiptNum
-		return respMap
-
-	case "suspend":
-		if !details.MakeSales {
-			respMap["response"] = "forbidden"
-			respMap["message"] = "forbidden"
-			return respMap
-		}
-
-		b, err := io.ReadAll(r.Body)
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "bad request"
-			return respMap
-		}
-
-		rcpt := sales.ReceiptLog{}
-		err = json.Unmarshal(b, &rcpt)
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "failed to unmarshal json"
-			return respMap
-		}
-
-		err = rcpt.Suspend()
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "failed to suspend"
-			respMap["trace"] = err
-			return respMap
-		}
-
-		err = rcpt.GenReceipt()
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "failed to gen new receipt"
-			respMap["trace"] = err
-			return respMap
-		}
-
-		respMap["response"] = "success"
-		return respMap
-
-	case "receipt":
-		if !details.MakeSales {
-			respMap["response"] = "forbidden"
-			respMap["message"] = "forbidden"
-			return respMap
-		}
-
-	case "add-cart":
-		if !details.MakeSales {
-			respMap["response"] = "forbidden"
-			respMap["message"] = "forbidden"
-			return respMap
-		}
-
-		// get params
-		b, err := io.ReadAll(r.Body)
-		fmt.Println("body =", r.Body)
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "bad request"
-
-			return respMap
-		}
-
-		// unmarshal body
-		cart := sales.Sales{}
-		err = json.Unmarshal(b, &cart)
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "bad request"
-
-			return respMap
-		}
-
-		// fetch receiptNum if not provided
-		if cart.ReceiptNum == 0 {
-			rcpt := sales.ReceiptLog{TillNum: details.TillNum, Poster: details.Username}
-			err = rcpt.GenReceipt()
-			if err != nil {
-				respMap["response"] = "error"
-				respMap["message"] = "receipt number is null"
-				respMap["trace"] = err
-
-				return respMap
-			}
-			cart.ReceiptItem = fmt.Sprintf("%v", rcpt.ReceiptNum)
-		}
-
-		err = cart.AddCart()
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "failed adding to cart"
-			respMap["trace"] = err
-
-			return respMap
-		}
-
-		respMap["response"] = "success"
-		respMap["cart"] = cart
-
-		return respMap
-
-	case "close_bill":
-		if !details.MakeSales {
-			respMap["response"] = "error"
-			respMap["message"] = "forbidden"
-			return respMap
-		}
-
-		// get params
-		b, err := io.ReadAll(r.Body)
-		fmt.Println("body =", r.Body)
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "bad request"
-
-			return respMap
-		}
-
-		// unmarshal body
-		receipt := sales.ReceiptLog{}
-		err = json.Unmarshal(b, &receipt)
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "bad request"
-
-			return respMap
-		}
-
-		// fetch receiptNum if not provided
-		if receipt.ReceiptNum == 0 {
-			respMap["response"] = "error"
-			respMap["message"] = "receipt number is null"
-			return respMap
-		}
-
-		err = receipt.CloseBill()
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "failed closing bill"
-			respMap["trace"] = err
-
-			return respMap
-		}
-
-		respMap["response"] = "success"
-		respMap["sales"] = receipt
-
-		return respMap
-
-	}
-	return respMap
+	return ReceiptResponse{Response: "success", Receipt: rcpt}, nil
 }
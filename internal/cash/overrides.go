@@ -0,0 +1,199 @@
+package cash
+
+import (
+	"context"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+)
+
+// PriceOverrideRequest holds a line's new price
+// Approver and ApToken are needed when the price cut is above the user's discount limit
+type PriceOverrideRequest struct {
+	ReceiptNum  int64        `json:"receipt_num" validate:"required"`
+	ReceiptItem string       `json:"receipt_item" validate:"required"`
+	Price       money.Amount `json:"price" validate:"required"`
+	Reason      string       `json:"reason" validate:"required"`
+	Approver    string       `json:"approver"`
+	ApToken     string       `json:"ap_token"`
+}
+
+func (r *PriceOverrideRequest) Validate() error {
+	if r.ReceiptNum == 0 {
+		return sales.ErrNullReceipt
+	}
+	if r.ReceiptItem == "" {
+		return apperr.New(apperr.ValidationFailed, "receipt_item is required")
+	}
+	if r.Price <= 0 {
+		return apperr.New(apperr.ValidationFailed, "price must be greater than zero")
+	}
+	if r.Reason == "" {
+		return apperr.New(apperr.ValidationFailed, "reason is required")
+	}
+	return nil
+}
+
+// OverridePrice changes the price of a line on the receipt
+func (h *Handler) OverridePrice(ctx context.Context, user logins.Users, req PriceOverrideRequest) (ReceiptResponse, error) {
+	if _, err := h.Receipt(ctx, user, ReceiptRequest{ReceiptNum: req.ReceiptNum}); err != nil {
+		return ReceiptResponse{}, err
+	}
+
+	rcpt := sales.ReceiptLog{ReceiptNum: req.ReceiptNum}
+	err := h.Sales.OverridePrice(ctx, user, &rcpt, sales.Override{
+		ReceiptItem: req.ReceiptItem,
+		Price:       req.Price,
+		Reason:      req.Reason,
+		Approver:    req.Approver,
+		ApToken:     req.ApToken,
+	})
+	if err != nil {
+		return ReceiptResponse{}, err
+	}
+
+	return ReceiptResponse{Response: "success", Receipt: rcpt}, nil
+}
+
+// DiscountRequest holds a discount by amount or percent
+// ReceiptItem selects the line, the whole receipt is discounted without it
+type DiscountRequest struct {
+	ReceiptNum  int64        `json:"receipt_num" validate:"required"`
+	ReceiptItem string       `json:"receipt_item"`
+	Amount      money.Amount `json:"amount"`
+	Percent     float64      `json:"percent"`
+	Reason      string       `json:"reason" validate:"required"`
+	Approver    string       `json:"approver"`
+	ApToken     string       `json:"ap_token"`
+}
+
+func (r *DiscountRequest) Validate() error {
+	if r.ReceiptNum == 0 {
+		return sales.ErrNullReceipt
+	}
+	if (r.Amount <= 0) == (r.Percent <= 0) {
+		return apperr.New(apperr.ValidationFailed, "either amount or percent is required")
+	}
+	if r.Percent > 100 {
+		return apperr.New(apperr.ValidationFailed, "percent can't be more than 100")
+	}
+	if r.Reason == "" {
+		return apperr.New(apperr.ValidationFailed, "reason is required")
+	}
+	return nil
+}
+
+func (r DiscountRequest) override() sales.Override {
+	return sales.Override{
+		ReceiptItem: r.ReceiptItem,
+		Amount:      r.Amount,
+		Percent:     r.Percent,
+		Reason:      r.Reason,
+		Approver:    r.Approver,
+		ApToken:     r.ApToken,
+	}
+}
+
+// DiscountLine discounts a line on the receipt
+func (h *Handler) DiscountLine(ctx context.Context, user logins.Users, req DiscountRequest) (ReceiptResponse, error) {
+	if req.ReceiptItem == "" {
+		return ReceiptResponse{}, apperr.New(apperr.ValidationFailed, "receipt_item is required")
+	}
+	if _, err := h.Receipt(ctx, user, ReceiptRequest{ReceiptNum: req.ReceiptNum}); err != nil {
+		return ReceiptResponse{}, err
+	}
+
+	rcpt := sales.ReceiptLog{ReceiptNum: req.ReceiptNum}
+	if err := h.Sales.DiscountLine(ctx, user, &rcpt, req.override()); err != nil {
+		return ReceiptResponse{}, err
+	}
+
+	return ReceiptResponse{Response: "success", Receipt: rcpt}, nil
+}
+
+// DiscountReceipt discounts the whole receipt
+func (h *Handler) DiscountReceipt(ctx context.Context, user logins.Users, req DiscountRequest) (ReceiptResponse, error) {
+	if _, err := h.Receipt(ctx, user, ReceiptRequest{ReceiptNum: req.ReceiptNum}); err != nil {
+		return ReceiptResponse{}, err
+	}
+
+	rcpt := sales.ReceiptLog{ReceiptNum: req.ReceiptNum}
+	if err := h.Sales.DiscountReceipt(ctx, user, &rcpt, req.override()); err != nil {
+		return ReceiptResponse{}, err
+	}
+
+	return ReceiptResponse{Response: "success", Receipt: rcpt}, nil
+}
+
+// VatExemptRequest exempts the receipt's customer from vat, or lifts the exemption
+// Approver and ApToken are needed to exempt without the vat exempt right
+type VatExemptRequest struct {
+	ReceiptNum  int64  `json:"receipt_num" validate:"required"`
+	Exempt      bool   `json:"exempt"`
+	Certificate string `json:"certificate"`
+	Approver    string `json:"approver"`
+	ApToken     string `json:"ap_token"`
+}
+
+func (r *VatExemptRequest) Validate() error {
+	if r.ReceiptNum == 0 {
+		return sales.ErrNullReceipt
+	}
+	if r.Exempt && r.Certificate == "" {
+		return apperr.New(apperr.ValidationFailed, "certificate is required")
+	}
+	return nil
+}
+
+// VatExempt sets the receipt's vat exemption and reprices it
+func (h *Handler) VatExempt(ctx context.Context, user logins.Users, req VatExemptRequest) (ReceiptResponse, error) {
+	if _, err := h.Receipt(ctx, user, ReceiptRequest{ReceiptNum: req.ReceiptNum}); err != nil {
+		return ReceiptResponse{}, err
+	}
+
+	rcpt := sales.ReceiptLog{ReceiptNum: req.ReceiptNum}
+	if err := h.Sales.SetVatExempt(ctx, user, &rcpt, req.Exempt, req.Certificate, req.Approver, req.ApToken); err != nil {
+		return ReceiptResponse{}, err
+	}
+
+	return ReceiptResponse{Response: "success", Receipt: rcpt}, nil
+}
+
+// CustomerRequest attaches a customer to the receipt, a zero CustomerID detaches them
+// Approver and ApToken are needed to apply the customer's exemption without the vat exempt right
+type CustomerRequest struct {
+	ReceiptNum int64  `json:"receipt_num" validate:"required"`
+	CustomerID int64  `json:"customer_id"`
+	Approver   string `json:"approver"`
+	ApToken    string `json:"ap_token"`
+}
+
+func (r *CustomerRequest) Validate() error {
+	if r.ReceiptNum == 0 {
+		return sales.ErrNullReceipt
+	}
+	return nil
+}
+
+type CustomerResponse struct {
+	Response string           `json:"response"`
+	Receipt  sales.ReceiptLog `json:"receipt"`
+	Customer sales.Customer   `json:"customer"`
+}
+
+// AttachCustomer sets the receipt's customer
+func (h *Handler) AttachCustomer(ctx context.Context, user logins.Users, req CustomerRequest) (CustomerResponse, error) {
+	if _, err := h.Receipt(ctx, user, ReceiptRequest{ReceiptNum: req.ReceiptNum}); err != nil {
+		return CustomerResponse{}, err
+	}
+
+	rcpt := sales.ReceiptLog{ReceiptNum: req.ReceiptNum}
+	c, err := h.Sales.AttachCustomer(ctx, user, &rcpt, req.CustomerID, req.Approver, req.ApToken)
+	if err != nil {
+		return CustomerResponse{}, err
+	}
+
+	return CustomerResponse{Response: "success", Receipt: rcpt, Customer: c}, nil
+}
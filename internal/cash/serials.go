@@ -0,0 +1,109 @@
+package cash
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+)
+
+// SerialRequest selects a serial number or IMEI
+type SerialRequest struct {
+	Serial string `query:"serial" validate:"required"`
+}
+
+func (r *SerialRequest) Validate() error {
+	if strings.TrimSpace(r.Serial) == "" {
+		return apperr.New(apperr.ValidationFailed, "serial is required")
+	}
+	return nil
+}
+
+type SerialResponse struct {
+	Response string           `json:"response"`
+	Sale     sales.SerialSale `json:"sale"`
+}
+
+// LookupSerial finds the receipt a serial was sold on for warranty claims
+func (h *Handler) LookupSerial(ctx context.Context, user logins.Users, req SerialRequest) (SerialResponse, error) {
+	sale, err := h.Sales.LookupSerial(ctx, req.Serial)
+	if err != nil {
+		return SerialResponse{}, err
+	}
+	return SerialResponse{Response: "success", Sale: sale}, nil
+}
+
+// ReturnRequest holds the serials of an item being returned from the receipt ReturnTrace
+type ReturnRequest struct {
+	ReturnTrace int64    `json:"return_trace" validate:"required"`
+	ItemCode    string   `json:"item_code" validate:"required"`
+	Quantity    float64  `json:"quantity" validate:"required"`
+	Serials     []string `json:"serials"`
+}
+
+func (r *ReturnRequest) Validate() error {
+	if r.ReturnTrace == 0 {
+		return apperr.New(apperr.ValidationFailed, "return_trace is required")
+	}
+	if r.ItemCode == "" {
+		return apperr.New(apperr.ValidationFailed, "item_code is required")
+	}
+	if r.Quantity <= 0 {
+		return apperr.New(apperr.ValidationFailed, "quantity must be greater than zero")
+	}
+	return nil
+}
+
+type ReturnResponse struct {
+	Response    string `json:"response"`
+	ReturnTrace int64  `json:"return_trace"`
+}
+
+// CheckReturn checks the returned serials were sold on the original receipt
+func (h *Handler) CheckReturn(ctx context.Context, user logins.Users, req ReturnRequest) (ReturnResponse, error) {
+	item := sales.Sales{ItemCode: req.ItemCode, Quantity: req.Quantity, Serials: req.Serials}
+	if err := h.Sales.CheckReturn(ctx, req.ReturnTrace, item); err != nil {
+		return ReturnResponse{}, err
+	}
+	return ReturnResponse{Response: "success", ReturnTrace: req.ReturnTrace}, nil
+}
+
+// SalesReturnRequest selects the lines of the posted receipt ReturnTrace to take back
+type SalesReturnRequest struct {
+	ReturnTrace int64              `json:"return_trace" validate:"required"`
+	Lines       []sales.ReturnLine `json:"lines" validate:"required"`
+}
+
+func (r *SalesReturnRequest) Validate() error {
+	if r.ReturnTrace == 0 {
+		return apperr.New(apperr.ValidationFailed, "return_trace is required")
+	}
+	if len(r.Lines) == 0 {
+		return apperr.New(apperr.ValidationFailed, "lines are required")
+	}
+	for _, l := range r.Lines {
+		if l.ReceiptItem == "" {
+			return apperr.New(apperr.ValidationFailed, "receipt_item is required")
+		}
+		if l.Quantity <= 0 {
+			return apperr.New(apperr.ValidationFailed, "quantity must be greater than zero")
+		}
+	}
+	return nil
+}
+
+// ReturnSale takes lines of a posted receipt back and refunds them from the user's till
+// the refund is paid out of the till, so the user needs the payment right as well as returns
+func (h *Handler) ReturnSale(ctx context.Context, user logins.Users, req SalesReturnRequest) (ReceiptResponse, error) {
+	if !user.HasRight(logins.RightAcceptPayment) {
+		return ReceiptResponse{}, apperr.New(apperr.Forbidden, fmt.Sprintf("refunds are paid from the till and need '%v' rights", logins.RightAcceptPayment.Title()))
+	}
+	rcpt, err := h.Sales.ReturnSale(ctx, user, sales.SalesReturn{ReturnTrace: req.ReturnTrace, Lines: req.Lines})
+	if err != nil {
+		return ReceiptResponse{}, err
+	}
+	return ReceiptResponse{Response: "success", Receipt: rcpt}, nil
+}
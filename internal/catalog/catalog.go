@@ -0,0 +1,70 @@
+package catalog
+
+import (
+	"context"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+)
+
+// Handler serves product lookups for the till
+type Handler struct {
+	Sales *sales.Service
+}
+
+// NewHandler creates a product handler on the given sales service
+func NewHandler(svc *sales.Service) *Handler {
+	return &Handler{Sales: svc}
+}
+
+// SearchRequest selects products by name or code, barcode or category
+// a request with only a category pages through the category for button screens
+type SearchRequest struct {
+	Query    string `query:"q"`
+	Barcode  string `query:"barcode"`
+	Category string `query:"category"`
+	Limit    int    `query:"limit"`
+	Offset   int    `query:"offset"`
+}
+
+func (r *SearchRequest) Validate() error {
+	if r.Query == "" && r.Barcode == "" && r.Category == "" {
+		return apperr.New(apperr.ValidationFailed, "q, barcode or category is required")
+	}
+	if r.Limit < 0 || r.Limit > products.MaxLimit {
+		return apperr.New(apperr.ValidationFailed, "limit must be between 0 and 100")
+	}
+	if r.Offset < 0 {
+		return apperr.New(apperr.ValidationFailed, "offset can't be negative")
+	}
+	return nil
+}
+
+type SearchResponse struct {
+	Response   string                 `json:"response"`
+	Products   []products.StockMaster `json:"products"`
+	Categories []string               `json:"categories"`
+	Total      int                    `json:"total"`
+}
+
+// Search finds products priced for the user's branch
+func (h *Handler) Search(ctx context.Context, user logins.Users, req SearchRequest) (SearchResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	res, err := h.Sales.SearchProducts(ctx, user, products.SearchQuery{
+		Query:    req.Query,
+		Barcode:  req.Barcode,
+		Category: req.Category,
+		Limit:    req.Limit,
+		Offset:   req.Offset,
+	})
+	if err != nil {
+		return SearchResponse{}, err
+	}
+
+	return SearchResponse{Response: "success", Products: res.Products, Categories: res.Categories, Total: res.Total}, nil
+}
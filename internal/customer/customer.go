@@ -0,0 +1,93 @@
+package customer
+
+import (
+	"context"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+)
+
+// Handler serves the customer master for the till
+type Handler struct {
+	Sales *sales.Service
+}
+
+// NewHandler creates a customer handler on the given sales service
+func NewHandler(svc *sales.Service) *Handler {
+	return &Handler{Sales: svc}
+}
+
+// SearchRequest finds customers by name, phone, email or KRA PIN
+type SearchRequest struct {
+	Query string `query:"q" validate:"required"`
+	Limit int    `query:"limit"`
+}
+
+func (r *SearchRequest) Validate() error {
+	if r.Query == "" {
+		return apperr.New(apperr.ValidationFailed, "q is required")
+	}
+	if r.Limit < 0 || r.Limit > sales.MaxCustomers {
+		return apperr.New(apperr.ValidationFailed, "limit must be between 0 and 50")
+	}
+	return nil
+}
+
+type SearchResponse struct {
+	Response  string           `json:"response"`
+	Customers []sales.Customer `json:"customers"`
+}
+
+// Search finds customers to attach to a receipt
+func (h *Handler) Search(ctx context.Context, user logins.Users, req SearchRequest) (SearchResponse, error) {
+	customers, err := h.Sales.SearchCustomers(ctx, req.Query, req.Limit)
+	if err != nil {
+		return SearchResponse{}, err
+	}
+	return SearchResponse{Response: "success", Customers: customers}, nil
+}
+
+// SaveRequest creates a customer, or updates them when ID is set
+type SaveRequest struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name" validate:"required"`
+	Phone      string `json:"phone" validate:"required"`
+	Email      string `json:"email"`
+	KraPin     string `json:"kra_pin"`
+	ExemptCert string `json:"exempt_cert"`
+	// Approver and ApToken are needed to set ExemptCert without the vat exempt right
+	Approver string `json:"approver"`
+	ApToken  string `json:"ap_token"`
+}
+
+func (r *SaveRequest) Validate() error {
+	if r.Name == "" {
+		return apperr.New(apperr.ValidationFailed, "name is required")
+	}
+	if r.Phone == "" {
+		return apperr.New(apperr.ValidationFailed, "phone is required")
+	}
+	return nil
+}
+
+type CustomerResponse struct {
+	Response string         `json:"response"`
+	Customer sales.Customer `json:"customer"`
+}
+
+// Save quick-creates a customer from the till or updates their details
+func (h *Handler) Save(ctx context.Context, user logins.Users, req SaveRequest) (CustomerResponse, error) {
+	c := sales.Customer{
+		ID:         req.ID,
+		Name:       req.Name,
+		Phone:      req.Phone,
+		Email:      req.Email,
+		KraPin:     req.KraPin,
+		ExemptCert: req.ExemptCert,
+	}
+	if err := h.Sales.SaveCustomer(ctx, user, &c, req.Approver, req.ApToken); err != nil {
+		return CustomerResponse{}, err
+	}
+	return CustomerResponse{Response: "success", Customer: c}, nil
+}
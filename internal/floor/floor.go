@@ -0,0 +1,181 @@
+package floor
+
+import (
+	"context"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+)
+
+// Handler serves restaurant floor and table requests
+type Handler struct {
+	Sales *sales.Service
+}
+
+// NewHandler creates a floor handler on the given sales service
+func NewHandler(svc *sales.Service) *Handler {
+	return &Handler{Sales: svc}
+}
+
+// Empty is a request without parameters
+type Empty struct{}
+
+type PlansResponse struct {
+	Response string        `json:"response"`
+	Floors   []sales.Floor `json:"floors"`
+}
+
+// Plans lists the user's branch's floors with their tables
+func (h *Handler) Plans(ctx context.Context, user logins.Users, req Empty) (PlansResponse, error) {
+	floors, err := h.Sales.Floors(ctx, user.Branch)
+	if err != nil {
+		return PlansResponse{}, err
+	}
+	return PlansResponse{Response: "success", Floors: floors}, nil
+}
+
+// SaveFloorRequest creates a floor, or updates it when ID is set
+type SaveFloorRequest struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name" validate:"required"`
+	SortOrder int    `json:"sort_order"`
+	Active    bool   `json:"active"`
+}
+
+func (r *SaveFloorRequest) Validate() error {
+	if r.Name == "" {
+		return apperr.New(apperr.ValidationFailed, "name is required")
+	}
+	return nil
+}
+
+type SaveFloorResponse struct {
+	Response string      `json:"response"`
+	Floor    sales.Floor `json:"floor"`
+}
+
+// SaveFloor creates or updates a floor at the user's branch
+func (h *Handler) SaveFloor(ctx context.Context, user logins.Users, req SaveFloorRequest) (SaveFloorResponse, error) {
+	f := sales.Floor{ID: req.ID, Branch: user.Branch, Name: req.Name, SortOrder: req.SortOrder, Active: req.Active}
+	if err := h.Sales.SaveFloor(ctx, &f); err != nil {
+		return SaveFloorResponse{}, err
+	}
+	return SaveFloorResponse{Response: "success", Floor: f}, nil
+}
+
+// SaveTableRequest places a table on a floor, or updates it when ID is set
+type SaveTableRequest struct {
+	ID      int64   `json:"id"`
+	FloorID int64   `json:"floor_id" validate:"required"`
+	Name    string  `json:"name" validate:"required"`
+	Seats   int     `json:"seats"`
+	PosX    float64 `json:"pos_x"`
+	PosY    float64 `json:"pos_y"`
+	Active  bool    `json:"active"`
+}
+
+func (r *SaveTableRequest) Validate() error {
+	if r.FloorID == 0 {
+		return apperr.New(apperr.ValidationFailed, "floor_id is required")
+	}
+	if r.Name == "" {
+		return apperr.New(apperr.ValidationFailed, "name is required")
+	}
+	if r.Seats < 0 {
+		return apperr.New(apperr.ValidationFailed, "seats can't be negative")
+	}
+	return nil
+}
+
+type TableResponse struct {
+	Response string            `json:"response"`
+	Table    sales.DiningTable `json:"table"`
+}
+
+// SaveTable creates or updates a table at the user's branch
+func (h *Handler) SaveTable(ctx context.Context, user logins.Users, req SaveTableRequest) (TableResponse, error) {
+	t := sales.DiningTable{
+		ID:      req.ID,
+		FloorID: req.FloorID,
+		Branch:  user.Branch,
+		Name:    req.Name,
+		Seats:   req.Seats,
+		PosX:    req.PosX,
+		PosY:    req.PosY,
+		Active:  req.Active,
+	}
+	if err := h.Sales.SaveTable(ctx, &t); err != nil {
+		return TableResponse{}, err
+	}
+	return TableResponse{Response: "success", Table: t}, nil
+}
+
+// StateRequest selects the floor to show, all of the branch's floors without it
+type StateRequest struct {
+	FloorID int64 `query:"floor_id"`
+}
+
+type StateResponse struct {
+	Response string             `json:"response"`
+	Tables   []sales.TableState `json:"tables"`
+}
+
+// State shows each table as free, ordering, waiting or bill requested with its elapsed time
+func (h *Handler) State(ctx context.Context, user logins.Users, req StateRequest) (StateResponse, error) {
+	tables, err := h.Sales.FloorState(ctx, user.Branch, req.FloorID)
+	if err != nil {
+		return StateResponse{}, err
+	}
+	return StateResponse{Response: "success", Tables: tables}, nil
+}
+
+// OpenTableRequest seats guests at a free table
+type OpenTableRequest struct {
+	TableID int64 `json:"table_id" validate:"required"`
+	Guests  int   `json:"guests"`
+}
+
+func (r *OpenTableRequest) Validate() error {
+	if r.TableID == 0 {
+		return apperr.New(apperr.ValidationFailed, "table_id is required")
+	}
+	if r.Guests < 0 {
+		return apperr.New(apperr.ValidationFailed, "guests can't be negative")
+	}
+	return nil
+}
+
+// OpenTable starts a bill at the table served by the user
+func (h *Handler) OpenTable(ctx context.Context, user logins.Users, req OpenTableRequest) (TableResponse, error) {
+	t, err := h.Sales.OpenTable(ctx, user, req.TableID, req.Guests)
+	if err != nil {
+		return TableResponse{}, err
+	}
+	return TableResponse{Response: "success", Table: t}, nil
+}
+
+// TransferRequest hands a table to another waiter
+type TransferRequest struct {
+	TableID int64  `json:"table_id" validate:"required"`
+	Waiter  string `json:"waiter" validate:"required"`
+}
+
+func (r *TransferRequest) Validate() error {
+	if r.TableID == 0 {
+		return apperr.New(apperr.ValidationFailed, "table_id is required")
+	}
+	if r.Waiter == "" {
+		return apperr.New(apperr.ValidationFailed, "waiter is required")
+	}
+	return nil
+}
+
+// Transfer hands the table's bill to another waiter
+func (h *Handler) Transfer(ctx context.Context, user logins.Users, req TransferRequest) (TableResponse, error) {
+	t, err := h.Sales.TransferTable(ctx, user, req.TableID, req.Waiter)
+	if err != nil {
+		return TableResponse{}, err
+	}
+	return TableResponse{Response: "success", Table: t}, nil
+}
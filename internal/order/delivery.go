@@ -0,0 +1,153 @@
+package order
+
+import (
+	"context"
+	"strings"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+)
+
+// DeliveryRequest books a bill for delivery to the customer's address
+type DeliveryRequest struct {
+	ReceiptNum int64        `json:"receipt_num" validate:"required"`
+	CustomerID int64        `json:"customer_id"`
+	Phone      string       `json:"phone"`
+	Address    string       `json:"address" validate:"required"`
+	Note       string       `json:"note"`
+	Fee        money.Amount `json:"fee"`
+}
+
+func (r *DeliveryRequest) Validate() error {
+	if r.ReceiptNum == 0 {
+		return sales.ErrNullReceipt
+	}
+	if strings.TrimSpace(r.Address) == "" {
+		return apperr.New(apperr.ValidationFailed, "address is required")
+	}
+	if r.Phone == "" && r.CustomerID == 0 {
+		return apperr.New(apperr.ValidationFailed, "phone or customer_id is required")
+	}
+	return nil
+}
+
+type DeliveryResponse struct {
+	Response string         `json:"response"`
+	Delivery sales.Delivery `json:"delivery"`
+}
+
+// BookDelivery books the bill for delivery
+func (h *Handler) BookDelivery(ctx context.Context, user logins.Users, req DeliveryRequest) (DeliveryResponse, error) {
+	d := sales.Delivery{
+		ReceiptNum: req.ReceiptNum,
+		CustomerID: req.CustomerID,
+		Phone:      req.Phone,
+		Address:    req.Address,
+		Note:       req.Note,
+		Fee:        req.Fee,
+	}
+	if err := h.Sales.BookDelivery(ctx, user, &d); err != nil {
+		return DeliveryResponse{}, err
+	}
+	return DeliveryResponse{Response: "success", Delivery: d}, nil
+}
+
+// Empty is a request without parameters
+type Empty struct{}
+
+type DeliveriesResponse struct {
+	Response   string           `json:"response"`
+	Deliveries []sales.Delivery `json:"deliveries"`
+}
+
+// Deliveries lists the deliveries on the road or with cash to settle at the user's branch
+func (h *Handler) Deliveries(ctx context.Context, user logins.Users, req Empty) (DeliveriesResponse, error) {
+	vals, err := h.Sales.OpenDeliveries(ctx, user.Branch)
+	if err != nil {
+		return DeliveriesResponse{}, err
+	}
+	return DeliveriesResponse{Response: "success", Deliveries: vals}, nil
+}
+
+// RiderRequest assigns a rider to a delivery
+type RiderRequest struct {
+	ReceiptNum int64  `json:"receipt_num" validate:"required"`
+	Rider      string `json:"rider" validate:"required"`
+}
+
+func (r *RiderRequest) Validate() error {
+	if r.ReceiptNum == 0 {
+		return sales.ErrNullReceipt
+	}
+	if r.Rider == "" {
+		return apperr.New(apperr.ValidationFailed, "rider is required")
+	}
+	return nil
+}
+
+// AssignRider gives the delivery to a rider
+func (h *Handler) AssignRider(ctx context.Context, user logins.Users, req RiderRequest) (DeliveryResponse, error) {
+	d, err := h.Sales.AssignRider(ctx, user, req.ReceiptNum, req.Rider)
+	if err != nil {
+		return DeliveryResponse{}, err
+	}
+	return DeliveryResponse{Response: "success", Delivery: d}, nil
+}
+
+// StatusRequest tracks a delivery as picked_up, delivered or returned
+type StatusRequest struct {
+	ReceiptNum int64  `json:"receipt_num" validate:"required"`
+	State      string `json:"state" validate:"required"`
+}
+
+func (r *StatusRequest) Validate() error {
+	if r.ReceiptNum == 0 {
+		return sales.ErrNullReceipt
+	}
+	if r.State == "" {
+		return apperr.New(apperr.ValidationFailed, "state is required")
+	}
+	return nil
+}
+
+// DeliveryStatus moves the delivery along
+func (h *Handler) DeliveryStatus(ctx context.Context, user logins.Users, req StatusRequest) (DeliveryResponse, error) {
+	d, err := h.Sales.DeliveryStatus(ctx, user, req.ReceiptNum, req.State)
+	if err != nil {
+		return DeliveryResponse{}, err
+	}
+	return DeliveryResponse{Response: "success", Delivery: d}, nil
+}
+
+// SettleRequest pays a delivered bill with what the rider collected
+type SettleRequest struct {
+	ReceiptNum int64           `json:"receipt_num" validate:"required"`
+	Payments   []sales.Payment `json:"payments" validate:"required"`
+}
+
+func (r *SettleRequest) Validate() error {
+	if r.ReceiptNum == 0 {
+		return sales.ErrNullReceipt
+	}
+	if len(r.Payments) == 0 {
+		return apperr.New(apperr.ValidationFailed, "payments is required")
+	}
+	return nil
+}
+
+type SettleResponse struct {
+	Response string           `json:"response"`
+	Receipt  sales.ReceiptLog `json:"receipt"`
+	Delivery sales.Delivery   `json:"delivery"`
+}
+
+// SettleDelivery settles the rider's cash into the user's till and posts the receipt
+func (h *Handler) SettleDelivery(ctx context.Context, user logins.Users, req SettleRequest) (SettleResponse, error) {
+	rcpt, d, err := h.Sales.SettleDelivery(ctx, user, req.ReceiptNum, req.Payments)
+	if err != nil {
+		return SettleResponse{}, err
+	}
+	return SettleResponse{Response: "success", Receipt: rcpt, Delivery: d}, nil
+}
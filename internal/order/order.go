@@ -2,258 +2,314 @@ package order
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
 	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
 	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
-	"github.com/gorilla/mux"
 )
 
-func Get(w http.ResponseWriter, r *http.Request) map[string]interface{} {
-	respMap := make(map[string]interface{})
-	fmt.Println("orders in bill")
+// Handler serves sales order requests
+type Handler struct {
+	Sales *sales.Service
+}
+
+// NewHandler creates a sales order handler on the given sales service
+func NewHandler(svc *sales.Service) *Handler {
+	return &Handler{Sales: svc}
+}
+
+// OrdersInBillRequest selects the bill whose orders to list
+type OrdersInBillRequest struct {
+	Receipt int64 `query:"receipt" validate:"required"`
+}
 
-	userStr := r.Header.Get("user_details")
-	if userStr == "" {
-		respMap["response"] = "error"
-		respMap["message"] = "user details not found"
-		return respMap
+func (r *OrdersInBillRequest) Validate() error {
+	if r.Receipt == 0 {
+		return sales.ErrNullReceipt
 	}
+	return nil
+}
 
-	details := logins.Users{}
-	json.Unmarshal([]byte(userStr), &details)
+type OrdersInBillResponse struct {
+	Response string        `json:"response"`
+	Values   []sales.Order `json:"values"`
+	Total    money.Amount  `json:"total"`
+}
 
-	vars := mux.Vars(r)
-	m := vars["module"]
+// OrdersInBill lists the orders in a bill
+func (h *Handler) OrdersInBill(ctx context.Context, user logins.Users, req OrdersInBillRequest) (OrdersInBillResponse, error) {
+	fmt.Println("bill_num =", req.Receipt)
 
-	switch m {
-	case "orders_in_bill":
-		if !details.MakeSales {
-			respMap["response"] = "forbidden"
-			respMap["message"] = "forbidden"
-			return respMap
-		}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
 
-		recptStr := r.URL.Query().Get("receipt")
-		fmt.Println("receipt = ", recptStr)
+	vals, total, err := h.Sales.OrdersInBill(ctx, req.Receipt)
+	if err != nil {
+		return OrdersInBillResponse{}, err
+	}
 
-		receipt, _ := strconv.ParseInt(recptStr, 10, 64)
+	return OrdersInBillResponse{Response: "success", Values: vals, Total: total}, nil
+}
 
-		ord := sales.Order{ReceiptNum: receipt}
-		fmt.Println("bill_num =", ord.ReceiptNum)
+// CartRequest selects the order whose items to fetch
+type CartRequest struct {
+	OrderNum int64 `query:"order_num" validate:"required"`
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+func (r *CartRequest) Validate() error {
+	if r.OrderNum == 0 {
+		return apperr.New(apperr.ValidationFailed, "order_num is required")
+	}
+	return nil
+}
 
-		vals, err := ord.GetOrdersInBills(ctx)
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "error fetching orders in bill"
-			respMap["trace"] = err
-			return respMap
-		}
+type CartResponse struct {
+	Response string        `json:"response"`
+	Values   []sales.Sales `json:"values"`
+	Total    money.Amount  `json:"total"`
+}
 
-		respMap["response"] = "success"
-		respMap["values"] = vals
-		respMap["total"] = ord.Total
-		return respMap
+// Cart fetches the items in an order
+func (h *Handler) Cart(ctx context.Context, user logins.Users, req CartRequest) (CartResponse, error) {
+	ord := sales.Order{OrderNum: req.OrderNum}
+	if err := h.Sales.OrderCart(ctx, &ord); err != nil {
+		return CartResponse{}, err
+	}
 
-	case "cart":
-		if !details.MakeSales {
-			respMap["response"] = "forbidden"
-			respMap["message"] = "forbidden"
-			return respMap
-		}
+	return CartResponse{Response: "success", Values: ord.OrderItems, Total: ord.CalcTotal()}, nil
+}
 
-		orderNum := r.URL.Query().Get("order_num")
-		if orderNum == "" {
-			respMap["response"] = "error"
-			respMap["message"] = "order_num is null"
-			return respMap
-		}
+// AddCartRequest holds items to add to the bill's open order
+// only the first item is added
+type AddCartRequest struct {
+	ReceiptNum int64         `json:"receipt_num" validate:"required"`
+	OrderItems []sales.Sales `json:"order_items" validate:"required"`
+	// Channel is dine_in, takeaway or delivery, dine_in when unset
+	Channel string `json:"channel"`
+	// Approver and ApToken allow selling beyond the stock balance
+	Approver string `json:"approver"`
+	ApToken  string `json:"ap_token"`
+}
 
-		ordNum, _ := strconv.ParseInt(orderNum, 10, 64)
+func (r *AddCartRequest) Validate() error {
+	if r.ReceiptNum == 0 {
+		return sales.ErrNullReceipt
+	}
+	if len(r.OrderItems) == 0 {
+		return apperr.New(apperr.ValidationFailed, "order_items is required")
+	}
+	if r.OrderItems[0].ItemCode == "" {
+		return apperr.New(apperr.ValidationFailed, "item_code is required")
+	}
+	if r.OrderItems[0].Quantity <= 0 {
+		return apperr.New(apperr.ValidationFailed, "quantity must be greater than zero")
+	}
+	return nil
+}
 
-		ord := sales.Order{OrderNum: ordNum}
-		err := ord.Fetchtems()
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "failed to fetch cart items"
-			return respMap
-		}
+type AddCartResponse struct {
+	Response string        `json:"response"`
+	Cart     []sales.Sales `json:"cart"`
+	Total    money.Amount  `json:"total"`
+}
+
+// AddCart adds an item to the user's open order on the bill
+func (h *Handler) AddCart(ctx context.Context, user logins.Users, req AddCartRequest) (AddCartResponse, error) {
+	fmt.Println("adding to order_cart")
+	ord := sales.Order{
+		ReceiptNum:  req.ReceiptNum,
+		Branch:      user.Branch,
+		StkLocation: "0",
+		CompanyID:   user.CompanyID,
+		Poster:      user.Username,
+		TillNum:     user.TillNum,
+		Channel:     req.Channel,
+	}
 
-		respMap["response"] = "success"
-		respMap["values"] = ord.OrderItems
-		respMap["total"] = ord.CalcTotal()
+	item := req.OrderItems[0]
+	item.StockApprover = req.Approver
+	item.ApToken = req.ApToken
 
-		fmt.Println("order_num =", orderNum)
-		return respMap
+	cart, total, err := h.Sales.AddToOrder(ctx, &ord, item)
+	if err != nil {
+		return AddCartResponse{}, err
 	}
 
-	return respMap
+	return AddCartResponse{Response: "success", Cart: cart, Total: total}, nil
+}
 
+// CompleteRequest selects the order to send to the kitchen
+type CompleteRequest struct {
+	OrderNum int64 `json:"order_num" validate:"required"`
 }
 
-func Post(w http.ResponseWriter, r *http.Request) map[string]interface{} {
-	respMap := make(map[string]interface{})
+func (r *CompleteRequest) Validate() error {
+	if r.OrderNum == 0 {
+		return apperr.New(apperr.ValidationFailed, "order_num is required")
+	}
+	return nil
+}
 
-	userStr := r.Header.Get("user_details")
-	if userStr == "" {
-		respMap["response"] = "error"
-		respMap["message"] = "user details not found"
-		return respMap
+type CompleteResponse struct {
+	Response string            `json:"response"`
+	Cart     []sales.OrderItem `json:"cart"`
+}
+
+// Complete completes an order and returns its voucher
+func (h *Handler) Complete(ctx context.Context, user logins.Users, req CompleteRequest) (CompleteResponse, error) {
+	cart, err := h.Sales.CompleteOrder(ctx, &sales.Order{OrderNum: req.OrderNum})
+	if err != nil {
+		return CompleteResponse{}, err
 	}
 
-	details := logins.Users{}
-	json.Unmarshal([]byte(userStr), &details)
+	return CompleteResponse{Response: "success", Cart: cart}, nil
+}
+
+// FireRequest selects the order and the course to send to the kitchen
+type FireRequest struct {
+	OrderNum int64 `json:"order_num" validate:"required"`
+	Course   int   `json:"course" validate:"required"`
+}
 
-	// ctx, cancel := context.WithTimeout(r.Context(), 45*time.Second)
-	// defcer cancel()
+func (r *FireRequest) Validate() error {
+	if r.OrderNum == 0 {
+		return apperr.New(apperr.ValidationFailed, "order_num is required")
+	}
+	if r.Course < sales.FirstCourse {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("course must be at least %d", sales.FirstCourse))
+	}
+	return nil
+}
 
-	vars := mux.Vars(r)
-	m := vars["module"]
+type FireResponse struct {
+	Response string        `json:"response"`
+	Fired    []sales.Sales `json:"fired"`
+}
 
-	fmt.Println("cash post module = ", m)
+// Fire sends an order's held items up to the course to the kitchen
+func (h *Handler) Fire(ctx context.Context, user logins.Users, req FireRequest) (FireResponse, error) {
+	fired, err := h.Sales.FireCourse(ctx, &sales.Order{OrderNum: req.OrderNum}, req.Course)
+	if err != nil {
+		return FireResponse{}, err
+	}
 
-	switch m {
-	case "add-cart":
-		fmt.Println("adding to order_cart")
-		if !details.MakeSales {
-			respMap["response"] = "forbidden"
-			return respMap
-		}
+	return FireResponse{Response: "success", Fired: fired}, nil
+}
 
-		b, err := io.ReadAll(r.Body)
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "wrong params"
-			return respMap
-		}
+// DeleteItemRequest selects the order item to delete
+type DeleteItemRequest struct {
+	AutoID   string `json:"auto_id" validate:"required"`
+	Approver string `json:"approver"`
+	Token    string `json:"auth_token"`
+	Receipt  string `json:"receipt"`
+	OrderNum string `json:"order_num" validate:"required"`
 
-		branch := details.Branch
-		if branch == "" {
-			branch = "Main"
-		}
+	orderNum int64
+	receipt  int64
+}
 
-		ord := sales.Order{
-			Branch:      details.Branch,
-			StkLocation: "0",
-			CompanyID:   details.CompanyID,
-			Poster:      details.Username,
-			TillNum:     details.TillNum,
-		}
-		err = json.Unmarshal(b, &ord)
-		if err != nil {
-			log.Println("failed to unmarshal body    err =", err)
-			respMap["response"] = "error"
-			respMap["message"] = "bad request"
-			respMap["trace"] = err
-			return respMap
-		}
+func (r *DeleteItemRequest) Validate() error {
+	if r.AutoID == "" {
+		return apperr.New(apperr.ValidationFailed, "auto_id is required")
+	}
 
-		// add item to cart
-		cart, total, err := ord.AddToOrder(ord.OrderItems[0])
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "failed to add order to cart"
-			respMap["trace"] = err
-			return respMap
-		}
+	num, err := strconv.ParseInt(r.OrderNum, 10, 64)
+	if err != nil || num == 0 {
+		return apperr.New(apperr.ValidationFailed, "order_num must be a number")
+	}
+	r.orderNum = num
 
-		respMap["response"] = "success"
-		respMap["cart"] = cart
-		respMap["total"] = total
-		return respMap
-
-	case "complete":
-		b, err := io.ReadAll(r.Body)
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "wrong params"
-			return respMap
-		}
+	// receipt is optional and only scopes the update pushed to other devices
+	r.receipt, _ = strconv.ParseInt(r.Receipt, 10, 64)
+	return nil
+}
 
-		ord := sales.Order{}
-		err = json.Unmarshal(b, &ord)
-		if err != nil {
-			log.Println("failed to unmarshal body    err =", err)
-			respMap["response"] = "error"
-			respMap["message"] = "bad request"
-			respMap["trace"] = err
-			return respMap
-		}
+type DeleteItemResponse struct {
+	Response string        `json:"response"`
+	Cart     []sales.Sales `json:"cart"`
+	Total    money.Amount  `json:"total"`
+}
 
-		cart, err := ord.CompleteOrder()
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "failed to complete order"
-			respMap["trace"] = err
-			return respMap
-		}
+// DeleteItem deletes a pending item from an order
+func (h *Handler) DeleteItem(ctx context.Context, user logins.Users, req DeleteItemRequest) (DeleteItemResponse, error) {
+	fmt.Printf("\t receipt_item = %v \t order_num = %v", req.AutoID, req.OrderNum)
 
-		respMap["response"] = "success"
-		respMap["cart"] = cart
-		return respMap
+	ord := sales.Order{OrderNum: req.orderNum, ReceiptNum: req.receipt, Branch: user.Branch, TillNum: user.TillNum}
+	cart, total, err := h.Sales.DeleteOrderItem(ctx, &ord, req.AutoID)
+	if err != nil {
+		return DeleteItemResponse{}, err
 	}
 
-	return respMap
+	return DeleteItemResponse{Response: "success", Cart: cart, Total: total}, nil
 }
 
-func Delete(w http.ResponseWriter, r *http.Request) map[string]interface{} {
-	respMap := make(map[string]interface{})
+// MergeRequest selects the bills to merge into a bill
+type MergeRequest struct {
+	ReceiptNum int64   `json:"receipt_num" validate:"required"`
+	Receipts   []int64 `json:"receipts" validate:"required"`
+}
 
-	vars := mux.Vars(r)
-	m := vars["module"]
+func (r *MergeRequest) Validate() error {
+	if r.ReceiptNum == 0 {
+		return sales.ErrNullReceipt
+	}
+	if len(r.Receipts) == 0 {
+		return apperr.New(apperr.ValidationFailed, "receipts is required")
+	}
+	for _, num := range r.Receipts {
+		if num == r.ReceiptNum {
+			return apperr.New(apperr.ValidationFailed, "a bill can't be merged into itself")
+		}
+	}
+	return nil
+}
 
-	fmt.Println("order delete route = ", m)
+type MergeResponse struct {
+	Response   string `json:"response"`
+	ReceiptNum int64  `json:"receipt_num"`
+}
 
-	switch m {
-	case "order-item":
-		b, err := io.ReadAll(r.Body)
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "params error"
-			return respMap
-		}
+// Merge moves the orders of other bills into a bill
+func (h *Handler) Merge(ctx context.Context, user logins.Users, req MergeRequest) (MergeResponse, error) {
+	rcpt := sales.ReceiptLog{ReceiptNum: req.ReceiptNum, Branch: user.Branch, TillNum: user.TillNum}
+	if err := h.Sales.MergeBills(ctx, &rcpt, req.Receipts); err != nil {
+		return MergeResponse{}, err
+	}
 
-		fmt.Printf("\t body = %s \n", b)
-
-		itm := struct {
-			AutoID   string `json:"auto_id"`
-			Approver string `json:"approver"`
-			Token    string `json:"auth_token"`
-			Receipt  string `json:"receipt"`
-			OrderNum string `json:"order_num"`
-		}{}
-
-		err = json.Unmarshal(b, &itm)
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "json error"
-			return respMap
-		}
+	return MergeResponse{Response: "success", ReceiptNum: rcpt.ReceiptNum}, nil
+}
 
-		fmt.Printf("\t receipt_item = %v \t order_num = %v", itm.AutoID, itm.OrderNum)
+// SplitRequest selects the bill to split and how
+type SplitRequest struct {
+	ReceiptNum int64 `json:"receipt_num" validate:"required"`
+	sales.Split
+}
 
-		cart, total, err := sales.DelOrderItem(itm.AutoID, itm.OrderNum)
-		if err != nil {
-			respMap["response"] = "error"
-			respMap["message"] = "failed to delete order item"
-			respMap["trace"] = err
-			return respMap
-		}
+func (r *SplitRequest) Validate() error {
+	if r.ReceiptNum == 0 {
+		return sales.ErrNullReceipt
+	}
+	return r.Split.Validate()
+}
+
+type SplitResponse struct {
+	Response   string             `json:"response"`
+	ReceiptNum int64              `json:"receipt_num"`
+	Total      money.Amount       `json:"total"`
+	Receipts   []sales.ReceiptLog `json:"receipts"`
+}
 
-		respMap["response"] = "success"
-		respMap["cart"] = cart
-		respMap["total"] = total
-		return respMap
+// Split moves items of a bill to new receipts, by item, by seat or into equal shares
+func (h *Handler) Split(ctx context.Context, user logins.Users, req SplitRequest) (SplitResponse, error) {
+	rcpt := sales.ReceiptLog{ReceiptNum: req.ReceiptNum, Branch: user.Branch, TillNum: user.TillNum}
+	receipts, err := h.Sales.SplitBill(ctx, &rcpt, req.Split)
+	if err != nil {
+		return SplitResponse{}, err
 	}
 
-	return respMap
+	return SplitResponse{Response: "success", ReceiptNum: rcpt.ReceiptNum, Total: rcpt.Total, Receipts: receipts}, nil
 }
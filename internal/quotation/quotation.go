@@ -0,0 +1,191 @@
+package quotation
+
+import (
+	"context"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+)
+
+// Handler serves quotations and their conversion into sales
+type Handler struct {
+	Sales *sales.Service
+}
+
+// NewHandler creates a quotation handler on the given sales service
+func NewHandler(svc *sales.Service) *Handler {
+	return &Handler{Sales: svc}
+}
+
+// CreateRequest starts a quotation, valid_until is a date like 2006-01-02
+type CreateRequest struct {
+	CustomerID int64  `json:"customer_id"`
+	ValidUntil string `json:"valid_until"`
+
+	validUntil time.Time
+}
+
+func (r *CreateRequest) Validate() error {
+	if r.ValidUntil == "" {
+		return nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, r.ValidUntil, time.Local)
+	if err != nil {
+		return apperr.New(apperr.ValidationFailed, "valid_until must be a date like 2006-01-02")
+	}
+	// the quotation holds to the end of the day
+	r.validUntil = day.AddDate(0, 0, 1).Add(-time.Second)
+	return nil
+}
+
+type QuotationResponse struct {
+	Response  string          `json:"response"`
+	Quotation sales.Quotation `json:"quotation"`
+}
+
+// Create starts a quotation at the user's branch
+func (h *Handler) Create(ctx context.Context, user logins.Users, req CreateRequest) (QuotationResponse, error) {
+	q := sales.Quotation{CustomerID: req.CustomerID, ValidUntil: req.validUntil}
+	if err := h.Sales.NewQuotation(ctx, user, &q); err != nil {
+		return QuotationResponse{}, err
+	}
+	return QuotationResponse{Response: "success", Quotation: q}, nil
+}
+
+// FetchRequest selects a quotation
+type FetchRequest struct {
+	QuoteNum int64 `query:"quote_num" validate:"required"`
+}
+
+func (r *FetchRequest) Validate() error {
+	if r.QuoteNum == 0 {
+		return apperr.New(apperr.ValidationFailed, "quote_num is required")
+	}
+	return nil
+}
+
+// Fetch loads a quotation with its lines and customer
+func (h *Handler) Fetch(ctx context.Context, user logins.Users, req FetchRequest) (QuotationResponse, error) {
+	q := sales.Quotation{QuoteNum: req.QuoteNum}
+	if err := h.Sales.Quotation(ctx, &q); err != nil {
+		return QuotationResponse{}, err
+	}
+	return QuotationResponse{Response: "success", Quotation: q}, nil
+}
+
+// CancelRequest withdraws a quotation
+type CancelRequest struct {
+	QuoteNum int64 `json:"quote_num" validate:"required"`
+}
+
+func (r *CancelRequest) Validate() error {
+	if r.QuoteNum == 0 {
+		return apperr.New(apperr.ValidationFailed, "quote_num is required")
+	}
+	return nil
+}
+
+// Cancel withdraws an open quotation
+func (h *Handler) Cancel(ctx context.Context, user logins.Users, req CancelRequest) (QuotationResponse, error) {
+	q := sales.Quotation{QuoteNum: req.QuoteNum}
+	if err := h.Sales.CancelQuotation(ctx, &q); err != nil {
+		return QuotationResponse{}, err
+	}
+	return QuotationResponse{Response: "success", Quotation: q}, nil
+}
+
+// AddItemRequest prices an item into a quotation
+type AddItemRequest struct {
+	QuoteNum int64   `json:"quote_num" validate:"required"`
+	ItemCode string  `json:"item_code" validate:"required"`
+	Quantity float64 `json:"quantity" validate:"required"`
+}
+
+func (r *AddItemRequest) Validate() error {
+	if r.QuoteNum == 0 {
+		return apperr.New(apperr.ValidationFailed, "quote_num is required")
+	}
+	if r.ItemCode == "" {
+		return apperr.New(apperr.ValidationFailed, "item_code is required")
+	}
+	if r.Quantity <= 0 {
+		return apperr.New(apperr.ValidationFailed, "quantity must be greater than zero")
+	}
+	return nil
+}
+
+type AddItemResponse struct {
+	Response  string          `json:"response"`
+	Item      sales.Sales     `json:"item"`
+	Quotation sales.Quotation `json:"quotation"`
+}
+
+// AddItem prices an item into the quotation
+func (h *Handler) AddItem(ctx context.Context, user logins.Users, req AddItemRequest) (AddItemResponse, error) {
+	q := sales.Quotation{QuoteNum: req.QuoteNum}
+	item := sales.Sales{ItemCode: req.ItemCode, Quantity: req.Quantity}
+	if err := h.Sales.AddQuoteItem(ctx, &q, &item); err != nil {
+		return AddItemResponse{}, err
+	}
+	return AddItemResponse{Response: "success", Item: item, Quotation: q}, nil
+}
+
+// DeleteItemRequest takes a line off a quotation
+type DeleteItemRequest struct {
+	QuoteNum    int64  `json:"quote_num" validate:"required"`
+	ReceiptItem string `json:"receipt_item" validate:"required"`
+}
+
+func (r *DeleteItemRequest) Validate() error {
+	if r.QuoteNum == 0 {
+		return apperr.New(apperr.ValidationFailed, "quote_num is required")
+	}
+	if r.ReceiptItem == "" {
+		return apperr.New(apperr.ValidationFailed, "receipt_item is required")
+	}
+	return nil
+}
+
+// DeleteItem takes a line off the quotation
+func (h *Handler) DeleteItem(ctx context.Context, user logins.Users, req DeleteItemRequest) (QuotationResponse, error) {
+	q := sales.Quotation{QuoteNum: req.QuoteNum}
+	if err := h.Sales.DeleteQuoteItem(ctx, &q, req.ReceiptItem); err != nil {
+		return QuotationResponse{}, err
+	}
+	return QuotationResponse{Response: "success", Quotation: q}, nil
+}
+
+// ConvertRequest opens a receipt from a quotation, at today's prices when Reprice is set
+// Approver and ApToken are needed to convert a vat exempt quotation without the vat exempt right
+type ConvertRequest struct {
+	QuoteNum int64  `json:"quote_num" validate:"required"`
+	Reprice  bool   `json:"reprice"`
+	Approver string `json:"approver"`
+	ApToken  string `json:"ap_token"`
+}
+
+func (r *ConvertRequest) Validate() error {
+	if r.QuoteNum == 0 {
+		return apperr.New(apperr.ValidationFailed, "quote_num is required")
+	}
+	return nil
+}
+
+type ConvertResponse struct {
+	Response string           `json:"response"`
+	Receipt  sales.ReceiptLog `json:"receipt"`
+	// Held are the lines left for the cashier to scan and why
+	Held []sales.HeldLine `json:"held"`
+}
+
+// Convert opens a receipt on the user's till with the quotation's lines
+func (h *Handler) Convert(ctx context.Context, user logins.Users, req ConvertRequest) (ConvertResponse, error) {
+	q := sales.Quotation{QuoteNum: req.QuoteNum}
+	rcpt, held, err := h.Sales.ConvertQuotation(ctx, user, &q, req.Reprice, req.Approver, req.ApToken)
+	if err != nil {
+		return ConvertResponse{}, err
+	}
+	return ConvertResponse{Response: "success", Receipt: rcpt, Held: held}, nil
+}
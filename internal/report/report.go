@@ -0,0 +1,67 @@
+package report
+
+import (
+	"context"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+)
+
+// Handler serves the registers and reports kept for supervisors and regulators
+type Handler struct {
+	Sales *sales.Service
+}
+
+// NewHandler creates a report handler on the given sales service
+func NewHandler(svc *sales.Service) *Handler {
+	return &Handler{Sales: svc}
+}
+
+// RegisterRequest selects the days of a register or report, from and to are dates like 2006-01-02
+type RegisterRequest struct {
+	From string `query:"from"`
+	To   string `query:"to"`
+
+	from, to time.Time
+}
+
+func (r *RegisterRequest) Validate() error {
+	var err error
+	if r.from, err = time.ParseInLocation(time.DateOnly, r.From, time.Local); err != nil {
+		return apperr.New(apperr.ValidationFailed, "from must be a date like 2006-01-02")
+	}
+	if r.to, err = time.ParseInLocation(time.DateOnly, r.To, time.Local); err != nil {
+		return apperr.New(apperr.ValidationFailed, "to must be a date like 2006-01-02")
+	}
+	return nil
+}
+
+type RegisterResponse struct {
+	Response string            `json:"response"`
+	Register []sales.Dispensed `json:"register"`
+}
+
+// Dispensing lists the batches and prescriptions dispensed at the user's branch
+func (h *Handler) Dispensing(ctx context.Context, user logins.Users, req RegisterRequest) (RegisterResponse, error) {
+	register, err := h.Sales.DispensingRegister(ctx, user.Branch, req.from, req.to)
+	if err != nil {
+		return RegisterResponse{}, err
+	}
+	return RegisterResponse{Response: "success", Register: register}, nil
+}
+
+type AgeRestrictedResponse struct {
+	Response string           `json:"response"`
+	Tellers  []sales.AgeSales `json:"tellers"`
+}
+
+// AgeRestricted sums each teller's age restricted sales and overrides at the user's branch
+func (h *Handler) AgeRestricted(ctx context.Context, user logins.Users, req RegisterRequest) (AgeRestrictedResponse, error) {
+	tellers, err := h.Sales.AgeRestrictedReport(ctx, user.Branch, req.from, req.to)
+	if err != nil {
+		return AgeRestrictedResponse{}, err
+	}
+	return AgeRestrictedResponse{Response: "success", Tellers: tellers}, nil
+}
@@ -0,0 +1,122 @@
+package apperr
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Code is a stable, machine readable error code returned to clients
+type Code string
+
+const (
+	BadRequest          Code = "BAD_REQUEST"
+	ValidationFailed    Code = "VALIDATION_FAILED"
+	Unauthorized        Code = "UNAUTHORIZED"
+	Forbidden           Code = "FORBIDDEN"
+	ApprovalRequired    Code = "APPROVAL_REQUIRED"
+	NotFound            Code = "NOT_FOUND"
+	ProductNotFound     Code = "PRODUCT_NOT_FOUND"
+	MethodNotAllowed    Code = "METHOD_NOT_ALLOWED"
+	TillNotOpen         Code = "TILL_NOT_OPEN"
+	ReceiptNotOpen      Code = "RECEIPT_NOT_OPEN"
+	PendingOrders       Code = "PENDING_ORDERS"
+	EmptyOrder          Code = "EMPTY_ORDER"
+	InsufficientPayment Code = "INSUFFICIENT_PAYMENT"
+	InsufficientStock   Code = "INSUFFICIENT_STOCK"
+	TableOccupied       Code = "TABLE_OCCUPIED"
+	BatchExpired        Code = "BATCH_EXPIRED"
+	SerialSold          Code = "SERIAL_SOLD"
+	AgeCheckRequired    Code = "AGE_CHECK_REQUIRED"
+	QuoteClosed         Code = "QUOTE_CLOSED"
+	RequestInProgress   Code = "REQUEST_IN_PROGRESS"
+	IdempotencyKeyReuse Code = "IDEMPOTENCY_KEY_REUSED"
+	UpstreamUnavailable Code = "UPSTREAM_UNAVAILABLE"
+	Internal            Code = "INTERNAL"
+)
+
+// statuses maps each code to its http status
+var statuses = map[Code]int{
+	BadRequest:          http.StatusBadRequest,
+	ValidationFailed:    http.StatusBadRequest,
+	Unauthorized:        http.StatusUnauthorized,
+	Forbidden:           http.StatusForbidden,
+	ApprovalRequired:    http.StatusForbidden,
+	NotFound:            http.StatusNotFound,
+	ProductNotFound:     http.StatusNotFound,
+	MethodNotAllowed:    http.StatusMethodNotAllowed,
+	TillNotOpen:         http.StatusConflict,
+	ReceiptNotOpen:      http.StatusConflict,
+	PendingOrders:       http.StatusConflict,
+	EmptyOrder:          http.StatusConflict,
+	InsufficientPayment: http.StatusConflict,
+	InsufficientStock:   http.StatusConflict,
+	TableOccupied:       http.StatusConflict,
+	BatchExpired:        http.StatusConflict,
+	SerialSold:          http.StatusConflict,
+	AgeCheckRequired:    http.StatusPreconditionRequired,
+	QuoteClosed:         http.StatusConflict,
+	RequestInProgress:   http.StatusConflict,
+	IdempotencyKeyReuse: http.StatusUnprocessableEntity,
+	UpstreamUnavailable: http.StatusBadGateway,
+	Internal:            http.StatusInternalServerError,
+}
+
+// Error is an error with a stable code and a client facing message
+type Error struct {
+	Code    Code
+	Message string
+	Err     error
+}
+
+// New creates an error with the given code and message
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap creates an error with the given code and message wrapping a cause
+func Wrap(code Code, message string, err error) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status returns the http status for the error's code
+func (e *Error) Status() int {
+	if status, ok := statuses[e.Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Trace returns the underlying cause's message if any
+func (e *Error) Trace() string {
+	if e.Err == nil {
+		return ""
+	}
+	return e.Err.Error()
+}
+
+// From returns err as an *Error
+// errors without a code are reported as internal errors
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Wrap(Internal, "internal server error", err)
+}
+
+// Is reports whether err carries the given code
+func Is(err error, code Code) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
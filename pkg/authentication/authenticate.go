@@ -2,10 +2,8 @@ package authentication
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"log"
-	"os"
+	"sync"
 	"time"
 
 	"github.com/JohnnyKahiu/speedsales/poserver/pkg/grpc"
@@ -24,10 +22,13 @@ type User struct {
 	Email                string    `json:"email" name:"email" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
 	CompanyID            int64     `json:"company_id" name:"company_id" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
 	UserClass            string    `json:"user_class" name:"user_class" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'user'"`
+	Branch               string    `json:"branch" name:"branch" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
+	Role                 string    `json:"role" name:"role" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
 	password             string    `name:"password" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
 	RemoteLogin          bool      `json:"remote_login" name:"remote_login" type:"field" sql:"BOOL NOT NULL DEFAULT 'FALSE'"`
 	AdoptStockcount      bool      `json:"adopt_stockcount" name:"adopt_stockcount" type:"field" sql:"BOOL NOT NULL DEFAULT 'FALSE'"`
 	CompleteStockcount   bool      `json:"complete_stockcount" name:"complete_stockcount" type:"field" sql:"BOOL NOT NULL DEFAULT 'FALSE'"`
+	PosSettings          bool      `json:"pos_settings" name:"pos_settings" type:"field" sql:"BOOL NOT NULL DEFAULT 'FALSE'"`
 	StkLocation          string    `json:"stk_location" name:"stk_location" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'shop'"`
 	SessionID            string    `json:"session_id" name:"session_id" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
 	MakeSales            bool      `json:"make_sales"`
@@ -36,6 +37,7 @@ type User struct {
 	CashOffice           bool      `json:"cash_office"`
 	CashRollups          bool      `json:"cash_rollups"`
 	ApproveAcceptPayment bool      `json:"approve_accept_payment"`
+	VatExempt            bool      `json:"vat_exempt"`
 	PostDispatch         bool      `json:"post_dispatch" name:"post_dispatch" type:"field" sql:"BOOL NOT NULL DEFAULT 'FALSE'"`
 	ApproveDispatch      bool      `json:"approve_dispatch" name:"approve_dispatch" type:"field" sql:"BOOL NOT NULL DEFAULT 'FALSE'"`
 	PostReceive          bool      `json:"post_receive" name:"post_receive" type:"field" sql:"BOOL NOT NULL DEFAULT 'FALSE'"`
@@ -59,32 +61,31 @@ type User struct {
 	SessionIDs           []string  `name:"session_ids" `
 }
 
-var mySigningKey = os.Getenv("JWT_KEY")
-
-func ValidateJWT(tokenStr string) (User, bool) {
-	address := os.Getenv("LOGIN_RPC_ADDR")
-
-	loginSvc, err := grpc.NewAuthService(address)
+// verifier checks tokens for the api against the auth service's keys
+var verifier = sync.OnceValue(func() *Verifier {
+	loginSvc, err := grpc.NewAuthService(grpc.Default())
 	if err != nil {
-		log.Println("failed to create login service: %v", err)
-		return User{}, false
+		log.Fatalf("failed to create login service: %v", err)
 	}
+	return NewVerifier(loginSvc)
+})
 
-	rights, isValid := loginSvc.ValidateUserToken(context.Background(), fmt.Sprintf("%v", tokenStr))
-	if !isValid {
-		log.Println("authorization failed: %v", err)
-		return User{}, false
-	}
+// SetVerifier replaces the verifier fed by the auth service
+func SetVerifier(v *Verifier) {
+	verifier = func() *Verifier { return v }
+}
+
+// Start keeps the signing keys and revoked sessions fresh until ctx is done
+func Start(ctx context.Context) {
+	go verifier().Run(ctx)
+}
 
-	usr := User{}
-	err = json.Unmarshal([]byte(rights), &usr)
+// ValidateJWT verifies the token locally and returns the user's rights
+func ValidateJWT(tokenStr string) (User, bool) {
+	usr, err := verifier().Verify(context.Background(), tokenStr)
 	if err != nil {
-		log.Println("failed to unmarshal user rights: %v", err)
+		log.Printf("authorization failed: %v", err)
 		return User{}, false
 	}
-
-	fmt.Println("\n\n\t accept_payment =", usr.AcceptPayment)
-	fmt.Println("\t make_sales =", usr.MakeSales)
-
 	return usr, true
 }
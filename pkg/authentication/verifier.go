@@ -0,0 +1,281 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/proto/authkeys"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// expiryLayout is the layout of exp claims issued as text
+const expiryLayout = "2006-01-02 15:04"
+
+// missRetry limits key refreshes triggered by tokens with an unknown kid
+const missRetry = 30 * time.Second
+
+var (
+	ErrUnknownKey     = errors.New("token signed with unknown key")
+	ErrTokenExpired   = errors.New("token expired")
+	ErrSessionRevoked = errors.New("session revoked")
+	ErrInvalidToken   = errors.New("invalid token")
+)
+
+// KeySource serves the auth service's signing keys and revoked sessions
+type KeySource interface {
+	SigningKeys(ctx context.Context) ([]*authkeys.SigningKey, error)
+	RevokedSessions(ctx context.Context, since int64) ([]string, int64, error)
+	ValidateUserToken(ctx context.Context, token string) (string, bool)
+}
+
+// Verifier checks tokens locally against cached signing keys
+// keys and revoked sessions are refreshed from the auth service in the background
+type Verifier struct {
+	src KeySource
+
+	mu       sync.RWMutex
+	keys     map[string][]byte
+	revoked  map[string]bool
+	asOf     int64
+	lastMiss time.Time
+}
+
+// NewVerifier creates a verifier fed by src
+func NewVerifier(src KeySource) *Verifier {
+	return &Verifier{
+		src:     src,
+		keys:    map[string][]byte{},
+		revoked: map[string]bool{},
+	}
+}
+
+// Run refreshes the keys and revoked sessions until ctx is done
+func (v *Verifier) Run(ctx context.Context) {
+	if err := v.RefreshKeys(ctx); err != nil {
+		log.Println("failed to fetch signing keys    err =", err)
+	}
+	if err := v.RefreshRevoked(ctx); err != nil {
+		log.Println("failed to fetch revoked sessions    err =", err)
+	}
+
+	keyTicker := time.NewTicker(envDuration("AUTH_KEYS_REFRESH", 5*time.Minute))
+	defer keyTicker.Stop()
+
+	revokedTicker := time.NewTicker(envDuration("AUTH_REVOKED_REFRESH", 30*time.Second))
+	defer revokedTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-keyTicker.C:
+			if err := v.RefreshKeys(ctx); err != nil {
+				log.Println("failed to refresh signing keys    err =", err)
+			}
+
+		case <-revokedTicker.C:
+			if err := v.RefreshRevoked(ctx); err != nil {
+				log.Println("failed to refresh revoked sessions    err =", err)
+			}
+		}
+	}
+}
+
+// RefreshKeys replaces the cached keys with the auth service's current keys
+// the cached keys are kept when the fetch fails
+func (v *Verifier) RefreshKeys(ctx context.Context) error {
+	keys, err := v.src.SigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	fresh := map[string][]byte{}
+	for _, k := range keys {
+		if k.ExpiresAt > 0 && k.ExpiresAt < now {
+			continue
+		}
+		fresh[k.Kid] = k.Secret
+	}
+
+	v.mu.Lock()
+	v.keys = fresh
+	v.mu.Unlock()
+	return nil
+}
+
+// RefreshRevoked fetches sessions revoked since the last refresh
+func (v *Verifier) RefreshRevoked(ctx context.Context) error {
+	v.mu.RLock()
+	since := v.asOf
+	v.mu.RUnlock()
+
+	sessions, asOf, err := v.src.RevokedSessions(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	v.asOf = asOf
+	v.mu.Unlock()
+
+	v.Revoke(sessions...)
+	return nil
+}
+
+// Revoke marks sessions as revoked
+// used by the refresh loop and by pushed revocations
+func (v *Verifier) Revoke(sessions ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, s := range sessions {
+		v.revoked[s] = true
+	}
+}
+
+// Verify checks the token's signature, expiry and session
+// and returns the user rights it carries
+func (v *Verifier) Verify(ctx context.Context, tokenStr string) (User, error) {
+	user := User{}
+
+	v.mu.RLock()
+	loaded := len(v.keys) > 0
+	v.mu.RUnlock()
+
+	// ask the auth service until keys are available
+	if !loaded {
+		return v.verifyRemote(ctx, tokenStr)
+	}
+
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		return v.key(ctx, kid)
+	})
+	if err != nil {
+		var verr *jwt.ValidationError
+		if errors.As(err, &verr) {
+			switch {
+			case verr.Errors&jwt.ValidationErrorExpired != 0:
+				return user, ErrTokenExpired
+			case errors.Is(verr.Inner, ErrUnknownKey):
+				return user, ErrUnknownKey
+			}
+		}
+		return user, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return user, ErrInvalidToken
+	}
+
+	expiry, ok := claimExpiry(claims["exp"])
+	if !ok || time.Now().After(expiry) {
+		return user, ErrTokenExpired
+	}
+
+	session := fmt.Sprintf("%v", claims["session"])
+	v.mu.RLock()
+	revoked := v.revoked[session]
+	v.mu.RUnlock()
+	if revoked {
+		return user, ErrSessionRevoked
+	}
+
+	jStr, err := json.Marshal(claims["rights"])
+	if err != nil {
+		return user, err
+	}
+	if err := json.Unmarshal(jStr, &user); err != nil {
+		return user, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	// the role picks the user's discount limit, older tokens carry it beside the rights
+	if user.Role == "" {
+		user.Role, _ = claims["role"].(string)
+	}
+	return user, nil
+}
+
+// key returns the secret for kid
+// an unknown kid refreshes the keys in case they were rotated
+func (v *Verifier) key(ctx context.Context, kid string) ([]byte, error) {
+	v.mu.Lock()
+	secret, ok := v.keys[kid]
+	retry := !ok && time.Since(v.lastMiss) > missRetry
+	if retry {
+		v.lastMiss = time.Now()
+	}
+	v.mu.Unlock()
+
+	if ok {
+		return secret, nil
+	}
+	if !retry {
+		return nil, ErrUnknownKey
+	}
+
+	if err := v.RefreshKeys(ctx); err != nil {
+		log.Println("failed to refresh signing keys    err =", err)
+		return nil, ErrUnknownKey
+	}
+
+	v.mu.RLock()
+	secret, ok = v.keys[kid]
+	v.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return secret, nil
+}
+
+// verifyRemote validates the token with the auth service
+func (v *Verifier) verifyRemote(ctx context.Context, tokenStr string) (User, error) {
+	user := User{}
+
+	rights, isValid := v.src.ValidateUserToken(ctx, tokenStr)
+	if !isValid {
+		return user, ErrInvalidToken
+	}
+
+	if err := json.Unmarshal([]byte(rights), &user); err != nil {
+		return user, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return user, nil
+}
+
+// claimExpiry reads exp as unix seconds or as expiryLayout text
+func claimExpiry(exp interface{}) (time.Time, bool) {
+	switch e := exp.(type) {
+	case float64:
+		return time.Unix(int64(e), 0), true
+	case json.Number:
+		n, err := e.Int64()
+		return time.Unix(n, 0), err == nil
+	case string:
+		t, err := time.Parse(expiryLayout, e)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// envDuration reads a duration from the environment
+func envDuration(name string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,152 @@
+package barcode
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+)
+
+// rule kinds
+const (
+	// KindWeight labels carry the item's weight
+	KindWeight = "weight"
+	// KindPrice labels carry the item's price
+	KindPrice = "price"
+	// KindPack codes are a case or pack of a unit, GTIN-14 with the packaging indicator first
+	KindPack = "pack"
+)
+
+// Rule describes a barcode layout, positions are 0 based
+// Prefix and Length select the rule, "20" and 13 for EAN-13 scale labels
+// Value holds the weight or price with Decimals places, 3 for grams as kilograms
+type Rule struct {
+	Prefix     string `json:"prefix"`
+	Length     int    `json:"length"`
+	Kind       string `json:"kind"`
+	PluStart   int    `json:"plu_start"`
+	PluLen     int    `json:"plu_len"`
+	ValueStart int    `json:"value_start"`
+	ValueLen   int    `json:"value_len"`
+	Decimals   int    `json:"decimals"`
+	// TrimZeros drops the PLU's leading zeros before the product lookup
+	TrimZeros bool `json:"trim_zeros"`
+}
+
+// Scan is a parsed barcode
+// Code is the item code to look up, Weight is in kilograms
+type Scan struct {
+	Code   string
+	Kind   string
+	Weight float64
+	Price  money.Amount
+}
+
+// Defaults are the usual in-store EAN-13 layouts
+// prefixes 20 to 24 carry a 5 digit PLU and the weight in grams, 25 to 29 the price in cents
+// and GTIN-14 case codes with indicators 1 to 8 resolve to their unit's EAN-13
+func Defaults() []Rule {
+	var rules []Rule
+	for p := 20; p <= 29; p++ {
+		r := Rule{Prefix: strconv.Itoa(p), Length: 13, Kind: KindWeight, PluStart: 2, PluLen: 5, ValueStart: 7, ValueLen: 5, Decimals: 3}
+		if p >= 25 {
+			r.Kind = KindPrice
+			r.Decimals = 2
+		}
+		rules = append(rules, r)
+	}
+	for i := 1; i <= 8; i++ {
+		rules = append(rules, Rule{Prefix: strconv.Itoa(i), Length: 14, Kind: KindPack})
+	}
+	return rules
+}
+
+// Parse reads code with the first matching rule
+// returns false when no rule matches or the check digit is wrong
+func Parse(code string, rules []Rule) (Scan, bool) {
+	code = strings.TrimSpace(code)
+	if !digits(code) {
+		return Scan{}, false
+	}
+
+	for _, r := range rules {
+		if r.Length != len(code) || !strings.HasPrefix(code, r.Prefix) {
+			continue
+		}
+		if !ValidCheckDigit(code) {
+			return Scan{}, false
+		}
+		if s, ok := r.parse(code); ok {
+			return s, true
+		}
+	}
+	return Scan{}, false
+}
+
+func (r Rule) parse(code string) (Scan, bool) {
+	if r.Kind == KindPack {
+		// the unit's GTIN-13 is the code without the indicator and check digit
+		unit := code[1 : len(code)-1]
+		return Scan{Code: unit + CheckDigit(unit), Kind: KindPack}, true
+	}
+
+	if r.PluStart+r.PluLen > len(code) || r.ValueStart+r.ValueLen > len(code) || r.PluLen <= 0 || r.ValueLen <= 0 {
+		return Scan{}, false
+	}
+
+	s := Scan{Code: code[r.PluStart : r.PluStart+r.PluLen], Kind: r.Kind}
+	if r.TrimZeros {
+		s.Code = strings.TrimLeft(s.Code, "0")
+	}
+
+	v, err := strconv.ParseInt(code[r.ValueStart:r.ValueStart+r.ValueLen], 10, 64)
+	if err != nil {
+		return Scan{}, false
+	}
+	value := float64(v) / math.Pow10(r.Decimals)
+
+	switch r.Kind {
+	case KindWeight:
+		s.Weight = value
+	case KindPrice:
+		s.Price = money.New(value)
+	default:
+		return Scan{}, false
+	}
+	return s, true
+}
+
+// CheckDigit works out the GS1 check digit of the digits before it
+func CheckDigit(s string) string {
+	sum := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		d := int(s[i] - '0')
+		// from the right the digits are weighted 3, 1, 3, ...
+		if (len(s)-1-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return strconv.Itoa((10 - sum%10) % 10)
+}
+
+// ValidCheckDigit reports whether the code's last digit is its GS1 check digit
+func ValidCheckDigit(code string) bool {
+	if len(code) < 2 || !digits(code) {
+		return false
+	}
+	return CheckDigit(code[:len(code)-1]) == code[len(code)-1:]
+}
+
+func digits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// event types pushed to subscribed devices
+const (
+	CartItemAdded    = "cart.item_added"
+	CartRepriced     = "cart.repriced"
+	ReceiptSuspended = "receipt.suspended"
+	CustomerAttached = "receipt.customer_attached"
+	OrderItemAdded   = "order.item_added"
+	OrderItemDeleted = "order.item_deleted"
+	OrderState       = "order.state_changed"
+	CourseFired      = "order.course_fired"
+	BillClosed       = "bill.closed"
+	BillMerged       = "bill.merged"
+	BillSplit        = "bill.split"
+	PaymentCompleted = "payment.completed"
+	ReceiptVoided    = "receipt.voided"
+	ReceiptReturned  = "receipt.returned"
+	QuoteConverted   = "quote.converted"
+	DeliveryChanged  = "delivery.changed"
+	TableChanged     = "table.changed"
+)
+
+// Event is a change to a cart, bill or order
+type Event struct {
+	Type       string    `json:"type"`
+	Branch     string    `json:"branch"`
+	TillNum    int64     `json:"till_num,omitempty"`
+	ReceiptNum int64     `json:"receipt_num,omitempty"`
+	OrderNum   int64     `json:"order_num,omitempty"`
+	State      string    `json:"state,omitempty"`
+	Data       any       `json:"data,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// Scope selects the events a subscriber receives
+// zero fields match any event
+type Scope struct {
+	Branch     string
+	TillNum    int64
+	ReceiptNum int64
+}
+
+// Matches reports whether the event falls within the scope
+func (s Scope) Matches(e Event) bool {
+	if s.Branch != "" && s.Branch != e.Branch {
+		return false
+	}
+	if s.TillNum != 0 && s.TillNum != e.TillNum {
+		return false
+	}
+	if s.ReceiptNum != 0 && s.ReceiptNum != e.ReceiptNum {
+		return false
+	}
+	return true
+}
+
+// subBuffer is how many events a subscriber can fall behind before events are dropped
+const subBuffer = 32
+
+type subscriber struct {
+	scope Scope
+	ch    chan Event
+}
+
+// Hub fans out events to subscribers in this process
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[*subscriber]struct{}
+}
+
+// NewHub creates an event hub
+func NewHub() *Hub {
+	return &Hub{subs: map[*subscriber]struct{}{}}
+}
+
+// Subscribe returns a channel receiving events within scope
+// cancel must be called to unsubscribe
+func (h *Hub) Subscribe(scope Scope) (<-chan Event, func()) {
+	sub := &subscriber{scope: scope, ch: make(chan Event, subBuffer)}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs, sub)
+			h.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+	return sub.ch, cancel
+}
+
+// Publish sends an event to matching subscribers
+// slow subscribers miss events rather than blocking the sale
+// publishing on a nil hub does nothing
+func (h *Hub) Publish(e Event) {
+	if h == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subs {
+		if !sub.scope.Matches(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+		}
+	}
+}
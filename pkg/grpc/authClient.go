@@ -4,18 +4,18 @@ import (
 	"context"
 
 	pb "github.com/JohnnyKahiu/speed_sales_proto/user"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"github.com/JohnnyKahiu/speedsales/poserver/proto/authkeys"
 )
 
 type AuthService struct {
 	authClient pb.AuthServiceClient
+	keysClient authkeys.KeyServiceClient
 }
 
 // NewAuthService creates a new login_service grpc client
-func NewAuthService(authAddr string) (*AuthService, error) {
+func NewAuthService(reg *Registry) (*AuthService, error) {
 
-	conn, err := grpc.NewClient(authAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := reg.Conn(ServiceLogin)
 	if err != nil {
 		return nil, err
 	}
@@ -24,6 +24,7 @@ func NewAuthService(authAddr string) (*AuthService, error) {
 
 	return &AuthService{
 		authClient: client,
+		keysClient: authkeys.NewKeyServiceClient(conn),
 	}, nil
 }
 
@@ -46,3 +47,22 @@ func (s *AuthService) ValidateUserToken(ctx context.Context, token string) (stri
 
 	return resp.Rights, resp.Valid
 }
+
+// SigningKeys fetches the auth service's current token signing keys
+func (s *AuthService) SigningKeys(ctx context.Context) ([]*authkeys.SigningKey, error) {
+	resp, err := s.keysClient.SigningKeys(ctx, &authkeys.SigningKeysRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Keys, nil
+}
+
+// RevokedSessions fetches sessions revoked since the given unix time
+// returns the time the list is current as of
+func (s *AuthService) RevokedSessions(ctx context.Context, since int64) ([]string, int64, error) {
+	resp, err := s.keysClient.RevokedSessions(ctx, &authkeys.RevokedSessionsRequest{Since: since})
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Sessions, resp.AsOf, nil
+}
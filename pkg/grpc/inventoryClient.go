@@ -4,16 +4,16 @@ import (
 	"context"
 
 	pb "github.com/JohnnyKahiu/speed_sales_proto/inventory"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"github.com/JohnnyKahiu/speedsales/poserver/proto/stock"
 )
 
 type InventoryService struct {
 	inventoryClient pb.InventoryServiceClient
+	stockClient     stock.StockServiceClient
 }
 
-func NewInventoryService(address string) (*InventoryService, error) {
-	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func NewInventoryService(reg *Registry) (*InventoryService, error) {
+	conn, err := reg.Conn(ServiceInventory)
 	if err != nil {
 		return nil, err
 	}
@@ -22,6 +22,7 @@ func NewInventoryService(address string) (*InventoryService, error) {
 
 	return &InventoryService{
 		inventoryClient: client,
+		stockClient:     stock.NewStockServiceClient(conn),
 	}, nil
 }
 
@@ -33,3 +34,23 @@ func (s *InventoryService) SearchProduct(ctx context.Context, req *pb.SearchRequ
 
 	return resp, nil
 }
+
+// ReserveStock takes the lines' quantities off the inventory under the reference
+// returns false when the reference was already reserved and nothing more was taken
+func (s *InventoryService) ReserveStock(ctx context.Context, req *stock.ReserveStockRequest) (bool, error) {
+	resp, err := s.stockClient.ReserveStock(ctx, req)
+	if err != nil {
+		return false, err
+	}
+	return resp.Created, nil
+}
+
+// ReleaseStock returns the quantities reserved under the reference, or only the lines given
+// returns false when nothing was reserved under it
+func (s *InventoryService) ReleaseStock(ctx context.Context, reference string, lines []*stock.StockLine) (bool, error) {
+	resp, err := s.stockClient.ReleaseStock(ctx, &stock.ReleaseStockRequest{Reference: reference, Lines: lines})
+	if err != nil {
+		return false, err
+	}
+	return resp.Released, nil
+}
@@ -0,0 +1,350 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+)
+
+// names of the services dialed by the pos
+const (
+	ServiceLogin     = "login"
+	ServiceInventory = "inventory"
+)
+
+// Config describes how to reach one service
+// CAFile enables tls and CertFile with KeyFile add a client certificate for mtls
+type Config struct {
+	Name       string
+	Address    string
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+
+	// Timeout is the deadline of each attempt when the caller sets none
+	Timeout time.Duration
+	// Retries is how many times an unavailable call is retried
+	Retries int
+	Backoff time.Duration
+
+	// the breaker opens after BreakerFailures consecutive failures
+	BreakerFailures int
+	BreakerCooldown time.Duration
+
+	Keepalive time.Duration
+
+	// Dialer replaces the network dialer, used by tests with bufconn
+	Dialer func(ctx context.Context, address string) (net.Conn, error)
+}
+
+// ConfigFromEnv reads a service's config from variables starting with prefix
+// e.g. LOGIN_RPC_ADDR, LOGIN_RPC_CA, LOGIN_RPC_CERT, LOGIN_RPC_KEY, LOGIN_RPC_TIMEOUT
+func ConfigFromEnv(name, prefix string) Config {
+	return Config{
+		Name:            name,
+		Address:         os.Getenv(prefix + "_ADDR"),
+		CAFile:          os.Getenv(prefix + "_CA"),
+		CertFile:        os.Getenv(prefix + "_CERT"),
+		KeyFile:         os.Getenv(prefix + "_KEY"),
+		ServerName:      os.Getenv(prefix + "_SERVER_NAME"),
+		Timeout:         envDuration(prefix+"_TIMEOUT", 10*time.Second),
+		Retries:         envInt(prefix+"_RETRIES", 3),
+		Backoff:         envDuration(prefix+"_BACKOFF", 100*time.Millisecond),
+		BreakerFailures: envInt(prefix+"_BREAKER_FAILURES", 5),
+		BreakerCooldown: envDuration(prefix+"_BREAKER_COOLDOWN", 30*time.Second),
+		Keepalive:       envDuration(prefix+"_KEEPALIVE", 30*time.Second),
+	}
+}
+
+// Health is a service's connection and health check status
+type Health struct {
+	Address string `json:"address"`
+	State   string `json:"state"`
+	Serving string `json:"serving"`
+	Breaker string `json:"breaker"`
+}
+
+type client struct {
+	cfg     Config
+	conn    *grpc.ClientConn
+	breaker *breaker
+}
+
+// Registry holds one shared connection per service
+type Registry struct {
+	mu      sync.RWMutex
+	clients map[string]*client
+}
+
+// NewRegistry dials the configured services
+// connections are established lazily on the first call
+func NewRegistry(cfgs ...Config) (*Registry, error) {
+	r := &Registry{clients: map[string]*client{}}
+	for _, cfg := range cfgs {
+		if err := r.Register(cfg); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// NewRegistryFromEnv configures the login and inventory services from the environment
+func NewRegistryFromEnv() (*Registry, error) {
+	return NewRegistry(
+		ConfigFromEnv(ServiceLogin, "LOGIN_RPC"),
+		ConfigFromEnv(ServiceInventory, "INVENTORY_RPC"),
+	)
+}
+
+// Register adds a service, replacing any with the same name
+func (r *Registry) Register(cfg Config) error {
+	creds, err := transportCredentials(cfg)
+	if err != nil {
+		return fmt.Errorf("%v service: %w", cfg.Name, err)
+	}
+
+	c := &client{cfg: cfg, breaker: newBreaker(cfg.BreakerFailures, cfg.BreakerCooldown)}
+
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(c.intercept),
+	}
+	if cfg.Keepalive > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                cfg.Keepalive,
+			Timeout:             cfg.Keepalive / 3,
+			PermitWithoutStream: true,
+		}))
+	}
+	if cfg.Dialer != nil {
+		opts = append(opts, grpc.WithContextDialer(cfg.Dialer))
+	}
+
+	c.conn, err = grpc.NewClient(cfg.Address, opts...)
+	if err != nil {
+		return fmt.Errorf("%v service: %w", cfg.Name, err)
+	}
+
+	r.mu.Lock()
+	old := r.clients[cfg.Name]
+	r.clients[cfg.Name] = c
+	r.mu.Unlock()
+
+	if old != nil {
+		old.conn.Close()
+	}
+	return nil
+}
+
+// Conn returns the shared connection to the named service
+func (r *Registry) Conn(name string) (*grpc.ClientConn, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.clients[name]
+	if !ok {
+		return nil, fmt.Errorf("grpc service %q is not registered", name)
+	}
+	return c.conn, nil
+}
+
+// Health checks every registered service
+func (r *Registry) Health(ctx context.Context) map[string]Health {
+	r.mu.RLock()
+	clients := make([]*client, 0, len(r.clients))
+	for _, c := range r.clients {
+		clients = append(clients, c)
+	}
+	r.mu.RUnlock()
+
+	health := map[string]Health{}
+	for _, c := range clients {
+		health[c.cfg.Name] = c.health(ctx)
+	}
+	return health
+}
+
+// Close closes every connection
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for name, c := range r.clients {
+		errs = append(errs, c.conn.Close())
+		delete(r.clients, name)
+	}
+	return errors.Join(errs...)
+}
+
+var (
+	defaultMu  sync.Mutex
+	defaultReg *Registry
+)
+
+// SetDefault sets the registry used by the service clients
+func SetDefault(r *Registry) {
+	defaultMu.Lock()
+	defaultReg = r
+	defaultMu.Unlock()
+}
+
+// Default returns the registry set at startup
+// one is configured from the environment when none was set
+func Default() *Registry {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	if defaultReg == nil {
+		reg, err := NewRegistryFromEnv()
+		if err != nil {
+			log.Println("failed to configure grpc services    err =", err)
+			reg = &Registry{clients: map[string]*client{}}
+		}
+		defaultReg = reg
+	}
+	return defaultReg
+}
+
+// intercept applies the breaker, deadline and retries to unary calls
+func (c *client) intercept(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if !c.breaker.allow() {
+		return status.Errorf(codes.Unavailable, "%v service circuit breaker is open", c.cfg.Name)
+	}
+
+	var err error
+	backoff := c.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		err = c.invoke(ctx, method, req, reply, cc, invoker, opts...)
+		if !retryable(err) || attempt >= c.cfg.Retries {
+			break
+		}
+
+		log.Printf("%v retrying %v after %v    err = %v", c.cfg.Name, method, backoff, err)
+		select {
+		case <-ctx.Done():
+			c.breaker.record(true)
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	c.breaker.record(failure(err))
+	return err
+}
+
+// invoke makes one attempt with the configured deadline
+func (c *client) invoke(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if _, ok := ctx.Deadline(); !ok && c.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.Timeout)
+		defer cancel()
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// health reports the connection state and the service's health check
+func (c *client) health(ctx context.Context) Health {
+	h := Health{
+		Address: c.cfg.Address,
+		State:   c.conn.GetState().String(),
+		Breaker: c.breaker.state(),
+		Serving: "UNKNOWN",
+	}
+	if h.Breaker == BreakerOpen {
+		return h
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	resp, err := healthpb.NewHealthClient(c.conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	switch {
+	case status.Code(err) == codes.Unimplemented:
+		// the service doesn't implement health checks
+	case err != nil:
+		h.Serving = "NOT_SERVING"
+	default:
+		h.Serving = resp.Status.String()
+	}
+	h.State = c.conn.GetState().String()
+	return h
+}
+
+// retryable reports whether a failed call may be attempted again
+func retryable(err error) bool {
+	return status.Code(err) == codes.Unavailable
+}
+
+// failure reports whether err counts against the service's breaker
+func failure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+func transportCredentials(cfg Config) (credentials.TransportCredentials, error) {
+	if cfg.CAFile == "" && cfg.CertFile == "" {
+		return insecure.NewCredentials(), nil
+	}
+
+	conf := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: cfg.ServerName}
+
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %v", cfg.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(conf), nil
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || d < 0 {
+		return def
+	}
+	return d
+}
+
+func envInt(name string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
@@ -4,8 +4,6 @@ import (
 	"context"
 
 	protoUser "github.com/JohnnyKahiu/speed_sales_proto/user"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 type TillService struct {
@@ -13,8 +11,8 @@ type TillService struct {
 }
 
 // NewUserService creates a new inventory service
-func NewTillService(authAddr string) (*TillService, error) {
-	conn, err := grpc.NewClient(authAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func NewTillService(reg *Registry) (*TillService, error) {
+	conn, err := reg.Conn(ServiceLogin)
 	if err != nil {
 		return nil, err
 	}
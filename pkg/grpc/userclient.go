@@ -4,8 +4,6 @@ import (
 	"context"
 
 	protoUser "github.com/JohnnyKahiu/speed_sales_proto/user"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 type UserService struct {
@@ -13,8 +11,8 @@ type UserService struct {
 }
 
 // NewUserService creates a new inventory service
-func NewUserService(authAddr string) (*UserService, error) {
-	conn, err := grpc.NewClient(authAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func NewUserService(reg *Registry) (*UserService, error) {
+	conn, err := reg.Conn(ServiceLogin)
 	if err != nil {
 		return nil, err
 	}
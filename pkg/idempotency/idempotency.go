@@ -0,0 +1,151 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/database"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
+	"github.com/go-redis/redis"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Record holds a claimed idempotency key and the response stored for it
+// a zero Status means the request is still in progress
+type Record struct {
+	table     string    `name:"idempotency_keys" type:"table"`
+	Key       string    `json:"key" name:"key" type:"field" sql:"VARCHAR NOT NULL PRIMARY KEY"`
+	ReqHash   string    `json:"req_hash" name:"req_hash" type:"field" sql:"VARCHAR NOT NULL"`
+	Status    int       `json:"status" name:"status" type:"field" sql:"INT NOT NULL DEFAULT '0'"`
+	Body      string    `json:"body" name:"body" type:"field" sql:"TEXT NOT NULL DEFAULT ''"`
+	ExpiresAt time.Time `json:"expires_at" name:"expires_at" type:"field" sql:"TIMESTAMPTZ NOT NULL"`
+	CreatedAt time.Time `json:"created_at" name:"created_at" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT now()"`
+}
+
+// GenTable creates the idempotency keys table
+func GenTable() error {
+	var tblStruct Record
+	return database.CreateFromStruct(tblStruct)
+}
+
+// Store keeps idempotency keys and their responses
+type Store interface {
+	// Reserve claims rec.Key while its request runs, the claim lapses after lease
+	// when the key is already claimed it returns false and fills rec with the stored record
+	Reserve(ctx context.Context, rec *Record, lease time.Duration) (bool, error)
+	// Save stores the response for a claimed key and keeps it for ttl
+	Save(ctx context.Context, rec Record, ttl time.Duration) error
+	// Release frees a key still in progress so the request can be retried, stored responses are kept
+	Release(ctx context.Context, key string) error
+}
+
+// NewStore returns the redis store when caching is enabled else the postgres store
+func NewStore() Store {
+	if variables.Cache {
+		return NewRedisStore(variables.RdbCon)
+	}
+	return NewPgStore(database.PgPool)
+}
+
+// Querier runs queries on a postgres pool or transaction
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type pgStore struct {
+	db Querier
+}
+
+// NewPgStore creates a store on the idempotency_keys table
+func NewPgStore(db Querier) Store {
+	return pgStore{db: db}
+}
+
+func (s pgStore) Reserve(ctx context.Context, rec *Record, lease time.Duration) (bool, error) {
+	// claim the key unless an unexpired claim exists
+	sql := `INSERT INTO idempotency_keys (key, req_hash, status, body, expires_at)
+				VALUES ($1, $2, 0, '', now() + make_interval(secs => $3))
+			ON CONFLICT (key) DO UPDATE
+				SET req_hash = EXCLUDED.req_hash, status = 0, body = '', expires_at = EXCLUDED.expires_at, created_at = now()
+				WHERE idempotency_keys.expires_at < now()`
+
+	tag, err := s.db.Exec(ctx, sql, rec.Key, rec.ReqHash, lease.Seconds())
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() > 0 {
+		return true, nil
+	}
+
+	sql = `SELECT req_hash, status, body, expires_at FROM idempotency_keys WHERE key = $1`
+	err = s.db.QueryRow(ctx, sql, rec.Key).Scan(&rec.ReqHash, &rec.Status, &rec.Body, &rec.ExpiresAt)
+	return false, err
+}
+
+func (s pgStore) Save(ctx context.Context, rec Record, ttl time.Duration) error {
+	sql := `UPDATE idempotency_keys SET status = $1, body = $2, expires_at = now() + make_interval(secs => $3) WHERE key = $4`
+
+	_, err := s.db.Exec(ctx, sql, rec.Status, rec.Body, ttl.Seconds(), rec.Key)
+	return err
+}
+
+func (s pgStore) Release(ctx context.Context, key string) error {
+	sql := `DELETE FROM idempotency_keys WHERE key = $1 AND status = 0`
+
+	_, err := s.db.Exec(ctx, sql, key)
+	return err
+}
+
+type redisStore struct {
+	rdb *redis.Client
+}
+
+// NewRedisStore creates a store on the redis client
+func NewRedisStore(rdb *redis.Client) Store {
+	return redisStore{rdb: rdb}
+}
+
+func (s redisStore) Reserve(ctx context.Context, rec *Record, lease time.Duration) (bool, error) {
+	jStr, err := json.Marshal(rec)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := s.rdb.SetNX(rec.Key, jStr, lease).Result()
+	if err != nil || ok {
+		return ok, err
+	}
+
+	val, err := s.rdb.Get(rec.Key).Result()
+	if err == redis.Nil {
+		// claim expired between the calls, try again
+		return s.Reserve(ctx, rec, lease)
+	} else if err != nil {
+		return false, err
+	}
+
+	return false, json.Unmarshal([]byte(val), rec)
+}
+
+func (s redisStore) Save(ctx context.Context, rec Record, ttl time.Duration) error {
+	jStr, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(rec.Key, jStr, ttl).Err()
+}
+
+// releaseScript deletes the key only while its status is 0, the check and delete are one step in redis
+var releaseScript = redis.NewScript(`
+local val = redis.call('GET', KEYS[1])
+if val and cjson.decode(val).status == 0 then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+
+func (s redisStore) Release(ctx context.Context, key string) error {
+	return releaseScript.Run(s.rdb, []string{key}).Err()
+}
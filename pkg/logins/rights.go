@@ -0,0 +1,94 @@
+package logins
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Right names a user right by its json field on Users
+type Right string
+
+// rights required by the pos routes
+const (
+	// RightAuthenticated is held by every logged in user
+	RightAuthenticated Right = "authenticated"
+
+	RightMakeSales     Right = "make_sales"
+	RightAcceptPayment Right = "accept_payment"
+	RightApproveSales  Right = "approve_sales"
+	RightCashRollups   Right = "cash_rollups"
+	RightPriceChange   Right = "price_change"
+	RightSalesReturns  Right = "sales_returns"
+	RightLaybyes       Right = "laybyes"
+	RightProduce       Right = "produce"
+	RightPosSettings   Right = "pos_settings"
+	RightVatExempt     Right = "vat_exempt"
+
+	// rights an approver needs to allow what a user can't do alone
+	RightGrantApproveSales Right = "grant_approve_sales"
+	RightGrantPriceChange  Right = "grant_price_change"
+	RightGrantVatExempt    Right = "grant_vat_exempt"
+)
+
+// rightFields maps each boolean right to its field index on Users
+var rightFields = sync.OnceValue(func() map[Right]int {
+	fields := map[Right]int{}
+
+	t := reflect.TypeFor[Users]()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type.Kind() != reflect.Bool || !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[Right(name)] = i
+		}
+	}
+	return fields
+})
+
+// ValidRight reports whether r names a right on Users
+func ValidRight(r Right) bool {
+	if r == RightAuthenticated {
+		return true
+	}
+	_, ok := rightFields()[r]
+	return ok
+}
+
+// HasRight reports whether the user holds the right
+func (arg Users) HasRight(r Right) bool {
+	if r == RightAuthenticated {
+		return arg.Username != ""
+	}
+
+	i, ok := rightFields()[r]
+	if !ok {
+		return false
+	}
+	return reflect.ValueOf(arg).Field(i).Bool()
+}
+
+// HasAnyRight reports whether the user holds at least one of the rights
+func (arg Users) HasAnyRight(rights ...Right) bool {
+	for _, r := range rights {
+		if arg.HasRight(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Title names the right the way user screens show it, like 'Grant Price Change'
+func (r Right) Title() string {
+	words := strings.Split(string(r), "_")
+	for i, w := range words {
+		if w != "" {
+			words[i] = strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"time"
 
 	pb "github.com/JohnnyKahiu/speed_sales_proto/user"
@@ -17,9 +16,7 @@ func (arg *Users) FetchUser(ctx context.Context) error {
 	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
 	defer cancel()
 
-	address := os.Getenv("LOGIN_RPC_ADDR")
-
-	loginService, err := grpc.NewUserService(address)
+	loginService, err := grpc.NewUserService(grpc.Default())
 	if err != nil {
 		fmt.Println("failed to create login service    err =", err)
 		return err
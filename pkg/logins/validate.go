@@ -1,17 +1,7 @@
 package logins
 
 import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"log"
-	"os"
 	"time"
-
-	"github.com/JohnnyKahiu/speedsales/poserver/database"
-	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
-	"github.com/dgrijalva/jwt-go"
-	"github.com/go-redis/redis"
 )
 
 // PasswordConfig password configuration
@@ -72,6 +62,8 @@ type Users struct {
 	GrantMakeSales     bool `json:"grant_make_sales" name:"grant_make_sales" type:"field" sql:"BOOL NOT NULL DEFAULT 'FALSE'"`
 	GrantApproveSales  bool `json:"grant_approve_sales" name:"grant_approve_sales" type:"field" sql:"BOOL NOT NULL DEFAULT 'FALSE'"`
 	GrantAcceptPayment bool `json:"grant_accept_payment" name:"grant_accept_payment" type:"field" sql:"BOOL NOT NULL DEFAULT 'FALSE'"`
+	VatExempt          bool `json:"vat_exempt" name:"vat_exempt" type:"field" sql:"BOOL NOT NULL DEFAULT 'FALSE'"`
+	GrantVatExempt     bool `json:"grant_vat_exempt" name:"grant_vat_exempt" type:"field" sql:"BOOL NOT NULL DEFAULT 'FALSE'"`
 
 	CashOffice              bool `json:"cash_office" name:"cash_office" type:"field" sql:"BOOL NOT NULL DEFAULT 'FALSE'"`
 	CashRollups             bool `json:"cash_rollups" name:"cash_rollups" type:"field" sql:"BOOL NOT NULL DEFAULT 'FALSE'"`
@@ -141,95 +133,3 @@ type Users struct {
 	Passcode   string   `json:"passcode"`
 	SessionIDs []string `name:"session_ids" `
 }
-
-var mySigningKey = os.Getenv("speedsales_key")
-
-// getSessionID fetches sessionID
-func getSessionID(username string) ([]string, error) {
-	sql := `SELECT 
-				session_id::varchar
-			FROM users 
-			WHERE username = $1`
-
-	rows, err := database.PgPool.Query(context.Background(), sql, username)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	sessionIDstr := ""
-	for rows.Next() {
-		rows.Scan(&sessionIDstr)
-	}
-
-	var sessionID []string
-	json.Unmarshal([]byte(sessionIDstr), &sessionID)
-	return sessionID, nil
-}
-
-// ValidateJWT validates whether token is valid
-func ValidateJWT(tokenStr string) (Users, bool) {
-	var user Users
-	token, _ := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		// Don't forget to validate the alg is what you expect:
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", "HMAC")
-		}
-
-		// hmacSampleSecret is a []byte containing your secret, e.g. []byte("my_secret_key")
-		return mySigningKey, nil
-	})
-
-	// check if token is valid and get claims
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		var err error
-
-		// get today's date to compare with token's expiry
-		today := time.Now()
-
-		// get expiry date to compare
-		expiry, _ := time.Parse("2006-01-02 15:04", fmt.Sprintf("%v", claims["exp"]))
-
-		// check if token is expired
-		if today.After(expiry) {
-			return user, false
-		}
-
-		username := fmt.Sprintf("%v", claims["username"])
-
-		var sessionIDs []string
-		if variables.Cache {
-			// get users sessionID from redis
-			sessionIDstr, err := variables.RdbCon.Get(username).Result()
-			if err == redis.Nil {
-				return user, false
-			} else if err != nil {
-				return user, false
-			}
-
-			json.Unmarshal([]byte(sessionIDstr), &sessionIDs)
-		} else {
-			sessionIDs, err = getSessionID(username)
-			if err != nil {
-				return user, false
-			}
-		}
-
-		// check if session key exists for user
-		for _, sessionID := range sessionIDs {
-			if fmt.Sprintf("%v", claims["session"]) != sessionID {
-				log.Printf("\tsession id: %v is not same as \n\t claims id: %v\n\n", sessionID, claims["session"])
-				continue
-			}
-
-			// convert map to json
-			jsonStr, _ := json.Marshal(claims["rights"])
-
-			// convert json to struct
-			json.Unmarshal(jsonStr, &user)
-
-			return user, true
-		}
-	}
-	return user, false
-}
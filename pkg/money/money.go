@@ -0,0 +1,171 @@
+package money
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Scale is the number of minor units in a major unit, cents in a shilling
+const Scale = 100
+
+// Amount is a sum of money in minor units
+// it is written to JSON as a decimal number and stored in NUMERIC columns
+type Amount int64
+
+// New converts a major unit value to an Amount, rounding half away from zero
+func New(major float64) Amount {
+	return Amount(math.Round(major * Scale))
+}
+
+// Parse reads a decimal amount like "1250.50"
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	return fromRat(r), nil
+}
+
+// fromRat rounds r to minor units half away from zero
+func fromRat(r *big.Rat) Amount {
+	r = new(big.Rat).Mul(r, big.NewRat(Scale, 1))
+	num, den := r.Num(), r.Denom()
+
+	q, m := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2)).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return Amount(q.Int64())
+}
+
+// Float returns the amount in major units
+// for display and rate calculations only, never for sums
+func (a Amount) Float() float64 {
+	return float64(a) / Scale
+}
+
+// Mul multiplies the amount by a quantity, rounding to minor units
+func (a Amount) Mul(qty float64) Amount {
+	return Amount(math.Round(float64(a) * qty))
+}
+
+// Percent returns pct percent of the amount, rounding to minor units
+func (a Amount) Percent(pct float64) Amount {
+	return Amount(math.Round(float64(a) * pct / 100))
+}
+
+// Round rounds the amount to the nearest multiple of step, halves going up
+// a zero step leaves the amount as it is
+func (a Amount) Round(step Amount) Amount {
+	if step <= 0 {
+		return a
+	}
+	rem := a % step
+	if rem < 0 {
+		rem += step
+	}
+	down := a - rem
+	if rem*2 >= step {
+		return down + step
+	}
+	return down
+}
+
+// String formats the amount with two decimals, like "-12.30"
+func (a Amount) String() string {
+	sign := ""
+	v := int64(a)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/Scale, v%Scale)
+}
+
+// MarshalJSON writes the amount as a decimal number
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON reads a decimal number or a quoted decimal
+// floats saved before amounts were kept in minor units are rounded to the cent
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if string(data) == "null" {
+		return nil
+	}
+
+	v, err := Parse(string(data))
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// ScanNumeric implements pgtype.NumericScanner
+func (a *Amount) ScanNumeric(n pgtype.Numeric) error {
+	if !n.Valid {
+		*a = 0
+		return nil
+	}
+	if n.NaN || n.InfinityModifier != pgtype.Finite {
+		return fmt.Errorf("cannot scan %v into an amount", n)
+	}
+
+	r := new(big.Rat).SetInt(n.Int)
+	exp := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(n.Exp))), nil))
+	if n.Exp < 0 {
+		r.Quo(r, exp)
+	} else {
+		r.Mul(r, exp)
+	}
+	*a = fromRat(r)
+	return nil
+}
+
+// NumericValue implements pgtype.NumericValuer
+func (a Amount) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(int64(a)), Exp: -2, Valid: true}, nil
+}
+
+// ScanFloat64 implements pgtype.Float64Scanner for columns not yet migrated to NUMERIC
+func (a *Amount) ScanFloat64(f pgtype.Float8) error {
+	if !f.Valid {
+		*a = 0
+		return nil
+	}
+	v, err := Parse(strconv.FormatFloat(f.Float64, 'f', -1, 64))
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Float64Value implements pgtype.Float64Valuer
+func (a Amount) Float64Value() (pgtype.Float8, error) {
+	return pgtype.Float8{Float64: a.Float(), Valid: true}, nil
+}
+
+func abs(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
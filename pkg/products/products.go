@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
+	"strings"
 
 	pb "github.com/JohnnyKahiu/speed_sales_proto/inventory"
 	"github.com/JohnnyKahiu/speedsales/poserver/pkg/grpc"
@@ -27,13 +27,75 @@ type StockMaster struct {
 	Disc             float64 `json:"Disc" `
 	Label            string  `json:"label" `
 	Bal              float64 `json:"bal" `
+	Category         string  `json:"category"`
+	// LocationBal is the balance held at each stock location, Bal is the total
+	LocationBal map[string]float64 `json:"location_bal"`
+	// Barcodes are the product's own codes besides its item code
+	Barcodes []string `json:"barcodes"`
+	// BranchPrices overrides the till price at the branches listed
+	BranchPrices map[string]float64 `json:"branch_prices"`
+	// ModifierGroups are the options the product is ordered with
+	ModifierGroups []ModifierGroup `json:"modifier_groups"`
+	// TrackBatches needs a batch and expiry on each line, PrescriptionOnly needs a prescription
+	TrackBatches     bool `json:"track_batches"`
+	PrescriptionOnly bool `json:"prescription_only"`
+	// Serialized needs a serial number or IMEI for each unit sold
+	Serialized bool `json:"serialized"`
+	// MinAge restricts the product to customers of the age and over, 0 for everyone
+	MinAge int `json:"min_age"`
+}
+
+// ModifierGroup is a set of options like toppings or how the product is cooked
+// Min and Max bound how many are chosen, a zero Max allows any number
+type ModifierGroup struct {
+	Name    string     `json:"name"`
+	Min     int        `json:"min"`
+	Max     int        `json:"max"`
+	Options []Modifier `json:"options"`
+}
+
+// Modifier is an option of a group, Price is added to the product's price
+type Modifier struct {
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+// PriceAt returns the product's till price at branch
+func (p StockMaster) PriceAt(branch string) float64 {
+	if price, ok := p.BranchPrices[branch]; ok && price > 0 {
+		return price
+	}
+	return p.TillPrice
+}
+
+// BalAt returns the product's balance at the stock location
+// products without location balances report their total
+func (p StockMaster) BalAt(location string) float64 {
+	if bal, ok := p.LocationBal[location]; ok {
+		return bal
+	}
+	return p.Bal
+}
+
+// Modifier finds the option of the product's modifier group
+func (p StockMaster) Modifier(group, name string) (Modifier, bool) {
+	for _, g := range p.ModifierGroups {
+		if !strings.EqualFold(g.Name, group) {
+			continue
+		}
+		for _, m := range g.Options {
+			if strings.EqualFold(m.Name, name) {
+				return m, true
+			}
+		}
+	}
+	return Modifier{}, false
 }
 
 // Fetch gets stock data from inventory service
 // Returns an error if it fails
 func (p *StockMaster) Fetch(ctx context.Context) error {
-	address := os.Getenv("INVENTORY_RPC_ADDR")
-	inventoryService, err := grpc.NewInventoryService(address)
+	inventoryService, err := grpc.NewInventoryService(grpc.Default())
 	if err != nil {
 		return err
 	}
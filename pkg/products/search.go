@@ -0,0 +1,259 @@
+package products
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	pb "github.com/JohnnyKahiu/speed_sales_proto/inventory"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/grpc"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
+	"github.com/go-redis/redis"
+)
+
+// search paging
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// CatalogTTL is how long a branch's catalog is kept in the cache
+var CatalogTTL = 5 * time.Minute
+
+// SearchQuery selects products by name, code, barcode or category
+// Branch and StkLocation pick the price list and stock balances
+type SearchQuery struct {
+	Query       string `json:"query"`
+	Barcode     string `json:"barcode"`
+	Category    string `json:"category"`
+	Branch      string `json:"branch"`
+	StkLocation string `json:"stk_location"`
+	Limit       int    `json:"limit"`
+	Offset      int    `json:"offset"`
+}
+
+// SearchResult holds a page of products
+// Total counts all the matches and Categories lists their categories
+type SearchResult struct {
+	Products   []StockMaster `json:"products"`
+	Categories []string      `json:"categories"`
+	Total      int           `json:"total"`
+}
+
+// Search finds products from the cached catalog when caching is on, otherwise from the inventory service
+func Search(ctx context.Context, q SearchQuery) (SearchResult, error) {
+	if variables.Cache && variables.RdbCon != nil {
+		catalog, err := cachedCatalog(ctx, q.Branch, q.StkLocation)
+		if err == nil {
+			return Filter(catalog, q), nil
+		}
+		log.Println("error loading cached catalog, searching the inventory service    err =", err)
+	}
+
+	var res SearchResult
+	err := query(ctx, q, &res)
+	return res, err
+}
+
+// cachedCatalog gets the products at the branch's stock location, loading them from inventory when not cached
+func cachedCatalog(ctx context.Context, branch, location string) ([]StockMaster, error) {
+	key := fmt.Sprintf("catalog:%s:%s", branch, location)
+
+	var catalog []StockMaster
+	rows, err := variables.RdbCon.Get(key).Result()
+	if err == nil {
+		err = json.Unmarshal([]byte(rows), &catalog)
+		return catalog, err
+	}
+	if err != redis.Nil {
+		return nil, err
+	}
+
+	// an empty query lists the whole catalog
+	var res SearchResult
+	if err := query(ctx, SearchQuery{Branch: branch, StkLocation: location, Limit: -1}, &res); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(res.Products)
+	if err != nil {
+		return nil, err
+	}
+	if err := variables.RdbCon.Set(key, data, CatalogTTL).Err(); err != nil {
+		log.Println("error caching catalog    err =", err)
+	}
+	return res.Products, nil
+}
+
+// query sends q to the inventory service's search
+func query(ctx context.Context, q SearchQuery, res *SearchResult) error {
+	inventoryService, err := grpc.NewInventoryService(grpc.Default())
+	if err != nil {
+		return err
+	}
+
+	qs, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+
+	resp, err := inventoryService.SearchProduct(ctx, &pb.SearchRequest{QueryString: string(qs)})
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(resp.Result), res)
+}
+
+// Filter searches catalog for q
+// a barcode matches the item code or one of the product's barcodes exactly
+// names and codes match by prefix first, then words starting with the query, then anywhere, then loosely
+func Filter(catalog []StockMaster, q SearchQuery) SearchResult {
+	type match struct {
+		p     StockMaster
+		score int
+	}
+
+	text := strings.ToLower(strings.TrimSpace(q.Query))
+	var matches []match
+	for _, p := range catalog {
+		if q.Category != "" && !strings.EqualFold(p.Category, q.Category) {
+			continue
+		}
+		if q.Barcode != "" && !hasBarcode(p, q.Barcode) {
+			continue
+		}
+
+		score := 0
+		if text != "" {
+			score = rank(p, text)
+			if score < 0 {
+				continue
+			}
+		}
+		matches = append(matches, match{p, score})
+	}
+
+	sort.SliceStable(matches, func(a, b int) bool {
+		if matches[a].score != matches[b].score {
+			return matches[a].score < matches[b].score
+		}
+		return matches[a].p.ItemName < matches[b].p.ItemName
+	})
+
+	res := SearchResult{Products: []StockMaster{}, Categories: []string{}, Total: len(matches)}
+	seen := map[string]bool{}
+	for _, m := range matches {
+		if m.p.Category != "" && !seen[m.p.Category] {
+			seen[m.p.Category] = true
+			res.Categories = append(res.Categories, m.p.Category)
+		}
+	}
+	sort.Strings(res.Categories)
+
+	limit := q.Limit
+	switch {
+	case limit < 0:
+		limit = len(matches)
+	case limit == 0:
+		limit = DefaultLimit
+	case limit > MaxLimit:
+		limit = MaxLimit
+	}
+	for i := max(q.Offset, 0); i < len(matches) && len(res.Products) < limit; i++ {
+		res.Products = append(res.Products, matches[i].p)
+	}
+	return res
+}
+
+func hasBarcode(p StockMaster, code string) bool {
+	code = strings.TrimSpace(code)
+	if p.ItemCode == code {
+		return true
+	}
+	for _, b := range p.Barcodes {
+		if b == code {
+			return true
+		}
+	}
+	return false
+}
+
+// rank scores how well the product matches text, lower is better and -1 is no match
+// codes and sizes are matched exactly so only queries with letters match loosely
+func rank(p StockMaster, text string) int {
+	name := strings.ToLower(p.ItemName)
+	code := strings.ToLower(p.ItemCode)
+
+	switch {
+	case strings.HasPrefix(name, text) || strings.HasPrefix(code, text):
+		return 0
+	case wordPrefix(name, text):
+		return 1
+	case strings.Contains(name, text):
+		return 2
+	case len(text) >= 3 && strings.IndexFunc(text, unicode.IsLetter) >= 0 && fuzzy(name, text):
+		return 3
+	}
+	return -1
+}
+
+func wordPrefix(name, text string) bool {
+	for _, w := range strings.Fields(name) {
+		if strings.HasPrefix(w, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzy matches each word of text to a word of name allowing a typo for every four letters
+func fuzzy(name, text string) bool {
+	words := strings.Fields(name)
+	for _, t := range strings.Fields(text) {
+		allowed := max(len(t)/4, 1)
+
+		found := false
+		for _, w := range words {
+			// compare against the starts of longer words so partly typed words still match
+			for n := max(len(t)-allowed, 1); n <= min(len(t)+allowed, len(w)) && !found; n++ {
+				found = distance(w[:n], t) <= allowed
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// distance is the levenshtein distance between a and b
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,54 @@
+package products
+
+import (
+	"context"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/grpc"
+	"github.com/JohnnyKahiu/speedsales/poserver/proto/stock"
+)
+
+// StockLine is a quantity of an item taken from stock
+type StockLine struct {
+	ItemCode string  `json:"item_code"`
+	Quantity float64 `json:"quantity"`
+}
+
+// Reservation takes a sale's quantities off the stock at a branch's location
+// Reference identifies the sale so retries and releases find it
+type Reservation struct {
+	Reference   string      `json:"reference"`
+	Branch      string      `json:"branch"`
+	StkLocation string      `json:"stk_location"`
+	Lines       []StockLine `json:"lines"`
+}
+
+// Reserve takes the reservation's quantities off the inventory service's stock
+// returns false when the reference was already reserved
+func (r Reservation) Reserve(ctx context.Context) (bool, error) {
+	inventoryService, err := grpc.NewInventoryService(grpc.Default())
+	if err != nil {
+		return false, err
+	}
+
+	req := &stock.ReserveStockRequest{Reference: r.Reference, Branch: r.Branch, StkLocation: r.StkLocation}
+	for _, l := range r.Lines {
+		req.Lines = append(req.Lines, &stock.StockLine{ItemCode: l.ItemCode, Quantity: l.Quantity})
+	}
+	return inventoryService.ReserveStock(ctx, req)
+}
+
+// Release returns the stock reserved under reference to the inventory service
+// lines give back part of the reservation, none gives back all of it
+func Release(ctx context.Context, reference string, lines []StockLine) error {
+	inventoryService, err := grpc.NewInventoryService(grpc.Default())
+	if err != nil {
+		return err
+	}
+
+	var released []*stock.StockLine
+	for _, l := range lines {
+		released = append(released, &stock.StockLine{ItemCode: l.ItemCode, Quantity: l.Quantity})
+	}
+	_, err = inventoryService.ReleaseStock(ctx, reference, released)
+	return err
+}
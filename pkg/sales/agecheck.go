@@ -0,0 +1,88 @@
+package sales
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+)
+
+// AgeCheck records how an age restricted line was allowed, by the customer's date of birth or an approver's override
+type AgeCheck struct {
+	MinAge     int        `json:"min_age"`
+	BirthDate  *time.Time `json:"birth_date,omitempty"`
+	Approver   string     `json:"approver,omitempty"`
+	ApToken    string     `json:"-"`
+	VerifiedBy string     `json:"verified_by"`
+	VerifiedAt time.Time  `json:"verified_at"`
+}
+
+// ageOn is the age of someone born on birth at t
+func ageOn(birth, t time.Time) int {
+	age := t.Year() - birth.Year()
+	if t.Month() < birth.Month() || (t.Month() == birth.Month() && t.Day() < birth.Day()) {
+		age--
+	}
+	return age
+}
+
+// inHours reports whether t's time of day is within start and end ("22:00")
+// the hours can run past midnight, unset or invalid hours are never in
+func inHours(start, end string, t time.Time) bool {
+	if start == "" || end == "" {
+		return false
+	}
+	s, err1 := time.Parse("15:04", start)
+	e, err2 := time.Parse("15:04", end)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	from := s.Hour()*60 + s.Minute()
+	to := e.Hour()*60 + e.Minute()
+	now := t.Hour()*60 + t.Minute()
+	if from <= to {
+		return now >= from && now < to
+	}
+	// the hours run past midnight
+	return now >= from || now < to
+}
+
+// AgeSales sums a teller's posted age restricted lines and the ones an approver overrode
+type AgeSales struct {
+	Poster    string       `json:"poster"`
+	Lines     int          `json:"lines"`
+	Verified  int          `json:"verified"`
+	Overrides int          `json:"overrides"`
+	Total     money.Amount `json:"total"`
+}
+
+// AgeRestricted sums the age restricted lines posted at the receipt's branch between from and to per teller
+func (arg *ReceiptLog) AgeRestricted(ctx context.Context, db Querier, from, to time.Time) ([]AgeSales, error) {
+	sql := `SELECT s.poster, count(*), count(*) FILTER (WHERE item->'age_check' ? 'approver'),
+				coalesce(sum((item->>'total')::numeric), 0)
+			FROM salestrace s CROSS JOIN jsonb_array_elements(coalesce(s.cart, '[]'::jsonb)) item
+			WHERE s.branch = $1 AND s.state = 'POSTED' AND s.trans_date >= $2 AND s.trans_date < $3
+				AND item->>'state' NOT IN ('DELETED', 'VOIDED') AND item ? 'age_check'
+			GROUP BY s.poster
+			ORDER BY s.poster`
+
+	rows, err := db.Query(ctx, sql, arg.Branch, from, to)
+	if err != nil {
+		log.Println("sql error. ReceiptLog->AgeRestricted()    err =", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := []AgeSales{}
+	for rows.Next() {
+		var a AgeSales
+		if err := rows.Scan(&a.Poster, &a.Lines, &a.Overrides, &a.Total); err != nil {
+			return nil, err
+		}
+		a.Verified = a.Lines - a.Overrides
+		report = append(report, a)
+	}
+	return report, rows.Err()
+}
@@ -0,0 +1,147 @@
+package sales
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/jackc/pgx/v5"
+)
+
+// FirstCourse is sent to the kitchen when the order is completed, lines without a course go with it
+const FirstCourse = 1
+
+// Modifier is an option chosen for a line, like no onions or extra cheese
+// Price is what it adds to the line's unit price, set from the product
+type Modifier struct {
+	Group string       `json:"group"`
+	Name  string       `json:"name"`
+	Price money.Amount `json:"price"`
+}
+
+// priceModifiers checks the line's modifiers against the product's modifier groups
+// returns the product priced with the modifiers chosen
+func (arg *Sales) priceModifiers(p products.StockMaster) (products.StockMaster, error) {
+	chosen := map[string]int{}
+	for i, m := range arg.Modifiers {
+		opt, ok := p.Modifier(m.Group, m.Name)
+		if !ok {
+			return p, apperr.New(apperr.ValidationFailed, fmt.Sprintf("%v is not a %v option for %v", m.Name, m.Group, p.ItemName))
+		}
+		arg.Modifiers[i].Price = money.New(opt.Price)
+		p.TillPrice += opt.Price
+		chosen[m.Group]++
+	}
+
+	for _, g := range p.ModifierGroups {
+		n := 0
+		for group, count := range chosen {
+			if strings.EqualFold(group, g.Name) {
+				n += count
+			}
+		}
+		if n < g.Min {
+			return p, apperr.New(apperr.ValidationFailed, fmt.Sprintf("choose at least %d %v for %v", g.Min, g.Name, p.ItemName))
+		}
+		if g.Max > 0 && n > g.Max {
+			return p, apperr.New(apperr.ValidationFailed, fmt.Sprintf("choose at most %d %v for %v", g.Max, g.Name, p.ItemName))
+		}
+	}
+	return p, nil
+}
+
+// FireLines marks the order's held lines up to course as fired at
+// returns the lines fired
+func FireLines(items []Sales, course int, at time.Time) []Sales {
+	var fired []Sales
+	for i, item := range items {
+		if item.State != "pending" || item.FiredAt != nil || max(item.Course, FirstCourse) > course {
+			continue
+		}
+		items[i].FiredAt = &at
+		fired = append(fired, items[i])
+	}
+	return fired
+}
+
+// Fire releases the completed order's held lines up to course
+// loads the order's bill, branch and till
+// send gets the lines before they're marked fired, so lines it fails to send stay held
+// returns the lines fired
+func (ord *Order) Fire(ctx context.Context, db DBPool, course int, send func([]Sales) error) ([]Sales, error) {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	fired, err := ord.fire(ctx, tx, course, send)
+	if err != nil {
+		return nil, err
+	}
+	return fired, tx.Commit(ctx)
+}
+
+// Send completes the order to ord.State and fires its first course in one transaction
+// the order is left as it was when send fails
+func (ord *Order) Send(ctx context.Context, db DBPool, send func([]Sales) error) ([]Sales, error) {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := ord.Complete(ctx, tx); err != nil {
+		return nil, err
+	}
+	fired, err := ord.fire(ctx, tx, FirstCourse, send)
+	if err != nil {
+		return nil, err
+	}
+	return fired, tx.Commit(ctx)
+}
+
+// fire marks the order's held lines up to course fired once send takes them
+func (ord *Order) fire(ctx context.Context, tx pgx.Tx, course int, send func([]Sales) error) ([]Sales, error) {
+	sql := `SELECT coalesce(order_items, '[]'::jsonb), state, receipt_num, branch, till_num
+			FROM salesorders
+			WHERE order_num = $1 AND state NOT IN ('voided', 'VOIDED', 'DELETED')
+			FOR UPDATE`
+
+	err := tx.QueryRow(ctx, sql, ord.OrderNum).Scan(&ord.OrderItems, &ord.State, &ord.ReceiptNum, &ord.Branch, &ord.TillNum)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, apperr.New(apperr.NotFound, fmt.Sprintf("order %v not found", ord.OrderNum))
+		}
+		log.Println("sql error. Order->Fire()    err =", err)
+		return nil, err
+	}
+	if ord.State == "pending" {
+		return nil, apperr.New(apperr.PendingOrders, fmt.Sprintf("order %v hasn't been completed", ord.OrderNum))
+	}
+
+	fired := FireLines(ord.OrderItems, course, time.Now())
+	if len(fired) == 0 {
+		return nil, nil
+	}
+
+	items, err := json.Marshal(ord.OrderItems)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE salesorders SET order_items = $1 WHERE order_num = $2`, string(items), ord.OrderNum); err != nil {
+		log.Println("sql error. Order->Fire()    err =", err)
+		return nil, err
+	}
+
+	if err := send(fired); err != nil {
+		return nil, err
+	}
+	return fired, nil
+}
@@ -0,0 +1,184 @@
+package sales
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/database"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// MaxCustomers is the most customers a search returns
+const MaxCustomers = 50
+
+// kraPin is a KRA PIN like A123456789B
+var kraPin = regexp.MustCompile(`^[A-Z][0-9]{9}[A-Z]$`)
+
+// Customer is a buyer receipts can be attached to
+// KraPin goes on their fiscal receipts and ExemptCert exempts their sales from vat
+type Customer struct {
+	table      string    `name:"customers" type:"table"`
+	ID         int64     `json:"id" name:"id" type:"field" sql:"BIGSERIAL PRIMARY KEY"`
+	Name       string    `json:"name" name:"name" type:"field" sql:"VARCHAR NOT NULL"`
+	Phone      string    `json:"phone" name:"phone" type:"field" sql:"VARCHAR NOT NULL"`
+	Email      string    `json:"email" name:"email" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
+	KraPin     string    `json:"kra_pin" name:"kra_pin" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
+	ExemptCert string    `json:"exempt_cert" name:"exempt_cert" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
+	CreatedBy  string    `json:"created_by" name:"created_by" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
+	CreatedAt  time.Time `json:"created_at" name:"created_at" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT now()"`
+	UpdatedAt  time.Time `json:"updated_at" name:"updated_at" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT now()"`
+	unique     string    `name:"customers_phone_key" type:"constraint" sql:"UNIQUE (phone)"`
+}
+
+func genCustomerTbl() error {
+	return database.CreateFromStruct(Customer{})
+}
+
+// normalPhone keeps the phone's digits with local numbers in international form, 0712... as 254712...
+func normalPhone(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, phone)
+	if len(digits) == 10 && strings.HasPrefix(digits, "0") {
+		digits = "254" + digits[1:]
+	}
+	return digits
+}
+
+// Validate tidies the customer's details and checks them
+func (arg *Customer) Validate() error {
+	arg.Name = strings.TrimSpace(arg.Name)
+	arg.Phone = normalPhone(arg.Phone)
+	arg.Email = strings.ToLower(strings.TrimSpace(arg.Email))
+	arg.KraPin = strings.ToUpper(strings.TrimSpace(arg.KraPin))
+	arg.ExemptCert = strings.TrimSpace(arg.ExemptCert)
+
+	if arg.Name == "" {
+		return apperr.New(apperr.ValidationFailed, "customer name is required")
+	}
+	if len(arg.Phone) < 9 || len(arg.Phone) > 15 {
+		return apperr.New(apperr.ValidationFailed, "a valid phone number is required")
+	}
+	if arg.Email != "" && !strings.Contains(arg.Email, "@") {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("%v is not an email address", arg.Email))
+	}
+	if arg.KraPin != "" && !kraPin.MatchString(arg.KraPin) {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("%v is not a KRA PIN", arg.KraPin))
+	}
+	return nil
+}
+
+const customerColumns = `id, name, phone, email, kra_pin, exempt_cert, created_by, created_at, updated_at`
+
+func scanCustomer(row pgx.Row, c *Customer) error {
+	return row.Scan(&c.ID, &c.Name, &c.Phone, &c.Email, &c.KraPin, &c.ExemptCert, &c.CreatedBy, &c.CreatedAt, &c.UpdatedAt)
+}
+
+// Save creates the customer or updates their details when they have an id
+func (arg *Customer) Save(ctx context.Context, db Querier) error {
+	var err error
+	if arg.ID == 0 {
+		sql := `INSERT INTO customers(name, phone, email, kra_pin, exempt_cert, created_by)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				RETURNING ` + customerColumns
+
+		err = scanCustomer(db.QueryRow(ctx, sql, arg.Name, arg.Phone, arg.Email, arg.KraPin, arg.ExemptCert, arg.CreatedBy), arg)
+	} else {
+		sql := `UPDATE customers
+				SET name = $2, phone = $3, email = $4, kra_pin = $5, exempt_cert = $6, updated_at = now()
+				WHERE id = $1
+				RETURNING ` + customerColumns
+
+		err = scanCustomer(db.QueryRow(ctx, sql, arg.ID, arg.Name, arg.Phone, arg.Email, arg.KraPin, arg.ExemptCert), arg)
+		if err == pgx.ErrNoRows {
+			return apperr.New(apperr.NotFound, fmt.Sprintf("customer %v not found", arg.ID))
+		}
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("phone %v belongs to another customer", arg.Phone))
+	}
+	if err != nil {
+		log.Println("sql error. Customer->Save()    err =", err)
+	}
+	return err
+}
+
+// FetchCustomer loads a customer by id, or by phone when id is 0
+func FetchCustomer(ctx context.Context, db Querier, id int64, phone string) (Customer, error) {
+	sql := `SELECT ` + customerColumns + ` FROM customers WHERE id = $1 OR ($1 = 0 AND phone = $2)`
+
+	var c Customer
+	err := scanCustomer(db.QueryRow(ctx, sql, id, phone), &c)
+	if err == pgx.ErrNoRows {
+		return c, apperr.New(apperr.NotFound, "customer not found")
+	}
+	if err != nil {
+		log.Println("sql error. FetchCustomer()    err =", err)
+	}
+	return c, err
+}
+
+// SearchCustomers finds customers by name, phone, email or KRA PIN
+func SearchCustomers(ctx context.Context, db Querier, q string, limit int) ([]Customer, error) {
+	sql := `SELECT ` + customerColumns + `
+			FROM customers
+			WHERE name ILIKE '%' || $1 || '%' OR phone LIKE '%' || $2 || '%' OR email ILIKE $1 || '%' OR kra_pin = upper($1)
+			ORDER BY name
+			LIMIT $3`
+
+	// only search phones by digits, a name would match every phone
+	digits := normalPhone(q)
+	if digits == "" {
+		digits = "-"
+	}
+
+	rows, err := db.Query(ctx, sql, q, digits, limit)
+	if err != nil {
+		log.Println("sql error. SearchCustomers()    err =", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	customers := []Customer{}
+	for rows.Next() {
+		var c Customer
+		if err := scanCustomer(rows, &c); err != nil {
+			return nil, err
+		}
+		customers = append(customers, c)
+	}
+	return customers, rows.Err()
+}
+
+// SetCustomer attaches the receipt's customer and carries their PIN into its fiscal details
+func (arg *ReceiptLog) SetCustomer(ctx context.Context, db Querier) error {
+	sql := `UPDATE salestrace
+			SET
+				customer_id = $2
+				, etr = jsonb_set(coalesce(etr, '{}'::jsonb), '{customer_pin}', to_jsonb($3::varchar))
+				, last_updated = now()
+			WHERE receipt_num = $1 AND state IN ('pending', 'suspend', 'paying', 'pending payment')`
+
+	tag, err := db.Exec(ctx, sql, arg.ReceiptNum, arg.CustomerID, arg.Etr.CustomerPin)
+	if err != nil {
+		log.Println("sql error. ReceiptLog->SetCustomer()    err =", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open", arg.ReceiptNum))
+	}
+	return nil
+}
@@ -1,16 +1,195 @@
 package sales
 
-import "log"
+import (
+	"context"
+	"log"
+	"time"
 
-// // DBPool interface for database operations to allow mocking
-// type DBPool interface {
-// 	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
-// 	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
-// 	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
-// 	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
-// }
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
 
-// var db DBPool = database.PgPool
+// Querier holds the database operations shared by pools and transactions
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// DBPool interface for database operations to allow mocking
+// satisfied by *pgxpool.Pool and pgxmock.PgxPoolIface
+type DBPool interface {
+	Querier
+	Begin(ctx context.Context) (pgx.Tx, error)
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+// ReceiptRepository persists receipts (salestrace) and their carts
+type ReceiptRepository interface {
+	// Pending sets rcpt.ReceiptNum to the latest pending receipt of the till or 0
+	Pending(ctx context.Context, rcpt *ReceiptLog) error
+	// Create numbers and logs a new receipt
+	Create(ctx context.Context, rcpt *ReceiptLog) error
+	Fetch(ctx context.Context, rcpt *ReceiptLog) error
+	FetchAll(ctx context.Context, rcpt *ReceiptLog) error
+	ActiveCarts(ctx context.Context, tillNum int64) ([]ReceiptLog, error)
+	AddItem(ctx context.Context, rcpt *ReceiptLog, item Sales) error
+	Suspend(ctx context.Context, tillNum int64) error
+	NewBill(ctx context.Context, tillNum int64) error
+	Resume(ctx context.Context, rcpt *ReceiptLog) error
+	Merge(ctx context.Context, rcpt *ReceiptLog, receipts []int64) error
+	// Split moves lines of the bill to new receipts and returns them
+	Split(ctx context.Context, rcpt *ReceiptLog, split Split) ([]ReceiptLog, error)
+	CloseBill(ctx context.Context, rcpt *ReceiptLog) error
+	Void(ctx context.Context, rcpt *ReceiptLog) error
+	// Pay applies a tender and Tendered sums the tenders applied
+	Pay(ctx context.Context, rcpt *ReceiptLog, pay Payment) error
+	Tendered(ctx context.Context, receiptNum int64) (money.Amount, error)
+	// Post completes the receipt with rcpt's total and change
+	Post(ctx context.Context, rcpt *ReceiptLog) error
+	// Reprice rewrites the open receipt's cart with fn
+	Reprice(ctx context.Context, rcpt *ReceiptLog, fn func([]Sales) []Sales) error
+	// SetVatExempt records rcpt's VatExempt and VatExemptRef
+	SetVatExempt(ctx context.Context, rcpt *ReceiptLog) error
+	// SetCustomer records rcpt's CustomerID and their PIN in its fiscal details
+	SetCustomer(ctx context.Context, rcpt *ReceiptLog) error
+	// SetPayTill records the till rcpt is paid into
+	SetPayTill(ctx context.Context, rcpt *ReceiptLog) error
+	// OpenQuantity sums the item's quantity in the branch's open carts and orders
+	OpenQuantity(ctx context.Context, branch, itemCode string) (float64, error)
+	// Dispensed lists the batch and prescription lines posted at the branch in [from, to)
+	Dispensed(ctx context.Context, branch string, from, to time.Time) ([]Dispensed, error)
+	// SerialSales lists the posted receipts carrying serial, newest first
+	SerialSales(ctx context.Context, serial string) ([]SerialSale, error)
+	// Returned sums the quantities of the receipt's lines already returned by receipt item
+	Returned(ctx context.Context, receiptNum int64) (map[string]float64, error)
+	// Return numbers and posts the return receipt rcpt and adds its refund to the till's returns
+	// returns an error if it returns more of a line than is left on its ReturnTrace
+	Return(ctx context.Context, rcpt *ReceiptLog) error
+	// AgeRestricted sums the age restricted lines posted at the branch in [from, to) per teller
+	AgeRestricted(ctx context.Context, branch string, from, to time.Time) ([]AgeSales, error)
+}
+
+// PromotionRepository looks up the promotions running at a branch
+type PromotionRepository interface {
+	Active(ctx context.Context, branch string, at time.Time) ([]Promotion, error)
+}
+
+// OrderRepository persists sales orders (salesorders)
+type OrderRepository interface {
+	Items(ctx context.Context, ord *Order) error
+	AddItem(ctx context.Context, ord *Order, item Sales) ([]Sales, money.Amount, error)
+	DeleteItem(ctx context.Context, orderNum int64, receiptItem string) ([]Sales, money.Amount, error)
+	// Complete moves the order to ord.State and fires its first course
+	// the order is left as it was when send fails
+	Complete(ctx context.Context, ord *Order, send func([]Sales) error) ([]Sales, error)
+	// Fire releases the completed order's held lines up to course and returns them
+	// the lines stay held when send fails
+	Fire(ctx context.Context, ord *Order, course int, send func([]Sales) error) ([]Sales, error)
+	Voucher(ctx context.Context, orderNum int64) ([]OrderItem, error)
+	OrdersInBill(ctx context.Context, receiptNum int64) ([]Order, money.Amount, error)
+	ActiveOrders(ctx context.Context, poster string) ([]Order, error)
+	// SetChannel sets how the bill's open orders are served
+	SetChannel(ctx context.Context, receiptNum int64, channel string) error
+}
+
+// TillRepository persists tills (sales_till) and their cash position
+type TillRepository interface {
+	// Open creates a till for the teller unless one is already open
+	Open(ctx context.Context, till *Till) error
+	CashInTill(ctx context.Context, tillNum int64) (money.Amount, error)
+	// AddDiscount adds discounts given at the till to its cash summary
+	AddDiscount(ctx context.Context, tillNum int64, amount money.Amount) error
+}
+
+// OverrideRepository records manual price changes and discounts
+type OverrideRepository interface {
+	// Apply reprices the open receipt's cart with fn and records o with it
+	Apply(ctx context.Context, rcpt *ReceiptLog, o *PriceOverride, fn func([]Sales) []Sales) error
+}
+
+// VoucherRepository persists gift vouchers
+type VoucherRepository interface {
+	Create(ctx context.Context, v *GiftVoucher) error
+	Fetch(ctx context.Context, serial string) (GiftVoucher, error)
+}
+
+// ProductCatalog looks up products from the inventory service
+type ProductCatalog interface {
+	Fetch(ctx context.Context, itemCode string) (products.StockMaster, error)
+	Search(ctx context.Context, q products.SearchQuery) (products.SearchResult, error)
+}
+
+// TableRepository persists floor plans and the bills served at their tables
+type TableRepository interface {
+	SaveFloor(ctx context.Context, f *Floor) error
+	SaveTable(ctx context.Context, t *DiningTable) error
+	Floors(ctx context.Context, branch string) ([]Floor, error)
+	Table(ctx context.Context, id int64) (DiningTable, error)
+	// Seat attaches t's bill unless the table is serving another open bill
+	Seat(ctx context.Context, t *DiningTable) error
+	Transfer(ctx context.Context, t *DiningTable, waiter string) error
+	// Release frees the tables serving the bills and returns them
+	Release(ctx context.Context, receipts []int64) ([]DiningTable, error)
+	FloorState(ctx context.Context, branch string, floorID int64) ([]TableState, error)
+}
+
+// CustomerRepository persists the customer master
+type CustomerRepository interface {
+	Save(ctx context.Context, c *Customer) error
+	// Fetch loads a customer by id, or by phone when id is 0
+	Fetch(ctx context.Context, id int64, phone string) (Customer, error)
+	Search(ctx context.Context, q string, limit int) ([]Customer, error)
+}
+
+// QuotationRepository persists quotations
+type QuotationRepository interface {
+	// Create numbers and saves a new quotation
+	Create(ctx context.Context, q *Quotation) error
+	Fetch(ctx context.Context, q *Quotation) error
+	// Edit rewrites the open quotation's lines with fn
+	Edit(ctx context.Context, q *Quotation, fn func([]Sales) []Sales) error
+	// Close moves the open quotation to q.State, converted into q.ReceiptNum or cancelled
+	Close(ctx context.Context, q *Quotation) error
+}
+
+// DeliveryRepository persists bills booked for delivery and their riders
+type DeliveryRepository interface {
+	Create(ctx context.Context, d *Delivery) error
+	Fetch(ctx context.Context, receiptNum int64) (Delivery, error)
+	// Update records d unless the delivery has moved on from state from
+	Update(ctx context.Context, d *Delivery, from string) error
+	// Open lists the branch's deliveries on the road or with cash to settle
+	Open(ctx context.Context, branch string) ([]Delivery, error)
+}
+
+// StockKeeper reserves sold quantities on the inventory service
+type StockKeeper interface {
+	// Reserve takes the reservation's quantities, returning false when its reference already had them
+	Reserve(ctx context.Context, r products.Reservation) (bool, error)
+	// Release returns the lines of the stock reserved under reference, all of it without lines
+	// doing nothing when none was
+	Release(ctx context.Context, reference string, lines []products.StockLine) error
+}
+
+// UserDirectory looks up users from the login service
+type UserDirectory interface {
+	FetchUser(ctx context.Context, username string) (logins.Users, error)
+}
+
+// TillRegistrar records a teller's open till on the login service
+type TillRegistrar interface {
+	UpdateTill(ctx context.Context, username string, tillNum int64) error
+}
+
+// Publisher produces messages to the broker
+type Publisher interface {
+	Publish(ctx context.Context, topic, key string, payload []byte) error
+}
 
 func GenTables() error {
 	err := genTillTbl()
@@ -29,5 +208,33 @@ func GenTables() error {
 	if err != nil {
 		log.Fatalln("failed to generate order table err =", err)
 	}
+	err = genPromotionTbl()
+	if err != nil {
+		log.Fatalln("failed to generate promotions table err =", err)
+	}
+	err = genOverrideTbl()
+	if err != nil {
+		log.Fatalln("failed to generate price overrides table err =", err)
+	}
+	err = genFloorTbls()
+	if err != nil {
+		log.Fatalln("failed to generate floor tables err =", err)
+	}
+	err = genCustomerTbl()
+	if err != nil {
+		log.Fatalln("failed to generate customers table err =", err)
+	}
+	err = genQuotationTbl()
+	if err != nil {
+		log.Fatalln("failed to generate quotations table err =", err)
+	}
+	err = genDeliveryTbl()
+	if err != nil {
+		log.Fatalln("failed to generate deliveries table err =", err)
+	}
+	err = variables.AddIndustryMode()
+	if err != nil {
+		log.Fatalln("failed to add the branches industry mode err =", err)
+	}
 	return err
 }
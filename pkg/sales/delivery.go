@@ -0,0 +1,247 @@
+package sales
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/database"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// order channels, how an order is served
+const (
+	ChannelDineIn   = "dine_in"
+	ChannelTakeaway = "takeaway"
+	ChannelDelivery = "delivery"
+)
+
+// Channels lists the order channels
+var Channels = []string{ChannelDineIn, ChannelTakeaway, ChannelDelivery}
+
+// delivery states
+const (
+	DeliveryPending   = "pending"
+	DeliveryAssigned  = "assigned"
+	DeliveryPickedUp  = "picked_up"
+	DeliveryDelivered = "delivered"
+	DeliveryReturned  = "returned"
+)
+
+// deliveryFlow lists the states a delivery can move to from each state
+// a rider can be reassigned until the order is picked up
+var deliveryFlow = map[string][]string{
+	DeliveryPending:  {DeliveryAssigned},
+	DeliveryAssigned: {DeliveryAssigned, DeliveryPickedUp},
+	DeliveryPickedUp: {DeliveryDelivered, DeliveryReturned},
+}
+
+// DeliveryFeeCode is the item code of delivery fee lines
+const DeliveryFeeCode = "DELIVERY"
+
+// Delivery takes a bill to the customer's address by a rider
+// CashDue is what the rider collects at the door, settled into SettledTill when they're back
+type Delivery struct {
+	table       string       `name:"deliveries" type:"table"`
+	ReceiptNum  int64        `json:"receipt_num" name:"receipt_num" type:"field" sql:"BIGINT PRIMARY KEY"`
+	Branch      string       `json:"branch" name:"branch" type:"field" sql:"VARCHAR NOT NULL"`
+	CustomerID  int64        `json:"customer_id" name:"customer_id" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
+	Phone       string       `json:"phone" name:"phone" type:"field" sql:"VARCHAR NOT NULL"`
+	Address     string       `json:"address" name:"address" type:"field" sql:"VARCHAR NOT NULL"`
+	Note        string       `json:"note" name:"note" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
+	Fee         money.Amount `json:"fee" name:"fee" type:"field" sql:"NUMERIC(14,2) NOT NULL DEFAULT '0'"`
+	Rider       string       `json:"rider" name:"rider" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
+	State       string       `json:"state" name:"state" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'pending'"`
+	CashDue     money.Amount `json:"cash_due" name:"cash_due" type:"field" sql:"NUMERIC(14,2) NOT NULL DEFAULT '0'"`
+	SettledTill int64        `json:"settled_till" name:"settled_till" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
+	CreatedBy   string       `json:"created_by" name:"created_by" type:"field" sql:"VARCHAR NOT NULL"`
+	CreatedAt   time.Time    `json:"created_at" name:"created_at" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT now()"`
+	AssignedAt  *time.Time   `json:"assigned_at" name:"assigned_at" type:"field" sql:"TIMESTAMPTZ"`
+	PickedUpAt  *time.Time   `json:"picked_up_at" name:"picked_up_at" type:"field" sql:"TIMESTAMPTZ"`
+	DeliveredAt *time.Time   `json:"delivered_at" name:"delivered_at" type:"field" sql:"TIMESTAMPTZ"`
+	ReturnedAt  *time.Time   `json:"returned_at" name:"returned_at" type:"field" sql:"TIMESTAMPTZ"`
+	SettledAt   *time.Time   `json:"settled_at" name:"settled_at" type:"field" sql:"TIMESTAMPTZ"`
+}
+
+func genDeliveryTbl() error {
+	return database.CreateFromStruct(Delivery{})
+}
+
+// Validate tidies the delivery's address and phone and checks them
+func (arg *Delivery) Validate() error {
+	arg.Address = strings.TrimSpace(arg.Address)
+	arg.Note = strings.TrimSpace(arg.Note)
+	arg.Phone = normalPhone(arg.Phone)
+
+	if arg.Address == "" {
+		return apperr.New(apperr.ValidationFailed, "a delivery address is required")
+	}
+	if len(arg.Phone) < 9 || len(arg.Phone) > 15 {
+		return apperr.New(apperr.ValidationFailed, "a valid phone number is required for the rider")
+	}
+	if arg.Fee < 0 {
+		return apperr.New(apperr.ValidationFailed, "the delivery fee can't be negative")
+	}
+	return nil
+}
+
+// move sets the delivery's state and the time it got there
+// returns an error if it can't move there from its state
+func (arg *Delivery) move(to string, at time.Time) error {
+	if !slices.Contains(deliveryFlow[arg.State], to) {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("the delivery of receipt %v is %v, it can't be %v", arg.ReceiptNum, arg.State, to))
+	}
+
+	arg.State = to
+	switch to {
+	case DeliveryAssigned:
+		arg.AssignedAt = &at
+	case DeliveryPickedUp:
+		arg.PickedUpAt = &at
+	case DeliveryDelivered:
+		arg.DeliveredAt = &at
+	case DeliveryReturned:
+		arg.ReturnedAt = &at
+	}
+	return nil
+}
+
+// feeItem is the receipt item of the bill's delivery fee line
+func feeItem(receiptNum int64) string {
+	return fmt.Sprintf("delivery-%d", receiptNum)
+}
+
+// feeLine charges the delivery fee on the bill at the vat code given
+func (arg *Delivery) feeLine(vatAlpha string) Sales {
+	return Sales{
+		TransDate:   time.Now(),
+		ReceiptNum:  arg.ReceiptNum,
+		ItemCode:    DeliveryFeeCode,
+		ItemName:    "Delivery fee",
+		Quantity:    1,
+		Price:       arg.Fee,
+		Total:       arg.Fee,
+		VatAlpha:    vatAlpha,
+		State:       "pending",
+		ReceiptItem: feeItem(arg.ReceiptNum),
+	}
+}
+
+const deliveryColumns = `receipt_num, branch, customer_id, phone, address, note, fee, rider, state, cash_due, settled_till
+	, created_by, created_at, assigned_at, picked_up_at, delivered_at, returned_at, settled_at`
+
+func scanDelivery(row pgx.Row, d *Delivery) error {
+	return row.Scan(&d.ReceiptNum, &d.Branch, &d.CustomerID, &d.Phone, &d.Address, &d.Note, &d.Fee, &d.Rider, &d.State,
+		&d.CashDue, &d.SettledTill, &d.CreatedBy, &d.CreatedAt, &d.AssignedAt, &d.PickedUpAt, &d.DeliveredAt, &d.ReturnedAt, &d.SettledAt)
+}
+
+// Create books the bill's delivery
+func (arg *Delivery) Create(ctx context.Context, db Querier) error {
+	sql := `INSERT INTO deliveries(receipt_num, branch, customer_id, phone, address, note, fee, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING ` + deliveryColumns
+
+	err := scanDelivery(db.QueryRow(ctx, sql, arg.ReceiptNum, arg.Branch, arg.CustomerID, arg.Phone, arg.Address, arg.Note, arg.Fee, arg.CreatedBy), arg)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("receipt %v is already booked for delivery", arg.ReceiptNum))
+	}
+	if err != nil {
+		log.Println("sql error. Delivery->Create()    err =", err)
+	}
+	return err
+}
+
+// FetchDelivery loads the bill's delivery
+func FetchDelivery(ctx context.Context, db Querier, receiptNum int64) (Delivery, error) {
+	sql := `SELECT ` + deliveryColumns + ` FROM deliveries WHERE receipt_num = $1`
+
+	var d Delivery
+	err := scanDelivery(db.QueryRow(ctx, sql, receiptNum), &d)
+	if err == pgx.ErrNoRows {
+		return d, apperr.New(apperr.NotFound, fmt.Sprintf("receipt %v is not booked for delivery", receiptNum))
+	}
+	if err != nil {
+		log.Println("sql error. FetchDelivery()    err =", err)
+	}
+	return d, err
+}
+
+// Update records the delivery's rider, state, cash and times unless it has moved on from state from
+func (arg *Delivery) Update(ctx context.Context, db Querier, from string) error {
+	sql := `UPDATE deliveries
+			SET rider = $3, state = $4, cash_due = $5, settled_till = $6
+				, assigned_at = $7, picked_up_at = $8, delivered_at = $9, returned_at = $10, settled_at = $11
+			WHERE receipt_num = $1 AND state = $2`
+
+	tag, err := db.Exec(ctx, sql, arg.ReceiptNum, from, arg.Rider, arg.State, arg.CashDue, arg.SettledTill,
+		arg.AssignedAt, arg.PickedUpAt, arg.DeliveredAt, arg.ReturnedAt, arg.SettledAt)
+	if err != nil {
+		log.Println("sql error. Delivery->Update()    err =", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("the delivery of receipt %v is no longer %v", arg.ReceiptNum, from))
+	}
+	return nil
+}
+
+// OpenDeliveries lists the branch's deliveries still on the road or with cash to settle, oldest first
+func OpenDeliveries(ctx context.Context, db Querier, branch string) ([]Delivery, error) {
+	sql := `SELECT ` + deliveryColumns + `
+			FROM deliveries
+			WHERE branch = $1 AND (state IN ('pending', 'assigned', 'picked_up') OR (state = 'delivered' AND settled_at IS NULL))
+			ORDER BY created_at`
+
+	rows, err := db.Query(ctx, sql, branch)
+	if err != nil {
+		log.Println("sql error. OpenDeliveries()    err =", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		var d Delivery
+		if err := scanDelivery(rows, &d); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// SetChannel sets how the bill's open orders are served
+func SetChannel(ctx context.Context, db Querier, receiptNum int64, channel string) error {
+	sql := `UPDATE salesorders SET channel = $2 WHERE receipt_num = $1 AND state IN ('pending', 'ordered', 'dispatched', 'paying')`
+
+	if _, err := db.Exec(ctx, sql, receiptNum, channel); err != nil {
+		log.Println("sql error. SetChannel()    err =", err)
+		return err
+	}
+	return nil
+}
+
+// SetPayTill records the till the receipt is paid into
+func (arg *ReceiptLog) SetPayTill(ctx context.Context, db Querier) error {
+	sql := `UPDATE salestrace SET pay_till = $2, last_updated = now()
+			WHERE receipt_num = $1 AND state IN ('pending', 'paying', 'pending payment')`
+
+	tag, err := db.Exec(ctx, sql, arg.ReceiptNum, arg.PayTill)
+	if err != nil {
+		log.Println("sql error. ReceiptLog->SetPayTill()    err =", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open for payment", arg.ReceiptNum))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package sales
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+)
+
+// Prescription is the script a prescription only item is dispensed against
+type Prescription struct {
+	Number     string `json:"number"`
+	Prescriber string `json:"prescriber"`
+	Patient    string `json:"patient"`
+}
+
+// checkBatch requires the line's batch and an expiry after on
+func (arg *Sales) checkBatch(p products.StockMaster, on time.Time) error {
+	arg.Batch = strings.TrimSpace(arg.Batch)
+	if arg.Batch == "" || arg.Expiry == nil {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("batch and expiry are required for %v", p.ItemName))
+	}
+
+	// batches can be sold up to the end of their expiry date
+	y, m, d := arg.Expiry.Date()
+	if !on.Before(time.Date(y, m, d+1, 0, 0, 0, 0, arg.Expiry.Location())) {
+		return apperr.New(apperr.BatchExpired, fmt.Sprintf("batch %v of %v expired on %v", arg.Batch, p.ItemName, arg.Expiry.Format(time.DateOnly)))
+	}
+	return nil
+}
+
+// checkPrescription requires the prescription's number, prescriber and patient
+func (arg *Sales) checkPrescription(p products.StockMaster) error {
+	rx := arg.Prescription
+	if rx == nil {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("%v is prescription only", p.ItemName))
+	}
+
+	rx.Number = strings.TrimSpace(rx.Number)
+	rx.Prescriber = strings.TrimSpace(rx.Prescriber)
+	rx.Patient = strings.TrimSpace(rx.Patient)
+	if rx.Number == "" || rx.Prescriber == "" || rx.Patient == "" {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("prescription number, prescriber and patient are required for %v", p.ItemName))
+	}
+	return nil
+}
+
+// Dispensed is a line of the dispensing register
+type Dispensed struct {
+	TransDate  time.Time  `json:"trans_date"`
+	ReceiptNum int64      `json:"receipt_num"`
+	Branch     string     `json:"branch"`
+	Poster     string     `json:"poster"`
+	ItemCode   string     `json:"item_code"`
+	ItemName   string     `json:"item_name"`
+	Quantity   float64    `json:"quantity"`
+	Batch      string     `json:"batch"`
+	Expiry     *time.Time `json:"expiry"`
+	Prescription
+}
+
+// Dispensed lists the batch and prescription lines posted at the receipt's branch between from and to
+func (arg *ReceiptLog) Dispensed(ctx context.Context, db Querier, from, to time.Time) ([]Dispensed, error) {
+	sql := `SELECT s.trans_date, s.receipt_num, s.branch, s.poster,
+				item->>'item_code', item->>'item_name', (item->>'quantity')::float,
+				coalesce(item->>'batch', ''), (item->>'expiry')::timestamptz,
+				coalesce(item#>>'{prescription,number}', ''),
+				coalesce(item#>>'{prescription,prescriber}', ''),
+				coalesce(item#>>'{prescription,patient}', '')
+			FROM salestrace s CROSS JOIN jsonb_array_elements(coalesce(s.cart, '[]'::jsonb)) item
+			WHERE s.branch = $1 AND s.state = 'POSTED' AND s.trans_date >= $2 AND s.trans_date < $3
+				AND item->>'state' NOT IN ('DELETED', 'VOIDED')
+				AND (item ? 'batch' OR item ? 'prescription')
+			ORDER BY s.trans_date, s.receipt_num`
+
+	rows, err := db.Query(ctx, sql, arg.Branch, from, to)
+	if err != nil {
+		log.Println("sql error. ReceiptLog->Dispensed()    err =", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	register := []Dispensed{}
+	for rows.Next() {
+		var d Dispensed
+		err := rows.Scan(&d.TransDate, &d.ReceiptNum, &d.Branch, &d.Poster, &d.ItemCode, &d.ItemName, &d.Quantity,
+			&d.Batch, &d.Expiry, &d.Number, &d.Prescriber, &d.Patient)
+		if err != nil {
+			return nil, err
+		}
+		register = append(register, d)
+	}
+	return register, rows.Err()
+}
+
+// WriteRegister writes the dispensing register as csv
+func WriteRegister(w io.Writer, register []Dispensed) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"date", "receipt", "branch", "dispenser", "item code", "item", "quantity", "batch", "expiry", "prescription", "prescriber", "patient"})
+
+	for _, d := range register {
+		expiry := ""
+		if d.Expiry != nil {
+			expiry = d.Expiry.Format(time.DateOnly)
+		}
+		cw.Write([]string{
+			d.TransDate.Format(time.DateTime), strconv.FormatInt(d.ReceiptNum, 10), d.Branch, d.Poster,
+			d.ItemCode, d.ItemName, strconv.FormatFloat(d.Quantity, 'f', -1, 64), d.Batch, expiry,
+			d.Number, d.Prescriber, d.Patient,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
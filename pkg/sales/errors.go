@@ -0,0 +1,12 @@
+package sales
+
+import "github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+
+// errors returned by the sales service
+var (
+	ErrTillNotOpen   = apperr.New(apperr.TillNotOpen, "till is not open")
+	ErrNullReceipt   = apperr.New(apperr.ValidationFailed, "receipt number is null")
+	ErrPendingOrders = apperr.New(apperr.PendingOrders, "incomplete orders exist in bill")
+	ErrEmptyOrder    = apperr.New(apperr.EmptyOrder, "order is empty")
+	ErrEmptyReceipt  = apperr.New(apperr.ValidationFailed, "receipt has no items")
+)
@@ -7,22 +7,23 @@ import (
 	"time"
 
 	"github.com/JohnnyKahiu/speedsales/poserver/database"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
 )
 
 type GiftVoucher struct {
-	table        string    `name:"gift_voucher" type:"table"`
-	RegDate      time.Time `json:"reg_date" name:"reg_date" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT NOW()"`
-	Serial       string    `json:"serial" name:"serial" type:"field" sql:"VARCHAR NOT NULL"`
-	RegisteredBY string    `json:"registerd_by" name:"registerd_by" type:"field" sql:"VARCHAR NOT NULL"`
-	Amount       float64   `json:"amount" name:"amount" type:"field" sql:"FLOAT NOT NULL"`
-	TxnReceipt   int64     `json:"txn_receipt" name:"txn_receipt" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
-	Teller       string    `json:"teller" name:"teller" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'nil'"`
-	ClaimerName  string    `json:"claimer_name" name:"claimer_name" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'nil'"`
-	ClaimerTel   string    `json:"claimer_tel" name:"claimer_tel" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'nil'"`
-	ClaimerID    string    `json:"claimer_id" name:"claimer_id" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'nil'"`
-	Approvers    []string  `json:"approvers" name:"approvers" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'nil'"`
-	constraint   string    `name:"gift_voucherPK" type:"constraint"  sql:"PRIMARY KEY(serial)`
-	fkconstraint string    `name:"gift_voucherFk" type:"constraint"  sql:"FOREIGN KEY (registerd_by) REFERENCES users(username)`
+	table        string       `name:"gift_voucher" type:"table"`
+	RegDate      time.Time    `json:"reg_date" name:"reg_date" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT NOW()"`
+	Serial       string       `json:"serial" name:"serial" type:"field" sql:"VARCHAR NOT NULL"`
+	RegisteredBY string       `json:"registerd_by" name:"registerd_by" type:"field" sql:"VARCHAR NOT NULL"`
+	Amount       money.Amount `json:"amount" name:"amount" type:"field" sql:"NUMERIC(14,2) NOT NULL"`
+	TxnReceipt   int64        `json:"txn_receipt" name:"txn_receipt" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
+	Teller       string       `json:"teller" name:"teller" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'nil'"`
+	ClaimerName  string       `json:"claimer_name" name:"claimer_name" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'nil'"`
+	ClaimerTel   string       `json:"claimer_tel" name:"claimer_tel" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'nil'"`
+	ClaimerID    string       `json:"claimer_id" name:"claimer_id" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'nil'"`
+	Approvers    []string     `json:"approvers" name:"approvers" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'nil'"`
+	constraint   string       `name:"gift_voucherPK" type:"constraint"  sql:"PRIMARY KEY(serial)"`
+	fkconstraint string       `name:"gift_voucherFk" type:"constraint"  sql:"FOREIGN KEY (registerd_by) REFERENCES users(username)"`
 }
 
 func genGiftVoucherTbl() error {
@@ -30,7 +31,7 @@ func genGiftVoucherTbl() error {
 	return database.CreateFromStruct(tbl)
 }
 
-func (arg *GiftVoucher) Create() error {
+func (arg *GiftVoucher) Create(ctx context.Context, db Querier) error {
 	if arg.Amount <= 0 {
 		return fmt.Errorf("no 0 amount gift voucher")
 	}
@@ -41,10 +42,26 @@ func (arg *GiftVoucher) Create() error {
 	sql := `INSERT INTO gift_voucher(serial, registerd_by, amount)
 			VALUES($1, $2, $3)`
 
-	_, err := database.PgPool.Exec(context.Background(), sql, arg.Serial, arg.RegisteredBY, arg.Amount)
+	_, err := db.Exec(ctx, sql, arg.Serial, arg.RegisteredBY, arg.Amount)
 	if err != nil {
 		log.Println("error. failed to create a new gift voucher     err =", err)
 		return err
 	}
 	return nil
 }
+
+// Fetch gets a gift voucher by its serial
+func (arg *GiftVoucher) Fetch(ctx context.Context, db Querier) error {
+	sql := `SELECT reg_date, serial, registerd_by, amount, txn_receipt, teller
+				, claimer_name, claimer_tel, claimer_id
+			FROM gift_voucher 
+			WHERE serial = $1`
+
+	err := db.QueryRow(ctx, sql, arg.Serial).Scan(&arg.RegDate, &arg.Serial, &arg.RegisteredBY, &arg.Amount, &arg.TxnReceipt, &arg.Teller,
+		&arg.ClaimerName, &arg.ClaimerTel, &arg.ClaimerID)
+	if err != nil {
+		log.Println("error. failed to fetch gift voucher     err =", err)
+		return err
+	}
+	return nil
+}
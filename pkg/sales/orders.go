@@ -3,61 +3,66 @@ package sales
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
-	"os"
-	"strconv"
+	"slices"
 	"time"
 
 	"github.com/JohnnyKahiu/speedsales/poserver/database"
-	db "github.com/JohnnyKahiu/speedsales/poserver/database"
-	"github.com/JohnnyKahiu/speedsales/poserver/pkg/broker"
-	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/events"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
 	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
 	"github.com/jackc/pgx/v5"
 )
 
 // Order holds a new sales order variable
 type Order struct {
-	table        string    `name:"salesorders" type:"table"`
-	TransDate    time.Time `json:"trans_date" name:"trans_date" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT now()"`
-	CompleteTime time.Time `json:"complete_time" name:"complete_time" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT now()"`
-	OrderNum     int64     `json:"order_num" name:"order_num" type:"field" sql:"BIGINT NOT NULL PRIMARY KEY"`
-	DailyCount   int64     `json:"daily_count" name:"daily_count" type:"field" sql:"BIGINT NOT NULL"`
-	OrderItems   []Sales   `json:"order_items" name:"order_items" type:"field" sql:"JSONB"`
-	Poster       string    `json:"poster" name:"poster" type:"field" sql:"VARCHAR NOT NULL"`
-	Branch       string    `json:"branch" name:"branch" type:"field" sql:"VARCHAR NOT NULL"`
-	StkLocation  string    `json:"stk_Location"`
-	DispBy       string    `json:"disp_by" name:"disp_by" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'nan'"`
-	DispTime     time.Time `json:"disp_time" name:"disp_time" type:"field" sql:"TIMESTAMPTZ"`
-	CompanyID    int64     `json:"company_id" name:"company_id" type:"field" sql:"BIGINT NOT NULL"`
-	TillNum      int64     `json:"till_num" name:"till_num" type:"field" sql:"BIGINT NOT NULL"`
-	PayTill      int64     `json:"pay_till" name:"pay_till" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
-	Receipt      int64     `json:"receipt" name:"receipt" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
-	ReceiptNum   int64     `json:"receipt_num" name:"receipt_num" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
-	AcNum        string    `json:"ac_num" name:"ac_num" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'pending'"`
-	State        string    `json:"state" name:"state" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'pending'"`
-	Elapsed      float64   `json:"elapsed"`
-	Total        float64   `json:"total"`
-	ServerID     int64
+	table        string       `name:"salesorders" type:"table"`
+	TransDate    time.Time    `json:"trans_date" name:"trans_date" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT now()"`
+	CompleteTime time.Time    `json:"complete_time" name:"complete_time" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT now()"`
+	OrderNum     int64        `json:"order_num" name:"order_num" type:"field" sql:"BIGINT NOT NULL PRIMARY KEY"`
+	DailyCount   int64        `json:"daily_count" name:"daily_count" type:"field" sql:"BIGINT NOT NULL"`
+	OrderItems   []Sales      `json:"order_items" name:"order_items" type:"field" sql:"JSONB"`
+	Poster       string       `json:"poster" name:"poster" type:"field" sql:"VARCHAR NOT NULL"`
+	Branch       string       `json:"branch" name:"branch" type:"field" sql:"VARCHAR NOT NULL"`
+	StkLocation  string       `json:"stk_Location"`
+	DispBy       string       `json:"disp_by" name:"disp_by" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'nan'"`
+	DispTime     time.Time    `json:"disp_time" name:"disp_time" type:"field" sql:"TIMESTAMPTZ"`
+	CompanyID    int64        `json:"company_id" name:"company_id" type:"field" sql:"BIGINT NOT NULL"`
+	TillNum      int64        `json:"till_num" name:"till_num" type:"field" sql:"BIGINT NOT NULL"`
+	PayTill      int64        `json:"pay_till" name:"pay_till" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
+	Receipt      int64        `json:"receipt" name:"receipt" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
+	ReceiptNum   int64        `json:"receipt_num" name:"receipt_num" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
+	AcNum        string       `json:"ac_num" name:"ac_num" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'pending'"`
+	State        string       `json:"state" name:"state" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'pending'"`
+	Channel      string       `json:"channel" name:"channel" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'dine_in'"`
+	Elapsed      float64      `json:"elapsed"`
+	Total        money.Amount `json:"total"`
+	// Course is the course sent to the kitchen with the order
+	Course   int `json:"course,omitempty"`
+	ServerID int64
 }
 
 // OrderItem is a variable for current order
 type OrderItem struct {
-	ItemCode string  `json:"item_code"`
-	ItemName string  `json:"item_name"`
-	Quantity float64 `json:"quantity"`
-	Price    float64 `json:"price"`
-	Cost     float64 `json:"cost"`
-	Total    float64 `json:"total"`
-	VatAlpha string  `json:"vat_alpha"`
-	VatPerc  float64 `json:"vat_perc"`
-	Vat      float64 `json:"vat"`
-	State    string  `json:"state"`
-	Poster   string  `json:"poster"`
-	OrderNum string  `json:"order_num"`
-	TxnTime  string  `json:"txn_time"`
+	ItemCode string       `json:"item_code"`
+	ItemName string       `json:"item_name"`
+	Quantity float64      `json:"quantity"`
+	Price    money.Amount `json:"price"`
+	Cost     money.Amount `json:"cost"`
+	Total    money.Amount `json:"total"`
+	VatAlpha string       `json:"vat_alpha"`
+	VatPerc  float64      `json:"vat_perc"`
+	Vat      money.Amount `json:"vat"`
+	State    string       `json:"state"`
+	Poster   string       `json:"poster"`
+	OrderNum string       `json:"order_num"`
+	TxnTime  string       `json:"txn_time"`
+	// Modifiers, Note and Course tell the kitchen how and when to make the item
+	Modifiers []Modifier `json:"modifiers,omitempty"`
+	Note      string     `json:"note,omitempty"`
+	Course    int        `json:"course,omitempty"`
 }
 
 // OrderCategories
@@ -65,11 +70,11 @@ type OrderItem struct {
 // genOrderTable
 func genOrderTable() error {
 	var tblStruct Order
-	return db.CreateFromStruct(tblStruct)
+	return database.CreateFromStruct(tblStruct)
 }
 
 // nextOrder fetches the next available order number
-func (ord *Order) NextOrder() error {
+func (ord *Order) NextOrder(ctx context.Context, db Querier) error {
 	fmt.Println("\n\t\t ac_num =", ord.AcNum)
 	fmt.Println("\t\t poster =", ord.Poster)
 	fmt.Println("\t\t receipt =", ord.ReceiptNum)
@@ -79,10 +84,11 @@ func (ord *Order) NextOrder() error {
 				coalesce(max(order_num), 0) 
 			FROM salesorders 
 			WHERE state = 'pending' 
+				AND poster = $1
 				AND till_num = $2
 				AND receipt_num = $3`
 
-	rows, err := db.PgPool.Query(context.Background(), sql, ord.Poster, ord.TillNum, ord.ReceiptNum)
+	rows, err := db.Query(ctx, sql, ord.Poster, ord.TillNum, ord.ReceiptNum)
 	if err != nil {
 		return err
 	}
@@ -132,9 +138,9 @@ func (ord *Order) NextOrderTX(ctx context.Context, tx pgx.Tx) error {
 }
 
 // NewOrder generates a new sales order
-func (ord *Order) NewOrder(ctx context.Context) error {
+func (ord *Order) NewOrder(ctx context.Context, db Querier) error {
 	var err error
-	err = ord.NextOrder()
+	err = ord.NextOrder(ctx, db)
 	if err != nil {
 		fmt.Println("error newOrder    err =", err)
 		return err
@@ -152,8 +158,12 @@ func (ord *Order) NewOrder(ctx context.Context) error {
 		ord.AcNum = fmt.Sprintf("%v", ord.ReceiptNum)
 	}
 
+	if ord.Channel == "" {
+		ord.Channel = ChannelDineIn
+	}
+
 	// create a new order if there is no active order
-	sql := `INSERT INTO salesorders(order_num, daily_count, till_num, poster, branch, company_id, ac_num, receipt_num)
+	sql := `INSERT INTO salesorders(order_num, daily_count, till_num, poster, branch, company_id, ac_num, receipt_num, channel)
 			SELECT CAST(CONCAT(
 							extract(YEAR FROM now()), 
 							LPAD(EXTRACT(MONTH FROM now())::text, 2, '0'), 
@@ -168,13 +178,14 @@ func (ord *Order) NewOrder(ctx context.Context) error {
 					, (SELECT company_id FROM users WHERE username = $1) as company_id
 					, $2
 					, $3
+					, $4
 				FROM salesorders 
 				WHERE trans_date::date = (SELECT now()::date) AND 
 				company_id = (SELECT coalesce(company_id, 0) FROM users WHERE username = $1) AND 
 				branch = (SELECT branch FROM users WHERE username = $1)
 			RETURNING order_num`
 
-	rows, err := db.PgPool.Query(ctx, sql, ord.Poster, ord.AcNum, ord.ReceiptNum)
+	rows, err := db.Query(ctx, sql, ord.Poster, ord.AcNum, ord.ReceiptNum, ord.Channel)
 	if err != nil {
 		fmt.Println("sale.Orders->NewOrder() query error     err =", err)
 		return err
@@ -212,8 +223,12 @@ func (ord *Order) NewOrderTX(ctx context.Context, tx pgx.Tx) error {
 		ord.AcNum = fmt.Sprintf("%v", ord.ReceiptNum)
 	}
 
+	if ord.Channel == "" {
+		ord.Channel = ChannelDineIn
+	}
+
 	// create a new order if there is no active order
-	sql := `INSERT INTO salesorders(order_num, daily_count, till_num, poster, branch, company_id, ac_num, receipt_num)
+	sql := `INSERT INTO salesorders(order_num, daily_count, till_num, poster, branch, company_id, ac_num, receipt_num, channel)
 			SELECT CAST(CONCAT(
 							extract(YEAR FROM now()), 
 							LPAD(EXTRACT(MONTH FROM now())::text, 2, '0'), 
@@ -228,13 +243,14 @@ func (ord *Order) NewOrderTX(ctx context.Context, tx pgx.Tx) error {
 					, $6 as company_id
 					, $4
 					, $5
+					, $7
 				FROM salesorders 
 				WHERE trans_date::date = (SELECT now()::date) 
 					-- AND company_id = $6 
 					AND branch = $1
 			RETURNING order_num`
 
-	rows, err := tx.Query(ctx, sql, ord.Branch, ord.TillNum, ord.Poster, ord.AcNum, ord.ReceiptNum, ord.CompanyID)
+	rows, err := tx.Query(ctx, sql, ord.Branch, ord.TillNum, ord.Poster, ord.AcNum, ord.ReceiptNum, ord.CompanyID, ord.Channel)
 	if err != nil {
 		log.Println("sale.Orders->NewOrder() query error     err =", err)
 		return err
@@ -252,13 +268,13 @@ func (ord *Order) NewOrderTX(ctx context.Context, tx pgx.Tx) error {
 }
 
 // FetchOrderItems gets all items in order
-func (ord *Order) Fetchtems() error {
+func (ord *Order) Fetchtems(ctx context.Context, db Querier) error {
 	sql := `SELECT 
-				cast(coalesce(order_items::varchar, '[]') as varchar) 
+				cast(coalesce(order_items::varchar, '[]') as varchar), receipt_num, branch, till_num
 			FROM salesorders 
 			WHERE order_num = $1`
 
-	rows, err := db.PgPool.Query(context.Background(), sql, ord.OrderNum)
+	rows, err := db.Query(ctx, sql, ord.OrderNum)
 	if err != nil {
 		log.Println("sql error, failed to query order items    err =", err)
 		return err
@@ -267,7 +283,7 @@ func (ord *Order) Fetchtems() error {
 
 	var orderItems string
 	for rows.Next() {
-		err := rows.Scan(&orderItems)
+		err := rows.Scan(&orderItems, &ord.ReceiptNum, &ord.Branch, &ord.TillNum)
 		if err != nil {
 			return err
 		}
@@ -335,7 +351,7 @@ func (ord *Order) FetchtemsCtx(ctx context.Context, tx pgx.Tx) error {
 			FROM salesorders 
 			WHERE order_num = $1`
 
-	rows, err := db.PgPool.Query(ctx, sql, ord.OrderNum)
+	rows, err := tx.Query(ctx, sql, ord.OrderNum)
 	if err != nil {
 		return err
 	}
@@ -359,7 +375,7 @@ func (ord *Order) FetchtemsCtx(ctx context.Context, tx pgx.Tx) error {
 }
 
 // FetchPayingOrderItems gets all items in order
-func FetchPayingOrderItems(tillNum string) ([]Sales, error) {
+func FetchPayingOrderItems(ctx context.Context, db Querier, tillNum string) ([]Sales, error) {
 	if tillNum == "" || tillNum == "00" {
 		return nil, fmt.Errorf("failed to fetch paying order till num")
 	}
@@ -369,7 +385,7 @@ func FetchPayingOrderItems(tillNum string) ([]Sales, error) {
 			WHERE state = 'paying' AND till_num = $1`
 
 	var values []Sales
-	rows, err := db.PgPool.Query(context.Background(), sql, tillNum)
+	rows, err := db.Query(ctx, sql, tillNum)
 	if err != nil {
 		return nil, err
 	}
@@ -434,7 +450,7 @@ func (arg *ReceiptLog) CombineOrdersInBill(ctx context.Context, tx pgx.Tx) error
 }
 
 // FetchActiveOrders gets all orders not paid yet
-func FetchActiveOrders(poster string) ([]Order, error) {
+func FetchActiveOrders(ctx context.Context, db Querier, poster string) ([]Order, error) {
 	// userDetails, err := login.FetchUser(poster)
 	// if err != nil {
 	// 	return nil, err
@@ -455,7 +471,7 @@ func FetchActiveOrders(poster string) ([]Order, error) {
 			ORDER BY trans_date ASC
 			`
 
-	rows, err := db.PgPool.Query(context.Background(), sql, poster)
+	rows, err := db.Query(ctx, sql, poster)
 	if err != nil {
 		fmt.Println("failed to query orders.  error =", err)
 		return nil, err
@@ -476,7 +492,7 @@ func FetchActiveOrders(poster string) ([]Order, error) {
 }
 
 // FetchActiveOrders gets all orders not paid yet
-func FetchActiveOrdersInBill(receipt string) ([]Order, error) {
+func FetchActiveOrdersInBill(ctx context.Context, db Querier, receipt string) ([]Order, error) {
 
 	sql := `SELECT
                 order_num
@@ -492,7 +508,7 @@ func FetchActiveOrdersInBill(receipt string) ([]Order, error) {
 			ORDER BY trans_date ASC
 			`
 
-	rows, err := db.PgPool.Query(context.Background(), sql, receipt)
+	rows, err := db.Query(ctx, sql, receipt)
 	if err != nil {
 		fmt.Println("failed to query orders.  error =", err)
 		return nil, err
@@ -513,28 +529,13 @@ func FetchActiveOrdersInBill(receipt string) ([]Order, error) {
 }
 
 // AddToOrder adds a new item to orders
-func (ord *Order) AddToOrder(args Sales) ([]Sales, float64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	if ord.ReceiptNum == 0 {
-		return nil, 0, fmt.Errorf("error. Order->AddToOrder()    null order num")
-	}
+// item details are expected to be filled from inventory
+func (ord *Order) AddToOrder(ctx context.Context, db DBPool, args Sales) ([]Sales, money.Amount, error) {
 	if ord.ReceiptNum == 0 {
 		return nil, 0, fmt.Errorf("error. Order->AddToOrder()    null receipt")
 	}
 
-	// fetch from details from inventory microservice
-	p := products.StockMaster{ItemCode: args.ItemCode}
-	err := p.Fetch(ctx)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	args.ItemName = p.ItemName
-	args.Price = p.TillPrice
-
-	tx, err := db.PgPool.Begin(ctx)
+	tx, err := db.Begin(ctx)
 	if err != nil {
 		log.Println("error creating transaction error =", err)
 		return nil, 0, err
@@ -599,15 +600,15 @@ func (ord *Order) AddToOrder(args Sales) ([]Sales, float64, error) {
 		}
 	}
 
-	total := OrderTotal(orderItems)
-	tx.Commit(ctx)
+	rows.Close()
 
-	return orderItems, total, nil
+	total := OrderTotal(orderItems)
+	return orderItems, total, tx.Commit(ctx)
 }
 
 // OrderTotal
-func (ord *Order) CalcTotal() float64 {
-	var total float64
+func (ord *Order) CalcTotal() money.Amount {
+	var total money.Amount
 
 	if len(ord.OrderItems) == 0 {
 		return 0
@@ -619,7 +620,7 @@ func (ord *Order) CalcTotal() float64 {
 
 	for _, itm := range ord.OrderItems {
 		if itm.State != "DELETED" && itm.State != "VOIDED" {
-			total += (itm.Quantity * itm.Price)
+			total += itm.Price.Mul(itm.Quantity)
 		}
 	}
 
@@ -627,13 +628,13 @@ func (ord *Order) CalcTotal() float64 {
 }
 
 // OrderTotal
-func OrderTotal(order []Sales) float64 {
-	var total float64
+func OrderTotal(order []Sales) money.Amount {
+	var total money.Amount
 
 	if order != nil {
 		for _, itm := range order {
 			if itm.State != "DELETED" && itm.State != "VOIDED" {
-				total += (itm.Quantity * itm.Price)
+				total += itm.Price.Mul(itm.Quantity)
 			}
 		}
 	} else {
@@ -643,82 +644,34 @@ func OrderTotal(order []Sales) float64 {
 	return total
 }
 
-// CompleteOrder completes an order
-func (ord *Order) CompleteOrder() ([]OrderItem, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	tx, err := db.PgPool.BeginTx(ctx, pgx.TxOptions{})
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback(ctx)
-
-	fmt.Println("\t order_num =", ord.OrderNum)
-
-	// if kitchen is enabled complete order state should be    'ordered'
-	// else  state = 'dispatched'
-	ord.State = "ordered"
-	if !variables.ProductionDisp {
-		ord.State = "dispatched"
-	}
-
-	err = ord.FetchtemsCtx(ctx, tx)
-	if err != nil {
-		fmt.Printf("\n\tfailed to complete order for order_num = %v fetch error = %v \n", ord.OrderNum, err)
-		return nil, err
-	}
-
-	if ord.OrderItems == nil || len(ord.OrderItems) == 0 {
-		return nil, fmt.Errorf("error order is empty")
-	}
-
+// Complete sets the order's completion state and time
+func (ord *Order) Complete(ctx context.Context, db Querier) error {
 	sql := `UPDATE salesorders 
 			SET 
 				state = $2
 				, complete_time = now() 
 			WHERE order_num = $1 `
 
-	_, err = database.PgPool.Exec(ctx, sql, ord.OrderNum, ord.State)
+	_, err := db.Exec(ctx, sql, ord.OrderNum, ord.State)
 	if err != nil {
 		fmt.Printf("\n\tfailed to complete order for order_num = %v error = %v \n", ord.OrderNum, err)
-		return nil, err
-	}
-
-	payLoad, err := json.Marshal(ord)
-
-	fmt.Printf("\t kafka broker    addr = 'tcp://	%v'\n", os.Getenv("KAFKA_BROKER"))
-
-	kf := broker.Kafka{
-		Broker:  os.Getenv("KAFKA_BROKER"),
-		Topic:   "sales_orders",
-		Key:     fmt.Sprintf("%v", ord.OrderNum),
-		Payload: payLoad,
-	}
-	err = kf.Produce(ctx)
-	if err != nil {
-		log.Println("kafka error    failed to produce message    err =", err)
-		return nil, err
-	}
-
-	voucher, err := ord.VoucherCtx(ctx, tx)
-	if err != nil {
-		return nil, err
+		return err
 	}
-
-	tx.Commit(ctx)
-
-	return voucher, nil
+	return nil
 }
 
-// OrderVoucher returns order details
-func (ord *Order) VoucherCtx(ctx context.Context, tx pgx.Tx) ([]OrderItem, error) {
+// Voucher returns order details
+// items made differently or in another course are listed apart
+func (ord *Order) Voucher(ctx context.Context, db Querier) ([]OrderItem, error) {
 	sql := `SELECT items.item_name
 				, SUM(items.quantity) as qty
 				, items.price, SUM(items.quantity * items.price) as total
 				, items.order_num
 				, (SELECT poster FROM salesorders WHERE order_num = $1) 
 				, (SELECT trans_date FROM salesorders WHERE order_num = $1)
+				, coalesce(items.modifiers, '[]')
+				, coalesce(items.note, '')
+				, coalesce(items.course, 0)
 			FROM salesorders ord, jsonb_to_recordset(ord.order_items) as  
 				items(
 					item_code varchar
@@ -727,11 +680,15 @@ func (ord *Order) VoucherCtx(ctx context.Context, tx pgx.Tx) ([]OrderItem, error
 					, price float
 					, order_num bigint
 					, state varchar 
+					, modifiers jsonb
+					, note varchar
+					, course int
 				)
 			WHERE ord.order_num = $1 AND items.state = 'pending'
-			GROUP BY items.item_name, items.price, items.order_num `
+			GROUP BY items.item_name, items.price, items.order_num, items.modifiers, items.note, items.course
+			ORDER BY coalesce(items.course, 0), items.item_name `
 
-	rows, err := db.PgPool.Query(ctx, sql, ord.OrderNum)
+	rows, err := db.Query(ctx, sql, ord.OrderNum)
 	if err != nil {
 		log.Println("error fetching order voucher err =", err)
 		return nil, err
@@ -742,7 +699,7 @@ func (ord *Order) VoucherCtx(ctx context.Context, tx pgx.Tx) ([]OrderItem, error
 	for rows.Next() {
 		var r OrderItem
 		var t time.Time
-		rows.Scan(&r.ItemName, &r.Quantity, &r.Price, &r.Total, &r.OrderNum, &r.Poster, &t)
+		rows.Scan(&r.ItemName, &r.Quantity, &r.Price, &r.Total, &r.OrderNum, &r.Poster, &t, &r.Modifiers, &r.Note, &r.Course)
 
 		r.TxnTime = fmt.Sprintf("%d-%02d-%02d %02d:%02d", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute())
 
@@ -755,8 +712,8 @@ func (ord *Order) VoucherCtx(ctx context.Context, tx pgx.Tx) ([]OrderItem, error
 // GetOrdersInBills gets all orders in a bill
 // Queries salesorders table for all orders in a bill
 // Returns an array of orders
-func (ord *Order) GetOrdersInBills(ctx context.Context) ([]Order, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (ord *Order) GetOrdersInBills(ctx context.Context, db Querier) ([]Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	sql := `SELECT
@@ -768,13 +725,14 @@ func (ord *Order) GetOrdersInBills(ctx context.Context) ([]Order, error) {
 				, ac_num
 				, receipt_num
 				, order_items::varchar
+				, channel
 			FROM salesorders 
 			WHERE 
 				state IN ('pending', 'dispatched', 'paying') AND 
 				receipt_num = $1
 			ORDER BY trans_date ASC `
 
-	rows, err := database.PgPool.Query(ctx, sql, ord.ReceiptNum)
+	rows, err := db.Query(ctx, sql, ord.ReceiptNum)
 	if err != nil {
 		log.Println("sql error failed to query salesorders    err =", err)
 		return []Order{}, err
@@ -788,7 +746,7 @@ func (ord *Order) GetOrdersInBills(ctx context.Context) ([]Order, error) {
 		ordItms := ""
 		err := rows.Scan(&r.TransDate, &r.OrderNum, &r.DailyCount,
 			&r.Poster, &r.State,
-			&r.AcNum, &r.ReceiptNum, &ordItms)
+			&r.AcNum, &r.ReceiptNum, &ordItms, &r.Channel)
 		if err != nil {
 			log.Println("sql scan error    err =", err)
 			return []Order{}, err
@@ -800,9 +758,9 @@ func (ord *Order) GetOrdersInBills(ctx context.Context) ([]Order, error) {
 			return []Order{}, err
 		}
 
-		total := float64(0)
+		total := money.Amount(0)
 		for _, itm := range r.OrderItems {
-			total += (itm.Price * itm.Quantity)
+			total += itm.Price.Mul(itm.Quantity)
 		}
 
 		ord.Total += total
@@ -813,7 +771,7 @@ func (ord *Order) GetOrdersInBills(ctx context.Context) ([]Order, error) {
 }
 
 // OrderVoucher returns order details
-func OrderVoucher(orderNum string) ([]OrderItem, error) {
+func OrderVoucher(ctx context.Context, db Querier, orderNum string) ([]OrderItem, error) {
 	sql := `SELECT items.item_name, SUM(items.quantity) as qty, items.price, SUM(items.quantity * items.price) as total
 				, items.order_num
 				, (SELECT poster FROM salesorders WHERE order_num = $1) 
@@ -830,7 +788,7 @@ func OrderVoucher(orderNum string) ([]OrderItem, error) {
 			WHERE ord.order_num = $1 AND items.state = 'pending'
 			GROUP BY items.item_name, items.price, items.order_num `
 
-	rows, err := db.PgPool.Query(context.Background(), sql, orderNum)
+	rows, err := db.Query(ctx, sql, orderNum)
 	if err != nil {
 		log.Println("error fetching order voucher err =", err)
 		return nil, err
@@ -852,7 +810,7 @@ func OrderVoucher(orderNum string) ([]OrderItem, error) {
 }
 
 // OrdIsDeletable checks if an order can be deleted
-func OrdIsDeletable(ordNum string) bool {
+func OrdIsDeletable(ctx context.Context, db Querier, ordNum string) bool {
 	sql := `SELECT 
 				CASE 
 					WHEN state = 'pending' THEN true
@@ -861,7 +819,7 @@ func OrdIsDeletable(ordNum string) bool {
 			FROM salesorders WHERE order_num = $1`
 
 	var isDelete bool
-	rows, err := db.PgPool.Query(context.Background(), sql, ordNum)
+	rows, err := db.Query(ctx, sql, ordNum)
 	if err != nil {
 		return false
 	}
@@ -875,18 +833,17 @@ func OrdIsDeletable(ordNum string) bool {
 }
 
 // DelOrderItem deletes an order item
-func DelOrderItem(orderItem, orderNum string) ([]Sales, float64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+func DelOrderItem(ctx context.Context, db DBPool, orderItem string, orderNum int64) ([]Sales, money.Amount, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	tx, err := db.PgPool.BeginTx(ctx, pgx.TxOptions{})
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return []Sales{}, 0, err
 	}
 	defer tx.Rollback(ctx)
 
-	ordNum, _ := strconv.ParseInt(orderNum, 10, 64)
-	ord := Order{OrderNum: ordNum}
+	ord := Order{OrderNum: orderNum}
 
 	// fetch order items
 	err = ord.FetchtemsCtx(ctx, tx) //FetchOrderItems(orderNum)
@@ -917,7 +874,7 @@ func DelOrderItem(orderItem, orderNum string) ([]Sales, float64, error) {
 			WHERE order_num = $2 
 				AND state = 'pending'
 			RETURNING order_items::varchar `
-	rows, err := db.PgPool.Query(context.Background(), sql, string(jStr), orderNum)
+	rows, err := tx.Query(ctx, sql, string(jStr), orderNum)
 	if err != nil {
 		fmt.Println("error updaring order items error =", err)
 		return nil, 0, err
@@ -938,15 +895,16 @@ func DelOrderItem(orderItem, orderNum string) ([]Sales, float64, error) {
 		}
 	}
 
+	rows.Close()
+
 	total := OrderTotal(cart)
 
 	return cart, total, tx.Commit(ctx)
 }
 
 // SetOrderPay sets an order to paying
-func SetOrderPay(ordNum string, receipt int64) error {
-	ctx := context.Background()
-	tx, err := db.PgPool.BeginTx(ctx, pgx.TxOptions{})
+func SetOrderPay(ctx context.Context, db DBPool, ordNum string, receipt int64) error {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return err
 	}
@@ -1016,12 +974,11 @@ func SetOrderPay(ordNum string, receipt int64) error {
 		return err
 	}
 
-	tx.Commit(ctx)
-	return nil
+	return tx.Commit(ctx)
 }
 
 // combines existing orders into a single bill
-func (arg *Order) CombineBill() error {
+func (arg *Order) CombineBill(ctx context.Context, db Querier) error {
 	var err error
 	var rcpt ReceiptLog
 
@@ -1029,17 +986,21 @@ func (arg *Order) CombineBill() error {
 		rcpt.Poster = arg.Poster
 		rcpt.TillNum = arg.TillNum
 
-		err = rcpt.GenReceipt()
+		err = rcpt.Pending(ctx, db)
+		if err == nil && rcpt.ReceiptNum == 0 {
+			_, err = rcpt.CreateReceipt(ctx, db)
+		}
 		if err != nil {
 			return fmt.Errorf("sales.Order->CombineBill(). failed to get next open order")
 		}
+		arg.Receipt = rcpt.ReceiptNum
 	}
 
 	if arg.OrderNum == 0 {
 		return fmt.Errorf("sales.Order->CombineBill(). order num is null")
 	}
 
-	err = arg.addOrderToReceipt()
+	err = arg.addOrderToReceipt(ctx, db)
 	if err != nil {
 		return fmt.Errorf("sales.Order->CombineBill(). error combining orders")
 	}
@@ -1047,14 +1008,14 @@ func (arg *Order) CombineBill() error {
 	return nil
 }
 
-func (arg *ReceiptLog) PendingOrdersInBill(ctx context.Context) bool {
+func (arg *ReceiptLog) PendingOrdersInBill(ctx context.Context, db Querier) bool {
 	sql := `SELECT
 				CASE WHEN count(*) > 0 THEN true ELSE false END exists 
 			FROM salesorders 
 			WHERE receipt_num = $1 AND state = 'pending'`
 
 	exists := false
-	if err := database.PgPool.QueryRow(ctx, sql, arg.ReceiptNum).Scan(&exists); err != nil {
+	if err := db.QueryRow(ctx, sql, arg.ReceiptNum).Scan(&exists); err != nil {
 		log.Println("sql error. failed to fetch pendingOrdersInBill    err =", err)
 		return false
 	}
@@ -1065,13 +1026,13 @@ func (arg *ReceiptLog) PendingOrdersInBill(ctx context.Context) bool {
 // addOrderToReceipt adds current order items into receipt
 // Updates receipt number to salesorders
 // returns an error if it fails
-func (arg *Order) addOrderToReceipt() error {
+func (arg *Order) addOrderToReceipt(ctx context.Context, db Querier) error {
 	sql := `UPDATE salesorders 
 			SET 
 				receipt = $1 
 			WHERE order_num = $2`
 
-	_, err := db.PgPool.Query(context.Background(), sql, arg.Receipt, arg.OrderNum)
+	_, err := db.Exec(ctx, sql, arg.Receipt, arg.OrderNum)
 	if err != nil {
 		log.Println("sales.Order->addOrderToReceipt()    error =", err)
 		return err
@@ -1080,9 +1041,8 @@ func (arg *Order) addOrderToReceipt() error {
 }
 
 // OrderToSales adds current order_items into sales_live
-func OrderToSales(orders []Sales, ords []string, receipt int64, username string) (float64, error) {
-	ctx := context.Background()
-	tx, err := db.PgPool.BeginTx(ctx, pgx.TxOptions{})
+func OrderToSales(ctx context.Context, db DBPool, orders []Sales, ords []string, receipt int64, username string) (money.Amount, error) {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return 0, err
 	}
@@ -1117,20 +1077,19 @@ func OrderToSales(orders []Sales, ords []string, receipt int64, username string)
 		return 0, err
 	}
 
-	tx.Commit(ctx)
-	return total, nil
+	return total, tx.Commit(ctx)
 }
 
 // OrdersToPay joins orders into sales
-func (arg *ReceiptLog) OrdersToPay(orders []string, receipt int64) error {
+func (arg *ReceiptLog) OrdersToPay(ctx context.Context, db DBPool, orders []string, receipt int64) error {
 
-	salesCarts, err := FetchPayingOrderItems(fmt.Sprintf("%v", arg.TillNum))
+	salesCarts, err := FetchPayingOrderItems(ctx, db, fmt.Sprintf("%v", arg.TillNum))
 	if err != nil {
 		log.Println("error. failed to fetch paying order items    err =", err)
 		return nil
 	}
 
-	_, err = OrderToSales(salesCarts, orders, receipt, arg.Poster)
+	_, err = OrderToSales(ctx, db, salesCarts, orders, receipt, arg.Poster)
 	if err != nil {
 		fmt.Println("failed order to sales err =", err)
 		return err
@@ -1140,11 +1099,11 @@ func (arg *ReceiptLog) OrdersToPay(orders []string, receipt int64) error {
 }
 
 // CloseBill joins orders in bill to sale
-func (arg *ReceiptLog) CloseBill() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
+func (arg *ReceiptLog) CloseBill(ctx context.Context, db DBPool) error {
+	ctx, cancel := context.WithTimeout(ctx, 45*time.Second)
 	defer cancel()
 
-	tx, err := db.PgPool.BeginTx(ctx, pgx.TxOptions{})
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return err
 	}
@@ -1155,8 +1114,8 @@ func (arg *ReceiptLog) CloseBill() error {
 	}
 
 	// check if there exists any pending orders
-	if arg.PendingOrdersInBill(ctx) {
-		return errors.New("Incomplete Orders exists")
+	if arg.PendingOrdersInBill(ctx, tx) {
+		return ErrPendingOrders
 	}
 
 	err = arg.CombineOrdersInBill(ctx, tx)
@@ -1193,7 +1152,7 @@ func (arg *ReceiptLog) UpdateCart(ctx context.Context, tx pgx.Tx) error {
 	arg.Total = 0
 	for _, row := range arg.Cart {
 		if row.State == "pending" {
-			arg.Total += float32(row.Quantity * row.Price)
+			arg.Total += row.Price.Mul(row.Quantity)
 		}
 	}
 
@@ -1238,3 +1197,193 @@ func (arg *ReceiptLog) CloseOrders(ctx context.Context, tx pgx.Tx) error {
 func ExcFromRcpt(orderNum string) error {
 	return nil
 }
+
+// OrdersInBill gets all orders in a bill and their total
+func (s *Service) OrdersInBill(ctx context.Context, receiptNum int64) ([]Order, money.Amount, error) {
+	return s.Orders.OrdersInBill(ctx, receiptNum)
+}
+
+// OrderCart gets the items in an order
+func (s *Service) OrderCart(ctx context.Context, ord *Order) error {
+	return s.Orders.Items(ctx, ord)
+}
+
+// AddToOrder fills an item from inventory and adds it to the bill's open order
+func (s *Service) AddToOrder(ctx context.Context, ord *Order, item Sales) ([]Sales, money.Amount, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if ord.ReceiptNum == 0 {
+		return nil, 0, ErrNullReceipt
+	}
+	if ord.Channel != "" && !slices.Contains(Channels, ord.Channel) {
+		return nil, 0, apperr.New(apperr.ValidationFailed, fmt.Sprintf("%v is not an order channel", ord.Channel))
+	}
+	if err := s.requireFeature(ctx, ord.Branch, variables.FeatureOrders); err != nil {
+		return nil, 0, err
+	}
+
+	// fetch from details from inventory microservice
+	p, err := s.scanProduct(ctx, &item, ord.Branch)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := s.checkStock(ctx, ord.Branch, s.stockLocation(ctx, ord.Poster), &item, p); err != nil {
+		return nil, 0, err
+	}
+	if err := s.checkDispensing(ctx, ord.Branch, &item, p); err != nil {
+		return nil, 0, err
+	}
+	if err := s.checkSerials(ctx, &item, p); err != nil {
+		return nil, 0, err
+	}
+	if err := s.checkAge(ctx, ord.Poster, &item, p); err != nil {
+		return nil, 0, err
+	}
+	if p, err = item.priceModifiers(p); err != nil {
+		return nil, 0, err
+	}
+
+	item.ItemName = p.ItemName
+	item.Price = money.New(p.TillPrice)
+	item.Cost = money.New(p.ItemCost)
+	item.VatAlpha = p.VatAlpha
+	item.VatPercent = p.VatPercent
+	item.Total = item.Price.Mul(item.Quantity)
+
+	// the bill's exemption is applied when it's closed
+	if taxes, err := s.taxTable(); err == nil && taxes != nil {
+		item.State = "pending"
+		item = taxes.Apply([]Sales{item}, false)[0]
+	}
+
+	cart, total, err := s.Orders.AddItem(ctx, ord, item)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	s.Events.Publish(events.Event{
+		Type:       events.OrderItemAdded,
+		Branch:     ord.Branch,
+		TillNum:    ord.TillNum,
+		ReceiptNum: ord.ReceiptNum,
+		OrderNum:   ord.OrderNum,
+		Data:       cart,
+	})
+	return cart, total, nil
+}
+
+// CompleteOrder completes an order and sends its first course to the kitchen
+// later courses are held until they're fired
+// returns the order voucher
+func (s *Service) CompleteOrder(ctx context.Context, ord *Order) ([]OrderItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	// if kitchen is enabled complete order state should be    'ordered'
+	// else  state = 'dispatched'
+	ord.State = "ordered"
+	if !variables.ProductionDisp {
+		ord.State = "dispatched"
+	}
+
+	err := s.Orders.Items(ctx, ord)
+	if err != nil {
+		log.Printf("failed to complete order %v    err = %v", ord.OrderNum, err)
+		return nil, err
+	}
+
+	if len(ord.OrderItems) == 0 {
+		return nil, ErrEmptyOrder
+	}
+	if err := s.requireFeature(ctx, ord.Branch, variables.FeatureOrders); err != nil {
+		return nil, err
+	}
+
+	// a first course the kitchen doesn't get leaves the order as it was
+	_, err = s.Orders.Complete(ctx, ord, func(fired []Sales) error {
+		return s.toKitchen(ctx, *ord, fired, FirstCourse)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Events.Publish(events.Event{
+		Type:       events.OrderState,
+		Branch:     ord.Branch,
+		TillNum:    ord.TillNum,
+		ReceiptNum: ord.ReceiptNum,
+		OrderNum:   ord.OrderNum,
+		State:      ord.State,
+	})
+
+	return s.Orders.Voucher(ctx, ord.OrderNum)
+}
+
+// FireCourse sends the order's held lines up to course to the kitchen
+// returns the lines fired
+func (s *Service) FireCourse(ctx context.Context, ord *Order, course int) ([]Sales, error) {
+	if course < FirstCourse {
+		return nil, apperr.New(apperr.ValidationFailed, fmt.Sprintf("course must be at least %d", FirstCourse))
+	}
+	if err := s.requireFeature(ctx, ord.Branch, variables.FeatureOrders); err != nil {
+		return nil, err
+	}
+
+	fired, err := s.Orders.Fire(ctx, ord, course, func(fired []Sales) error {
+		return s.toKitchen(ctx, *ord, fired, course)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(fired) == 0 {
+		return nil, apperr.New(apperr.ValidationFailed, fmt.Sprintf("order %v has nothing held up to course %d", ord.OrderNum, course))
+	}
+
+	s.Events.Publish(events.Event{
+		Type:       events.CourseFired,
+		Branch:     ord.Branch,
+		TillNum:    ord.TillNum,
+		ReceiptNum: ord.ReceiptNum,
+		OrderNum:   ord.OrderNum,
+		Data:       fired,
+	})
+	return fired, nil
+}
+
+// toKitchen publishes the order's fired lines to the kitchen
+// it runs before the lines are marked fired, so a failed publish leaves them held to fire again
+func (s *Service) toKitchen(ctx context.Context, ord Order, fired []Sales, course int) error {
+	ord.OrderItems = fired
+	ord.Course = course
+
+	payLoad, err := json.Marshal(ord)
+	if err != nil {
+		return err
+	}
+
+	err = s.Publisher.Publish(ctx, "sales_orders", fmt.Sprintf("%v", ord.OrderNum), payLoad)
+	if err != nil {
+		log.Println("kafka error    failed to produce message    err =", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteOrderItem marks a pending order item as deleted
+func (s *Service) DeleteOrderItem(ctx context.Context, ord *Order, receiptItem string) ([]Sales, money.Amount, error) {
+	cart, total, err := s.Orders.DeleteItem(ctx, ord.OrderNum, receiptItem)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	s.Events.Publish(events.Event{
+		Type:       events.OrderItemDeleted,
+		Branch:     ord.Branch,
+		TillNum:    ord.TillNum,
+		ReceiptNum: ord.ReceiptNum,
+		OrderNum:   ord.OrderNum,
+		Data:       cart,
+	})
+	return cart, total, nil
+}
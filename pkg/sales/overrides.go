@@ -0,0 +1,401 @@
+package sales
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/database"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/events"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/jackc/pgx/v5"
+)
+
+// override kinds
+const (
+	OverridePrice           = "price"
+	OverrideLineDiscount    = "line_discount"
+	OverrideReceiptDiscount = "receipt_discount"
+)
+
+// Override is a manual price change or discount on a receipt
+// Amount or Percent sets a discount, Approver and ApToken are needed above the user's limit
+type Override struct {
+	ReceiptNum  int64
+	ReceiptItem string
+	Price       money.Amount
+	Amount      money.Amount
+	Percent     float64
+	Reason      string
+	Approver    string
+	ApToken     string
+}
+
+// PriceOverride records who changed a price or gave a discount and why
+// ReceiptItem is empty for receipt discounts, whose OriginalPrice is the receipt's gross total
+type PriceOverride struct {
+	table         string       `name:"price_overrides" type:"table"`
+	ID            int64        `json:"id" name:"id" type:"field" sql:"BIGSERIAL PRIMARY KEY"`
+	CreatedAt     time.Time    `json:"created_at" name:"created_at" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT now()"`
+	ReceiptNum    int64        `json:"receipt_num" name:"receipt_num" type:"field" sql:"BIGINT NOT NULL"`
+	ReceiptItem   string       `json:"receipt_item" name:"receipt_item" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
+	ItemCode      string       `json:"item_code" name:"item_code" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
+	Kind          string       `json:"kind" name:"kind" type:"field" sql:"VARCHAR NOT NULL"`
+	OriginalPrice money.Amount `json:"original_price" name:"original_price" type:"field" sql:"NUMERIC(14,2) NOT NULL DEFAULT '0'"`
+	Price         money.Amount `json:"price" name:"price" type:"field" sql:"NUMERIC(14,2) NOT NULL DEFAULT '0'"`
+	Discount      money.Amount `json:"discount" name:"discount" type:"field" sql:"NUMERIC(14,2) NOT NULL DEFAULT '0'"`
+	Percent       float64      `json:"percent" name:"percent" type:"field" sql:"FLOAT NOT NULL DEFAULT '0'"`
+	Reason        string       `json:"reason" name:"reason" type:"field" sql:"VARCHAR NOT NULL"`
+	Poster        string       `json:"poster" name:"poster" type:"field" sql:"VARCHAR NOT NULL"`
+	Approver      string       `json:"approver" name:"approver" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
+	Branch        string       `json:"branch" name:"branch" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
+	TillNum       int64        `json:"till_num" name:"till_num" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
+	constraint    string       `name:"price_overrides_kind_chk" type:"constraint" sql:"CHECK (kind IN ('price', 'line_discount', 'receipt_discount'))"`
+}
+
+func genOverrideTbl() error {
+	var tblStruct PriceOverride
+	return database.CreateFromStruct(tblStruct)
+}
+
+// Log records the override
+func (arg *PriceOverride) Log(ctx context.Context, db Querier) error {
+	sql := `INSERT INTO price_overrides(receipt_num, receipt_item, item_code, kind, original_price
+				, price, discount, percent, reason, poster, approver, branch, till_num)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			RETURNING id, created_at`
+
+	err := db.QueryRow(ctx, sql, arg.ReceiptNum, arg.ReceiptItem, arg.ItemCode, arg.Kind, arg.OriginalPrice,
+		arg.Price, arg.Discount, arg.Percent, arg.Reason, arg.Poster, arg.Approver, arg.Branch, arg.TillNum).Scan(&arg.ID, &arg.CreatedAt)
+	if err != nil {
+		log.Println("sql error. PriceOverride->Log()    err =", err)
+		return err
+	}
+	return nil
+}
+
+// Override reprices the open receipt's cart with fn and records o in the same transaction
+// so a price change is never left without its audit record
+func (arg *ReceiptLog) Override(ctx context.Context, db DBPool, o *PriceOverride, fn func([]Sales) []Sales) error {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := arg.reprice(ctx, tx, fn); err != nil {
+		return err
+	}
+	if err := o.Log(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// AddDiscount adds discounts given on the till's receipts to its cash summary
+func (arg *Till) AddDiscount(ctx context.Context, db Querier, amount money.Amount) error {
+	sql := `UPDATE sales_till
+			SET
+				cash_summary = jsonb_set(cash_summary, '{discount}', to_jsonb(coalesce((cash_summary->>'discount')::numeric, 0) + $2))
+			WHERE till_no = $1`
+
+	_, err := db.Exec(ctx, sql, arg.TillNO, amount)
+	if err != nil {
+		log.Println("sql error. Till->AddDiscount()    err =", err)
+		return err
+	}
+	return nil
+}
+
+// OverridePrice changes the price of a line on the receipt
+// a price cut above the user's discount limit needs an approver
+func (s *Service) OverridePrice(ctx context.Context, user logins.Users, rcpt *ReceiptLog, o Override) error {
+	if o.Price <= 0 {
+		return apperr.New(apperr.ValidationFailed, "price must be greater than zero")
+	}
+
+	line, err := s.cartLine(ctx, rcpt, o.ReceiptItem)
+	if err != nil {
+		return err
+	}
+
+	original := line.Price
+	if line.OriginalPrice != 0 {
+		original = line.OriginalPrice
+	}
+	percent := float64(0)
+	if o.Price < original {
+		percent = float64(original-o.Price) * 100 / float64(original)
+	}
+
+	// the line's discounts stay, so the new price and them are approved together
+	cut := (original - o.Price).Mul(line.Quantity) + line.ManualDiscount + line.ReceiptDiscount
+	approver, err := s.approveDiscount(ctx, user, cutPercent(cut, tillGross(line)), o)
+	if err != nil {
+		return err
+	}
+
+	edit := func(cart []Sales) []Sales {
+		for i := range cart {
+			if cart[i].ReceiptItem != o.ReceiptItem {
+				continue
+			}
+			cart[i].Price = o.Price
+			cart[i].OriginalPrice = original
+			if o.Price == original {
+				cart[i].OriginalPrice = 0
+			}
+		}
+		return cart
+	}
+
+	return s.override(ctx, user, rcpt, PriceOverride{
+		ReceiptItem:   line.ReceiptItem,
+		ItemCode:      line.ItemCode,
+		Kind:          OverridePrice,
+		OriginalPrice: original,
+		Price:         o.Price,
+		Percent:       round2(percent),
+		Reason:        o.Reason,
+		Approver:      approver,
+	}, edit)
+}
+
+// DiscountLine discounts a line on the receipt by Amount or Percent
+// replacing any discount given on the line before
+func (s *Service) DiscountLine(ctx context.Context, user logins.Users, rcpt *ReceiptLog, o Override) error {
+	line, err := s.cartLine(ctx, rcpt, o.ReceiptItem)
+	if err != nil {
+		return err
+	}
+
+	gross := line.Price.Mul(line.Quantity)
+	amount, percent, err := discountOf(gross, o)
+	if err != nil {
+		return err
+	}
+
+	// an overridden price and the receipt discount count towards the limit with the new discount
+	cut := tillGross(line) - gross + amount + line.ReceiptDiscount
+	approver, err := s.approveDiscount(ctx, user, cutPercent(cut, tillGross(line)), o)
+	if err != nil {
+		return err
+	}
+
+	edit := func(cart []Sales) []Sales {
+		for i := range cart {
+			if cart[i].ReceiptItem == o.ReceiptItem {
+				cart[i].ManualDiscount = amount
+			}
+		}
+		return cart
+	}
+
+	return s.override(ctx, user, rcpt, PriceOverride{
+		ReceiptItem:   line.ReceiptItem,
+		ItemCode:      line.ItemCode,
+		Kind:          OverrideLineDiscount,
+		OriginalPrice: line.Price,
+		Price:         line.Price,
+		Discount:      amount,
+		Percent:       round2(percent),
+		Reason:        o.Reason,
+		Approver:      approver,
+	}, edit)
+}
+
+// DiscountReceipt discounts the receipt's pending lines by Amount or Percent
+// the discount is shared across the lines by value and replaces any receipt discount given before
+func (s *Service) DiscountReceipt(ctx context.Context, user logins.Users, rcpt *ReceiptLog, o Override) error {
+	if o.Reason == "" {
+		return apperr.New(apperr.ValidationFailed, "reason is required")
+	}
+	if err := s.Receipt(ctx, rcpt); err != nil {
+		return err
+	}
+
+	gross, till := money.Amount(0), money.Amount(0)
+	for _, item := range rcpt.Cart {
+		gross += item.Price.Mul(item.Quantity) - item.ManualDiscount
+		till += tillGross(item)
+	}
+	if gross <= 0 {
+		return ErrEmptyReceipt
+	}
+
+	amount, percent, err := discountOf(gross, o)
+	if err != nil {
+		return err
+	}
+
+	// price overrides and line discounts count towards the limit with the receipt discount
+	approver, err := s.approveDiscount(ctx, user, cutPercent(till-gross+amount, till), o)
+	if err != nil {
+		return err
+	}
+
+	edit := func(cart []Sales) []Sales {
+		base := money.Amount(0)
+		last := -1
+		for i, item := range cart {
+			cart[i].ReceiptDiscount = 0
+			if item.State == "pending" {
+				base += item.Price.Mul(item.Quantity) - item.ManualDiscount
+				last = i
+			}
+		}
+		if base <= 0 {
+			return cart
+		}
+
+		left := amount
+		for i, item := range cart {
+			if item.State != "pending" {
+				continue
+			}
+			// the last line takes the rounding remainder
+			part := share(amount, item.Price.Mul(item.Quantity)-item.ManualDiscount, base)
+			if i == last {
+				part = left
+			}
+			cart[i].ReceiptDiscount = part
+			left -= part
+		}
+		return cart
+	}
+
+	return s.override(ctx, user, rcpt, PriceOverride{
+		Kind:          OverrideReceiptDiscount,
+		OriginalPrice: gross,
+		Price:         gross - amount,
+		Discount:      amount,
+		Percent:       round2(percent),
+		Reason:        o.Reason,
+		Approver:      approver,
+	}, edit)
+}
+
+// cartLine fetches the receipt and the pending line for the override
+func (s *Service) cartLine(ctx context.Context, rcpt *ReceiptLog, receiptItem string) (Sales, error) {
+	if receiptItem == "" {
+		return Sales{}, apperr.New(apperr.ValidationFailed, "receipt_item is required")
+	}
+	if err := s.Receipt(ctx, rcpt); err != nil {
+		return Sales{}, err
+	}
+
+	for _, item := range rcpt.Cart {
+		if item.ReceiptItem == receiptItem && item.State == "pending" {
+			return item, nil
+		}
+	}
+	return Sales{}, apperr.New(apperr.NotFound, fmt.Sprintf("item %v not found in receipt %v", receiptItem, rcpt.ReceiptNum))
+}
+
+// discountOf works out the discount amount and percent of gross asked for by o
+func discountOf(gross money.Amount, o Override) (money.Amount, float64, error) {
+	if o.Reason == "" {
+		return 0, 0, apperr.New(apperr.ValidationFailed, "reason is required")
+	}
+
+	amount := o.Amount
+	if o.Percent > 0 {
+		amount = gross.Percent(o.Percent)
+	}
+	if amount <= 0 || amount > gross {
+		return 0, 0, apperr.New(apperr.ValidationFailed, fmt.Sprintf("discount must be between 0 and %v", gross))
+	}
+	return amount, float64(amount) * 100 / float64(gross), nil
+}
+
+// tillGross is the line's value at the price it rang up at, before any price override
+func tillGross(item Sales) money.Amount {
+	price := item.Price
+	if item.OriginalPrice != 0 {
+		price = item.OriginalPrice
+	}
+	return price.Mul(item.Quantity)
+}
+
+// cutPercent is the percent of gross taken off by cut
+func cutPercent(cut, gross money.Amount) float64 {
+	if gross <= 0 {
+		return 0
+	}
+	return float64(cut) * 100 / float64(gross)
+}
+
+// approveDiscount checks a discount of percent against the user's limit
+// returns the approver when the discount is above it
+func (s *Service) approveDiscount(ctx context.Context, user logins.Users, percent float64, o Override) (string, error) {
+	if o.Reason == "" {
+		return "", apperr.New(apperr.ValidationFailed, "reason is required")
+	}
+
+	limit := float64(0)
+	if s.Settings != nil {
+		poSett, _ := s.Settings()
+		limit = poSett.DiscountLimits[user.Role]
+	}
+	if round2(percent) <= limit {
+		return "", nil
+	}
+
+	if o.Approver == "" {
+		return "", apperr.New(apperr.ApprovalRequired, fmt.Sprintf("a %.2f%% discount is above your %.2f%% limit \n approval is required", percent, limit))
+	}
+
+	authDetails, err := s.approve(ctx, o.Approver, o.ApToken, logins.RightGrantPriceChange)
+	if err != nil {
+		return "", err
+	}
+	return authDetails.Username, nil
+}
+
+// approve checks username holds right and token is their current approval token
+// returns the approver's details
+func (s *Service) approve(ctx context.Context, username, token string, right logins.Right) (logins.Users, error) {
+	authDetails, err := s.Users.FetchUser(ctx, username)
+	if err != nil {
+		return logins.Users{}, apperr.Wrap(apperr.ApprovalRequired, "failed to get approver", err)
+	}
+	if !authDetails.HasRight(right) {
+		return logins.Users{}, apperr.New(apperr.ApprovalRequired, fmt.Sprintf("approval error \n approver is forbidden from approving this \n ensure you have '%v' rights to continue", right.Title()))
+	}
+	if authDetails.Token != token {
+		return logins.Users{}, apperr.New(apperr.ApprovalRequired, "incorrect user or password \n ensure you have the correct approval token \n or you have selected the right user")
+	}
+	if time.Now().After(authDetails.TokenDate) {
+		return logins.Users{}, apperr.New(apperr.ApprovalRequired, "approval error \n Token Expired \n Please renew your token to continue")
+	}
+	return authDetails, nil
+}
+
+// override reprices the receipt with edit, records the override with it and publishes the repriced cart
+func (s *Service) override(ctx context.Context, user logins.Users, rcpt *ReceiptLog, o PriceOverride, edit func([]Sales) []Sales) error {
+	fn, err := s.priced(ctx, rcpt, edit)
+	if err != nil {
+		return err
+	}
+
+	o.ReceiptNum = rcpt.ReceiptNum
+	o.Poster = user.Username
+	o.Branch = rcpt.Branch
+	o.TillNum = rcpt.TillNum
+	if err := s.Overrides.Apply(ctx, rcpt, &o, fn); err != nil {
+		log.Printf("failed to record %v override on receipt %v    err = %v", o.Kind, rcpt.ReceiptNum, err)
+		return err
+	}
+
+	s.Events.Publish(events.Event{
+		Type:       events.CartRepriced,
+		Branch:     rcpt.Branch,
+		TillNum:    rcpt.TillNum,
+		ReceiptNum: rcpt.ReceiptNum,
+		Data:       rcpt,
+	})
+	return nil
+}
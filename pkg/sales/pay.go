@@ -1,8 +1,19 @@
 package sales
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+)
+
 type MpesaDetails struct {
-	MpesaCode string  `json:"mpesa_code"`
-	Amount    float64 `json:"mpesa_tendered"`
+	MpesaCode string       `json:"mpesa_code"`
+	Amount    money.Amount `json:"mpesa_tendered"`
 }
 
 type ETR struct {
@@ -11,4 +22,115 @@ type ETR struct {
 	DATE string `json:"date"`
 	CUSN string `json:"cusn"`
 	CUIN string `json:"cuin"`
+	// CustomerPin is the attached customer's KRA PIN for the fiscal receipt
+	CustomerPin string `json:"customer_pin,omitempty"`
+}
+
+// RoundingCode is the item code of cash rounding lines
+const RoundingCode = "ROUNDING"
+
+// roundingLine records the receipt's cash rounding as a line of its own
+// rounding isn't a supply so the line carries no vat
+func (arg *ReceiptLog) roundingLine(vatAlpha string) Sales {
+	return Sales{
+		TransDate:   time.Now(),
+		ReceiptNum:  arg.ReceiptNum,
+		ItemCode:    RoundingCode,
+		ItemName:    "Cash rounding",
+		Quantity:    1,
+		Price:       arg.Rounding,
+		Total:       arg.Rounding,
+		VatAlpha:    vatAlpha,
+		VatExempt:   true,
+		State:       "pending",
+		ReceiptItem: fmt.Sprintf("rounding-%d", arg.ReceiptNum),
+	}
+}
+
+// Payment is a tender applied to a receipt
+type Payment struct {
+	Paymode   string       `json:"paymode"`
+	Amount    money.Amount `json:"amount"`
+	Reference string       `json:"reference"`
+}
+
+// ApplyPayment adds the tender to the receipt's pay details
+// returns an error if the receipt is no longer open for payment
+func (arg *ReceiptLog) ApplyPayment(ctx context.Context, db Querier, pay Payment) error {
+	sql := `UPDATE salestrace 
+			SET 
+				state = 'paying'
+				, paymode = $2
+				, cash = coalesce(cash, 0) + CASE WHEN $2 = 'cash' THEN $3 ELSE 0 END
+				, pay_details = pay_details || jsonb_build_object($2::varchar, coalesce((pay_details->>$2::varchar)::numeric, 0) + $3)
+				, mpesa_txn = CASE WHEN $2 = 'mpesa' THEN $4 ELSE mpesa_txn END
+				, last_updated = now()
+			WHERE receipt_num = $1 AND state IN ('pending', 'paying', 'pending payment')`
+
+	tag, err := db.Exec(ctx, sql, arg.ReceiptNum, pay.Paymode, pay.Amount, pay.Reference)
+	if err != nil {
+		log.Println("sql error. ReceiptLog->ApplyPayment()    err =", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open for payment", arg.ReceiptNum))
+	}
+	return nil
+}
+
+// Tendered sums the payments applied to the receipt
+func (arg *ReceiptLog) Tendered(ctx context.Context, db Querier) (money.Amount, error) {
+	sql := `SELECT coalesce(sum(p.value::numeric), 0)
+			FROM salestrace s, jsonb_each_text(s.pay_details) p
+			WHERE s.receipt_num = $1`
+
+	tendered := money.Amount(0)
+	if err := db.QueryRow(ctx, sql, arg.ReceiptNum).Scan(&tendered); err != nil {
+		log.Println("sql error. ReceiptLog->Tendered()    err =", err)
+		return 0, err
+	}
+	return tendered, nil
+}
+
+// Post completes the paid receipt with its total, change and cash rounding
+// the rounding line is added to the stored cart and the tax summary is written to match
+func (arg *ReceiptLog) Post(ctx context.Context, db Querier) error {
+	rounding := []Sales{}
+	for _, item := range arg.Cart {
+		if item.ItemCode == RoundingCode {
+			rounding = append(rounding, item)
+		}
+	}
+	items, err := json.Marshal(rounding)
+	if err != nil {
+		return err
+	}
+	summary := SummarizeTax(arg.Cart)
+	jSummary, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	sql := `UPDATE salestrace 
+			SET 
+				state = 'POSTED'
+				, total = $2
+				, change = $3
+				, rounding = $4
+				, cart = coalesce(cart, '[]'::jsonb) || cast($5 as jsonb)
+				, tax_summary = $6
+				, last_updated = now()
+			WHERE receipt_num = $1 AND state IN ('pending', 'paying', 'pending payment')`
+
+	tag, err := db.Exec(ctx, sql, arg.ReceiptNum, arg.Total, arg.Change, arg.Rounding, string(items), string(jSummary))
+	if err != nil {
+		log.Println("sql error. ReceiptLog->Post()    err =", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open for payment", arg.ReceiptNum))
+	}
+	arg.State = "POSTED"
+	arg.TaxSummary = summary
+	return nil
 }
@@ -0,0 +1,416 @@
+package sales
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/database"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/jackc/pgx/v5"
+)
+
+// promotion kinds
+const (
+	// PromoBuyXGetY gives the cheapest GetQty of every BuyQty+GetQty units free
+	PromoBuyXGetY = "buy_x_get_y"
+	// PromoMixMatch sells any BuyQty units of the group for Amount
+	PromoMixMatch = "mix_match"
+	// PromoQuantityBreak takes Percent, or Amount per unit, off once MinQty units are bought
+	PromoQuantityBreak = "quantity_break"
+	// PromoPercent takes Percent off the items
+	PromoPercent = "percent"
+	// PromoFixed takes Amount off the items' total
+	PromoFixed = "fixed"
+)
+
+// Promotion is an offer applied to carts at sale time
+// ItemCodes lists the qualifying items, percent and fixed offers with none apply to the whole cart
+// Branches limits the offer to some branches, DailyStart and DailyEnd ("15:00") to a time of day
+type Promotion struct {
+	table      string       `name:"promotions" type:"table"`
+	ID         int64        `json:"id" name:"id" type:"field" sql:"BIGSERIAL PRIMARY KEY"`
+	Name       string       `json:"name" name:"name" type:"field" sql:"VARCHAR NOT NULL"`
+	Kind       string       `json:"kind" name:"kind" type:"field" sql:"VARCHAR NOT NULL"`
+	ItemCodes  []string     `json:"item_codes" name:"item_codes" type:"field" sql:"VARCHAR[] NOT NULL DEFAULT '{}'"`
+	BuyQty     float64      `json:"buy_qty" name:"buy_qty" type:"field" sql:"FLOAT NOT NULL DEFAULT '0'"`
+	GetQty     float64      `json:"get_qty" name:"get_qty" type:"field" sql:"FLOAT NOT NULL DEFAULT '0'"`
+	MinQty     float64      `json:"min_qty" name:"min_qty" type:"field" sql:"FLOAT NOT NULL DEFAULT '0'"`
+	Percent    float64      `json:"percent" name:"percent" type:"field" sql:"FLOAT NOT NULL DEFAULT '0'"`
+	Amount     money.Amount `json:"amount" name:"amount" type:"field" sql:"NUMERIC(14,2) NOT NULL DEFAULT '0'"`
+	Branches   []string     `json:"branches" name:"branches" type:"field" sql:"VARCHAR[] NOT NULL DEFAULT '{}'"`
+	StartsAt   time.Time    `json:"starts_at" name:"starts_at" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT now()"`
+	EndsAt     time.Time    `json:"ends_at" name:"ends_at" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT '9999-12-31'"`
+	DailyStart string       `json:"daily_start" name:"daily_start" type:"field" sql:"VARCHAR(5) NOT NULL DEFAULT ''"`
+	DailyEnd   string       `json:"daily_end" name:"daily_end" type:"field" sql:"VARCHAR(5) NOT NULL DEFAULT ''"`
+	Priority   int          `json:"priority" name:"priority" type:"field" sql:"INT NOT NULL DEFAULT '0'"`
+	Active     bool         `json:"active" name:"active" type:"field" sql:"BOOL NOT NULL DEFAULT 'true'"`
+	constraint string       `name:"promotions_name_uq" type:"constraint" sql:"UNIQUE(name)"`
+}
+
+func genPromotionTbl() error {
+	var tblStruct Promotion
+	return database.CreateFromStruct(tblStruct)
+}
+
+// ActivePromotions fetches the promotions running at the branch at the given time
+func ActivePromotions(ctx context.Context, db Querier, branch string, at time.Time) ([]Promotion, error) {
+	sql := `SELECT id, name, kind, item_codes, buy_qty, get_qty, min_qty, percent, amount
+				, branches, starts_at, ends_at, daily_start, daily_end, priority, active
+			FROM promotions
+			WHERE active AND starts_at <= $1 AND ends_at > $1
+				AND (cardinality(branches) = 0 OR $2 = ANY(branches))
+			ORDER BY priority DESC, id`
+
+	rows, err := db.Query(ctx, sql, at, branch)
+	if err != nil {
+		log.Println("sql error. ActivePromotions()    err =", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promos []Promotion
+	for rows.Next() {
+		p := Promotion{}
+		err := rows.Scan(&p.ID, &p.Name, &p.Kind, &p.ItemCodes, &p.BuyQty, &p.GetQty, &p.MinQty, &p.Percent, &p.Amount,
+			&p.Branches, &p.StartsAt, &p.EndsAt, &p.DailyStart, &p.DailyEnd, &p.Priority, &p.Active)
+		if err != nil {
+			return nil, fmt.Errorf("error. failed to scan promotions    err = %v", err)
+		}
+		promos = append(promos, p)
+	}
+	return promos, rows.Err()
+}
+
+// ActiveAt reports whether the promotion runs at the branch at t
+func (p Promotion) ActiveAt(branch string, t time.Time) bool {
+	if !p.Active || t.Before(p.StartsAt) {
+		return false
+	}
+	if !p.EndsAt.IsZero() && !t.Before(p.EndsAt) {
+		return false
+	}
+	if len(p.Branches) > 0 && !slices.Contains(p.Branches, branch) {
+		return false
+	}
+
+	if p.DailyStart == "" || p.DailyEnd == "" {
+		return true
+	}
+	return inHours(p.DailyStart, p.DailyEnd, t)
+}
+
+// qualifies reports whether the line's item takes part in the promotion
+func (p Promotion) qualifies(item Sales) bool {
+	if len(p.ItemCodes) == 0 {
+		return p.Kind == PromoPercent || p.Kind == PromoFixed
+	}
+	return slices.Contains(p.ItemCodes, item.ItemCode)
+}
+
+// run is n whole units of a cart line
+type run struct {
+	line  int
+	price money.Amount
+	n     int
+}
+
+// ApplyPromotions reprices the cart's pending lines with the promotions running at the branch at t
+// promotions apply in priority order and each unit of a line takes part in one promotion at most
+// line discounts, with any manual discount, are allocated so the tax table can work out each line's vat
+func ApplyPromotions(cart []Sales, promos []Promotion, branch string, t time.Time) []Sales {
+	free := make([]float64, len(cart))
+	for i := range cart {
+		if cart[i].State != "pending" {
+			continue
+		}
+		cart[i].Discount = 0
+		cart[i].OnOffer = false
+		cart[i].Promotions = nil
+		// lines with an overridden price are sold at that price
+		if cart[i].OriginalPrice == 0 {
+			free[i] = cart[i].Quantity
+		}
+	}
+
+	promos = slices.Clone(promos)
+	sort.SliceStable(promos, func(i, j int) bool { return promos[i].Priority > promos[j].Priority })
+
+	for _, p := range promos {
+		if !p.ActiveAt(branch, t) {
+			continue
+		}
+
+		var lines []int
+		for i, item := range cart {
+			if item.State == "pending" && free[i] > 0 && p.qualifies(item) {
+				lines = append(lines, i)
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		discounts := map[int]money.Amount{}
+		switch p.Kind {
+		case PromoPercent:
+			for _, i := range lines {
+				discounts[i] = cart[i].Price.Mul(free[i]).Percent(p.Percent)
+				free[i] = 0
+			}
+
+		case PromoFixed:
+			base := money.Amount(0)
+			for _, i := range lines {
+				base += cart[i].Price.Mul(free[i])
+			}
+			if base <= 0 || p.Amount <= 0 {
+				continue
+			}
+			amount := min(p.Amount, base)
+			left := amount
+			for k, i := range lines {
+				// the last line takes the rounding remainder
+				discounts[i] = share(amount, cart[i].Price.Mul(free[i]), base)
+				if k == len(lines)-1 {
+					discounts[i] = left
+				}
+				left -= discounts[i]
+				free[i] = 0
+			}
+
+		case PromoQuantityBreak:
+			qty := float64(0)
+			for _, i := range lines {
+				qty += free[i]
+			}
+			if qty < p.MinQty {
+				continue
+			}
+			for _, i := range lines {
+				if p.Percent > 0 {
+					discounts[i] = cart[i].Price.Mul(free[i]).Percent(p.Percent)
+				} else {
+					discounts[i] = min(p.Amount, cart[i].Price).Mul(free[i])
+				}
+				free[i] = 0
+			}
+
+		case PromoBuyXGetY:
+			size := int(p.BuyQty + p.GetQty)
+			if p.BuyQty <= 0 || p.GetQty <= 0 {
+				continue
+			}
+			runs := wholeUnits(cart, lines, free)
+			total := 0
+			for _, r := range runs {
+				total += r.n
+			}
+			// only units in whole groups take part
+			end := total - total%size
+
+			// got counts the units before the nth that fall after the buy units of their group
+			buy := int(p.BuyQty)
+			got := func(n int) int { return n/size*(size-buy) + max(n%size-buy, 0) }
+
+			at := 0
+			for _, r := range runs {
+				from, to := min(at, end), min(at+r.n, end)
+				at += r.n
+				discounts[r.line] += r.price.Mul(float64(got(to) - got(from)))
+				free[r.line] -= float64(to - from)
+			}
+
+		case PromoMixMatch:
+			size := int(p.BuyQty)
+			if size <= 0 {
+				continue
+			}
+			var group []run
+			taken := 0
+			for _, r := range wholeUnits(cart, lines, free) {
+				for r.n > 0 {
+					// groups within one line are priced alike
+					if taken == 0 && r.n >= size {
+						k := r.n / size
+						mixMatch(discounts, free, []run{{line: r.line, price: r.price, n: size}}, p.Amount, k)
+						r.n -= k * size
+						continue
+					}
+
+					take := min(r.n, size-taken)
+					group = append(group, run{line: r.line, price: r.price, n: take})
+					taken += take
+					r.n -= take
+					if taken == size {
+						mixMatch(discounts, free, group, p.Amount, 1)
+						group, taken = nil, 0
+					}
+				}
+			}
+
+		default:
+			log.Printf("unknown promotion kind %q for %v", p.Kind, p.Name)
+			continue
+		}
+
+		for i, amount := range discounts {
+			if amount <= 0 {
+				continue
+			}
+			cart[i].Discount += amount
+			cart[i].OnOffer = true
+			if !slices.Contains(cart[i].Promotions, p.Name) {
+				cart[i].Promotions = append(cart[i].Promotions, p.Name)
+			}
+		}
+	}
+
+	for i := range cart {
+		if cart[i].State != "pending" {
+			continue
+		}
+		gross := cart[i].Price.Mul(cart[i].Quantity)
+		cart[i].Discount = min(cart[i].Discount+cart[i].ManualDiscount+cart[i].ReceiptDiscount, gross)
+		cart[i].Total = gross - cart[i].Discount
+	}
+	return cart
+}
+
+// Reprice rewrites the open receipt's cart with fn and updates its total and tax summary
+// the cart row is locked so concurrent changes are repriced in turn
+// arg.VatExempt is loaded before fn runs
+func (arg *ReceiptLog) Reprice(ctx context.Context, db DBPool, fn func([]Sales) []Sales) error {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := arg.reprice(ctx, tx, fn); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// reprice rewrites the cart within the caller's transaction
+func (arg *ReceiptLog) reprice(ctx context.Context, tx Querier, fn func([]Sales) []Sales) error {
+	sql := `SELECT coalesce(cart, '[]'::jsonb), vat_exempt FROM salestrace
+			WHERE receipt_num = $1 AND state IN ('pending', 'paying', 'pending payment')
+			FOR UPDATE`
+
+	var cart []Sales
+	if err := tx.QueryRow(ctx, sql, arg.ReceiptNum).Scan(&cart, &arg.VatExempt); err != nil {
+		if err == pgx.ErrNoRows {
+			return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open", arg.ReceiptNum))
+		}
+		log.Println("sql error. ReceiptLog->Reprice()    err =", err)
+		return err
+	}
+
+	cart = fn(cart)
+
+	total := money.Amount(0)
+	for _, item := range cart {
+		if item.State == "pending" {
+			total += item.Total
+		}
+	}
+
+	summary := SummarizeTax(cart)
+
+	jCart, err := json.Marshal(cart)
+	if err != nil {
+		return err
+	}
+	jSummary, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	sql = `UPDATE salestrace SET cart = $1, total = $2, tax_summary = $3, last_updated = now() WHERE receipt_num = $4`
+	if _, err := tx.Exec(ctx, sql, string(jCart), total, string(jSummary), arg.ReceiptNum); err != nil {
+		log.Println("sql error. ReceiptLog->Reprice()    err =", err)
+		return err
+	}
+
+	arg.Cart = cart
+	arg.Total = total
+	arg.Promotions = PromotionNames(cart)
+	arg.TaxSummary = summary
+	return nil
+}
+
+// wholeUnits counts the lines' unpromoted whole units in runs, dearest first
+// units are counted rather than listed so a line's quantity doesn't size the work
+func wholeUnits(cart []Sales, lines []int, free []float64) []run {
+	var runs []run
+	for _, i := range lines {
+		if n := int(min(free[i], maxUnits)); n > 0 {
+			runs = append(runs, run{line: i, price: cart[i].Price, n: n})
+		}
+	}
+	sort.SliceStable(runs, func(a, b int) bool { return runs[a].price > runs[b].price })
+	return runs
+}
+
+// maxUnits keeps whole unit counts within exact float range
+const maxUnits = 1 << 53
+
+// mixMatch sells times groups of units at price, sharing the discount by the units' prices
+// units in a group priced at or under price take no part
+func mixMatch(discounts map[int]money.Amount, free []float64, group []run, price money.Amount, times int) {
+	sum := money.Amount(0)
+	for _, u := range group {
+		sum += u.price.Mul(float64(u.n))
+	}
+	if sum <= price {
+		return
+	}
+
+	left := sum - price
+	for k, u := range group {
+		// the last unit takes the rounding remainder
+		off := share(sum-price, u.price, sum).Mul(float64(u.n))
+		if k == len(group)-1 {
+			off = left
+		}
+		discounts[u.line] += off.Mul(float64(times))
+		left -= off
+		free[u.line] -= float64(u.n * times)
+	}
+}
+
+// PromotionNames lists the promotions applied to the cart's pending lines
+func PromotionNames(cart []Sales) []string {
+	var names []string
+	for _, item := range cart {
+		if item.State != "pending" {
+			continue
+		}
+		for _, name := range item.Promotions {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// share splits amount in the ratio part/whole, rounding to minor units
+func share(amount, part, whole money.Amount) money.Amount {
+	if whole == 0 {
+		return 0
+	}
+	return money.Amount(math.Round(float64(amount) * float64(part) / float64(whole)))
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
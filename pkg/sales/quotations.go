@@ -0,0 +1,248 @@
+package sales
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/database"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
+	"github.com/jackc/pgx/v5"
+)
+
+// quotation states
+const (
+	QuoteOpen      = "open"
+	QuoteConverted = "converted"
+	QuoteCancelled = "cancelled"
+)
+
+// QuoteDays is how long a quotation is valid for when no date is given
+const QuoteDays = 14
+
+// Quotation prices a cart for a customer without a till, until it's converted into a receipt
+type Quotation struct {
+	table      string       `name:"quotations" type:"table"`
+	QuoteNum   int64        `json:"quote_num" name:"quote_num" type:"field" sql:"BIGINT PRIMARY KEY"`
+	DailyCount int64        `json:"daily_count" name:"daily_count" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
+	TransDate  time.Time    `json:"trans_date" name:"trans_date" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT now()"`
+	ValidUntil time.Time    `json:"valid_until" name:"valid_until" type:"field" sql:"TIMESTAMPTZ NOT NULL"`
+	Branch     string       `json:"branch" name:"branch" type:"field" sql:"VARCHAR NOT NULL"`
+	CompanyID  int64        `json:"company_id" name:"company_id" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
+	Poster     string       `json:"poster" name:"poster" type:"field" sql:"VARCHAR NOT NULL"`
+	CustomerID int64        `json:"customer_id" name:"customer_id" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
+	VatExempt  bool         `json:"vat_exempt" name:"vat_exempt" type:"field" sql:"BOOL NOT NULL DEFAULT 'false'"`
+	Items      []Sales      `json:"items" name:"items" type:"field" sql:"JSONB NOT NULL DEFAULT '[]'"`
+	Total      money.Amount `json:"total" name:"total" type:"field" sql:"NUMERIC(14,2) NOT NULL DEFAULT '0'"`
+	TaxSummary []TaxBand    `json:"tax_summary" name:"tax_summary" type:"field" sql:"JSONB NOT NULL DEFAULT '[]'"`
+	State      string       `json:"state" name:"state" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'open'"`
+	// ReceiptNum is the receipt the quotation was converted into
+	ReceiptNum  int64     `json:"receipt_num" name:"receipt_num" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
+	LastUpdated time.Time `json:"last_updated" name:"last_updated" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT now()"`
+	Customer    *Customer `json:"customer,omitempty"`
+}
+
+func genQuotationTbl() error {
+	return database.CreateFromStruct(Quotation{})
+}
+
+// Expired reports whether the quotation's prices no longer hold at t
+func (arg *Quotation) Expired(t time.Time) bool {
+	return t.After(arg.ValidUntil)
+}
+
+// HeldLine is a quoted line left off a converted receipt and why, to be scanned at the till
+type HeldLine struct {
+	Line   Sales  `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// captureAtSale names what the product needs from the customer or the stock at the till, or "" when nothing
+func captureAtSale(p products.StockMaster) string {
+	switch {
+	case p.Serialized:
+		return "serial numbers are captured when sold"
+	case p.TrackBatches:
+		return "the batch is captured when dispensed"
+	case p.PrescriptionOnly:
+		return "a prescription is required"
+	case p.MinAge > 0:
+		return "the customer's age is checked when sold"
+	}
+	return ""
+}
+
+// Create numbers the quotation and saves it
+// numbers are 2YYYYMMDD0 followed by the day's count, keeping them apart from receipts
+func (arg *Quotation) Create(ctx context.Context, db Querier) error {
+	sql := `INSERT INTO quotations(quote_num, daily_count, valid_until, branch, company_id, poster, customer_id, vat_exempt)
+			SELECT CAST(CONCAT(
+						cast(2 as varchar)
+						, extract(YEAR FROM now())
+						, LPAD(EXTRACT(MONTH FROM now())::text, 2, '0')
+						, LPAD(EXTRACT(DAY FROM now())::text, 2, '0')
+						, '0'
+						, cast(coalesce(max(daily_count), 0) + 1 as varchar)
+					) AS BIGINT)
+				, coalesce(max(daily_count), 0) + 1
+				, $1, $2, $3, $4, $5, $6
+			FROM quotations WHERE trans_date::date = (SELECT now()::date)
+			RETURNING quote_num, daily_count, trans_date, state`
+
+	err := db.QueryRow(ctx, sql, arg.ValidUntil, arg.Branch, arg.CompanyID, arg.Poster, arg.CustomerID, arg.VatExempt).
+		Scan(&arg.QuoteNum, &arg.DailyCount, &arg.TransDate, &arg.State)
+	if err != nil {
+		log.Println("sql error. Quotation->Create()    err =", err)
+		return err
+	}
+	arg.Items = []Sales{}
+	return nil
+}
+
+// Fetch loads the quotation by its number
+func (arg *Quotation) Fetch(ctx context.Context, db Querier) error {
+	sql := `SELECT quote_num, daily_count, trans_date, valid_until, branch, company_id, poster, customer_id, vat_exempt
+				, items, total, tax_summary, state, receipt_num, last_updated
+			FROM quotations
+			WHERE quote_num = $1`
+
+	err := db.QueryRow(ctx, sql, arg.QuoteNum).Scan(&arg.QuoteNum, &arg.DailyCount, &arg.TransDate, &arg.ValidUntil,
+		&arg.Branch, &arg.CompanyID, &arg.Poster, &arg.CustomerID, &arg.VatExempt,
+		&arg.Items, &arg.Total, &arg.TaxSummary, &arg.State, &arg.ReceiptNum, &arg.LastUpdated)
+	if err == pgx.ErrNoRows {
+		return apperr.New(apperr.NotFound, fmt.Sprintf("quotation %v not found", arg.QuoteNum))
+	}
+	if err != nil {
+		log.Println("sql error. Quotation->Fetch()    err =", err)
+	}
+	return err
+}
+
+// Edit rewrites the open quotation's lines with fn and totals them
+func (arg *Quotation) Edit(ctx context.Context, db DBPool, fn func([]Sales) []Sales) error {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	sql := `SELECT items FROM quotations WHERE quote_num = $1 AND state = 'open' FOR UPDATE`
+
+	var items []Sales
+	if err := tx.QueryRow(ctx, sql, arg.QuoteNum).Scan(&items); err != nil {
+		if err == pgx.ErrNoRows {
+			return apperr.New(apperr.QuoteClosed, fmt.Sprintf("quotation %v is not open", arg.QuoteNum))
+		}
+		log.Println("sql error. Quotation->Edit()    err =", err)
+		return err
+	}
+
+	items = fn(items)
+
+	total := money.Amount(0)
+	for _, item := range items {
+		if item.State == "pending" {
+			total += item.Total
+		}
+	}
+	summary := SummarizeTax(items)
+
+	jItems, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	jSummary, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	sql = `UPDATE quotations SET items = $1, total = $2, tax_summary = $3, last_updated = now() WHERE quote_num = $4`
+	if _, err := tx.Exec(ctx, sql, string(jItems), total, string(jSummary), arg.QuoteNum); err != nil {
+		log.Println("sql error. Quotation->Edit()    err =", err)
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	arg.Items = items
+	arg.Total = total
+	arg.TaxSummary = summary
+	return nil
+}
+
+// Close moves the open quotation to its State, recording the ReceiptNum it was converted into
+func (arg *Quotation) Close(ctx context.Context, db Querier) error {
+	sql := `UPDATE quotations SET state = $2, receipt_num = $3, last_updated = now()
+			WHERE quote_num = $1 AND state = 'open'`
+
+	tag, err := db.Exec(ctx, sql, arg.QuoteNum, arg.State, arg.ReceiptNum)
+	if err != nil {
+		log.Println("sql error. Quotation->Close()    err =", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.New(apperr.QuoteClosed, fmt.Sprintf("quotation %v is not open", arg.QuoteNum))
+	}
+	return nil
+}
+
+var quoteTmpl = template.Must(template.New("quotation").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.Format("02 Jan 2006") },
+	"qty":  func(q float64) string { return fmt.Sprintf("%g", q) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Quotation {{.Quote.QuoteNum}}</title>
+<style>
+body { font-family: sans-serif; font-size: 12px; margin: 24px; }
+table { width: 100%; border-collapse: collapse; margin-top: 16px; }
+th, td { padding: 4px 6px; border-bottom: 1px solid #ccc; text-align: left; }
+.num { text-align: right; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h2>{{.Head.CompanyName}}</h2>
+<div>{{.Head.Location}}{{if .Head.Box}}, {{.Head.Box}}{{end}}</div>
+<div>{{.Head.Telephone}} {{.Head.Email}}</div>
+{{if .Head.CompanyPin}}<div>PIN: {{.Head.CompanyPin}}</div>{{end}}
+
+<h3>{{if eq .Quote.State "open"}}QUOTATION{{else}}QUOTATION ({{.Quote.State}}){{end}} {{.Quote.QuoteNum}}</h3>
+<div>Date: {{date .Quote.TransDate}}</div>
+<div>Valid until: {{date .Quote.ValidUntil}}</div>
+<div>Prepared by: {{.Quote.Poster}}</div>
+{{with .Quote.Customer}}<div>Customer: {{.Name}} {{.Phone}}{{if .KraPin}} PIN: {{.KraPin}}{{end}}</div>{{end}}
+
+<table>
+<tr><th>Code</th><th>Description</th><th class="num">Qty</th><th class="num">Price</th><th class="num">Discount</th><th class="num">Total</th><th>Tax</th></tr>
+{{range .Quote.Items}}{{if eq .State "pending"}}<tr><td>{{.ItemCode}}</td><td>{{.ItemName}}</td><td class="num">{{qty .Quantity}}</td><td class="num">{{.Price}}</td><td class="num">{{.Discount}}</td><td class="num">{{.Total}}</td><td>{{.VatAlpha}}</td></tr>
+{{end}}{{end}}</table>
+
+<table>
+<tr><th>Tax</th><th class="num">Net</th><th class="num">Vat</th><th class="num">Gross</th></tr>
+{{range .Quote.TaxSummary}}<tr><td>{{.Code}}{{if .Exempt}} exempt{{else}} {{.Rate}}%{{end}}</td><td class="num">{{.Net}}</td><td class="num">{{.Vat}}</td><td class="num">{{.Gross}}</td></tr>
+{{end}}<tr><th>Total</th><th></th><th></th><th class="num">{{.Quote.Total}}</th></tr>
+</table>
+
+<p>Prices are valid until {{date .Quote.ValidUntil}} and subject to stock availability.</p>
+</body>
+</html>
+`))
+
+// WriteQuotation writes the quotation as a printable html document under the company's heading
+func WriteQuotation(w io.Writer, q Quotation, head variables.DocHead) error {
+	return quoteTmpl.Execute(w, struct {
+		Head  variables.DocHead
+		Quote Quotation
+	}{head, q})
+}
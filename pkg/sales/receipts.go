@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/JohnnyKahiu/speedsales/poserver/database"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
 	"github.com/jackc/pgx/v5"
 )
 
@@ -23,15 +25,16 @@ type ReceiptLog struct {
 	DailyCount      int32                  `json:"daily_count" name:"daily_count" type:"field" sql:"INT"`
 	Branch          string                 `json:"branch" name:"branch" type:"field" sql:"VARCHAR"`
 	Poster          string                 `json:"poster" name:"poster" type:"field" sql:"VARCHAR"`
-	Total           float32                `json:"total" name:"total" type:"field" sql:"FLOAT NOT NULL DEFAULT '0'"`
-	Cash            float32                `json:"cash" name:"cash" type:"field" sql:"FLOAT NOT NULL DEFAULT '0'"`
-	Change          float32                `json:"change" name:"change" type:"field" sql:"FLOAT"`
+	Total           money.Amount           `json:"total" name:"total" type:"field" sql:"NUMERIC(14,2) NOT NULL DEFAULT '0'"`
+	Rounding        money.Amount           `json:"rounding" name:"rounding" type:"field" sql:"NUMERIC(14,2) NOT NULL DEFAULT '0'"`
+	Cash            money.Amount           `json:"cash" name:"cash" type:"field" sql:"NUMERIC(14,2) NOT NULL DEFAULT '0'"`
+	Change          money.Amount           `json:"change" name:"change" type:"field" sql:"NUMERIC(14,2)"`
 	MpesaDetails    []MpesaDetails         `json:"mpesa_details" name:"mpesa_details" type:"field" sql:"JSONB"`
 	Loyalty         map[string]string      `json:"loyalty" name:"loyalty" type:"field" sql:"JSONB"`
 	Paymode         string                 `json:"paymode" name:"paymode" type:"field" sql:"VARCHAR"`
 	SaleType        string                 `json:"sale_type" name:"sale_type" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'Cash Sale'"`
 	Cart            []Sales                `json:"cart" name:"cart" type:"field" sql:"JSONB"`
-	CashBal         float64                `json:"cash_bal" name:"cash_bal" type:"field" sql:"FLOAT NOT NULL DEFAULT '0'"`
+	CashBal         money.Amount           `json:"cash_bal" name:"cash_bal" type:"field" sql:"NUMERIC(14,2) NOT NULL DEFAULT '0'"`
 	State           string                 `json:"state" name:"state" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'pending'"`
 	Approver        string                 `json:"approver" name:"approver" type:"field" sql:"VARCHAR(100) NOT NULL DEFAULT 'nan'"`
 	MirroredBy      []string               `json:"mirrored_by" name:"mirrored_by" type:"field"  sql:"VARCHAR[]"`
@@ -44,9 +47,14 @@ type ReceiptLog struct {
 	OrdersInBill    int                    `json:"orders_in_bill" name:"orders_in_bill" type:"field" sql:"INT NOT NULL DEFAULT '0'"`
 	Etr             ETR                    `json:"etr" name:"etr" type:"field" sql:"JSONB"`
 	ReturnTrace     int64                  `json:"return_trace" name:"return_trace" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
+	CustomerID      int64                  `json:"customer_id" name:"customer_id" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
 	Analysis        map[string]interface{} `json:"analysis" name:"analysis" type:"field" sql:"JSONB"`
 	AcNum           string                 `json:"ac_num" name:"ac_num" type:"field" sql:"VARCHAR"`
+	VatExempt       bool                   `json:"vat_exempt" name:"vat_exempt" type:"field" sql:"BOOL NOT NULL DEFAULT 'false'"`
+	VatExemptRef    string                 `json:"vat_exempt_ref" name:"vat_exempt_ref" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
+	TaxSummary      []TaxBand              `json:"tax_summary" name:"tax_summary" type:"field" sql:"JSONB NOT NULL DEFAULT '[]'"`
 	Token           string                 `json:"token"`
+	Promotions      []string               `json:"promotions,omitempty"`
 	constraint      string                 `name:"" type:"field" sql:"CONSTRAINT fk_salestrace_till_num FOREIGN KEY (till_num) REFERENCES sales_till(till_no)"`
 	debtoronstraint string                 `name:"" type:"field" sql:"CONSTRAINT fk_debtors_acnum FOREIGN KEY (ac_num) REFERENCES debtors(ac_num)"`
 }
@@ -56,13 +64,9 @@ func genReceiptTbl() error {
 	return database.CreateFromStruct(tblStruct)
 }
 
-// GenReceipt creates or returns next available sales receipt
-func (arg *ReceiptLog) GenReceipt() error {
-	start := time.Now()
-	fmt.Println("sale type =", arg.SaleType)
-	fmt.Println("laybye id =", arg.LaybyeID)
-	defer fmt.Printf("GenReceipt took %v", time.Since(start))
-
+// Pending fetches the latest pending receipt for the till
+// sets ReceiptNum to 0 when the till has no pending receipt
+func (arg *ReceiptLog) Pending(ctx context.Context, db Querier) error {
 	if arg.TillNum == 0 {
 		return fmt.Errorf("op error, till num is null")
 	}
@@ -78,40 +82,26 @@ func (arg *ReceiptLog) GenReceipt() error {
 				AND sale_type = $2`
 
 	// Query database rows
-	rows, err := database.PgPool.Query(context.Background(), sql, arg.TillNum, arg.SaleType)
+	rows, err := db.Query(ctx, sql, arg.TillNum, arg.SaleType)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
 	// scan rows
+	arg.ReceiptNum = 0
 	for rows.Next() {
 		rows.Scan(&arg.ReceiptNum)
 	}
 
 	fmt.Println("Gen Receipt num =", arg.ReceiptNum)
-	// return receipt number when there exists a pending receipt number
-	if arg.ReceiptNum > 0 {
-		elapsed := time.Since(start)
-		fmt.Printf("\n\t\t function GenReceipt() took:   %v \n", elapsed)
-		return nil
-	}
-
-	// create a new receipt number
-	arg.ReceiptNum, err = arg.CreateReceipt()
-	fmt.Printf("\nreceipt = %v\n", arg.ReceiptNum)
-	if err != nil {
-		fmt.Printf("Error creating receipt %v\n", err.Error())
-		return err
-	}
-
 	return nil
 }
 
-// GenReceipt creates or returns next available sales receipt
-func (arg *ReceiptLog) Fetch() error {
+// Fetch gets a receipt and its pending cart items
+func (arg *ReceiptLog) Fetch(ctx context.Context, db Querier) error {
 	start := time.Now()
-	defer fmt.Printf("GenReceipt took %v", time.Since(start))
+	defer func() { fmt.Printf("Fetch took %v", time.Since(start)) }()
 
 	if arg.ReceiptNum == 0 {
 		return fmt.Errorf("op error, receipt num is null")
@@ -133,11 +123,17 @@ func (arg *ReceiptLog) Fetch() error {
 				, coalesce(cart::varchar, '')
 				, pay_details
 				, total
+				, vat_exempt
+				, vat_exempt_ref
+				, cash
+				, rounding
+				, customer_id
+				, coalesce(etr, '{}'::jsonb)
 			FROM salestrace
 			WHERE receipt_num = $1`
 
 	// Query database rows
-	rows, err := database.PgPool.Query(context.Background(), sql, arg.ReceiptNum)
+	rows, err := db.Query(ctx, sql, arg.ReceiptNum)
 	if err != nil {
 		fmt.Printf("operation error \n%v", err.Error())
 		return err
@@ -150,7 +146,8 @@ func (arg *ReceiptLog) Fetch() error {
 	arg.ReceiptNum = 0
 	for rows.Next() {
 		err := rows.Scan(&arg.TransDate, &arg.ReceiptNum, &arg.TillNum, &arg.PayTill, &arg.Branch, &arg.Poster,
-			&arg.Total, &arg.Change, &arg.State, &arg.Approver, &cart, &payDets, &arg.Total)
+			&arg.Total, &arg.Change, &arg.State, &arg.Approver, &cart, &payDets, &arg.Total, &arg.VatExempt, &arg.VatExemptRef,
+			&arg.Cash, &arg.Rounding, &arg.CustomerID, &arg.Etr)
 		if err != nil {
 			fmt.Printf("error. failed to scan receipt_log items \n\t %v\n\n", err.Error())
 			return fmt.Errorf("error. failed to scan receipt log items    err = %v", err)
@@ -168,7 +165,7 @@ func (arg *ReceiptLog) Fetch() error {
 	Cart := []Sales{}
 	for _, item := range arg.Cart {
 		if item.State == "pending" {
-			arg.Total += (float32(item.Price) * float32(item.Quantity))
+			arg.Total += item.Total
 
 			Cart = append(Cart, item)
 		}
@@ -176,14 +173,16 @@ func (arg *ReceiptLog) Fetch() error {
 	}
 
 	arg.Cart = Cart
+	arg.Promotions = PromotionNames(arg.Cart)
+	arg.TaxSummary = SummarizeTax(arg.Cart)
 
 	return nil
 }
 
-// FetchAll creates or returns next available sales receipt
-func (arg *ReceiptLog) FetchAll() error {
+// FetchAll gets a receipt with its payment, loyalty and analysis details
+func (arg *ReceiptLog) FetchAll(ctx context.Context, db Querier) error {
 	start := time.Now()
-	defer fmt.Printf("GenReceipt took %v", time.Since(start))
+	defer func() { fmt.Printf("FetchAll took %v", time.Since(start)) }()
 
 	if arg.ReceiptNum == 0 {
 		return fmt.Errorf("op error, receipt num is null")
@@ -193,14 +192,14 @@ func (arg *ReceiptLog) FetchAll() error {
 	// get all pending receipts for current user
 	sql := `SELECT
 				trans_date, receipt_num, till_num, pay_till, branch, poster
-				, coalesce(total, 0), coalesce(cash, 0), coalesce(change, 0), loyalty, state, approver
+				, coalesce(total, 0), coalesce(cash, 0), coalesce(change, 0), rounding, loyalty, state, approver
 				, coalesce(cart::varchar, ''), pay_details, etr_seal, coalesce(orders_in_bill, 0)
 				, analysis::varchar, last_updated
 			FROM salestrace
 			WHERE receipt_num = $1`
 
 	// Query database rows
-	rows, err := database.PgPool.Query(context.Background(), sql, arg.ReceiptNum)
+	rows, err := db.Query(ctx, sql, arg.ReceiptNum)
 	if err != nil {
 		fmt.Printf("operation error \n%v", err.Error())
 		return err
@@ -216,7 +215,7 @@ func (arg *ReceiptLog) FetchAll() error {
 		analysis := ""
 
 		err := rows.Scan(&arg.TransDate, &arg.ReceiptNum, &arg.TillNum, &arg.PayTill, &arg.Branch, &arg.Poster,
-			&arg.Total, &arg.Cash, &arg.Change, &loyalty, &arg.State, &arg.Approver,
+			&arg.Total, &arg.Cash, &arg.Change, &arg.Rounding, &loyalty, &arg.State, &arg.Approver,
 			&cart, &arg.PayDetails, &arg.EtrSeal, &arg.OrdersInBill,
 			&analysis, &arg.LastUpdated)
 		if err != nil {
@@ -245,15 +244,17 @@ func (arg *ReceiptLog) FetchAll() error {
 	arg.Total = 0
 	for _, item := range arg.Cart {
 		if item.State == "pending" {
-			arg.Total += (float32(item.Price) * float32(item.Quantity))
+			arg.Total += item.Total
 		}
 	}
+	arg.Promotions = PromotionNames(arg.Cart)
+	arg.TaxSummary = SummarizeTax(arg.Cart)
 
 	return nil
 }
 
-func (arg *ReceiptLog) Archive() error {
-	err := arg.FetchAll()
+func (arg *ReceiptLog) Archive(ctx context.Context, db Querier) error {
+	err := arg.FetchAll(ctx, db)
 	if err != nil {
 		return err
 	}
@@ -265,7 +266,7 @@ func (arg *ReceiptLog) FetchRange() error {
 	return nil
 }
 
-func (arg *ReceiptLog) GetActiveCarts() ([]ReceiptLog, error) {
+func (arg *ReceiptLog) GetActiveCarts(ctx context.Context, db Querier) ([]ReceiptLog, error) {
 	activeRcpts := []ReceiptLog{}
 
 	sql := `SELECT 
@@ -281,7 +282,7 @@ func (arg *ReceiptLog) GetActiveCarts() ([]ReceiptLog, error) {
 			ORDER BY trans_date ASC`
 
 	// Query database rows
-	rows, err := database.PgPool.Query(context.Background(), sql, arg.TillNum)
+	rows, err := db.Query(ctx, sql, arg.TillNum)
 	if err != nil {
 		log.Printf("operation error \n%v", err.Error())
 		return activeRcpts, err
@@ -305,10 +306,10 @@ func (arg *ReceiptLog) GetActiveCarts() ([]ReceiptLog, error) {
 	return activeRcpts, nil
 }
 
-func (arg *ReceiptLog) GetEmpty() (int, error) {
+func (arg *ReceiptLog) GetEmpty(ctx context.Context, db Querier) (int, error) {
 	sql := `SELECT count(*) FROM salestrace WHERE receipt_num = $1 AND cart IS NULL`
 
-	rows, err := database.PgPool.Query(context.Background(), sql, arg.ReceiptNum)
+	rows, err := db.Query(ctx, sql, arg.ReceiptNum)
 	if err != nil {
 		return 0, err
 	}
@@ -325,23 +326,31 @@ func (arg *ReceiptLog) GetEmpty() (int, error) {
 	return counted, nil
 }
 
-func (arg *ReceiptLog) Delete() error {
+func (arg *ReceiptLog) Delete(ctx context.Context, db Querier) error {
 	sql := `UPDATE salestrace SET state = 'VOIDED' WHERE receipt_num = $1 AND state not in ('POSTED', 'DEBITED', 'CREDITED', 'PAID', 'AWAITING RECEIPT')`
 
-	_, err := database.PgPool.Exec(context.Background(), sql, arg.ReceiptNum)
+	_, err := db.Exec(ctx, sql, arg.ReceiptNum)
 	if err != nil {
 		return fmt.Errorf("failed to void receipt")
 	}
 	return nil
 }
 
+// closedStates are the states of settled or voided receipts, which can't be voided
+var closedStates = []string{"POSTED", "DEBITED", "CREDITED", "PAID", "AWAITING RECEIPT", "VOIDED"}
+
+// DeleteCtx voids the receipt
+// returns an error if the receipt is already settled or voided
 func (arg *ReceiptLog) DeleteCtx(ctx context.Context, tx pgx.Tx) error {
-	sql := `UPDATE salestrace SET state = 'VOIDED' WHERE receipt_num = $1 AND state not in ('POSTED', 'DEBITED', 'CREDITED', 'PAID', 'AWAITING RECEIPT')`
+	sql := `UPDATE salestrace SET state = 'VOIDED' WHERE receipt_num = $1 AND state <> ALL($2)`
 
-	_, err := tx.Exec(ctx, sql, arg.ReceiptNum)
+	tag, err := tx.Exec(ctx, sql, arg.ReceiptNum, closedStates)
 	if err != nil {
 		return fmt.Errorf("failed to void receipt")
 	}
+	if tag.RowsAffected() == 0 {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open", arg.ReceiptNum))
+	}
 	return nil
 }
 
@@ -355,10 +364,8 @@ func (arg *ReceiptLog) DelOrderCtx(ctx context.Context, tx pgx.Tx) error {
 	return nil
 }
 
-func (arg *ReceiptLog) DelCascade() error {
-	ctx := context.Background()
-
-	tx, err := database.PgPool.BeginTx(ctx, pgx.TxOptions{})
+func (arg *ReceiptLog) DelCascade(ctx context.Context, db DBPool) error {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return err
 	}
@@ -377,11 +384,11 @@ func (arg *ReceiptLog) DelCascade() error {
 	return tx.Commit(ctx)
 }
 
-func (arg *ReceiptLog) Suspend() error {
+func (arg *ReceiptLog) Suspend(ctx context.Context, db Querier) error {
 	sql := `UPDATE salestrace SET state = 'suspend' 
 			WHERE till_num = $1 AND state = 'pending' AND cart IS NOT NULL `
 
-	_, err := database.PgPool.Exec(context.Background(), sql, arg.TillNum)
+	_, err := db.Exec(ctx, sql, arg.TillNum)
 	if err != nil {
 		log.Println("suspend error ", err)
 		return err
@@ -389,7 +396,7 @@ func (arg *ReceiptLog) Suspend() error {
 	return nil
 }
 
-func (arg *ReceiptLog) NewBill() error {
+func (arg *ReceiptLog) NewBill(ctx context.Context, db Querier) error {
 	sql := `UPDATE salestrace st
 			SET
 				state = 'suspend'
@@ -405,7 +412,7 @@ func (arg *ReceiptLog) NewBill() error {
 			WHERE st.receipt_num = a.receipt_num
 		`
 
-	_, err := database.PgPool.Exec(context.Background(), sql, arg.TillNum)
+	_, err := db.Exec(ctx, sql, arg.TillNum)
 	if err != nil {
 		log.Println("suspend error ", err)
 		return err
@@ -413,6 +420,57 @@ func (arg *ReceiptLog) NewBill() error {
 	return nil
 }
 
+// AddItem appends an item to the receipt's cart
+// returns an error if the receipt is no longer open
+func (arg *ReceiptLog) AddItem(ctx context.Context, db Querier, item Sales) error {
+	items, err := json.Marshal([]Sales{item})
+	if err != nil {
+		return err
+	}
+
+	sql := `UPDATE salestrace 
+			SET 
+				cart = coalesce(cart, '[]'::jsonb) || cast($1 as jsonb)
+				, total = coalesce(total, 0) + $2
+				, last_updated = now()
+			WHERE receipt_num = $3 AND state IN ('pending', 'paying')`
+
+	tag, err := db.Exec(ctx, sql, string(items), item.Total, arg.ReceiptNum)
+	if err != nil {
+		log.Println("sql error. ReceiptLog->AddItem()    err =", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open", arg.ReceiptNum))
+	}
+	return nil
+}
+
+// OpenQuantity sums the item's quantity in the branch's open carts and the orders on its open bills
+func (arg *ReceiptLog) OpenQuantity(ctx context.Context, db Querier, itemCode string) (float64, error) {
+	sql := `SELECT coalesce(sum(qty), 0) FROM (
+				SELECT (item->>'quantity')::float qty
+				FROM salestrace s CROSS JOIN jsonb_array_elements(coalesce(s.cart, '[]'::jsonb)) item
+				WHERE s.branch = $1 AND s.state IN ('pending', 'paying', 'pending payment', 'suspend')
+					AND item->>'item_code' = $2 AND item->>'state' = 'pending'
+				UNION ALL
+				SELECT (item->>'quantity')::float qty
+				FROM salesorders o JOIN salestrace s ON s.receipt_num = o.receipt_num
+					CROSS JOIN jsonb_array_elements(coalesce(o.order_items, '[]'::jsonb)) item
+				WHERE o.branch = $1 AND s.state IN ('pending', 'suspend')
+					AND o.state NOT IN ('paying', 'voided', 'VOIDED', 'deleted', 'DELETED')
+					AND item->>'item_code' = $2 AND item->>'state' NOT IN ('DELETED', 'VOIDED')
+			) open_items`
+
+	qty := float64(0)
+	err := db.QueryRow(ctx, sql, arg.Branch, itemCode).Scan(&qty)
+	if err != nil {
+		log.Println("sql error. ReceiptLog->OpenQuantity()    err =", err)
+		return 0, err
+	}
+	return qty, nil
+}
+
 func (arg *ReceiptLog) ResumeOrderContext(ctx context.Context, tx pgx.Tx) error {
 	sql := `UPDATE salesorders 
 			SET 
@@ -442,9 +500,8 @@ func (arg *ReceiptLog) ResumeBillContext(ctx context.Context, tx pgx.Tx) error {
 	return nil
 }
 
-func (arg *ReceiptLog) Resume() error {
-	ctx := context.Background()
-	tx, err := database.PgPool.BeginTx(ctx, pgx.TxOptions{})
+func (arg *ReceiptLog) Resume(ctx context.Context, db DBPool) error {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return err
 	}
@@ -460,13 +517,11 @@ func (arg *ReceiptLog) Resume() error {
 		return err
 	}
 
-	tx.Commit(ctx)
-	return nil
+	return tx.Commit(ctx)
 }
 
-func (arg *ReceiptLog) Merge(receipts []int64) error {
-	ctx := context.Background()
-	tx, err := database.PgPool.BeginTx(ctx, pgx.TxOptions{})
+func (arg *ReceiptLog) Merge(ctx context.Context, db DBPool, receipts []int64) error {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return err
 	}
@@ -502,8 +557,7 @@ func (arg *ReceiptLog) Merge(receipts []int64) error {
 		return err
 	}
 
-	tx.Commit(ctx)
-	return nil
+	return tx.Commit(ctx)
 }
 
 func (arg *ReceiptLog) CombineOrdersContext(receiptCombo string, ctx context.Context, tx pgx.Tx) error {
@@ -585,7 +639,7 @@ func (arg *ReceiptLog) CombineReceiptsContext(ctx context.Context, tx pgx.Tx) er
 	arg.Total = 0
 	for _, row := range arg.Cart {
 		if row.State != "DELETED" {
-			arg.Total += (float32(row.Quantity) * float32(row.Price))
+			arg.Total += row.Price.Mul(row.Quantity)
 		}
 	}
 
@@ -599,7 +653,7 @@ func (arg *ReceiptLog) CombineReceiptsContext(ctx context.Context, tx pgx.Tx) er
 }
 
 func (arg *ReceiptLog) CommitSaleCtx(ctx context.Context, tx pgx.Tx) error {
-	err := arg.Fetch()
+	err := arg.Fetch(ctx, tx)
 	if err != nil {
 		return err
 	}
@@ -624,8 +678,8 @@ func (arg *ReceiptLog) CommitSaleCtx(ctx context.Context, tx pgx.Tx) error {
 }
 
 // Summarize: gives an analysis of how much time was taken
-func (arg *ReceiptLog) Analyze() error {
-	err := arg.Fetch()
+func (arg *ReceiptLog) Analyze(ctx context.Context, db Querier) error {
+	err := arg.Fetch(ctx, db)
 	if err != nil {
 		return err
 	}
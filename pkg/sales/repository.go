@@ -0,0 +1,373 @@
+package sales
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+)
+
+// pgReceipts implements ReceiptRepository on postgres
+type pgReceipts struct {
+	db DBPool
+}
+
+// NewReceiptRepository returns a postgres backed ReceiptRepository
+func NewReceiptRepository(db DBPool) ReceiptRepository {
+	return &pgReceipts{db: db}
+}
+
+func (r *pgReceipts) Pending(ctx context.Context, rcpt *ReceiptLog) error {
+	return rcpt.Pending(ctx, r.db)
+}
+
+func (r *pgReceipts) Create(ctx context.Context, rcpt *ReceiptLog) error {
+	_, err := rcpt.CreateReceipt(ctx, r.db)
+	return err
+}
+
+func (r *pgReceipts) Fetch(ctx context.Context, rcpt *ReceiptLog) error {
+	return rcpt.Fetch(ctx, r.db)
+}
+
+func (r *pgReceipts) FetchAll(ctx context.Context, rcpt *ReceiptLog) error {
+	return rcpt.FetchAll(ctx, r.db)
+}
+
+func (r *pgReceipts) ActiveCarts(ctx context.Context, tillNum int64) ([]ReceiptLog, error) {
+	rcpt := ReceiptLog{TillNum: tillNum}
+	return rcpt.GetActiveCarts(ctx, r.db)
+}
+
+func (r *pgReceipts) AddItem(ctx context.Context, rcpt *ReceiptLog, item Sales) error {
+	return rcpt.AddItem(ctx, r.db, item)
+}
+
+func (r *pgReceipts) Suspend(ctx context.Context, tillNum int64) error {
+	rcpt := ReceiptLog{TillNum: tillNum}
+	return rcpt.Suspend(ctx, r.db)
+}
+
+func (r *pgReceipts) NewBill(ctx context.Context, tillNum int64) error {
+	rcpt := ReceiptLog{TillNum: tillNum}
+	return rcpt.NewBill(ctx, r.db)
+}
+
+func (r *pgReceipts) Resume(ctx context.Context, rcpt *ReceiptLog) error {
+	return rcpt.Resume(ctx, r.db)
+}
+
+func (r *pgReceipts) Merge(ctx context.Context, rcpt *ReceiptLog, receipts []int64) error {
+	return rcpt.Merge(ctx, r.db, receipts)
+}
+
+func (r *pgReceipts) Split(ctx context.Context, rcpt *ReceiptLog, split Split) ([]ReceiptLog, error) {
+	return rcpt.Split(ctx, r.db, split)
+}
+
+func (r *pgReceipts) CloseBill(ctx context.Context, rcpt *ReceiptLog) error {
+	return rcpt.CloseBill(ctx, r.db)
+}
+
+func (r *pgReceipts) Void(ctx context.Context, rcpt *ReceiptLog) error {
+	return rcpt.DelCascade(ctx, r.db)
+}
+
+func (r *pgReceipts) Pay(ctx context.Context, rcpt *ReceiptLog, pay Payment) error {
+	return rcpt.ApplyPayment(ctx, r.db, pay)
+}
+
+func (r *pgReceipts) Tendered(ctx context.Context, receiptNum int64) (money.Amount, error) {
+	rcpt := ReceiptLog{ReceiptNum: receiptNum}
+	return rcpt.Tendered(ctx, r.db)
+}
+
+func (r *pgReceipts) Post(ctx context.Context, rcpt *ReceiptLog) error {
+	return rcpt.Post(ctx, r.db)
+}
+
+func (r *pgReceipts) Reprice(ctx context.Context, rcpt *ReceiptLog, fn func([]Sales) []Sales) error {
+	return rcpt.Reprice(ctx, r.db, fn)
+}
+
+func (r *pgReceipts) OpenQuantity(ctx context.Context, branch, itemCode string) (float64, error) {
+	rcpt := ReceiptLog{Branch: branch}
+	return rcpt.OpenQuantity(ctx, r.db, itemCode)
+}
+
+func (r *pgReceipts) Dispensed(ctx context.Context, branch string, from, to time.Time) ([]Dispensed, error) {
+	rcpt := ReceiptLog{Branch: branch}
+	return rcpt.Dispensed(ctx, r.db, from, to)
+}
+
+func (r *pgReceipts) SerialSales(ctx context.Context, serial string) ([]SerialSale, error) {
+	rcpt := ReceiptLog{}
+	return rcpt.SerialSales(ctx, r.db, serial)
+}
+
+func (r *pgReceipts) Returned(ctx context.Context, receiptNum int64) (map[string]float64, error) {
+	rcpt := ReceiptLog{ReceiptNum: receiptNum}
+	return rcpt.Returned(ctx, r.db)
+}
+
+func (r *pgReceipts) Return(ctx context.Context, rcpt *ReceiptLog) error {
+	return rcpt.Return(ctx, r.db)
+}
+
+func (r *pgReceipts) AgeRestricted(ctx context.Context, branch string, from, to time.Time) ([]AgeSales, error) {
+	rcpt := ReceiptLog{Branch: branch}
+	return rcpt.AgeRestricted(ctx, r.db, from, to)
+}
+
+func (r *pgReceipts) SetVatExempt(ctx context.Context, rcpt *ReceiptLog) error {
+	return rcpt.SetVatExempt(ctx, r.db)
+}
+
+func (r *pgReceipts) SetCustomer(ctx context.Context, rcpt *ReceiptLog) error {
+	return rcpt.SetCustomer(ctx, r.db)
+}
+
+func (r *pgReceipts) SetPayTill(ctx context.Context, rcpt *ReceiptLog) error {
+	return rcpt.SetPayTill(ctx, r.db)
+}
+
+// pgPromotions implements PromotionRepository on postgres
+type pgPromotions struct {
+	db DBPool
+}
+
+// NewPromotionRepository returns a postgres backed PromotionRepository
+func NewPromotionRepository(db DBPool) PromotionRepository {
+	return &pgPromotions{db: db}
+}
+
+func (r *pgPromotions) Active(ctx context.Context, branch string, at time.Time) ([]Promotion, error) {
+	return ActivePromotions(ctx, r.db, branch, at)
+}
+
+// pgOrders implements OrderRepository on postgres
+type pgOrders struct {
+	db DBPool
+}
+
+// NewOrderRepository returns a postgres backed OrderRepository
+func NewOrderRepository(db DBPool) OrderRepository {
+	return &pgOrders{db: db}
+}
+
+func (r *pgOrders) Items(ctx context.Context, ord *Order) error {
+	return ord.Fetchtems(ctx, r.db)
+}
+
+func (r *pgOrders) AddItem(ctx context.Context, ord *Order, item Sales) ([]Sales, money.Amount, error) {
+	return ord.AddToOrder(ctx, r.db, item)
+}
+
+func (r *pgOrders) DeleteItem(ctx context.Context, orderNum int64, receiptItem string) ([]Sales, money.Amount, error) {
+	return DelOrderItem(ctx, r.db, receiptItem, orderNum)
+}
+
+func (r *pgOrders) Complete(ctx context.Context, ord *Order, send func([]Sales) error) ([]Sales, error) {
+	return ord.Send(ctx, r.db, send)
+}
+
+func (r *pgOrders) Fire(ctx context.Context, ord *Order, course int, send func([]Sales) error) ([]Sales, error) {
+	return ord.Fire(ctx, r.db, course, send)
+}
+
+func (r *pgOrders) Voucher(ctx context.Context, orderNum int64) ([]OrderItem, error) {
+	ord := Order{OrderNum: orderNum}
+	return ord.Voucher(ctx, r.db)
+}
+
+func (r *pgOrders) OrdersInBill(ctx context.Context, receiptNum int64) ([]Order, money.Amount, error) {
+	ord := Order{ReceiptNum: receiptNum}
+	orders, err := ord.GetOrdersInBills(ctx, r.db)
+	return orders, ord.Total, err
+}
+
+func (r *pgOrders) ActiveOrders(ctx context.Context, poster string) ([]Order, error) {
+	return FetchActiveOrders(ctx, r.db, poster)
+}
+
+func (r *pgOrders) SetChannel(ctx context.Context, receiptNum int64, channel string) error {
+	return SetChannel(ctx, r.db, receiptNum, channel)
+}
+
+// pgTills implements TillRepository on postgres
+type pgTills struct {
+	db DBPool
+}
+
+// NewTillRepository returns a postgres backed TillRepository
+func NewTillRepository(db DBPool) TillRepository {
+	return &pgTills{db: db}
+}
+
+func (r *pgTills) Open(ctx context.Context, till *Till) error {
+	return till.OpenTill(ctx, r.db)
+}
+
+func (r *pgTills) CashInTill(ctx context.Context, tillNum int64) (money.Amount, error) {
+	return CashInTill(ctx, r.db, tillNum)
+}
+
+func (r *pgTills) AddDiscount(ctx context.Context, tillNum int64, amount money.Amount) error {
+	till := Till{TillNO: tillNum}
+	return till.AddDiscount(ctx, r.db, amount)
+}
+
+// pgOverrides implements OverrideRepository on postgres
+type pgOverrides struct {
+	db DBPool
+}
+
+// NewOverrideRepository returns a postgres backed OverrideRepository
+func NewOverrideRepository(db DBPool) OverrideRepository {
+	return &pgOverrides{db: db}
+}
+
+func (r *pgOverrides) Apply(ctx context.Context, rcpt *ReceiptLog, o *PriceOverride, fn func([]Sales) []Sales) error {
+	return rcpt.Override(ctx, r.db, o, fn)
+}
+
+// pgVouchers implements VoucherRepository on postgres
+type pgVouchers struct {
+	db DBPool
+}
+
+// NewVoucherRepository returns a postgres backed VoucherRepository
+func NewVoucherRepository(db DBPool) VoucherRepository {
+	return &pgVouchers{db: db}
+}
+
+func (r *pgVouchers) Create(ctx context.Context, v *GiftVoucher) error {
+	return v.Create(ctx, r.db)
+}
+
+func (r *pgVouchers) Fetch(ctx context.Context, serial string) (GiftVoucher, error) {
+	v := GiftVoucher{Serial: serial}
+	if err := v.Fetch(ctx, r.db); err != nil {
+		return GiftVoucher{}, fmt.Errorf("gift voucher %v not found", serial)
+	}
+	return v, nil
+}
+
+// pgTables implements TableRepository on postgres
+type pgTables struct {
+	db DBPool
+}
+
+// NewTableRepository returns a postgres backed TableRepository
+func NewTableRepository(db DBPool) TableRepository {
+	return &pgTables{db: db}
+}
+
+func (r *pgTables) SaveFloor(ctx context.Context, f *Floor) error {
+	return f.Save(ctx, r.db)
+}
+
+func (r *pgTables) SaveTable(ctx context.Context, t *DiningTable) error {
+	return t.Save(ctx, r.db)
+}
+
+func (r *pgTables) Floors(ctx context.Context, branch string) ([]Floor, error) {
+	return FetchFloors(ctx, r.db, branch)
+}
+
+func (r *pgTables) Table(ctx context.Context, id int64) (DiningTable, error) {
+	t := DiningTable{ID: id}
+	err := t.Fetch(ctx, r.db)
+	return t, err
+}
+
+func (r *pgTables) Seat(ctx context.Context, t *DiningTable) error {
+	return t.Seat(ctx, r.db)
+}
+
+func (r *pgTables) Transfer(ctx context.Context, t *DiningTable, waiter string) error {
+	return t.Transfer(ctx, r.db, waiter)
+}
+
+func (r *pgTables) Release(ctx context.Context, receipts []int64) ([]DiningTable, error) {
+	return ReleaseTables(ctx, r.db, receipts)
+}
+
+func (r *pgTables) FloorState(ctx context.Context, branch string, floorID int64) ([]TableState, error) {
+	return FetchFloorState(ctx, r.db, branch, floorID)
+}
+
+// pgCustomers implements CustomerRepository on postgres
+type pgCustomers struct {
+	db DBPool
+}
+
+// NewCustomerRepository returns a postgres backed CustomerRepository
+func NewCustomerRepository(db DBPool) CustomerRepository {
+	return &pgCustomers{db: db}
+}
+
+func (r *pgCustomers) Save(ctx context.Context, c *Customer) error {
+	return c.Save(ctx, r.db)
+}
+
+func (r *pgCustomers) Fetch(ctx context.Context, id int64, phone string) (Customer, error) {
+	return FetchCustomer(ctx, r.db, id, phone)
+}
+
+func (r *pgCustomers) Search(ctx context.Context, q string, limit int) ([]Customer, error) {
+	return SearchCustomers(ctx, r.db, q, limit)
+}
+
+// pgQuotations implements QuotationRepository on postgres
+type pgQuotations struct {
+	db DBPool
+}
+
+// NewQuotationRepository returns a postgres backed QuotationRepository
+func NewQuotationRepository(db DBPool) QuotationRepository {
+	return &pgQuotations{db: db}
+}
+
+func (r *pgQuotations) Create(ctx context.Context, q *Quotation) error {
+	return q.Create(ctx, r.db)
+}
+
+func (r *pgQuotations) Fetch(ctx context.Context, q *Quotation) error {
+	return q.Fetch(ctx, r.db)
+}
+
+func (r *pgQuotations) Edit(ctx context.Context, q *Quotation, fn func([]Sales) []Sales) error {
+	return q.Edit(ctx, r.db, fn)
+}
+
+func (r *pgQuotations) Close(ctx context.Context, q *Quotation) error {
+	return q.Close(ctx, r.db)
+}
+
+// pgDeliveries implements DeliveryRepository on postgres
+type pgDeliveries struct {
+	db DBPool
+}
+
+// NewDeliveryRepository returns a postgres backed DeliveryRepository
+func NewDeliveryRepository(db DBPool) DeliveryRepository {
+	return &pgDeliveries{db: db}
+}
+
+func (r *pgDeliveries) Create(ctx context.Context, d *Delivery) error {
+	return d.Create(ctx, r.db)
+}
+
+func (r *pgDeliveries) Fetch(ctx context.Context, receiptNum int64) (Delivery, error) {
+	return FetchDelivery(ctx, r.db, receiptNum)
+}
+
+func (r *pgDeliveries) Update(ctx context.Context, d *Delivery, from string) error {
+	return d.Update(ctx, r.db, from)
+}
+
+func (r *pgDeliveries) Open(ctx context.Context, branch string) ([]Delivery, error) {
+	return OpenDeliveries(ctx, r.db, branch)
+}
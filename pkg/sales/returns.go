@@ -0,0 +1,269 @@
+package sales
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/events"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/jackc/pgx/v5"
+)
+
+// ReturnLine is a quantity of a posted receipt's line brought back
+// serialized items need the serial of each unit returned
+type ReturnLine struct {
+	ReceiptItem string   `json:"receipt_item"`
+	Quantity    float64  `json:"quantity"`
+	Serials     []string `json:"serials"`
+}
+
+// SalesReturn takes lines of the posted receipt ReturnTrace back and refunds them in cash
+type SalesReturn struct {
+	ReturnTrace int64        `json:"return_trace"`
+	Lines       []ReturnLine `json:"lines"`
+}
+
+// returnedLine is the negative of qty of the sold line, priced as it was sold
+func returnedLine(sold Sales, l ReturnLine, at time.Time) Sales {
+	share := l.Quantity / sold.Quantity
+
+	item := sold
+	item.TransDate = at
+	item.Quantity = -l.Quantity
+	item.Total = -sold.Total.Mul(share)
+	item.Vat = -sold.Vat.Mul(share)
+	item.Discount = -sold.Discount.Mul(share)
+	item.ManualDiscount = -sold.ManualDiscount.Mul(share)
+	item.ReceiptDiscount = -sold.ReceiptDiscount.Mul(share)
+	item.Serials = l.Serials
+	return item
+}
+
+// CheckReturnable rejects returning more of a sold line than is left after the earlier returns
+// returned holds the quantities already returned by receipt item
+func CheckReturnable(sold []Sales, returned map[string]float64, cart []Sales) error {
+	for _, item := range cart {
+		left := 0.0
+		for _, line := range sold {
+			if line.ReceiptItem == item.ReceiptItem {
+				left = line.Quantity - returned[item.ReceiptItem]
+			}
+		}
+		if -item.Quantity > left {
+			return apperr.New(apperr.ValidationFailed, fmt.Sprintf("only %v of %v is left to return", max(left, 0), item.ItemName))
+		}
+	}
+	return nil
+}
+
+// Returned sums the quantities of the receipt's lines already returned by receipt item
+func (arg *ReceiptLog) Returned(ctx context.Context, db Querier) (map[string]float64, error) {
+	sql := `SELECT item->>'receipt_item', coalesce(sum(-(item->>'quantity')::numeric), 0)::float8
+			FROM salestrace s CROSS JOIN jsonb_array_elements(coalesce(s.cart, '[]'::jsonb)) item
+			WHERE s.return_trace = $1 AND s.state = 'POSTED'
+			GROUP BY 1`
+
+	rows, err := db.Query(ctx, sql, arg.ReceiptNum)
+	if err != nil {
+		log.Println("sql error. ReceiptLog->Returned()    err =", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	returned := map[string]float64{}
+	for rows.Next() {
+		var item string
+		var qty float64
+		if err := rows.Scan(&item, &qty); err != nil {
+			return nil, err
+		}
+		returned[item] = qty
+	}
+	return returned, rows.Err()
+}
+
+// Return numbers and posts the return receipt and adds its refund to the till's returns
+// the original receipt is locked so returns of it can't together exceed what was sold
+func (arg *ReceiptLog) Return(ctx context.Context, db DBPool) error {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var sold []Sales
+	sql := `SELECT coalesce(cart, '[]'::jsonb) FROM salestrace WHERE receipt_num = $1 AND state = 'POSTED' FOR UPDATE`
+	if err := tx.QueryRow(ctx, sql, arg.ReturnTrace).Scan(&sold); err != nil {
+		if err == pgx.ErrNoRows {
+			return apperr.New(apperr.ValidationFailed, fmt.Sprintf("receipt %v isn't a posted sale", arg.ReturnTrace))
+		}
+		log.Println("sql error. ReceiptLog->Return()    err =", err)
+		return err
+	}
+
+	orig := ReceiptLog{ReceiptNum: arg.ReturnTrace}
+	returned, err := orig.Returned(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if err := CheckReturnable(sold, returned, arg.Cart); err != nil {
+		return err
+	}
+
+	if err := arg.nextNumber(ctx, tx); err != nil {
+		return err
+	}
+	items, err := json.Marshal(arg.Cart)
+	if err != nil {
+		return err
+	}
+	sql = `INSERT INTO salestrace(trans_date, till_num, pay_till, receipt_num, poster, daily_count, branch, company_id, sale_type
+				, customer_id, cart, total, cash, change, paymode, pay_details, state, return_trace)
+			VALUES(now(), $1, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10, 0, 'cash', jsonb_build_object('cash', $10::numeric), 'POSTED', $11)`
+	_, err = tx.Exec(ctx, sql, arg.TillNum, arg.ReceiptNum, arg.Poster, arg.DailyCount, arg.Branch, arg.CompanyID, arg.SaleType,
+		arg.CustomerID, items, arg.Total, arg.ReturnTrace)
+	if err != nil {
+		log.Println("sql error. ReceiptLog->Return()    err =", err)
+		return err
+	}
+
+	till := Till{TillNO: arg.TillNum}
+	if err := till.AddReturns(ctx, tx, -arg.Total); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	arg.State = "POSTED"
+	return nil
+}
+
+// AddReturns adds refunds paid out of the till to its cash summary
+func (arg *Till) AddReturns(ctx context.Context, db Querier, amount money.Amount) error {
+	sql := `UPDATE sales_till
+			SET
+				cash_summary = jsonb_set(cash_summary, '{returns}', to_jsonb(coalesce((cash_summary->>'returns')::numeric, 0) + $2))
+			WHERE till_no = $1`
+
+	_, err := db.Exec(ctx, sql, arg.TillNO, amount)
+	if err != nil {
+		log.Println("sql error. Till->AddReturns()    err =", err)
+		return err
+	}
+	return nil
+}
+
+// CheckReturn checks the serials of a returned item were sold with it on the receipt returnTrace points to
+// and haven't been returned since
+func (s *Service) CheckReturn(ctx context.Context, returnTrace int64, item Sales) error {
+	p, err := s.Catalog.Fetch(ctx, item.ItemCode)
+	if err != nil {
+		return apperr.Wrap(apperr.ProductNotFound, "failed to fetch product "+item.ItemCode, err)
+	}
+	if !p.Serialized {
+		return nil
+	}
+	if err := item.checkSerials(p); err != nil {
+		return err
+	}
+
+	for _, serial := range item.Serials {
+		sale, err := s.LookupSerial(ctx, serial)
+		if err != nil && !apperr.Is(err, apperr.NotFound) {
+			return err
+		}
+		if err != nil || sale.ReceiptNum != returnTrace || sale.ItemCode != p.ItemCode {
+			return apperr.New(apperr.ValidationFailed, fmt.Sprintf("serial %v wasn't sold with %v on receipt %v", serial, p.ItemName, returnTrace))
+		}
+		if sale.Returned {
+			return apperr.New(apperr.ValidationFailed, fmt.Sprintf("serial %v has already been returned", serial))
+		}
+	}
+	return nil
+}
+
+// ReturnSale takes lines of a posted sale at the user's branch back into the user's till and its stock
+// each serial returned must have been sold on the sale and not returned since
+// returns the posted return receipt with its negative lines, the refund is its total
+func (s *Service) ReturnSale(ctx context.Context, user logins.Users, arg SalesReturn) (ReceiptLog, error) {
+	orig := ReceiptLog{ReceiptNum: arg.ReturnTrace}
+	if err := s.Receipt(ctx, &orig); err != nil {
+		return ReceiptLog{}, err
+	}
+	if orig.State != "POSTED" {
+		return ReceiptLog{}, apperr.New(apperr.ValidationFailed, fmt.Sprintf("receipt %v isn't a posted sale", orig.ReceiptNum))
+	}
+	if orig.Branch != user.Branch {
+		return ReceiptLog{}, apperr.New(apperr.Forbidden, fmt.Sprintf("receipt %v was sold at another branch", orig.ReceiptNum))
+	}
+	if len(arg.Lines) == 0 {
+		return ReceiptLog{}, apperr.New(apperr.ValidationFailed, "nothing to return")
+	}
+
+	ret := ReceiptLog{
+		TransDate:   time.Now(),
+		TillNum:     user.TillNum,
+		PayTill:     user.TillNum,
+		Poster:      user.Username,
+		Branch:      user.Branch,
+		CompanyID:   user.CompanyID,
+		SaleType:    "Cash Sale",
+		CustomerID:  orig.CustomerID,
+		ReturnTrace: orig.ReceiptNum,
+	}
+	for _, l := range arg.Lines {
+		i := slices.IndexFunc(orig.Cart, func(item Sales) bool { return item.ReceiptItem == l.ReceiptItem })
+		if i < 0 {
+			return ReceiptLog{}, apperr.New(apperr.ValidationFailed, fmt.Sprintf("receipt %v has no line %v", orig.ReceiptNum, l.ReceiptItem))
+		}
+		sold := orig.Cart[i]
+		if sold.ItemCode == RoundingCode {
+			return ReceiptLog{}, apperr.New(apperr.ValidationFailed, fmt.Sprintf("the cash rounding on receipt %v can't be returned", orig.ReceiptNum))
+		}
+		if l.Quantity <= 0 || l.Quantity > sold.Quantity {
+			return ReceiptLog{}, apperr.New(apperr.ValidationFailed, fmt.Sprintf("between 0 and %v of %v can be returned", sold.Quantity, sold.ItemName))
+		}
+		if err := s.CheckReturn(ctx, orig.ReceiptNum, Sales{ItemCode: sold.ItemCode, Quantity: l.Quantity, Serials: l.Serials}); err != nil {
+			return ReceiptLog{}, err
+		}
+
+		item := returnedLine(sold, l, ret.TransDate)
+		ret.Cart = append(ret.Cart, item)
+		ret.Total += item.Total
+	}
+	ret.Cash = ret.Total
+
+	if err := s.Receipts.Return(ctx, &ret); err != nil {
+		return ReceiptLog{}, err
+	}
+	log.Printf("%v returned %v of receipt %v on receipt %v", user.Username, -ret.Total, orig.ReceiptNum, ret.ReceiptNum)
+
+	// the returned units go back into the stock the sale reserved
+	var lines []products.StockLine
+	for _, item := range ret.Cart {
+		if item.ItemCode != DeliveryFeeCode {
+			lines = append(lines, products.StockLine{ItemCode: item.ItemCode, Quantity: -item.Quantity})
+		}
+	}
+	if len(lines) > 0 {
+		s.releaseStock(ctx, orig.ReceiptNum, lines)
+	}
+
+	s.Events.Publish(events.Event{
+		Type:       events.ReceiptReturned,
+		Branch:     ret.Branch,
+		TillNum:    ret.TillNum,
+		ReceiptNum: ret.ReceiptNum,
+		State:      ret.State,
+		Data:       ret,
+	})
+	return ret, nil
+}
@@ -8,30 +8,57 @@ import (
 	"time"
 
 	"github.com/JohnnyKahiu/speedsales/poserver/database"
-	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
 	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
 	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
 )
 
 type Sales struct {
-	table       string    `name:"sales" type:"table"`
-	TransDate   time.Time `json:"trans_date" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT now()"`
-	ReceiptNum  int64     `json:"receipt_num" type:"field" sql:"BIGINT NOT NULL"`
-	OrderNum    int64     `json:"order_num" type:"field" sql:"BIGINT NOT NULL"`
-	TxnID       int64     `json:"txn_id" type:"field" sql:"BIGSERIAL NOT NULL UNIQUE"`
-	HsCode      string    `json:"hs_code" type:"field" sql:"VARCHAR NOT NULL"`
-	ItemCode    string    `json:"item_code" type:"field" sql:"VARCHAR NOT NULL"`
-	ItemName    string    `json:"item_name" type:"field" sql:"VARCHAR NOT NULL"`
-	Quantity    float64   `json:"quantity" type:"field" sql:"FLOAT NOT NULL DEFAULT '0' "`
-	Cost        float64   `json:"cost" type:"field" sql:"FLOAT NOT NULL DEFAULT '0' "`
-	Price       float64   `json:"price" type:"field" sql:"FLOAT NOT NULL DEFAULT '0' "`
-	Discount    float64   `json:"discount" type:"field" sql:"FLOAT NOT NULL DEFAULT '0' "`
-	Total       float64   `json:"total" type:"field" sql:"FLOAT NOT NULL DEFAULT '0' "`
-	OnOffer     bool      `json:"on_offer" type:"field" sql:"BOOL NOT NULL DEFAULT 'false'"`
-	Vat         float64   `json:"vat" type:"field" sql:"FLOAT NOT NULL DEFAULT '0'"`
-	VatAlpha    string    `json:"vat_alpha" type:"field" sql:"VARCHAR(1) NOT NULL"`
-	State       string    `json:"state" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'active' "`
-	ReceiptItem string    `json:"receipt_item" type:"field" sql:"VARCHAR NOT NULL"`
+	table      string       `name:"sales" type:"table"`
+	TransDate  time.Time    `json:"trans_date" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT now()"`
+	ReceiptNum int64        `json:"receipt_num" type:"field" sql:"BIGINT NOT NULL"`
+	OrderNum   int64        `json:"order_num" type:"field" sql:"BIGINT NOT NULL"`
+	TxnID      int64        `json:"txn_id" type:"field" sql:"BIGSERIAL NOT NULL UNIQUE"`
+	HsCode     string       `json:"hs_code" type:"field" sql:"VARCHAR NOT NULL"`
+	ItemCode   string       `json:"item_code" type:"field" sql:"VARCHAR NOT NULL"`
+	ItemName   string       `json:"item_name" type:"field" sql:"VARCHAR NOT NULL"`
+	Quantity   float64      `json:"quantity" type:"field" sql:"FLOAT NOT NULL DEFAULT '0' "`
+	Cost       money.Amount `json:"cost" type:"field" sql:"NUMERIC(14,2) NOT NULL DEFAULT '0' "`
+	Price      money.Amount `json:"price" type:"field" sql:"NUMERIC(14,2) NOT NULL DEFAULT '0' "`
+	Discount   money.Amount `json:"discount" type:"field" sql:"NUMERIC(14,2) NOT NULL DEFAULT '0' "`
+	Total      money.Amount `json:"total" type:"field" sql:"NUMERIC(14,2) NOT NULL DEFAULT '0' "`
+	OnOffer    bool         `json:"on_offer" type:"field" sql:"BOOL NOT NULL DEFAULT 'false'"`
+	Vat        money.Amount `json:"vat" type:"field" sql:"NUMERIC(14,2) NOT NULL DEFAULT '0'"`
+	VatAlpha   string       `json:"vat_alpha" type:"field" sql:"VARCHAR(1) NOT NULL"`
+	VatPercent float64      `json:"vat_percent" type:"field" sql:"FLOAT NOT NULL DEFAULT '0'"`
+	Promotions []string     `json:"promotions,omitempty"`
+	// OriginalPrice is the price before a manual override
+	OriginalPrice  money.Amount `json:"original_price,omitempty"`
+	ManualDiscount money.Amount `json:"manual_discount,omitempty"`
+	// ReceiptDiscount is the line's share of a discount on the whole receipt
+	ReceiptDiscount money.Amount `json:"receipt_discount,omitempty"`
+	VatExempt       bool         `json:"vat_exempt,omitempty"`
+	// Seat is the diner's seat at the table, bills split by seat
+	Seat int `json:"seat,omitempty"`
+	// Modifiers change how the line is made and are priced into it, Note is free text for the kitchen
+	Modifiers []Modifier `json:"modifiers,omitempty"`
+	Note      string     `json:"note,omitempty"`
+	// Course holds the line until its course is fired, FiredAt is when it went to the kitchen
+	Course  int        `json:"course,omitempty"`
+	FiredAt *time.Time `json:"fired_at,omitempty"`
+	// Batch and Expiry are the dispensed stock's, Prescription is required for prescription only items
+	Batch        string        `json:"batch,omitempty"`
+	Expiry       *time.Time    `json:"expiry,omitempty"`
+	Prescription *Prescription `json:"prescription,omitempty"`
+	// Serials are the serial numbers or IMEIs of the units sold
+	Serials []string `json:"serials,omitempty"`
+	// AgeCheck is how an age restricted line was allowed
+	AgeCheck *AgeCheck `json:"age_check,omitempty"`
+	// StockApprover allowed the line beyond the stock balance, the token isn't kept
+	StockApprover string `json:"stock_approver,omitempty"`
+	ApToken       string `json:"-"`
+	State         string `json:"state" type:"field" sql:"VARCHAR NOT NULL DEFAULT 'active' "`
+	ReceiptItem   string `json:"receipt_item" type:"field" sql:"VARCHAR NOT NULL"`
 }
 
 // genSalesTbl
@@ -40,24 +67,9 @@ func genSalesTbl() error {
 	return database.CreateFromStruct(tblStruct)
 }
 
-var fetchUser = func(u *logins.Users, ctx context.Context) error {
-	return u.FetchUser(ctx)
-}
-
-// AddCart adds an item to the cart
-// writes through to cache
-// returns an error if fails
-func (arg *Sales) AddCart() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	// fetch from details from inventory microservice
-	p := products.StockMaster{ItemCode: arg.ItemCode}
-	err := p.Fetch(ctx)
-	if err != nil {
-		return err
-	}
-
+// Fill completes a cart line from the product's inventory details
+// returns an error if the product can't be sold
+func (arg *Sales) Fill(p products.StockMaster) error {
 	// validate p
 	if p.ItemCode == "" {
 		return errors.New("item code is required")
@@ -67,15 +79,19 @@ func (arg *Sales) AddCart() error {
 	}
 
 	arg.TransDate = time.Now()
-	arg.Cost = p.ItemCost
-	arg.Total = arg.Quantity * arg.Price
+	arg.ItemName = p.ItemName
+	arg.Price = money.New(p.TillPrice)
+	arg.Cost = money.New(p.ItemCost)
+	arg.Total = arg.Price.Mul(arg.Quantity)
 
-	arg.Vat = p.VatPercent * arg.Total / (100 + p.VatPercent)
+	arg.Vat = arg.Total.Percent(p.VatPercent * 100 / (100 + p.VatPercent))
 	arg.VatAlpha = p.VatAlpha
+	arg.VatPercent = p.VatPercent
 	// create a unique ReceiptItem for entry
 	arg.ReceiptItem = fmt.Sprintf("%d", time.Now().UnixNano())
-
-	// add to in memory fileDB esp one in use
+	if arg.State == "" {
+		arg.State = "pending"
+	}
 
 	log.Println("product details = ", p)
 
@@ -83,21 +99,20 @@ func (arg *Sales) AddCart() error {
 }
 
 // CreateReceipt creates a new receipt number
-func (arg *ReceiptLog) CreateReceipt() (int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	userDetails := logins.Users{Username: arg.Poster}
-	err := fetchUser(&userDetails, ctx)
-	if err != nil {
+func (arg *ReceiptLog) CreateReceipt(ctx context.Context, db Querier) (int64, error) {
+	if err := arg.nextNumber(ctx, db); err != nil {
 		return 0, err
 	}
-	fmt.Println("user_details =", userDetails)
 
-	if userDetails.AcceptPayment {
-		arg.PayTill = userDetails.TillNum
+	err := arg.LogReceipt(ctx, db)
+	if err != nil {
+		return -1, err
 	}
+	return arg.ReceiptNum, nil
+}
 
+// nextNumber sets the receipt's number and daily count from today's receipts
+func (arg *ReceiptLog) nextNumber(ctx context.Context, db Querier) error {
 	// prepare sql statement to get the next receipt number
 	sql := `SELECT CAST(CONCAT(
 						cast(1 as varchar)
@@ -111,10 +126,10 @@ func (arg *ReceiptLog) CreateReceipt() (int64, error) {
 			FROM salestrace WHERE trans_date::date = (SELECT now()::date)
 	`
 
-	rows, err := database.PgPool.Query(ctx, sql)
+	rows, err := db.Query(ctx, sql)
 	if err != nil {
 		log.Println("error. failed to get receipt     err =", err)
-		return 0, err
+		return err
 	}
 	defer rows.Close()
 
@@ -124,16 +139,11 @@ func (arg *ReceiptLog) CreateReceipt() (int64, error) {
 	}
 
 	fmt.Printf("created receipt = %v", arg.ReceiptNum)
-
-	err = arg.LogReceipt(ctx)
-	if err != nil {
-		return -1, err
-	}
-	return arg.ReceiptNum, nil
+	return rows.Err()
 }
 
 // LogReceipt logs the created receipt number to database
-func (a *ReceiptLog) LogReceipt(ctx context.Context) error {
+func (a *ReceiptLog) LogReceipt(ctx context.Context, db Querier) error {
 	fmt.Printf("\n\treceipt logged = %v, %v, %v, %v, %v, %v, %v, %v ", a.TillNum, a.ReceiptNum, a.Poster, a.DailyCount, a.Branch, a.CompanyID, a.SaleType, a.LaybyeID)
 
 	// prepare sql to insert new receipt number
@@ -141,12 +151,14 @@ func (a *ReceiptLog) LogReceipt(ctx context.Context) error {
 			VALUES(now(), $1, $2, $3, $4, $5, $6, $7, $8, $9)
 			RETURNING trans_date`
 	// execute statement
-	rows, err := database.PgPool.Query(ctx, sql, a.TillNum, a.ReceiptNum, a.Poster, a.DailyCount, a.Branch, a.CompanyID, a.SaleType, a.LaybyeID, a.PayTill)
+	rows, err := db.Query(ctx, sql, a.TillNum, a.ReceiptNum, a.Poster, a.DailyCount, a.Branch, a.CompanyID, a.SaleType, a.LaybyeID, a.PayTill)
 	if err != nil {
 		log.Println("error. failed to save receipt to log     err =", err)
 		return err
 	}
 
+	defer rows.Close()
+
 	var transDate time.Time
 	for rows.Next() {
 		err := rows.Scan(&transDate)
@@ -158,13 +170,13 @@ func (a *ReceiptLog) LogReceipt(ctx context.Context) error {
 }
 
 // CashInTill fetches and returns total cash in current till
-func CashInTill(till int64) (float64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+func CashInTill(ctx context.Context, db Querier, till int64) (money.Amount, error) {
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
 	defer cancel()
 
 	start := time.Now()
 	fmt.Println("Fetching cash in till for till num ", till)
-	defer fmt.Printf("\n\t\t function CashInTill() took:  %v \n", time.Since(start))
+	defer func() { fmt.Printf("\n\t\t function CashInTill() took:  %v \n", time.Since(start)) }()
 
 	// cash in register = amount_paid_in_cash - rollups
 	// fetch cash amount in till from database
@@ -174,12 +186,12 @@ func CashInTill(till int64) (float64, error) {
 		FROM
 		(SELECT 
 			pay_till 
-			, SUM(cast(pay_details::json->'cash' as varchar)::float) as cash 
-			, SUM(cast(pay_details::json->'mpesa' as varchar)::float) as mpesa 
-			, SUM(cast(pay_details::json->'ecard' as varchar)::float) as ecard 
-			, SUM(cast(pay_details::json->'check' as varchar)::float) as cheque
-			, SUM(cast(pay_details::json->'redeem' as varchar)::float) as redeemed 
-			, SUM(cast(pay_details::json->'voucher' as varchar)::float) as voucher 
+			, SUM(cast(pay_details::json->'cash' as varchar)::numeric) as cash 
+			, SUM(cast(pay_details::json->'mpesa' as varchar)::numeric) as mpesa 
+			, SUM(cast(pay_details::json->'ecard' as varchar)::numeric) as ecard 
+			, SUM(cast(pay_details::json->'check' as varchar)::numeric) as cheque
+			, SUM(cast(pay_details::json->'redeem' as varchar)::numeric) as redeemed 
+			, SUM(cast(pay_details::json->'voucher' as varchar)::numeric) as voucher 
 		FROM salestrace  
 		WHERE pay_till = $1 AND state = 'POSTED' GROUP BY pay_till) as c
 				LEFT JOIN
@@ -225,14 +237,14 @@ func CashInTill(till int64) (float64, error) {
 		`
 
 	// fmt.Printf("\n\t SQL \n %v", sql)
-	rows, err := database.PgPool.Query(ctx, sql, till)
+	rows, err := db.Query(ctx, sql, till)
 	if err != nil {
 		log.Println("\n\t\t error cash in till, ", err)
 		return 0, err
 	}
 	defer rows.Close()
 
-	var balance float64
+	var balance money.Amount
 	for rows.Next() {
 		err := rows.Scan(&balance)
 		if err != nil {
@@ -0,0 +1,99 @@
+package sales
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+)
+
+// SerialSale is a posted receipt line carrying a serial
+// a ReturnTrace other than 0 is the sale the serial was returned from
+type SerialSale struct {
+	TransDate   time.Time    `json:"trans_date"`
+	ReceiptNum  int64        `json:"receipt_num"`
+	ReturnTrace int64        `json:"return_trace"`
+	Branch      string       `json:"branch"`
+	Poster      string       `json:"poster"`
+	ItemCode    string       `json:"item_code"`
+	ItemName    string       `json:"item_name"`
+	Price       money.Amount `json:"price"`
+	// Returned is set on a sale the serial has since been returned from
+	Returned bool `json:"returned"`
+}
+
+// checkSerials requires a distinct serial for each unit of the line
+func (arg *Sales) checkSerials(p products.StockMaster) error {
+	qty := math.Abs(arg.Quantity)
+	if qty != math.Trunc(qty) {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("%v is sold in whole units", p.ItemName))
+	}
+	if len(arg.Serials) != int(qty) {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("%v serials are required for %v of %v, got %d", qty, qty, p.ItemName, len(arg.Serials)))
+	}
+
+	seen := map[string]bool{}
+	for i, serial := range arg.Serials {
+		serial = strings.ToUpper(strings.TrimSpace(serial))
+		if serial == "" {
+			return apperr.New(apperr.ValidationFailed, "serials can't be blank")
+		}
+		if seen[serial] {
+			return apperr.New(apperr.ValidationFailed, fmt.Sprintf("serial %v is entered twice", serial))
+		}
+		seen[serial] = true
+		arg.Serials[i] = serial
+	}
+	return nil
+}
+
+// latestSale finds the newest sale in a serial's history, newest first
+// marks it returned when a later receipt returns it
+func latestSale(history []SerialSale) (SerialSale, bool) {
+	for i, s := range history {
+		if s.ReturnTrace != 0 {
+			continue
+		}
+		for _, later := range history[:i] {
+			if later.ReturnTrace == s.ReceiptNum {
+				s.Returned = true
+			}
+		}
+		return s, true
+	}
+	return SerialSale{}, false
+}
+
+// SerialSales lists the posted receipts carrying serial, newest first
+func (arg *ReceiptLog) SerialSales(ctx context.Context, db Querier, serial string) ([]SerialSale, error) {
+	sql := `SELECT s.trans_date, s.receipt_num, s.return_trace, s.branch, s.poster,
+				item->>'item_code', item->>'item_name', (item->>'price')::numeric
+			FROM salestrace s CROSS JOIN jsonb_array_elements(coalesce(s.cart, '[]'::jsonb)) item
+			WHERE s.state = 'POSTED' AND item->>'state' NOT IN ('DELETED', 'VOIDED')
+				AND item->'serials' ? $1
+			ORDER BY s.trans_date DESC, s.receipt_num DESC`
+
+	rows, err := db.Query(ctx, sql, serial)
+	if err != nil {
+		log.Println("sql error. ReceiptLog->SerialSales()    err =", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []SerialSale
+	for rows.Next() {
+		var s SerialSale
+		err := rows.Scan(&s.TransDate, &s.ReceiptNum, &s.ReturnTrace, &s.Branch, &s.Poster, &s.ItemCode, &s.ItemName, &s.Price)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, s)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,1850 @@
+package sales
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	pb "github.com/JohnnyKahiu/speed_sales_proto/user"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/barcode"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/broker"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/events"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/grpc"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
+)
+
+// Service holds the sales repositories and remote services used by the handlers
+type Service struct {
+	Receipts ReceiptRepository
+	Orders   OrderRepository
+	Tills    TillRepository
+	Vouchers VoucherRepository
+	// Promotions is optional, carts are sold at full price without it
+	Promotions PromotionRepository
+	Catalog    ProductCatalog
+	Users      UserDirectory
+	Registrar  TillRegistrar
+	Publisher  Publisher
+	Overrides  OverrideRepository
+	Stock      StockKeeper
+	Tables     TableRepository
+	Customers  CustomerRepository
+	Quotes     QuotationRepository
+	Deliveries DeliveryRepository
+	Events     *events.Hub
+	Settings   func() (variables.PosSettings, error)
+	// Profile loads the branch's industry profile, every feature is on without it
+	Profile func(ctx context.Context, branch string) (variables.Profile, error)
+	// Taxes loads the vat codes, lines keep the inventory's vat without it
+	Taxes func() (TaxTable, error)
+}
+
+// NewService wires the postgres repositories and the remote services
+func NewService(db DBPool) *Service {
+	return &Service{
+		Receipts:   NewReceiptRepository(db),
+		Orders:     NewOrderRepository(db),
+		Tills:      NewTillRepository(db),
+		Vouchers:   NewVoucherRepository(db),
+		Promotions: NewPromotionRepository(db),
+		Catalog:    inventoryCatalog{},
+		Users:      loginDirectory{},
+		Registrar:  loginRegistrar{},
+		Publisher:  kafkaPublisher{},
+		Overrides:  NewOverrideRepository(db),
+		Stock:      inventoryStock{},
+		Tables:     NewTableRepository(db),
+		Customers:  NewCustomerRepository(db),
+		Quotes:     NewQuotationRepository(db),
+		Deliveries: NewDeliveryRepository(db),
+		Events:     events.NewHub(),
+		Settings:   FetchSettings,
+		Profile:    variables.BranchProfile,
+		Taxes:      FetchTaxTable,
+	}
+}
+
+// OpenTill opens a till for the teller approved by the supervisor
+// returns an error if the approver can't open tills
+func (s *Service) OpenTill(ctx context.Context, teller logins.Users, approver, apToken string) (Till, error) {
+	poSett, _ := s.Settings()
+	if poSett.ApproveSales {
+		if _, err := s.approve(ctx, approver, apToken, logins.RightCashRollups); err != nil {
+			return Till{}, err
+		}
+	} else if _, err := s.Users.FetchUser(ctx, approver); err != nil {
+		log.Printf("\t error fetching user %v\t error = %v\n\n", approver, err)
+		return Till{}, apperr.Wrap(apperr.ApprovalRequired, "failed to get approver", err)
+	}
+
+	till := Till{
+		Teller:     teller.Username,
+		Branch:     teller.Branch,
+		Supervisor: approver,
+	}
+
+	// open sales till
+	if err := s.Tills.Open(ctx, &till); err != nil {
+		return Till{}, err
+	}
+
+	// update till to user
+	if err := s.Registrar.UpdateTill(ctx, till.Teller, till.TillNO); err != nil {
+		log.Println("error, failed to update till    err =", err)
+		return Till{}, err
+	}
+
+	return till, nil
+}
+
+// GenReceipt returns the till's pending receipt or creates a new one
+func (s *Service) GenReceipt(ctx context.Context, rcpt *ReceiptLog) error {
+	if rcpt.TillNum == 0 {
+		return ErrTillNotOpen
+	}
+
+	err := s.Receipts.Pending(ctx, rcpt)
+	if err != nil {
+		return err
+	}
+
+	// return receipt number when there exists a pending receipt number
+	if rcpt.ReceiptNum > 0 {
+		return nil
+	}
+	return s.createReceipt(ctx, rcpt)
+}
+
+// createReceipt numbers a new receipt for the poster's till
+func (s *Service) createReceipt(ctx context.Context, rcpt *ReceiptLog) error {
+	userDetails, err := s.Users.FetchUser(ctx, rcpt.Poster)
+	if err != nil {
+		return err
+	}
+	if userDetails.AcceptPayment {
+		rcpt.PayTill = userDetails.TillNum
+	}
+
+	// create a new receipt number
+	err = s.Receipts.Create(ctx, rcpt)
+	if err != nil {
+		fmt.Printf("Error creating receipt %v\n", err.Error())
+		return err
+	}
+	return nil
+}
+
+// Cart fetches the receipt's cart, creating a receipt when none is given
+// reports whether the till needs a cash rollup before the next sale
+func (s *Service) Cart(ctx context.Context, rcpt *ReceiptLog) (bool, variables.PosSettings, error) {
+	if rcpt.ReceiptNum == 0 {
+		if err := s.GenReceipt(ctx, rcpt); err != nil {
+			return false, variables.PosSettings{}, err
+		}
+	}
+
+	if err := s.Receipts.Fetch(ctx, rcpt); err != nil {
+		return false, variables.PosSettings{}, err
+	}
+
+	reqRollup := false
+
+	// fetch system defaults
+	poSett, _ := s.Settings()
+	if rcpt.Total <= 0 {
+		// fetch current cash in till
+		cashInTill, _ := s.Tills.CashInTill(ctx, rcpt.TillNum)
+		if money.New(poSett.Rollup) <= cashInTill {
+			reqRollup = true
+		}
+	}
+
+	return reqRollup, poSett, nil
+}
+
+// NewReceipt returns the next receipt and the till's active carts
+func (s *Service) NewReceipt(ctx context.Context, rcpt *ReceiptLog) ([]ReceiptLog, error) {
+	if err := s.GenReceipt(ctx, rcpt); err != nil {
+		return nil, err
+	}
+	return s.Receipts.ActiveCarts(ctx, rcpt.TillNum)
+}
+
+// NewBill suspends open bills and starts a new one
+func (s *Service) NewBill(ctx context.Context, rcpt *ReceiptLog) ([]ReceiptLog, error) {
+	if err := s.Receipts.NewBill(ctx, rcpt.TillNum); err != nil {
+		log.Println("error requesting new bill     err =", err)
+		return nil, err
+	}
+	return s.NewReceipt(ctx, rcpt)
+}
+
+// Suspend suspends the till's pending receipt and starts a new one
+func (s *Service) Suspend(ctx context.Context, rcpt *ReceiptLog) error {
+	if err := s.Receipts.Suspend(ctx, rcpt.TillNum); err != nil {
+		return err
+	}
+	s.Events.Publish(events.Event{Type: events.ReceiptSuspended, Branch: rcpt.Branch, TillNum: rcpt.TillNum})
+
+	rcpt.ReceiptNum = 0
+	return s.GenReceipt(ctx, rcpt)
+}
+
+// AddCart fills an item from inventory and adds it to the user's receipt
+func (s *Service) AddCart(ctx context.Context, user logins.Users, item *Sales) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	rcpt := ReceiptLog{
+		ReceiptNum: item.ReceiptNum,
+		TillNum:    user.TillNum,
+		Poster:     user.Username,
+		Branch:     user.Branch,
+		CompanyID:  user.CompanyID,
+		SaleType:   "Cash Sale",
+	}
+
+	// fetch receiptNum if not provided
+	if rcpt.ReceiptNum == 0 {
+		if err := s.GenReceipt(ctx, &rcpt); err != nil {
+			return err
+		}
+		item.ReceiptNum = rcpt.ReceiptNum
+	}
+
+	// fetch from details from inventory microservice
+	p, err := s.scanProduct(ctx, item, rcpt.Branch)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkStock(ctx, rcpt.Branch, user.StkLocation, item, p); err != nil {
+		return err
+	}
+	if err := s.checkDispensing(ctx, rcpt.Branch, item, p); err != nil {
+		return err
+	}
+	if err := s.checkSerials(ctx, item, p); err != nil {
+		return err
+	}
+	if err := s.checkAge(ctx, user.Username, item, p); err != nil {
+		return err
+	}
+	if p, err = item.priceModifiers(p); err != nil {
+		return err
+	}
+
+	if err := item.Fill(p); err != nil {
+		return apperr.Wrap(apperr.ProductNotFound, "product "+item.ItemCode+" is not for sale", err)
+	}
+
+	if err := s.Receipts.AddItem(ctx, &rcpt, *item); err != nil {
+		return err
+	}
+
+	// the whole cart is repriced since the item may complete an offer
+	if err := s.reprice(ctx, &rcpt, nil); err != nil {
+		return err
+	}
+	for _, line := range rcpt.Cart {
+		if line.ReceiptItem == item.ReceiptItem {
+			*item = line
+		}
+	}
+
+	s.Events.Publish(events.Event{
+		Type:       events.CartItemAdded,
+		Branch:     rcpt.Branch,
+		TillNum:    rcpt.TillNum,
+		ReceiptNum: rcpt.ReceiptNum,
+		Data:       item,
+	})
+	return nil
+}
+
+// scanProduct fetches the scanned item's product
+// scale labels and case codes resolve to their product, setting the quantity from the weight, price or pack size
+// the quantity asked for counts the labels or cases scanned and the product is priced for branch
+func (s *Service) scanProduct(ctx context.Context, item *Sales, branch string) (products.StockMaster, error) {
+	rules := barcode.Defaults()
+	if s.Settings != nil {
+		if poSett, err := s.Settings(); err == nil && poSett.BarcodeRules != nil {
+			rules = poSett.BarcodeRules
+		}
+	}
+	// scale labels are read as plain codes where nothing is weighed
+	if p, ok := s.profile(ctx, branch); ok && !p.Has(variables.FeatureWeighedItems) {
+		rules = slices.DeleteFunc(slices.Clone(rules), func(r barcode.Rule) bool {
+			return r.Kind == barcode.KindWeight || r.Kind == barcode.KindPrice
+		})
+	}
+
+	scan, ok := barcode.Parse(item.ItemCode, rules)
+	if ok {
+		p, err := s.Catalog.Fetch(ctx, scan.Code)
+		if err == nil && p.ItemCode != "" {
+			p.TillPrice = p.PriceAt(branch)
+			count := item.Quantity
+			if count <= 0 {
+				count = 1
+			}
+
+			switch scan.Kind {
+			case barcode.KindWeight:
+				item.Quantity = count * scan.Weight
+			case barcode.KindPrice:
+				if p.TillPrice <= 0 {
+					return p, apperr.New(apperr.ProductNotFound, "product "+p.ItemCode+" has no price to weigh the label by")
+				}
+				// the weight the label was priced for, unrounded so the line comes to the label's price
+				item.Quantity = count * float64(scan.Price) / float64(money.New(p.TillPrice))
+			case barcode.KindPack:
+				if p.PkgQty > 0 {
+					count *= p.PkgQty
+				}
+				item.Quantity = count
+			}
+			item.ItemCode = p.ItemCode
+			return p, nil
+		}
+		// the code may still be a product's own barcode
+		log.Printf("barcode %v resolved to %v which was not found    err = %v", item.ItemCode, scan.Code, err)
+	}
+
+	p, err := s.Catalog.Fetch(ctx, item.ItemCode)
+	if err != nil {
+		return p, apperr.Wrap(apperr.ProductNotFound, "failed to fetch product "+item.ItemCode, err)
+	}
+	p.TillPrice = p.PriceAt(branch)
+	return p, nil
+}
+
+// checkStock rejects an item beyond the product's balance at the seller's stock location
+// less what's in the branch's open carts, unless negative sales are allowed or an approver allows it
+func (s *Service) checkStock(ctx context.Context, branch, location string, item *Sales, p products.StockMaster) error {
+	if s.Settings == nil {
+		return nil
+	}
+	poSett, err := s.Settings()
+	if err != nil || poSett.AllowNegSale {
+		return nil
+	}
+
+	open, err := s.Receipts.OpenQuantity(ctx, branch, p.ItemCode)
+	if err != nil {
+		return apperr.Wrap(apperr.Internal, "failed to check open carts", err)
+	}
+	available := p.BalAt(location) - open
+	if item.Quantity <= available {
+		return nil
+	}
+
+	if item.StockApprover == "" {
+		return apperr.New(apperr.InsufficientStock, fmt.Sprintf("only %v of %v is in stock \n approval is required to sell %v", max(available, 0), p.ItemName, item.Quantity))
+	}
+
+	authDetails, err := s.approve(ctx, item.StockApprover, item.ApToken, logins.RightGrantApproveSales)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%v approved selling %v of %v with %v in stock", authDetails.Username, item.Quantity, p.ItemCode, available)
+	item.StockApprover = authDetails.Username
+	return nil
+}
+
+// checkDispensing requires a batch and expiry, or a prescription, for products flagged for them
+// where the branch's profile captures them
+func (s *Service) checkDispensing(ctx context.Context, branch string, item *Sales, p products.StockMaster) error {
+	if p.TrackBatches && s.featureOn(ctx, branch, variables.FeatureBatches) {
+		if err := item.checkBatch(p, time.Now()); err != nil {
+			return err
+		}
+	}
+	if p.PrescriptionOnly && s.featureOn(ctx, branch, variables.FeaturePrescriptions) {
+		if err := item.checkPrescription(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSerials requires a serial for each unit of a serialized product
+// returns an error if a serial is still out on a posted sale
+func (s *Service) checkSerials(ctx context.Context, item *Sales, p products.StockMaster) error {
+	if !p.Serialized {
+		return nil
+	}
+	if err := item.checkSerials(p); err != nil {
+		return err
+	}
+
+	for _, serial := range item.Serials {
+		history, err := s.Receipts.SerialSales(ctx, serial)
+		if err != nil {
+			return apperr.Wrap(apperr.Internal, "failed to check serials", err)
+		}
+		if sale, ok := latestSale(history); ok && !sale.Returned {
+			return apperr.New(apperr.SerialSold, fmt.Sprintf("serial %v was sold on receipt %v", serial, sale.ReceiptNum))
+		}
+	}
+	return nil
+}
+
+// checkAge holds an age restricted item until poster confirms the customer's date of birth or an approver overrides
+// returns an error during the hours restricted items can't be sold
+func (s *Service) checkAge(ctx context.Context, poster string, item *Sales, p products.StockMaster) error {
+	if p.MinAge <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if s.Settings != nil {
+		if poSett, err := s.Settings(); err == nil && inHours(poSett.RestrictedFrom, poSett.RestrictedTo, now) {
+			return apperr.New(apperr.Forbidden, fmt.Sprintf("%v can't be sold between %v and %v", p.ItemName, poSett.RestrictedFrom, poSett.RestrictedTo))
+		}
+	}
+
+	chk := item.AgeCheck
+	if chk == nil || (chk.BirthDate == nil && chk.Approver == "") {
+		return apperr.New(apperr.AgeCheckRequired, fmt.Sprintf("%v is for customers %d and over \n confirm the customer's date of birth or get approval", p.ItemName, p.MinAge))
+	}
+	chk.MinAge = p.MinAge
+	chk.VerifiedBy = poster
+	chk.VerifiedAt = now
+
+	if chk.BirthDate != nil {
+		if age := ageOn(*chk.BirthDate, now); age < p.MinAge {
+			return apperr.New(apperr.Forbidden, fmt.Sprintf("the customer is %d, %v is for customers %d and over", age, p.ItemName, p.MinAge))
+		}
+		chk.Approver = ""
+		return nil
+	}
+
+	authDetails, err := s.approve(ctx, chk.Approver, chk.ApToken, logins.RightGrantApproveSales)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%v overrode the age check on %v for %v", authDetails.Username, p.ItemCode, poster)
+	chk.Approver = authDetails.Username
+	return nil
+}
+
+// AgeRestrictedReport sums each teller's age restricted sales and overrides at the branch from the start of from to the end of to
+func (s *Service) AgeRestrictedReport(ctx context.Context, branch string, from, to time.Time) ([]AgeSales, error) {
+	if to.Before(from) {
+		return nil, apperr.New(apperr.ValidationFailed, "the report can't end before it starts")
+	}
+	return s.Receipts.AgeRestricted(ctx, branch, from, to.AddDate(0, 0, 1))
+}
+
+// LookupSerial finds the latest sale of a serial for warranty claims
+func (s *Service) LookupSerial(ctx context.Context, serial string) (SerialSale, error) {
+	serial = strings.ToUpper(strings.TrimSpace(serial))
+	history, err := s.Receipts.SerialSales(ctx, serial)
+	if err != nil {
+		return SerialSale{}, apperr.Wrap(apperr.Internal, "failed to look up serial", err)
+	}
+
+	sale, ok := latestSale(history)
+	if !ok {
+		return SerialSale{}, apperr.New(apperr.NotFound, fmt.Sprintf("serial %v hasn't been sold", serial))
+	}
+	return sale, nil
+}
+
+// DispensingRegister lists the batch and prescription lines the branch posted from the start of from to the end of to
+func (s *Service) DispensingRegister(ctx context.Context, branch string, from, to time.Time) ([]Dispensed, error) {
+	if to.Before(from) {
+		return nil, apperr.New(apperr.ValidationFailed, "the register can't end before it starts")
+	}
+	return s.Receipts.Dispensed(ctx, branch, from, to.AddDate(0, 0, 1))
+}
+
+// SearchProducts finds products for the till, priced for the user's branch with balances at their stock location
+// returns an error when product search or category browsing is turned off
+func (s *Service) SearchProducts(ctx context.Context, user logins.Users, q products.SearchQuery) (products.SearchResult, error) {
+	poSett, err := s.Settings()
+	if err != nil {
+		return products.SearchResult{}, apperr.Wrap(apperr.Internal, "failed to load pos settings", err)
+	}
+	if !poSett.AllowProductSearch {
+		return products.SearchResult{}, apperr.New(apperr.Forbidden, "product search is turned off")
+	}
+	if q.Category != "" && !poSett.HasCategories {
+		return products.SearchResult{}, apperr.New(apperr.BadRequest, "product categories are turned off")
+	}
+
+	q.Branch = user.Branch
+	q.StkLocation = user.StkLocation
+	res, err := s.Catalog.Search(ctx, q)
+	if err != nil {
+		return products.SearchResult{}, apperr.Wrap(apperr.UpstreamUnavailable, "failed to search products", err)
+	}
+
+	for i := range res.Products {
+		res.Products[i].TillPrice = res.Products[i].PriceAt(user.Branch)
+	}
+	if !poSett.HasCategories {
+		res.Categories = []string{}
+	}
+	return res, nil
+}
+
+// reprice applies edit, then the promotions running at the receipt's branch and the vat, to its cart
+// without an edit the cart keeps its prices when the promotions or vat codes can't be loaded
+func (s *Service) reprice(ctx context.Context, rcpt *ReceiptLog, edit func([]Sales) []Sales) error {
+	if s.Promotions == nil && s.Taxes == nil && edit == nil {
+		return nil
+	}
+
+	fn, err := s.priced(ctx, rcpt, edit)
+	if err != nil {
+		if edit == nil {
+			return nil
+		}
+		return err
+	}
+	return s.Receipts.Reprice(ctx, rcpt, fn)
+}
+
+// priced wraps edit with the promotions running at the receipt's branch and the vat
+func (s *Service) priced(ctx context.Context, rcpt *ReceiptLog, edit func([]Sales) []Sales) (func([]Sales) []Sales, error) {
+	price, err := s.pricer(ctx, rcpt.Branch)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(cart []Sales) []Sales {
+		if edit != nil {
+			cart = edit(cart)
+		}
+		return price(cart, rcpt.VatExempt)
+	}, nil
+}
+
+// pricer loads the branch's running promotions and the vat codes
+// the returned func applies them to a cart, exempting it from vat for exempt customers
+func (s *Service) pricer(ctx context.Context, branch string) (func(cart []Sales, exempt bool) []Sales, error) {
+	taxes, err := s.taxTable()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var promos []Promotion
+	if s.Promotions != nil {
+		promos, err = s.Promotions.Active(ctx, branch, now)
+		if err != nil {
+			log.Println("failed to load promotions    err =", err)
+			return nil, err
+		}
+	}
+
+	return func(cart []Sales, exempt bool) []Sales {
+		cart = ApplyPromotions(cart, promos, branch, now)
+		if taxes == nil {
+			return cart
+		}
+		return taxes.Apply(cart, exempt)
+	}, nil
+}
+
+// taxTable loads the vat codes
+// returns nil when the service has no tax table
+func (s *Service) taxTable() (*TaxTable, error) {
+	if s.Taxes == nil {
+		return nil, nil
+	}
+
+	taxes, err := s.Taxes()
+	if err != nil {
+		log.Println("failed to load vat codes    err =", err)
+		return nil, err
+	}
+	return &taxes, nil
+}
+
+// profile loads the branch's industry profile
+// reports false when the service has no profiles or the branch's can't be loaded
+func (s *Service) profile(ctx context.Context, branch string) (variables.Profile, bool) {
+	if s.Profile == nil {
+		return variables.Profile{}, false
+	}
+
+	p, err := s.Profile(ctx, branch)
+	if err != nil {
+		// the server's mode still applies when the branch's can't be read
+		log.Println("failed to load branch profile    err =", err)
+		return variables.Profiles[variables.IndustryMode], true
+	}
+	return p, true
+}
+
+// featureOn reports whether the branch's profile has feature on, every feature is on without a profile
+func (s *Service) featureOn(ctx context.Context, branch, feature string) bool {
+	p, ok := s.profile(ctx, branch)
+	return !ok || p.Has(feature)
+}
+
+// requireFeature returns an error if the branch's profile has feature off
+func (s *Service) requireFeature(ctx context.Context, branch, feature string) error {
+	if p, ok := s.profile(ctx, branch); ok && !p.Has(feature) {
+		return apperr.New(apperr.Forbidden, fmt.Sprintf("%v is off for %v branches", strings.ReplaceAll(feature, "_", " "), p.Mode))
+	}
+	return nil
+}
+
+// SetVatExempt marks the receipt's customer as exempt from vat, or not, and reprices the cart
+// ref is the customer's exemption certificate, required to exempt them
+// exempting needs the user's vat exempt right or an approver who can grant it
+func (s *Service) SetVatExempt(ctx context.Context, user logins.Users, rcpt *ReceiptLog, exempt bool, ref, approver, apToken string) error {
+	if exempt && ref == "" {
+		return apperr.New(apperr.ValidationFailed, "exemption certificate number is required")
+	}
+	if err := s.Receipt(ctx, rcpt); err != nil {
+		return err
+	}
+	if exempt {
+		if err := s.approveExempt(ctx, user, approver, apToken); err != nil {
+			return err
+		}
+	}
+	return s.setVatExempt(ctx, rcpt, exempt, ref)
+}
+
+// setVatExempt records the receipt's exemption and reprices the cart
+func (s *Service) setVatExempt(ctx context.Context, rcpt *ReceiptLog, exempt bool, ref string) error {
+	rcpt.VatExempt = exempt
+	rcpt.VatExemptRef = ref
+	if !exempt {
+		rcpt.VatExemptRef = ""
+	}
+	if err := s.Receipts.SetVatExempt(ctx, rcpt); err != nil {
+		return err
+	}
+	if err := s.reprice(ctx, rcpt, nil); err != nil {
+		return err
+	}
+
+	s.Events.Publish(events.Event{
+		Type:       events.CartRepriced,
+		Branch:     rcpt.Branch,
+		TillNum:    rcpt.TillNum,
+		ReceiptNum: rcpt.ReceiptNum,
+		Data:       rcpt,
+	})
+	return nil
+}
+
+// SaveCustomer creates the customer or updates their details when they have an id
+// creating a customer with a phone already on file returns that customer, so the till can quick-create
+// a new exemption certificate needs the user's vat exempt right or an approver who can grant it
+func (s *Service) SaveCustomer(ctx context.Context, user logins.Users, c *Customer, approver, apToken string) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	cert := ""
+	if c.ID == 0 {
+		existing, err := s.Customers.Fetch(ctx, 0, c.Phone)
+		if err == nil {
+			*c = existing
+			return nil
+		}
+		if !apperr.Is(err, apperr.NotFound) {
+			return err
+		}
+		c.CreatedBy = user.Username
+	} else {
+		existing, err := s.Customers.Fetch(ctx, c.ID, "")
+		if err != nil {
+			return err
+		}
+		cert = existing.ExemptCert
+	}
+
+	if c.ExemptCert != "" && c.ExemptCert != cert {
+		if err := s.approveExempt(ctx, user, approver, apToken); err != nil {
+			return err
+		}
+	}
+	return s.Customers.Save(ctx, c)
+}
+
+// SearchCustomers finds customers by name, phone, email or KRA PIN
+func (s *Service) SearchCustomers(ctx context.Context, q string, limit int) ([]Customer, error) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return nil, apperr.New(apperr.ValidationFailed, "search text is required")
+	}
+	if limit <= 0 || limit > MaxCustomers {
+		limit = MaxCustomers
+	}
+	return s.Customers.Search(ctx, q, limit)
+}
+
+// AttachCustomer sets the open receipt's customer, or clears it when customerID is 0
+// their PIN goes into the receipt's fiscal details and their exemption certificate exempts the sale from vat
+// applying the certificate needs the user's vat exempt right or an approver who can grant it
+// an exemption that came from the customer being replaced is lifted
+func (s *Service) AttachCustomer(ctx context.Context, user logins.Users, rcpt *ReceiptLog, customerID int64, approver, apToken string) (Customer, error) {
+	if err := s.Receipt(ctx, rcpt); err != nil {
+		return Customer{}, err
+	}
+
+	var c Customer
+	if customerID != 0 {
+		var err error
+		if c, err = s.Customers.Fetch(ctx, customerID, ""); err != nil {
+			return Customer{}, err
+		}
+	}
+
+	// the exemption is the previous customer's when it carries their certificate
+	inherited := false
+	if rcpt.VatExempt && rcpt.CustomerID != 0 && rcpt.CustomerID != c.ID {
+		prev, err := s.Customers.Fetch(ctx, rcpt.CustomerID, "")
+		if err != nil && !apperr.Is(err, apperr.NotFound) {
+			return Customer{}, err
+		}
+		inherited = prev.ExemptCert != "" && prev.ExemptCert == rcpt.VatExemptRef
+	}
+
+	apply := c.ExemptCert != "" && (!rcpt.VatExempt || inherited) && c.ExemptCert != rcpt.VatExemptRef
+	if apply {
+		if err := s.approveExempt(ctx, user, approver, apToken); err != nil {
+			return Customer{}, err
+		}
+	}
+
+	rcpt.CustomerID = c.ID
+	rcpt.Etr.CustomerPin = c.KraPin
+	if err := s.Receipts.SetCustomer(ctx, rcpt); err != nil {
+		return Customer{}, err
+	}
+
+	switch {
+	case apply:
+		if err := s.setVatExempt(ctx, rcpt, true, c.ExemptCert); err != nil {
+			return Customer{}, err
+		}
+	case inherited && c.ExemptCert == "":
+		if err := s.setVatExempt(ctx, rcpt, false, ""); err != nil {
+			return Customer{}, err
+		}
+	}
+
+	s.Events.Publish(events.Event{
+		Type:       events.CustomerAttached,
+		Branch:     rcpt.Branch,
+		TillNum:    rcpt.TillNum,
+		ReceiptNum: rcpt.ReceiptNum,
+		Data:       rcpt,
+	})
+	return c, nil
+}
+
+// approveExempt checks the user may exempt sales from vat, or their approver can grant it
+func (s *Service) approveExempt(ctx context.Context, user logins.Users, approver, apToken string) error {
+	if user.HasRight(logins.RightVatExempt) {
+		return nil
+	}
+	if approver == "" {
+		return apperr.New(apperr.ApprovalRequired, "exempting the sale from vat needs approval")
+	}
+	_, err := s.approve(ctx, approver, apToken, logins.RightGrantVatExempt)
+	return err
+}
+
+// NewQuotation starts a quotation at the user's branch, valid for QuoteDays when it has no date
+// a customer with an exemption certificate is quoted without vat
+func (s *Service) NewQuotation(ctx context.Context, user logins.Users, q *Quotation) error {
+	now := time.Now()
+	if q.ValidUntil.IsZero() {
+		q.ValidUntil = now.AddDate(0, 0, QuoteDays)
+	}
+	if !q.ValidUntil.After(now) {
+		return apperr.New(apperr.ValidationFailed, "a quotation must be valid until a later date")
+	}
+
+	if q.CustomerID != 0 {
+		c, err := s.Customers.Fetch(ctx, q.CustomerID, "")
+		if err != nil {
+			return err
+		}
+		q.VatExempt = c.ExemptCert != ""
+		q.Customer = &c
+	}
+
+	q.Branch = user.Branch
+	q.CompanyID = user.CompanyID
+	q.Poster = user.Username
+	return s.Quotes.Create(ctx, q)
+}
+
+// Quotation loads the quotation and its customer
+func (s *Service) Quotation(ctx context.Context, q *Quotation) error {
+	if err := s.Quotes.Fetch(ctx, q); err != nil {
+		return err
+	}
+	if q.CustomerID != 0 {
+		c, err := s.Customers.Fetch(ctx, q.CustomerID, "")
+		if err != nil {
+			log.Printf("failed to load customer %v of quotation %v    err = %v", q.CustomerID, q.QuoteNum, err)
+		} else {
+			q.Customer = &c
+		}
+	}
+	return nil
+}
+
+// openQuote loads the quotation, returning an error unless it's open and its prices still hold
+func (s *Service) openQuote(ctx context.Context, q *Quotation) error {
+	if err := s.Quotation(ctx, q); err != nil {
+		return err
+	}
+	if q.State != QuoteOpen {
+		return apperr.New(apperr.QuoteClosed, fmt.Sprintf("quotation %v is %v", q.QuoteNum, q.State))
+	}
+	if q.Expired(time.Now()) {
+		return apperr.New(apperr.QuoteClosed, fmt.Sprintf("quotation %v expired on %v", q.QuoteNum, q.ValidUntil.Format(time.DateOnly)))
+	}
+	return nil
+}
+
+// AddQuoteItem fills an item from inventory and prices it into the quotation as the cart would
+// stock isn't checked or held until the quotation is converted into a sale
+func (s *Service) AddQuoteItem(ctx context.Context, q *Quotation, item *Sales) error {
+	if err := s.openQuote(ctx, q); err != nil {
+		return err
+	}
+
+	p, err := s.scanProduct(ctx, item, q.Branch)
+	if err != nil {
+		return err
+	}
+	if p, err = item.priceModifiers(p); err != nil {
+		return err
+	}
+	if err := item.Fill(p); err != nil {
+		return apperr.Wrap(apperr.ProductNotFound, "product "+item.ItemCode+" is not for sale", err)
+	}
+	item.ReceiptNum = 0
+
+	price, err := s.pricer(ctx, q.Branch)
+	if err != nil {
+		return err
+	}
+	err = s.Quotes.Edit(ctx, q, func(items []Sales) []Sales {
+		return price(append(items, *item), q.VatExempt)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, line := range q.Items {
+		if line.ReceiptItem == item.ReceiptItem {
+			*item = line
+		}
+	}
+	return nil
+}
+
+// DeleteQuoteItem takes the line off the quotation and reprices the rest
+func (s *Service) DeleteQuoteItem(ctx context.Context, q *Quotation, receiptItem string) error {
+	if err := s.openQuote(ctx, q); err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(q.Items, func(line Sales) bool { return line.ReceiptItem == receiptItem && line.State == "pending" }) {
+		return apperr.New(apperr.NotFound, fmt.Sprintf("item %v is not on quotation %v", receiptItem, q.QuoteNum))
+	}
+
+	price, err := s.pricer(ctx, q.Branch)
+	if err != nil {
+		return err
+	}
+	return s.Quotes.Edit(ctx, q, func(items []Sales) []Sales {
+		for i := range items {
+			if items[i].ReceiptItem == receiptItem {
+				items[i].State = "DELETED"
+			}
+		}
+		return price(items, q.VatExempt)
+	})
+}
+
+// CancelQuotation withdraws an open quotation
+func (s *Service) CancelQuotation(ctx context.Context, q *Quotation) error {
+	if err := s.Quotation(ctx, q); err != nil {
+		return err
+	}
+	q.State = QuoteCancelled
+	return s.Quotes.Close(ctx, q)
+}
+
+// ConvertQuotation opens a receipt on the user's till with the quotation's lines
+// lines keep their quoted prices unless reprice is set, expired quotations and those of other branches are only converted at today's prices
+// lines whose serials, batch, prescription or age are captured at the till, or that fail the stock check, are held back for the cashier to scan
+func (s *Service) ConvertQuotation(ctx context.Context, user logins.Users, q *Quotation, reprice bool, approver, apToken string) (ReceiptLog, []HeldLine, error) {
+	if err := s.Quotation(ctx, q); err != nil {
+		return ReceiptLog{}, nil, err
+	}
+	if q.State != QuoteOpen {
+		return ReceiptLog{}, nil, apperr.New(apperr.QuoteClosed, fmt.Sprintf("quotation %v is %v", q.QuoteNum, q.State))
+	}
+	if !reprice && q.Expired(time.Now()) {
+		return ReceiptLog{}, nil, apperr.New(apperr.QuoteClosed, fmt.Sprintf("quotation %v expired on %v \n convert it at today's prices", q.QuoteNum, q.ValidUntil.Format(time.DateOnly)))
+	}
+	if !reprice && q.Branch != user.Branch {
+		return ReceiptLog{}, nil, apperr.New(apperr.ValidationFailed, fmt.Sprintf("quotation %v was priced for %v \n convert it at today's prices", q.QuoteNum, q.Branch))
+	}
+	if q.VatExempt {
+		if err := s.approveExempt(ctx, user, approver, apToken); err != nil {
+			return ReceiptLog{}, nil, err
+		}
+	}
+
+	rcpt := ReceiptLog{
+		TillNum:   user.TillNum,
+		Poster:    user.Username,
+		Branch:    user.Branch,
+		CompanyID: user.CompanyID,
+		SaleType:  "Cash Sale",
+	}
+	if rcpt.TillNum == 0 {
+		return ReceiptLog{}, nil, ErrTillNotOpen
+	}
+	if err := s.createReceipt(ctx, &rcpt); err != nil {
+		return ReceiptLog{}, nil, err
+	}
+
+	held, err := s.convertQuote(ctx, user, &rcpt, q, reprice, approver, apToken)
+	if err == nil {
+		q.State = QuoteConverted
+		q.ReceiptNum = rcpt.ReceiptNum
+		err = s.Quotes.Close(ctx, q)
+	}
+	if err != nil {
+		if vErr := s.Receipts.Void(ctx, &rcpt); vErr != nil {
+			log.Printf("failed to void receipt %v of quotation %v    err = %v", rcpt.ReceiptNum, q.QuoteNum, vErr)
+		}
+		return ReceiptLog{}, nil, err
+	}
+
+	if err := s.Receipt(ctx, &rcpt); err != nil {
+		return ReceiptLog{}, nil, err
+	}
+	s.Events.Publish(events.Event{
+		Type:       events.QuoteConverted,
+		Branch:     rcpt.Branch,
+		TillNum:    rcpt.TillNum,
+		ReceiptNum: rcpt.ReceiptNum,
+		Data:       rcpt,
+	})
+	return rcpt, held, nil
+}
+
+// convertQuote adds the quotation's lines to the receipt, priced and with its customer attached
+// returns the lines held back
+func (s *Service) convertQuote(ctx context.Context, user logins.Users, rcpt *ReceiptLog, q *Quotation, reprice bool, approver, apToken string) ([]HeldLine, error) {
+	location := s.stockLocation(ctx, rcpt.Poster)
+	held := []HeldLine{}
+	for _, line := range q.Items {
+		if line.State != "pending" {
+			continue
+		}
+
+		p, err := s.Catalog.Fetch(ctx, line.ItemCode)
+		if err != nil || p.ItemCode == "" {
+			held = append(held, HeldLine{Line: line, Reason: "the product is no longer sold"})
+			continue
+		}
+		if reason := captureAtSale(p); reason != "" {
+			held = append(held, HeldLine{Line: line, Reason: reason})
+			continue
+		}
+
+		item := line
+		item.ReceiptNum = rcpt.ReceiptNum
+		item.TransDate = time.Now()
+		if reprice {
+			p.TillPrice = p.PriceAt(rcpt.Branch)
+			if p, err = item.priceModifiers(p); err != nil {
+				held = append(held, HeldLine{Line: line, Reason: apperr.From(err).Message})
+				continue
+			}
+			if err := item.Fill(p); err != nil {
+				held = append(held, HeldLine{Line: line, Reason: "the product is not for sale"})
+				continue
+			}
+			// the quoted line's id is kept, it's unique on the new receipt
+			item.ReceiptItem = line.ReceiptItem
+		}
+		if err := s.checkStock(ctx, rcpt.Branch, location, &item, p); err != nil {
+			held = append(held, HeldLine{Line: line, Reason: apperr.From(err).Message})
+			continue
+		}
+
+		if err := s.Receipts.AddItem(ctx, rcpt, item); err != nil {
+			return nil, err
+		}
+	}
+
+	if q.CustomerID != 0 {
+		if _, err := s.AttachCustomer(ctx, user, rcpt, q.CustomerID, approver, apToken); err != nil {
+			return nil, err
+		}
+	}
+	return held, s.reprice(ctx, rcpt, nil)
+}
+
+// CloseBill joins the bill's dispatched orders into its receipt, with the delivery fee of bills booked for delivery
+func (s *Service) CloseBill(ctx context.Context, rcpt *ReceiptLog) error {
+	if rcpt.ReceiptNum == 0 {
+		return ErrNullReceipt
+	}
+	if err := s.Receipts.CloseBill(ctx, rcpt); err != nil {
+		return err
+	}
+	if err := s.reprice(ctx, rcpt, s.deliveryFee(ctx, rcpt.ReceiptNum)); err != nil {
+		return err
+	}
+
+	s.Events.Publish(events.Event{
+		Type:       events.BillClosed,
+		Branch:     rcpt.Branch,
+		TillNum:    rcpt.TillNum,
+		ReceiptNum: rcpt.ReceiptNum,
+		State:      rcpt.State,
+		Data:       rcpt,
+	})
+	return nil
+}
+
+// MergeBills moves the orders of the given bills into rcpt and voids them
+func (s *Service) MergeBills(ctx context.Context, rcpt *ReceiptLog, receipts []int64) error {
+	if rcpt.ReceiptNum == 0 {
+		return ErrNullReceipt
+	}
+	if err := s.Receipts.Merge(ctx, rcpt, receipts); err != nil {
+		return err
+	}
+
+	s.Events.Publish(events.Event{
+		Type:       events.BillMerged,
+		Branch:     rcpt.Branch,
+		TillNum:    rcpt.TillNum,
+		ReceiptNum: rcpt.ReceiptNum,
+		Data:       receipts,
+	})
+
+	s.releaseTables(ctx, receipts...)
+
+	// devices showing the voided bills follow them to the merged bill
+	for _, num := range receipts {
+		s.Events.Publish(events.Event{
+			Type:       events.BillMerged,
+			Branch:     rcpt.Branch,
+			TillNum:    rcpt.TillNum,
+			ReceiptNum: num,
+			State:      "VOIDED",
+			Data:       rcpt.ReceiptNum,
+		})
+	}
+	return nil
+}
+
+// SplitBill moves lines of the bill to new receipts by item, by seat or into equal shares
+// bills split from the cart are repriced so each receipt gets its own promotions and tax
+// returns the new receipts, rcpt keeps the rest of the bill
+func (s *Service) SplitBill(ctx context.Context, rcpt *ReceiptLog, split Split) ([]ReceiptLog, error) {
+	if rcpt.ReceiptNum == 0 {
+		return nil, ErrNullReceipt
+	}
+	if err := split.Validate(); err != nil {
+		return nil, err
+	}
+
+	receipts, err := s.Receipts.Split(ctx, rcpt, split)
+	if err != nil {
+		return nil, err
+	}
+
+	nums := make([]int64, len(receipts))
+	for i := range receipts {
+		nums[i] = receipts[i].ReceiptNum
+		if len(receipts[i].Cart) == 0 {
+			continue
+		}
+		if err := s.reprice(ctx, &receipts[i], nil); err != nil {
+			log.Println("failed to reprice split receipt    err =", err)
+		}
+	}
+	if len(rcpt.Cart) > 0 {
+		if err := s.reprice(ctx, rcpt, nil); err != nil {
+			log.Println("failed to reprice split bill    err =", err)
+		}
+	}
+
+	s.Events.Publish(events.Event{
+		Type:       events.BillSplit,
+		Branch:     rcpt.Branch,
+		TillNum:    rcpt.TillNum,
+		ReceiptNum: rcpt.ReceiptNum,
+		Data:       nums,
+	})
+	return receipts, nil
+}
+
+// Receipt fetches the receipt with its pending cart
+func (s *Service) Receipt(ctx context.Context, rcpt *ReceiptLog) error {
+	if rcpt.ReceiptNum == 0 {
+		return ErrNullReceipt
+	}
+
+	num := rcpt.ReceiptNum
+	if err := s.Receipts.Fetch(ctx, rcpt); err != nil || rcpt.ReceiptNum == 0 {
+		return apperr.Wrap(apperr.NotFound, fmt.Sprintf("receipt %v not found", num), err)
+	}
+	return nil
+}
+
+// ApplyPayment tenders a payment against the receipt
+// returns the balance left to pay
+func (s *Service) ApplyPayment(ctx context.Context, rcpt *ReceiptLog, pay Payment) (money.Amount, error) {
+	if pay.Paymode == "" {
+		return 0, apperr.New(apperr.ValidationFailed, "paymode is required")
+	}
+	if pay.Amount <= 0 {
+		return 0, apperr.New(apperr.ValidationFailed, "amount must be greater than zero")
+	}
+
+	if err := s.Receipt(ctx, rcpt); err != nil {
+		return 0, err
+	}
+	if err := s.Receipts.Pay(ctx, rcpt, pay); err != nil {
+		return 0, err
+	}
+
+	tendered, err := s.Receipts.Tendered(ctx, rcpt.ReceiptNum)
+	if err != nil {
+		return 0, err
+	}
+	if pay.Paymode == "cash" {
+		rcpt.Cash += pay.Amount
+	}
+	return s.amountDue(rcpt) - tendered, nil
+}
+
+// amountDue is the receipt's total with the cash rounding
+// receipts settled in cash are rounded to the configured step, the difference is kept in Rounding
+func (s *Service) amountDue(rcpt *ReceiptLog) money.Amount {
+	rcpt.Rounding = 0
+	if rcpt.Cash <= 0 || s.Settings == nil {
+		return rcpt.Total
+	}
+
+	poSett, _ := s.Settings()
+	rcpt.Rounding = rcpt.Total.Round(poSett.CashRounding) - rcpt.Total
+	return rcpt.Total + rcpt.Rounding
+}
+
+// addRounding puts the receipt's cash rounding on its cart as a line, so the lines add up to what was paid
+func (s *Service) addRounding(rcpt *ReceiptLog) {
+	if rcpt.Rounding == 0 {
+		return
+	}
+
+	vat := ""
+	if s.Settings != nil {
+		if poSett, err := s.Settings(); err == nil {
+			vat = poSett.RoundingVat
+			if vat == "" && len(poSett.VatExemptCodes) > 0 {
+				vat = poSett.VatExemptCodes[0]
+			}
+		}
+	}
+
+	rcpt.Cart = append(rcpt.Cart, rcpt.roundingLine(vat))
+	rcpt.Total += rcpt.Rounding
+	rcpt.TaxSummary = SummarizeTax(rcpt.Cart)
+}
+
+// PostReceipt completes a fully paid receipt
+// returns an error if the payments don't cover the total
+func (s *Service) PostReceipt(ctx context.Context, rcpt *ReceiptLog) error {
+	if err := s.Receipt(ctx, rcpt); err != nil {
+		return err
+	}
+	// a posted receipt's stock is already taken, posting it again mustn't take or give back any
+	if !slices.Contains([]string{"pending", "pending payment", "paying"}, rcpt.State) {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open for payment", rcpt.ReceiptNum))
+	}
+	if len(rcpt.Cart) == 0 {
+		return ErrEmptyReceipt
+	}
+
+	tendered, err := s.Receipts.Tendered(ctx, rcpt.ReceiptNum)
+	if err != nil {
+		return err
+	}
+
+	balance := s.amountDue(rcpt) - tendered
+	if balance > 0 {
+		return apperr.New(apperr.InsufficientPayment, fmt.Sprintf("payment is short by %v", balance))
+	}
+
+	rcpt.Change = -balance
+	s.addRounding(rcpt)
+	reserved, err := s.reserveStock(ctx, rcpt)
+	if err != nil {
+		return err
+	}
+	if err := s.Receipts.Post(ctx, rcpt); err != nil {
+		// only give back what this post took
+		if reserved {
+			s.releaseStock(ctx, rcpt.ReceiptNum, nil)
+		}
+		return err
+	}
+
+	s.releaseTables(ctx, rcpt.ReceiptNum)
+
+	// discounts go to the paying till's summary for the till report
+	discount := money.Amount(0)
+	for _, item := range rcpt.Cart {
+		discount += item.Discount
+	}
+	if discount > 0 {
+		till := rcpt.PayTill
+		if till == 0 {
+			till = rcpt.TillNum
+		}
+		if err := s.Tills.AddDiscount(ctx, till, discount); err != nil {
+			log.Printf("failed to add receipt %v discount to till %v    err = %v", rcpt.ReceiptNum, till, err)
+		}
+	}
+
+	s.Events.Publish(events.Event{
+		Type:       events.PaymentCompleted,
+		Branch:     rcpt.Branch,
+		TillNum:    rcpt.TillNum,
+		ReceiptNum: rcpt.ReceiptNum,
+		State:      rcpt.State,
+		Data:       rcpt,
+	})
+	return nil
+}
+
+// VoidReceipt voids an open receipt and its orders, returning any stock reserved for it
+// returns an error if the receipt is already settled or voided
+func (s *Service) VoidReceipt(ctx context.Context, rcpt *ReceiptLog) error {
+	if err := s.Receipt(ctx, rcpt); err != nil {
+		return err
+	}
+	if slices.Contains(closedStates, rcpt.State) {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is %v and can't be voided", rcpt.ReceiptNum, rcpt.State))
+	}
+	if err := s.Receipts.Void(ctx, rcpt); err != nil {
+		return err
+	}
+	s.releaseStock(ctx, rcpt.ReceiptNum, nil)
+	s.releaseTables(ctx, rcpt.ReceiptNum)
+
+	rcpt.State = "VOIDED"
+	s.Events.Publish(events.Event{
+		Type:       events.ReceiptVoided,
+		Branch:     rcpt.Branch,
+		TillNum:    rcpt.TillNum,
+		ReceiptNum: rcpt.ReceiptNum,
+		State:      rcpt.State,
+		Data:       rcpt,
+	})
+	return nil
+}
+
+// stockReference names the reservation of a receipt's stock
+func stockReference(receiptNum int64) string {
+	return fmt.Sprintf("receipt-%d", receiptNum)
+}
+
+// stockLocation returns the stock location the user sells from
+// an unknown user leaves it blank and their sales are checked against the product's total
+func (s *Service) stockLocation(ctx context.Context, username string) string {
+	user, err := s.Users.FetchUser(ctx, username)
+	if err != nil {
+		log.Printf("failed to get %v's stock location    err = %v", username, err)
+		return ""
+	}
+	return user.StkLocation
+}
+
+// reserveStock takes the receipt's sold quantities off the stock location its poster sells from
+// returns false when the receipt's stock was already reserved
+func (s *Service) reserveStock(ctx context.Context, rcpt *ReceiptLog) (bool, error) {
+	if s.Stock == nil {
+		return false, nil
+	}
+
+	r := products.Reservation{
+		Reference:   stockReference(rcpt.ReceiptNum),
+		Branch:      rcpt.Branch,
+		StkLocation: s.stockLocation(ctx, rcpt.Poster),
+	}
+	qty := map[string]float64{}
+	for _, item := range rcpt.Cart {
+		// delivery fees and cash rounding aren't stock
+		if item.State != "pending" || item.ItemCode == DeliveryFeeCode || item.ItemCode == RoundingCode {
+			continue
+		}
+		if _, ok := qty[item.ItemCode]; !ok {
+			r.Lines = append(r.Lines, products.StockLine{ItemCode: item.ItemCode})
+		}
+		qty[item.ItemCode] += item.Quantity
+	}
+	for i := range r.Lines {
+		r.Lines[i].Quantity = qty[r.Lines[i].ItemCode]
+	}
+
+	created, err := s.Stock.Reserve(ctx, r)
+	if err != nil {
+		return false, apperr.Wrap(apperr.UpstreamUnavailable, "failed to reserve stock", err)
+	}
+	return created, nil
+}
+
+// releaseStock returns the stock reserved for the receipt, or only the lines given
+// failures are logged since the receipt is already voided, returned or failed to post
+func (s *Service) releaseStock(ctx context.Context, receiptNum int64, lines []products.StockLine) {
+	if s.Stock == nil {
+		return
+	}
+	if err := s.Stock.Release(ctx, stockReference(receiptNum), lines); err != nil {
+		log.Printf("failed to release stock reserved for receipt %v    err = %v", receiptNum, err)
+	}
+}
+
+// BookDelivery books the open bill for delivery to the customer's address and marks its orders for delivery
+// the fee is charged when the bill's orders are closed, or straight away on a bill without orders
+func (s *Service) BookDelivery(ctx context.Context, user logins.Users, d *Delivery) error {
+	rcpt := ReceiptLog{ReceiptNum: d.ReceiptNum}
+	if err := s.Receipt(ctx, &rcpt); err != nil {
+		return err
+	}
+	if rcpt.Branch != user.Branch {
+		return apperr.New(apperr.NotFound, fmt.Sprintf("receipt %v not found", d.ReceiptNum))
+	}
+	if !slices.Contains([]string{"pending", "pending payment", "paying"}, rcpt.State) {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open", d.ReceiptNum))
+	}
+
+	if d.CustomerID != 0 {
+		c, err := s.Customers.Fetch(ctx, d.CustomerID, "")
+		if err != nil {
+			return err
+		}
+		if d.Phone == "" {
+			d.Phone = c.Phone
+		}
+	}
+	if err := d.Validate(); err != nil {
+		return err
+	}
+
+	// attached first so a customer whose exemption needs approval isn't booked
+	if d.CustomerID != 0 {
+		if _, err := s.AttachCustomer(ctx, user, &rcpt, d.CustomerID, "", ""); err != nil {
+			return err
+		}
+	}
+
+	d.Branch = rcpt.Branch
+	d.CreatedBy = user.Username
+	if err := s.Deliveries.Create(ctx, d); err != nil {
+		return err
+	}
+
+	orders, _, err := s.Orders.OrdersInBill(ctx, d.ReceiptNum)
+	if err != nil {
+		return err
+	}
+	if len(orders) > 0 {
+		if err := s.Orders.SetChannel(ctx, d.ReceiptNum, ChannelDelivery); err != nil {
+			return err
+		}
+	}
+	if rcpt.State != "pending" || len(orders) == 0 {
+		if err := s.reprice(ctx, &rcpt, s.feeEdit(*d)); err != nil {
+			return err
+		}
+	}
+
+	s.publishDelivery(*d)
+	return nil
+}
+
+// OpenDeliveries lists the branch's deliveries on the road or with cash to settle
+func (s *Service) OpenDeliveries(ctx context.Context, branch string) ([]Delivery, error) {
+	return s.Deliveries.Open(ctx, branch)
+}
+
+// AssignRider gives the delivery to a rider, or to another rider until it's picked up
+// riders are sales staff of the user's branch
+func (s *Service) AssignRider(ctx context.Context, user logins.Users, receiptNum int64, rider string) (Delivery, error) {
+	d, err := s.delivery(ctx, user.Branch, receiptNum)
+	if err != nil {
+		return Delivery{}, err
+	}
+	riderDetails, err := s.Users.FetchUser(ctx, rider)
+	if err != nil {
+		return Delivery{}, apperr.Wrap(apperr.NotFound, fmt.Sprintf("rider %v not found", rider), err)
+	}
+	if riderDetails.Branch != user.Branch {
+		return Delivery{}, apperr.New(apperr.ValidationFailed, fmt.Sprintf("rider %v isn't at the %v branch", rider, user.Branch))
+	}
+	if !riderDetails.HasRight(logins.RightMakeSales) {
+		return Delivery{}, apperr.New(apperr.ValidationFailed, fmt.Sprintf("rider %v needs '%v' rights to take deliveries", rider, logins.RightMakeSales.Title()))
+	}
+
+	from := d.State
+	if err := d.move(DeliveryAssigned, time.Now()); err != nil {
+		return Delivery{}, err
+	}
+	d.Rider = rider
+	if err := s.Deliveries.Update(ctx, &d, from); err != nil {
+		return Delivery{}, err
+	}
+
+	s.publishDelivery(d)
+	return d, nil
+}
+
+// DeliveryStatus tracks the delivery as picked up, delivered or returned
+// picking up closes the bill with the delivery fee and sets the cash the rider collects
+// returning voids the unpaid bill, a prepaid one is refunded through a sales return
+func (s *Service) DeliveryStatus(ctx context.Context, user logins.Users, receiptNum int64, state string) (Delivery, error) {
+	if !slices.Contains([]string{DeliveryPickedUp, DeliveryDelivered, DeliveryReturned}, state) {
+		return Delivery{}, apperr.New(apperr.ValidationFailed, "state must be picked_up, delivered or returned")
+	}
+
+	d, err := s.delivery(ctx, user.Branch, receiptNum)
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	now := time.Now()
+	from := d.State
+	if err := d.move(state, now); err != nil {
+		return Delivery{}, err
+	}
+
+	switch state {
+	case DeliveryPickedUp:
+		rcpt, err := s.chargeDelivery(ctx, d)
+		if err != nil {
+			return Delivery{}, err
+		}
+		d.CashDue = 0
+		if rcpt.State != "POSTED" {
+			tendered, err := s.Receipts.Tendered(ctx, rcpt.ReceiptNum)
+			if err != nil {
+				return Delivery{}, err
+			}
+			d.CashDue = max(rcpt.Total-tendered, 0)
+		}
+	case DeliveryDelivered:
+		// prepaid deliveries leave nothing to settle
+		if d.CashDue == 0 {
+			d.SettledAt = &now
+		}
+	case DeliveryReturned:
+		// the rider brings the order back, not cash
+		d.CashDue = 0
+	}
+
+	if err := s.Deliveries.Update(ctx, &d, from); err != nil {
+		return Delivery{}, err
+	}
+
+	if state == DeliveryReturned {
+		rcpt := ReceiptLog{ReceiptNum: d.ReceiptNum}
+		if err := s.Receipt(ctx, &rcpt); err != nil {
+			return Delivery{}, err
+		}
+		if !slices.Contains(closedStates, rcpt.State) {
+			if err := s.VoidReceipt(ctx, &rcpt); err != nil {
+				return Delivery{}, err
+			}
+		}
+	}
+
+	s.publishDelivery(d)
+	return d, nil
+}
+
+// SettleDelivery pays the delivered bill with what the rider collected into the cashier's till and posts it
+func (s *Service) SettleDelivery(ctx context.Context, cashier logins.Users, receiptNum int64, pays []Payment) (ReceiptLog, Delivery, error) {
+	if cashier.TillNum == 0 {
+		return ReceiptLog{}, Delivery{}, ErrTillNotOpen
+	}
+	if len(pays) == 0 {
+		return ReceiptLog{}, Delivery{}, apperr.New(apperr.ValidationFailed, "the payments the rider collected are required")
+	}
+
+	d, err := s.delivery(ctx, cashier.Branch, receiptNum)
+	if err != nil {
+		return ReceiptLog{}, Delivery{}, err
+	}
+	if d.State != DeliveryDelivered {
+		return ReceiptLog{}, Delivery{}, apperr.New(apperr.ValidationFailed, fmt.Sprintf("the delivery of receipt %v is %v, only delivered orders are settled", receiptNum, d.State))
+	}
+	if d.SettledAt != nil {
+		return ReceiptLog{}, Delivery{}, apperr.New(apperr.ValidationFailed, fmt.Sprintf("the delivery of receipt %v is already settled", receiptNum))
+	}
+
+	// the cash goes to the settling cashier's till
+	rcpt := ReceiptLog{ReceiptNum: receiptNum, PayTill: cashier.TillNum}
+	if err := s.Receipts.SetPayTill(ctx, &rcpt); err != nil {
+		return ReceiptLog{}, Delivery{}, err
+	}
+	for _, pay := range pays {
+		if _, err := s.ApplyPayment(ctx, &rcpt, pay); err != nil {
+			return ReceiptLog{}, Delivery{}, err
+		}
+	}
+	if err := s.PostReceipt(ctx, &rcpt); err != nil {
+		return ReceiptLog{}, Delivery{}, err
+	}
+
+	now := time.Now()
+	d.SettledTill = cashier.TillNum
+	d.SettledAt = &now
+	if err := s.Deliveries.Update(ctx, &d, DeliveryDelivered); err != nil {
+		return ReceiptLog{}, Delivery{}, err
+	}
+
+	s.publishDelivery(d)
+	return rcpt, d, nil
+}
+
+// delivery loads the delivery of a bill at the branch
+func (s *Service) delivery(ctx context.Context, branch string, receiptNum int64) (Delivery, error) {
+	d, err := s.Deliveries.Fetch(ctx, receiptNum)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if d.Branch != branch {
+		return Delivery{}, apperr.New(apperr.NotFound, fmt.Sprintf("receipt %v is not booked for delivery", receiptNum))
+	}
+	return d, nil
+}
+
+// chargeDelivery puts the delivery fee on the bill, closing it when its orders are still to be joined
+// prepaid bills are left as they are
+func (s *Service) chargeDelivery(ctx context.Context, d Delivery) (ReceiptLog, error) {
+	rcpt := ReceiptLog{ReceiptNum: d.ReceiptNum}
+	if err := s.Receipt(ctx, &rcpt); err != nil {
+		return ReceiptLog{}, err
+	}
+	if rcpt.State == "POSTED" {
+		return rcpt, nil
+	}
+
+	if rcpt.State == "pending" {
+		orders, _, err := s.Orders.OrdersInBill(ctx, rcpt.ReceiptNum)
+		if err != nil {
+			return ReceiptLog{}, err
+		}
+		if len(orders) > 0 {
+			return rcpt, s.CloseBill(ctx, &rcpt)
+		}
+	}
+	return rcpt, s.reprice(ctx, &rcpt, s.feeEdit(d))
+}
+
+// deliveryFee returns an edit charging the bill's delivery fee, or nil when it isn't booked for delivery
+func (s *Service) deliveryFee(ctx context.Context, receiptNum int64) func([]Sales) []Sales {
+	if s.Deliveries == nil {
+		return nil
+	}
+	d, err := s.Deliveries.Fetch(ctx, receiptNum)
+	if err != nil {
+		if !apperr.Is(err, apperr.NotFound) {
+			log.Printf("failed to load the delivery of receipt %v    err = %v", receiptNum, err)
+		}
+		return nil
+	}
+	return s.feeEdit(d)
+}
+
+// feeEdit returns an edit adding the delivery fee line to a cart once, or nil for free deliveries
+func (s *Service) feeEdit(d Delivery) func([]Sales) []Sales {
+	if d.Fee == 0 {
+		return nil
+	}
+
+	vat := "A"
+	if s.Settings != nil {
+		if poSett, err := s.Settings(); err == nil && poSett.DeliveryVat != "" {
+			vat = poSett.DeliveryVat
+		}
+	}
+
+	line := d.feeLine(vat)
+	return func(cart []Sales) []Sales {
+		if slices.ContainsFunc(cart, func(item Sales) bool { return item.ReceiptItem == line.ReceiptItem }) {
+			return cart
+		}
+		return append(cart, line)
+	}
+}
+
+func (s *Service) publishDelivery(d Delivery) {
+	s.Events.Publish(events.Event{
+		Type:       events.DeliveryChanged,
+		Branch:     d.Branch,
+		ReceiptNum: d.ReceiptNum,
+		State:      d.State,
+		Data:       d,
+	})
+}
+
+// Floors lists the branch's floor plans with their tables
+func (s *Service) Floors(ctx context.Context, branch string) ([]Floor, error) {
+	if err := s.requireFeature(ctx, branch, variables.FeatureTables); err != nil {
+		return nil, err
+	}
+	return s.Tables.Floors(ctx, branch)
+}
+
+// SaveFloor creates a floor or renames, reorders or retires it
+func (s *Service) SaveFloor(ctx context.Context, f *Floor) error {
+	if f.Name == "" {
+		return apperr.New(apperr.ValidationFailed, "name is required")
+	}
+	if err := s.requireFeature(ctx, f.Branch, variables.FeatureTables); err != nil {
+		return err
+	}
+	if f.ID == 0 {
+		f.Active = true
+	}
+	return s.Tables.SaveFloor(ctx, f)
+}
+
+// SaveTable creates a table on a floor or changes its layout
+func (s *Service) SaveTable(ctx context.Context, t *DiningTable) error {
+	if t.Name == "" {
+		return apperr.New(apperr.ValidationFailed, "name is required")
+	}
+	if t.FloorID == 0 {
+		return apperr.New(apperr.ValidationFailed, "floor_id is required")
+	}
+	if err := s.requireFeature(ctx, t.Branch, variables.FeatureTables); err != nil {
+		return err
+	}
+	if t.ID == 0 {
+		t.Active = true
+	}
+	return s.Tables.SaveTable(ctx, t)
+}
+
+// FloorState lists the branch's tables as free, ordering, waiting on the kitchen or with the bill requested
+func (s *Service) FloorState(ctx context.Context, branch string, floorID int64) ([]TableState, error) {
+	if err := s.requireFeature(ctx, branch, variables.FeatureTables); err != nil {
+		return nil, err
+	}
+	states, err := s.Tables.FloorState(ctx, branch, floorID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := range states {
+		states[i].resolve(now)
+	}
+	return states, nil
+}
+
+// OpenTable starts a bill for the guests at a free table, served by the user
+func (s *Service) OpenTable(ctx context.Context, user logins.Users, tableID int64, guests int) (DiningTable, error) {
+	if err := s.requireFeature(ctx, user.Branch, variables.FeatureTables); err != nil {
+		return DiningTable{}, err
+	}
+	t, err := s.Tables.Table(ctx, tableID)
+	if err != nil {
+		return DiningTable{}, err
+	}
+	if t.Branch != user.Branch || !t.Active {
+		return DiningTable{}, apperr.New(apperr.NotFound, fmt.Sprintf("table %v not found", tableID))
+	}
+	if user.TillNum == 0 {
+		return DiningTable{}, ErrTillNotOpen
+	}
+
+	rcpt := ReceiptLog{
+		TillNum:   user.TillNum,
+		Poster:    user.Username,
+		Branch:    user.Branch,
+		CompanyID: user.CompanyID,
+		SaleType:  "Cash Sale",
+	}
+	if err := s.createReceipt(ctx, &rcpt); err != nil {
+		return DiningTable{}, err
+	}
+
+	t.ReceiptNum = rcpt.ReceiptNum
+	t.Waiter = user.Username
+	t.Guests = guests
+	if err := s.Tables.Seat(ctx, &t); err != nil {
+		// the table was taken meanwhile, drop the new bill
+		if verr := s.Receipts.Void(ctx, &rcpt); verr != nil {
+			log.Printf("failed to void unused bill %v    err = %v", rcpt.ReceiptNum, verr)
+		}
+		return DiningTable{}, err
+	}
+
+	s.publishTable(t, TableOrdering)
+	return t, nil
+}
+
+// TransferTable hands the table's bill to another waiter
+// only the table's waiter or a user who approves sales can transfer it
+func (s *Service) TransferTable(ctx context.Context, user logins.Users, tableID int64, waiter string) (DiningTable, error) {
+	if err := s.requireFeature(ctx, user.Branch, variables.FeatureTables); err != nil {
+		return DiningTable{}, err
+	}
+	t, err := s.Tables.Table(ctx, tableID)
+	if err != nil {
+		return DiningTable{}, err
+	}
+	if t.Branch != user.Branch {
+		return DiningTable{}, apperr.New(apperr.NotFound, fmt.Sprintf("table %v not found", tableID))
+	}
+	if t.ReceiptNum == 0 {
+		return DiningTable{}, apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("table %v has no open bill", t.Name))
+	}
+	if t.Waiter != user.Username && !user.ApproveSales {
+		return DiningTable{}, apperr.New(apperr.Forbidden, fmt.Sprintf("table %v is served by %v", t.Name, t.Waiter))
+	}
+
+	to, err := s.Users.FetchUser(ctx, waiter)
+	if err != nil {
+		return DiningTable{}, apperr.Wrap(apperr.NotFound, "failed to get waiter "+waiter, err)
+	}
+	if !to.MakeSales || to.Branch != t.Branch {
+		return DiningTable{}, apperr.New(apperr.BadRequest, fmt.Sprintf("%v can't serve tables at %v", waiter, t.Branch))
+	}
+
+	if err := s.Tables.Transfer(ctx, &t, to.Username); err != nil {
+		return DiningTable{}, err
+	}
+
+	s.publishTable(t, TableOrdering)
+	return t, nil
+}
+
+// releaseTables frees the tables of bills that were posted, voided or merged
+func (s *Service) releaseTables(ctx context.Context, receipts ...int64) {
+	if s.Tables == nil {
+		return
+	}
+	freed, err := s.Tables.Release(ctx, receipts)
+	if err != nil {
+		log.Printf("failed to free the tables of bills %v    err = %v", receipts, err)
+		return
+	}
+	for _, t := range freed {
+		s.publishTable(t, TableFree)
+	}
+}
+
+func (s *Service) publishTable(t DiningTable, status string) {
+	s.Events.Publish(events.Event{
+		Type:       events.TableChanged,
+		Branch:     t.Branch,
+		ReceiptNum: t.ReceiptNum,
+		State:      status,
+		Data:       t,
+	})
+}
+
+// inventoryCatalog fetches products from the inventory service
+type inventoryCatalog struct{}
+
+func (inventoryCatalog) Fetch(ctx context.Context, itemCode string) (products.StockMaster, error) {
+	p := products.StockMaster{ItemCode: itemCode}
+	err := p.Fetch(ctx)
+	return p, err
+}
+
+func (inventoryCatalog) Search(ctx context.Context, q products.SearchQuery) (products.SearchResult, error) {
+	return products.Search(ctx, q)
+}
+
+// inventoryStock reserves stock on the inventory service
+type inventoryStock struct{}
+
+func (inventoryStock) Reserve(ctx context.Context, r products.Reservation) (bool, error) {
+	return r.Reserve(ctx)
+}
+
+func (inventoryStock) Release(ctx context.Context, reference string, lines []products.StockLine) error {
+	return products.Release(ctx, reference, lines)
+}
+
+// loginDirectory fetches users from the login service
+type loginDirectory struct{}
+
+func (loginDirectory) FetchUser(ctx context.Context, username string) (logins.Users, error) {
+	u := logins.Users{Username: username}
+	err := u.FetchUser(ctx)
+	return u, err
+}
+
+// loginRegistrar updates tellers' tills on the login service
+type loginRegistrar struct{}
+
+func (loginRegistrar) UpdateTill(ctx context.Context, username string, tillNum int64) error {
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+
+	loginService, err := grpc.NewTillService(grpc.Default())
+	if err != nil {
+		fmt.Println("failed to create login service    err =", err)
+		return err
+	}
+
+	resp, err := loginService.UpdateTill(ctx, &pb.UpdateTillRequest{Username: username, TillNum: tillNum})
+	if err != nil {
+		return err
+	}
+	fmt.Println("response =", resp)
+
+	return nil
+}
+
+// kafkaPublisher produces messages to the kafka broker
+type kafkaPublisher struct{}
+
+func (kafkaPublisher) Publish(ctx context.Context, topic, key string, payload []byte) error {
+	fmt.Printf("\t kafka broker    addr = 'tcp://	%v'\n", os.Getenv("KAFKA_BROKER"))
+
+	kf := broker.Kafka{
+		Broker:  os.Getenv("KAFKA_BROKER"),
+		Topic:   topic,
+		Key:     key,
+		Payload: payload,
+	}
+	return kf.Produce(ctx)
+}
@@ -0,0 +1,457 @@
+package sales
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/jackc/pgx/v5"
+)
+
+// SplitLine moves a bill line to a new receipt, only Quantity of it when given
+type SplitLine struct {
+	ReceiptItem string  `json:"receipt_item"`
+	Quantity    float64 `json:"quantity"`
+}
+
+// Split chooses how a bill is split
+// Lines move to one new receipt, each of Seats gets its own receipt and Shares divides the bill into equal parts
+type Split struct {
+	Lines  []SplitLine `json:"lines"`
+	Seats  []int       `json:"seats"`
+	Shares int         `json:"shares"`
+}
+
+// Validate checks that exactly one way of splitting is chosen
+func (sp Split) Validate() error {
+	ways := 0
+	for _, set := range []bool{len(sp.Lines) > 0, len(sp.Seats) > 0, sp.Shares != 0} {
+		if set {
+			ways++
+		}
+	}
+	if ways != 1 {
+		return apperr.New(apperr.ValidationFailed, "split by one of lines, seats or shares")
+	}
+
+	if sp.Shares != 0 && sp.Shares < 2 {
+		return apperr.New(apperr.ValidationFailed, "shares must be at least 2")
+	}
+	for _, l := range sp.Lines {
+		if l.ReceiptItem == "" {
+			return apperr.New(apperr.ValidationFailed, "receipt_item is required")
+		}
+		if l.Quantity < 0 {
+			return apperr.New(apperr.ValidationFailed, "quantity can't be negative")
+		}
+	}
+	return nil
+}
+
+// Division is a planned split of a bill's pending lines
+// kept holds what stays of each divided line, nil when all of it moves
+type Division struct {
+	Parts int
+	kept  map[string]*Sales
+	moved map[string][]movedLine
+}
+
+type movedLine struct {
+	part int
+	line Sales
+}
+
+// Divide plans how the bill's pending lines are shared between it and Parts new receipts
+// returns an error if a line isn't on the bill or nothing would be left on it
+func (sp Split) Divide(lines []Sales) (*Division, error) {
+	if err := sp.Validate(); err != nil {
+		return nil, err
+	}
+
+	pending := map[string]Sales{}
+	var order []string
+	for _, l := range lines {
+		if l.State == "pending" {
+			pending[l.ReceiptItem] = l
+			order = append(order, l.ReceiptItem)
+		}
+	}
+	if len(order) == 0 {
+		return nil, ErrEmptyReceipt
+	}
+
+	// parts[i] lists the quantities of each line moving to the i'th new receipt
+	var parts [][]SplitLine
+	switch {
+	case len(sp.Lines) > 0:
+		var part []SplitLine
+		for _, l := range sp.Lines {
+			line, ok := pending[l.ReceiptItem]
+			if !ok {
+				return nil, apperr.New(apperr.ValidationFailed, fmt.Sprintf("item %v is not on the bill", l.ReceiptItem))
+			}
+			if l.Quantity == 0 {
+				l.Quantity = line.Quantity
+			}
+			part = append(part, l)
+		}
+		parts = append(parts, part)
+
+	case len(sp.Seats) > 0:
+		var seats []int
+		for _, seat := range sp.Seats {
+			if !slices.Contains(seats, seat) {
+				seats = append(seats, seat)
+			}
+		}
+		for _, seat := range seats {
+			var part []SplitLine
+			for _, item := range order {
+				if line := pending[item]; line.Seat == seat {
+					part = append(part, SplitLine{ReceiptItem: item, Quantity: line.Quantity})
+				}
+			}
+			if len(part) == 0 {
+				return nil, apperr.New(apperr.ValidationFailed, fmt.Sprintf("no items on seat %d", seat))
+			}
+			parts = append(parts, part)
+		}
+
+	default:
+		// the bill keeps the last share with any remainder
+		for i := 1; i < sp.Shares; i++ {
+			var part []SplitLine
+			for _, item := range order {
+				part = append(part, SplitLine{ReceiptItem: item, Quantity: pending[item].Quantity / float64(sp.Shares)})
+			}
+			parts = append(parts, part)
+		}
+	}
+
+	d := &Division{Parts: len(parts), kept: map[string]*Sales{}, moved: map[string][]movedLine{}}
+	stamp := time.Now().UnixNano()
+	for p, part := range parts {
+		for _, l := range part {
+			rest, divided := d.kept[l.ReceiptItem]
+			if !divided {
+				line := pending[l.ReceiptItem]
+				rest = &line
+			}
+			if rest == nil || l.Quantity > rest.Quantity+1e-9 {
+				return nil, apperr.New(apperr.ValidationFailed, fmt.Sprintf("item %v has less than %v left to move", l.ReceiptItem, l.Quantity))
+			}
+
+			var moved Sales
+			if l.Quantity >= rest.Quantity-1e-9 {
+				moved, rest = *rest, nil
+			} else {
+				stamp++
+				moved = rest.divide(l.Quantity, fmt.Sprintf("%d", stamp))
+			}
+			d.kept[l.ReceiptItem] = rest
+			d.moved[l.ReceiptItem] = append(d.moved[l.ReceiptItem], movedLine{part: p, line: moved})
+		}
+	}
+
+	for _, item := range order {
+		if rest, divided := d.kept[item]; !divided || rest != nil {
+			return d, nil
+		}
+	}
+	return nil, apperr.New(apperr.ValidationFailed, "a split must leave items on the bill")
+}
+
+// divide takes qty off the line as a new line numbered receiptItem
+// amounts are shared by quantity and the line keeps what's left so the two add up to the original
+func (arg *Sales) divide(qty float64, receiptItem string) Sales {
+	moved := *arg
+	moved.ReceiptItem = receiptItem
+	moved.Quantity = qty
+
+	share := func(a money.Amount) money.Amount { return a.Mul(qty / arg.Quantity) }
+	moved.Discount = share(arg.Discount)
+	moved.ManualDiscount = share(arg.ManualDiscount)
+	moved.ReceiptDiscount = share(arg.ReceiptDiscount)
+	moved.Vat = share(arg.Vat)
+	moved.Total = arg.Price.Mul(qty) - moved.Discount
+
+	gross := arg.Price.Mul(arg.Quantity) - moved.Price.Mul(qty)
+	arg.Quantity -= qty
+	// keep the price times quantity of what's left equal to the rest of the line
+	if arg.Price != 0 && arg.Price.Mul(arg.Quantity) != gross {
+		arg.Quantity = float64(gross) / float64(arg.Price)
+	}
+	arg.Discount -= moved.Discount
+	arg.ManualDiscount -= moved.ManualDiscount
+	arg.ReceiptDiscount -= moved.ReceiptDiscount
+	arg.Vat -= moved.Vat
+	arg.Total -= moved.Total
+	return moved
+}
+
+// Apply divides items, a cart or an order's items
+// returns the items staying on the bill and those moving to each new receipt
+func (d *Division) Apply(items []Sales) ([]Sales, [][]Sales) {
+	var stay []Sales
+	parts := make([][]Sales, d.Parts)
+	for _, item := range items {
+		rest, divided := d.kept[item.ReceiptItem]
+		if item.State != "pending" || !divided {
+			stay = append(stay, item)
+			continue
+		}
+
+		if rest != nil {
+			stay = append(stay, *rest)
+		}
+		for _, m := range d.moved[item.ReceiptItem] {
+			parts[m.part] = append(parts[m.part], m.line)
+		}
+	}
+	return stay, parts
+}
+
+// Split moves lines of the open bill to new receipts from the same till
+// orders follow their items, an order split between receipts is copied with the next number in its series
+// returns the new receipts, arg keeps the rest of the bill
+func (arg *ReceiptLog) Split(ctx context.Context, db DBPool, split Split) ([]ReceiptLog, error) {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	sql := `SELECT state, coalesce(cart, '[]'::jsonb), pay_details = '{}'::jsonb
+				, till_num, pay_till, coalesce(branch, ''), coalesce(poster, ''), coalesce(company_id, 0), sale_type
+			FROM salestrace
+			WHERE receipt_num = $1 AND state IN ('pending', 'suspend', 'pending payment')
+			FOR UPDATE`
+
+	var cart []Sales
+	unpaid := false
+	err = tx.QueryRow(ctx, sql, arg.ReceiptNum).Scan(&arg.State, &cart, &unpaid,
+		&arg.TillNum, &arg.PayTill, &arg.Branch, &arg.Poster, &arg.CompanyID, &arg.SaleType)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open", arg.ReceiptNum))
+		}
+		log.Println("sql error. ReceiptLog->Split()    err =", err)
+		return nil, err
+	}
+	if !unpaid {
+		return nil, apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v has payments and can't be split", arg.ReceiptNum))
+	}
+
+	orders, err := arg.billOrders(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	// closed bills and cash sales are split from the cart, open bills from their orders
+	lines := pendingLines(cart)
+	onCart := len(lines) > 0
+	if !onCart {
+		for _, ord := range orders {
+			lines = append(lines, pendingLines(ord.OrderItems)...)
+		}
+	}
+
+	d, err := split.Divide(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	receipts := make([]ReceiptLog, d.Parts)
+	for i := range receipts {
+		r := ReceiptLog{TillNum: arg.TillNum, PayTill: arg.PayTill, Branch: arg.Branch, Poster: arg.Poster,
+			CompanyID: arg.CompanyID, SaleType: arg.SaleType}
+		if _, err := r.CreateReceipt(ctx, tx); err != nil {
+			return nil, err
+		}
+		r.State = arg.State
+		receipts[i] = r
+	}
+
+	if onCart {
+		stay, parts := d.Apply(cart)
+		arg.Cart = stay
+		if err := arg.saveSplit(ctx, tx); err != nil {
+			return nil, err
+		}
+		for i := range receipts {
+			receipts[i].Cart = parts[i]
+			if err := receipts[i].saveSplit(ctx, tx); err != nil {
+				return nil, err
+			}
+		}
+	} else if arg.State != "pending" {
+		for _, r := range receipts {
+			if _, err := tx.Exec(ctx, `UPDATE salestrace SET state = $1 WHERE receipt_num = $2`, r.State, r.ReceiptNum); err != nil {
+				log.Println("sql error. ReceiptLog->Split()    err =", err)
+				return nil, err
+			}
+		}
+	}
+
+	for _, ord := range orders {
+		stay, parts := d.Apply(ord.OrderItems)
+		if err := ord.split(ctx, tx, arg.ReceiptNum, stay, parts, receipts); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	arg.Cart = pendingLines(arg.Cart)
+	arg.Total = cartTotal(arg.Cart)
+	for i := range receipts {
+		receipts[i].Cart = pendingLines(receipts[i].Cart)
+		receipts[i].Total = cartTotal(receipts[i].Cart)
+	}
+	return receipts, nil
+}
+
+// billOrders locks the orders on the bill
+// returns an error if an order is still being taken
+func (arg *ReceiptLog) billOrders(ctx context.Context, tx pgx.Tx) ([]Order, error) {
+	sql := `SELECT order_num, state, coalesce(order_items, '[]'::jsonb)
+			FROM salesorders
+			WHERE receipt_num = $1 AND state NOT IN ('voided', 'VOIDED', 'DELETED')
+			ORDER BY order_num
+			FOR UPDATE`
+
+	rows, err := tx.Query(ctx, sql, arg.ReceiptNum)
+	if err != nil {
+		log.Println("sql error. ReceiptLog->billOrders()    err =", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []Order
+	for rows.Next() {
+		var ord Order
+		if err := rows.Scan(&ord.OrderNum, &ord.State, &ord.OrderItems); err != nil {
+			return nil, err
+		}
+		if ord.State == "pending" {
+			return nil, ErrPendingOrders
+		}
+		orders = append(orders, ord)
+	}
+	return orders, rows.Err()
+}
+
+// saveSplit writes the receipt's cart and total after a split
+func (arg *ReceiptLog) saveSplit(ctx context.Context, tx pgx.Tx) error {
+	cart, err := json.Marshal(arg.Cart)
+	if err != nil {
+		return err
+	}
+
+	sql := `UPDATE salestrace SET cart = $1, total = $2, state = $3, last_updated = now() WHERE receipt_num = $4`
+	if _, err := tx.Exec(ctx, sql, string(cart), cartTotal(arg.Cart), arg.State, arg.ReceiptNum); err != nil {
+		log.Println("sql error. ReceiptLog->saveSplit()    err =", err)
+		return err
+	}
+	return nil
+}
+
+// split moves the order's items to the receipts they were split to
+// the order itself goes with its items when they all move to one receipt, otherwise the moved items are copied to new orders
+func (ord *Order) split(ctx context.Context, tx pgx.Tx, receiptNum int64, stay []Sales, parts [][]Sales, receipts []ReceiptLog) error {
+	var to []int
+	for i, part := range parts {
+		if len(part) > 0 {
+			to = append(to, i)
+		}
+	}
+	if len(to) == 0 {
+		return nil
+	}
+
+	// the order stays on the bill unless none of its items do
+	home := receiptNum
+	if len(pendingLines(stay)) == 0 {
+		home = receipts[to[0]].ReceiptNum
+		stay = append(stay, parts[to[0]]...)
+		to = to[1:]
+	}
+
+	items, err := json.Marshal(stay)
+	if err != nil {
+		return err
+	}
+	sql := `UPDATE salesorders SET order_items = $1, receipt_num = $2 WHERE order_num = $3`
+	if _, err := tx.Exec(ctx, sql, string(items), home, ord.OrderNum); err != nil {
+		log.Println("sql error. Order->split()    err =", err)
+		return err
+	}
+
+	for _, i := range to {
+		if err := ord.copyTo(ctx, tx, parts[i], receipts[i].ReceiptNum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyTo copies the order with items onto the receipt
+// order numbers end in 0 so the copies count up the last digit, nine to an order
+func (ord *Order) copyTo(ctx context.Context, tx pgx.Tx, items []Sales, receiptNum int64) error {
+	base := ord.OrderNum - ord.OrderNum%10
+
+	var last int64
+	sql := `SELECT max(order_num) FROM salesorders WHERE order_num BETWEEN $1 AND $2`
+	if err := tx.QueryRow(ctx, sql, base, base+9).Scan(&last); err != nil {
+		log.Println("sql error. Order->copyTo()    err =", err)
+		return err
+	}
+	if last >= base+9 {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("order %v can't be split any further", ord.OrderNum))
+	}
+
+	jItems, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	sql = `INSERT INTO salesorders(order_num, daily_count, trans_date, complete_time, order_items, poster, branch
+				, disp_by, disp_time, company_id, till_num, pay_till, receipt, receipt_num, ac_num, state, channel)
+			SELECT $1, daily_count, trans_date, complete_time, $2, poster, branch
+				, disp_by, disp_time, company_id, till_num, pay_till, receipt, $3, ac_num, state, channel
+			FROM salesorders WHERE order_num = $4`
+	if _, err := tx.Exec(ctx, sql, last+1, string(jItems), receiptNum, ord.OrderNum); err != nil {
+		log.Println("sql error. Order->copyTo()    err =", err)
+		return err
+	}
+	return nil
+}
+
+// pendingLines filters the pending lines of a cart
+func pendingLines(cart []Sales) []Sales {
+	var lines []Sales
+	for _, item := range cart {
+		if item.State == "pending" {
+			lines = append(lines, item)
+		}
+	}
+	return lines
+}
+
+// cartTotal sums the pending lines of a cart
+func cartTotal(cart []Sales) money.Amount {
+	total := money.Amount(0)
+	for _, item := range cart {
+		if item.State == "pending" {
+			total += item.Total
+		}
+	}
+	return total
+}
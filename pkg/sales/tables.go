@@ -0,0 +1,341 @@
+package sales
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/database"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/jackc/pgx/v5"
+)
+
+// table statuses on the floor
+const (
+	TableFree          = "free"
+	TableOrdering      = "ordering"
+	TableWaiting       = "waiting"
+	TableBillRequested = "bill_requested"
+)
+
+// Floor is a dining area of a branch
+type Floor struct {
+	table     string        `name:"floors" type:"table"`
+	ID        int64         `json:"id" name:"id" type:"field" sql:"BIGSERIAL PRIMARY KEY"`
+	Branch    string        `json:"branch" name:"branch" type:"field" sql:"VARCHAR NOT NULL"`
+	Name      string        `json:"name" name:"name" type:"field" sql:"VARCHAR NOT NULL"`
+	SortOrder int           `json:"sort_order" name:"sort_order" type:"field" sql:"INT NOT NULL DEFAULT '0'"`
+	Active    bool          `json:"active" name:"active" type:"field" sql:"BOOL NOT NULL DEFAULT 'true'"`
+	Tables    []DiningTable `json:"tables"`
+	unique    string        `name:"floors_branch_name_key" type:"constraint" sql:"UNIQUE (branch, name)"`
+}
+
+// DiningTable is a table on a floor plan
+// PosX and PosY place it on the plan, ReceiptNum is its open bill and Waiter serves it
+type DiningTable struct {
+	table      string    `name:"dining_tables" type:"table"`
+	ID         int64     `json:"id" name:"id" type:"field" sql:"BIGSERIAL PRIMARY KEY"`
+	FloorID    int64     `json:"floor_id" name:"floor_id" type:"field" sql:"BIGINT NOT NULL"`
+	Branch     string    `json:"branch" name:"branch" type:"field" sql:"VARCHAR NOT NULL"`
+	Name       string    `json:"name" name:"name" type:"field" sql:"VARCHAR NOT NULL"`
+	Seats      int       `json:"seats" name:"seats" type:"field" sql:"INT NOT NULL DEFAULT '0'"`
+	PosX       float64   `json:"pos_x" name:"pos_x" type:"field" sql:"FLOAT NOT NULL DEFAULT '0'"`
+	PosY       float64   `json:"pos_y" name:"pos_y" type:"field" sql:"FLOAT NOT NULL DEFAULT '0'"`
+	Waiter     string    `json:"waiter" name:"waiter" type:"field" sql:"VARCHAR NOT NULL DEFAULT ''"`
+	ReceiptNum int64     `json:"receipt_num" name:"receipt_num" type:"field" sql:"BIGINT NOT NULL DEFAULT '0'"`
+	Guests     int       `json:"guests" name:"guests" type:"field" sql:"INT NOT NULL DEFAULT '0'"`
+	SeatedAt   time.Time `json:"seated_at" name:"seated_at" type:"field" sql:"TIMESTAMPTZ NOT NULL DEFAULT now()"`
+	Active     bool      `json:"active" name:"active" type:"field" sql:"BOOL NOT NULL DEFAULT 'true'"`
+	unique     string    `name:"dining_tables_floor_name_key" type:"constraint" sql:"UNIQUE (floor_id, name)"`
+}
+
+// TableState is a table's status on the floor
+// BillState and KitchenSince come from its bill, Elapsed is the seconds since the status began
+type TableState struct {
+	DiningTable
+	Status       string     `json:"status"`
+	Since        time.Time  `json:"since"`
+	Elapsed      float64    `json:"elapsed"`
+	SeatedFor    float64    `json:"seated_for"`
+	BillState    string     `json:"bill_state"`
+	BillUpdated  time.Time  `json:"-"`
+	KitchenSince *time.Time `json:"-"`
+}
+
+func genFloorTbls() error {
+	if err := database.CreateFromStruct(Floor{}); err != nil {
+		return err
+	}
+	return database.CreateFromStruct(DiningTable{})
+}
+
+// openBill reports whether a bill in state is still being served
+func openBill(state string) bool {
+	switch state {
+	case "pending", "suspend", "paying", "pending payment":
+		return true
+	}
+	return false
+}
+
+// resolve works out the table's status at now
+// a table whose bill was posted or voided elsewhere is free
+func (t *TableState) resolve(now time.Time) {
+	t.Since = now
+	t.Status = TableFree
+	if t.ReceiptNum != 0 && openBill(t.BillState) {
+		t.SeatedFor = now.Sub(t.SeatedAt).Seconds()
+		switch {
+		case t.BillState == "paying" || t.BillState == "pending payment":
+			t.Status = TableBillRequested
+			t.Since = t.BillUpdated
+		case t.KitchenSince != nil:
+			t.Status = TableWaiting
+			t.Since = *t.KitchenSince
+		default:
+			t.Status = TableOrdering
+			t.Since = t.SeatedAt
+		}
+	}
+	t.Elapsed = max(now.Sub(t.Since).Seconds(), 0)
+}
+
+// Save creates the floor or updates it when it has an id
+func (arg *Floor) Save(ctx context.Context, db Querier) error {
+	if arg.ID == 0 {
+		sql := `INSERT INTO floors(branch, name, sort_order, active)
+				VALUES ($1, $2, $3, $4)
+				RETURNING id`
+
+		err := db.QueryRow(ctx, sql, arg.Branch, arg.Name, arg.SortOrder, arg.Active).Scan(&arg.ID)
+		if err != nil {
+			log.Println("sql error. Floor->Save()    err =", err)
+		}
+		return err
+	}
+
+	sql := `UPDATE floors SET name = $3, sort_order = $4, active = $5 WHERE id = $1 AND branch = $2`
+	tag, err := db.Exec(ctx, sql, arg.ID, arg.Branch, arg.Name, arg.SortOrder, arg.Active)
+	if err != nil {
+		log.Println("sql error. Floor->Save()    err =", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.New(apperr.NotFound, fmt.Sprintf("floor %v not found", arg.ID))
+	}
+	return nil
+}
+
+// Save creates the table or updates its layout when it has an id
+func (arg *DiningTable) Save(ctx context.Context, db Querier) error {
+	if arg.ID == 0 {
+		sql := `INSERT INTO dining_tables(floor_id, branch, name, seats, pos_x, pos_y, active)
+				SELECT id, branch, $2, $3, $4, $5, $6 FROM floors WHERE id = $1 AND branch = $7
+				RETURNING id`
+
+		err := db.QueryRow(ctx, sql, arg.FloorID, arg.Name, arg.Seats, arg.PosX, arg.PosY, arg.Active, arg.Branch).Scan(&arg.ID)
+		if err == pgx.ErrNoRows {
+			return apperr.New(apperr.NotFound, fmt.Sprintf("floor %v not found", arg.FloorID))
+		}
+		if err != nil {
+			log.Println("sql error. DiningTable->Save()    err =", err)
+		}
+		return err
+	}
+
+	sql := `UPDATE dining_tables
+			SET
+				floor_id = $3, name = $4, seats = $5, pos_x = $6, pos_y = $7, active = $8
+			WHERE id = $1 AND branch = $2`
+	tag, err := db.Exec(ctx, sql, arg.ID, arg.Branch, arg.FloorID, arg.Name, arg.Seats, arg.PosX, arg.PosY, arg.Active)
+	if err != nil {
+		log.Println("sql error. DiningTable->Save()    err =", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.New(apperr.NotFound, fmt.Sprintf("table %v not found", arg.ID))
+	}
+	return nil
+}
+
+const tableColumns = `t.id, t.floor_id, t.branch, t.name, t.seats, t.pos_x, t.pos_y, t.waiter, t.receipt_num, t.guests, t.seated_at, t.active`
+
+func (arg *DiningTable) scanFields() []any {
+	return []any{&arg.ID, &arg.FloorID, &arg.Branch, &arg.Name, &arg.Seats, &arg.PosX, &arg.PosY,
+		&arg.Waiter, &arg.ReceiptNum, &arg.Guests, &arg.SeatedAt, &arg.Active}
+}
+
+// Fetch gets the table by its id
+func (arg *DiningTable) Fetch(ctx context.Context, db Querier) error {
+	sql := `SELECT ` + tableColumns + ` FROM dining_tables t WHERE t.id = $1`
+
+	err := db.QueryRow(ctx, sql, arg.ID).Scan(arg.scanFields()...)
+	if err == pgx.ErrNoRows {
+		return apperr.New(apperr.NotFound, fmt.Sprintf("table %v not found", arg.ID))
+	}
+	if err != nil {
+		log.Println("sql error. DiningTable->Fetch()    err =", err)
+	}
+	return err
+}
+
+// FetchFloors gets the branch's active floors and their tables
+func FetchFloors(ctx context.Context, db Querier, branch string) ([]Floor, error) {
+	sql := `SELECT id, branch, name, sort_order, active FROM floors WHERE branch = $1 AND active ORDER BY sort_order, name`
+
+	rows, err := db.Query(ctx, sql, branch)
+	if err != nil {
+		log.Println("sql error. FetchFloors()    err =", err)
+		return nil, err
+	}
+	floors := []Floor{}
+	index := map[int64]int{}
+	for rows.Next() {
+		var f Floor
+		if err := rows.Scan(&f.ID, &f.Branch, &f.Name, &f.SortOrder, &f.Active); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		f.Tables = []DiningTable{}
+		index[f.ID] = len(floors)
+		floors = append(floors, f)
+	}
+	rows.Close()
+
+	sql = `SELECT ` + tableColumns + ` FROM dining_tables t WHERE t.branch = $1 AND t.active ORDER BY t.name`
+	rows, err = db.Query(ctx, sql, branch)
+	if err != nil {
+		log.Println("sql error. FetchFloors()    err =", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t DiningTable
+		if err := rows.Scan(t.scanFields()...); err != nil {
+			return nil, err
+		}
+		if i, ok := index[t.FloorID]; ok {
+			floors[i].Tables = append(floors[i].Tables, t)
+		}
+	}
+	return floors, nil
+}
+
+// Seat attaches the bill to the table unless it's serving another open bill
+func (arg *DiningTable) Seat(ctx context.Context, db Querier) error {
+	sql := `UPDATE dining_tables t
+			SET
+				receipt_num = $2, waiter = $3, guests = $4, seated_at = now()
+			WHERE t.id = $1 AND t.active
+				AND NOT EXISTS (
+					SELECT 1 FROM salestrace s
+					WHERE s.receipt_num = t.receipt_num AND t.receipt_num <> 0
+						AND s.state IN ('pending', 'suspend', 'paying', 'pending payment')
+				)
+			RETURNING seated_at`
+
+	err := db.QueryRow(ctx, sql, arg.ID, arg.ReceiptNum, arg.Waiter, arg.Guests).Scan(&arg.SeatedAt)
+	if err == pgx.ErrNoRows {
+		return apperr.New(apperr.TableOccupied, fmt.Sprintf("table %v is occupied", arg.Name))
+	}
+	if err != nil {
+		log.Println("sql error. DiningTable->Seat()    err =", err)
+	}
+	return err
+}
+
+// Transfer hands the table, its bill and the bill's open orders to waiter
+func (arg *DiningTable) Transfer(ctx context.Context, db DBPool, waiter string) error {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	sql := `UPDATE dining_tables SET waiter = $2 WHERE id = $1 AND receipt_num = $3 AND receipt_num <> 0`
+	tag, err := tx.Exec(ctx, sql, arg.ID, waiter, arg.ReceiptNum)
+	if err != nil {
+		log.Println("sql error. DiningTable->Transfer()    err =", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("table %v has no open bill", arg.Name))
+	}
+
+	sql = `UPDATE salestrace SET poster = $2, last_updated = now() WHERE receipt_num = $1`
+	if _, err := tx.Exec(ctx, sql, arg.ReceiptNum, waiter); err != nil {
+		log.Println("sql error. DiningTable->Transfer()    err =", err)
+		return err
+	}
+
+	sql = `UPDATE salesorders SET poster = $2
+			WHERE receipt_num = $1 AND state NOT IN ('paying', 'voided', 'VOIDED', 'deleted', 'DELETED')`
+	if _, err := tx.Exec(ctx, sql, arg.ReceiptNum, waiter); err != nil {
+		log.Println("sql error. DiningTable->Transfer()    err =", err)
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	arg.Waiter = waiter
+	return nil
+}
+
+// ReleaseTables frees the tables serving the bills
+// returns the tables freed
+func ReleaseTables(ctx context.Context, db Querier, receipts []int64) ([]DiningTable, error) {
+	sql := `UPDATE dining_tables t SET receipt_num = 0, waiter = '', guests = 0
+			WHERE t.receipt_num = ANY($1)
+			RETURNING ` + tableColumns
+
+	rows, err := db.Query(ctx, sql, receipts)
+	if err != nil {
+		log.Println("sql error. ReleaseTables()    err =", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var freed []DiningTable
+	for rows.Next() {
+		var t DiningTable
+		if err := rows.Scan(t.scanFields()...); err != nil {
+			return nil, err
+		}
+		freed = append(freed, t)
+	}
+	return freed, rows.Err()
+}
+
+// FetchFloorState gets the branch's tables with their bills' state, on one floor when floorID is set
+func FetchFloorState(ctx context.Context, db Querier, branch string, floorID int64) ([]TableState, error) {
+	sql := `SELECT ` + tableColumns + `
+				, coalesce(s.state, ''), coalesce(s.last_updated, t.seated_at)
+				, min(o.complete_time) FILTER (WHERE o.state = 'ordered')
+			FROM dining_tables t
+				LEFT JOIN salestrace s ON s.receipt_num = t.receipt_num AND t.receipt_num <> 0
+				LEFT JOIN salesorders o ON o.receipt_num = t.receipt_num AND t.receipt_num <> 0
+			WHERE t.branch = $1 AND ($2 = 0 OR t.floor_id = $2) AND t.active
+			GROUP BY t.id, s.state, s.last_updated
+			ORDER BY t.floor_id, t.name`
+
+	rows, err := db.Query(ctx, sql, branch, floorID)
+	if err != nil {
+		log.Println("sql error. FetchFloorState()    err =", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := []TableState{}
+	for rows.Next() {
+		var t TableState
+		fields := append(t.scanFields(), &t.BillState, &t.BillUpdated, &t.KitchenSince)
+		if err := rows.Scan(fields...); err != nil {
+			return nil, err
+		}
+		states = append(states, t)
+	}
+	return states, nil
+}
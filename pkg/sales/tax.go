@@ -0,0 +1,218 @@
+package sales
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
+)
+
+// vat rounding modes
+const (
+	// RoundPerLine rounds each line's vat
+	RoundPerLine = "line"
+	// RoundPerReceipt rounds each band's vat on the receipt and shares it across the band's lines
+	RoundPerReceipt = "receipt"
+)
+
+// TaxTable resolves each line's vat code against the configured codes
+// Exempt lists the codes of exempt supplies, reported apart from zero rated ones
+type TaxTable struct {
+	Rates     map[string]float64
+	Exempt    []string
+	Exclusive bool
+	Rounding  string
+}
+
+// NewTaxTable builds the tax table from the system settings
+func NewTaxTable(settings variables.SysSettings) TaxTable {
+	t := TaxTable{
+		Rates:     map[string]float64{},
+		Exempt:    settings.PosDefaults.VatExemptCodes,
+		Exclusive: settings.PosDefaults.VatExclusive,
+		Rounding:  settings.PosDefaults.VatRounding,
+	}
+	for code, rate := range settings.VatCodes {
+		t.Rates[code] = float64(rate)
+	}
+	return t
+}
+
+// FetchTaxTable loads the vat codes and pricing from the settings
+func FetchTaxTable() (TaxTable, error) {
+	settings, err := variables.SysDefaults()
+	if err != nil {
+		return TaxTable{}, err
+	}
+	return NewTaxTable(settings), nil
+}
+
+// Apply sets the vat of the cart's pending lines from their bands
+// Total is what the customer pays, exclusive prices have the vat added
+// exempt customers pay the price before vat
+func (t TaxTable) Apply(cart []Sales, exemptCustomer bool) []Sales {
+	// each line's vat in fractions of a minor unit
+	exact := make([]float64, len(cart))
+	nets := make([]money.Amount, len(cart))
+	for i := range cart {
+		if cart[i].State != "pending" {
+			continue
+		}
+
+		rate, ok := t.Rates[cart[i].VatAlpha]
+		if !ok {
+			// keep the rate the inventory gave the line
+			log.Printf("vat code %q of item %v is not configured", cart[i].VatAlpha, cart[i].ItemCode)
+			rate = cart[i].VatPercent
+			if rate == 0 && cart[i].Vat > 0 && cart[i].Total > cart[i].Vat {
+				// carts saved before vat_percent was kept on the line
+				rate = round2(float64(cart[i].Vat) * 100 / float64(cart[i].Total-cart[i].Vat))
+			}
+		}
+		exempt := slices.Contains(t.Exempt, cart[i].VatAlpha)
+		if exempt {
+			rate = 0
+		}
+		cart[i].VatPercent = rate
+		cart[i].VatExempt = exempt || exemptCustomer
+
+		// the line's price after discounts
+		amount := cart[i].Price.Mul(cart[i].Quantity) - cart[i].Discount
+
+		net := float64(amount)
+		if !t.Exclusive {
+			net = net * 100 / (100 + rate)
+		}
+		nets[i] = money.Amount(math.Round(net))
+		exact[i] = net * rate / 100
+		if cart[i].VatExempt {
+			exact[i] = 0
+		}
+		cart[i].Vat = money.Amount(math.Round(exact[i]))
+	}
+
+	if t.Rounding == RoundPerReceipt {
+		shareVat(cart, exact)
+	}
+
+	for i := range cart {
+		if cart[i].State != "pending" {
+			continue
+		}
+		switch {
+		case t.Exclusive || exemptCustomer:
+			cart[i].Total = nets[i] + cart[i].Vat
+		default:
+			cart[i].Total = cart[i].Price.Mul(cart[i].Quantity) - cart[i].Discount
+		}
+	}
+	return cart
+}
+
+// shareVat rounds each band's exact vat once and shares it across the band's lines
+// lines with the largest fractions take the leftover minor units
+func shareVat(cart []Sales, exact []float64) {
+	bands := map[string][]int{}
+	for i, item := range cart {
+		if item.State == "pending" {
+			key := bandKey(item)
+			bands[key] = append(bands[key], i)
+		}
+	}
+
+	for _, lines := range bands {
+		sum := float64(0)
+		floors := money.Amount(0)
+		for _, i := range lines {
+			sum += exact[i]
+			cart[i].Vat = money.Amount(math.Floor(exact[i]))
+			floors += cart[i].Vat
+		}
+
+		sort.SliceStable(lines, func(a, b int) bool {
+			return exact[lines[a]]-math.Floor(exact[lines[a]]) > exact[lines[b]]-math.Floor(exact[lines[b]])
+		})
+		left := money.Amount(math.Round(sum)) - floors
+		for k := 0; left > 0 && k < len(lines); k++ {
+			cart[lines[k]].Vat++
+			left--
+		}
+	}
+}
+
+// bandKey groups lines by vat code, keeping exempt lines apart
+func bandKey(item Sales) string {
+	if item.VatExempt {
+		return item.VatAlpha + "/exempt"
+	}
+	return item.VatAlpha
+}
+
+// TaxBand is the vat charged in one band of a receipt
+type TaxBand struct {
+	Code   string       `json:"code"`
+	Rate   float64      `json:"rate"`
+	Exempt bool         `json:"exempt"`
+	Net    money.Amount `json:"net"`
+	Vat    money.Amount `json:"vat"`
+	Gross  money.Amount `json:"gross"`
+}
+
+// SummarizeTax totals the vat of the cart's pending lines by band
+func SummarizeTax(cart []Sales) []TaxBand {
+	bands := map[string]*TaxBand{}
+	var keys []string
+	for _, item := range cart {
+		if item.State != "pending" {
+			continue
+		}
+
+		key := bandKey(item)
+		b, ok := bands[key]
+		if !ok {
+			b = &TaxBand{Code: item.VatAlpha, Rate: item.VatPercent, Exempt: item.VatExempt}
+			if item.VatExempt {
+				b.Rate = 0
+			}
+			bands[key] = b
+			keys = append(keys, key)
+		}
+		b.Vat += item.Vat
+		b.Gross += item.Total
+	}
+
+	sort.Strings(keys)
+	summary := make([]TaxBand, 0, len(keys))
+	for _, key := range keys {
+		b := bands[key]
+		b.Net = b.Gross - b.Vat
+		summary = append(summary, *b)
+	}
+	return summary
+}
+
+// SetVatExempt marks the open receipt's customer as exempt from vat with their exemption certificate
+func (arg *ReceiptLog) SetVatExempt(ctx context.Context, db Querier) error {
+	sql := `UPDATE salestrace
+			SET
+				vat_exempt = $2
+				, vat_exempt_ref = $3
+				, last_updated = now()
+			WHERE receipt_num = $1 AND state IN ('pending', 'paying', 'pending payment')`
+
+	tag, err := db.Exec(ctx, sql, arg.ReceiptNum, arg.VatExempt, arg.VatExemptRef)
+	if err != nil {
+		log.Println("sql error. ReceiptLog->SetVatExempt()    err =", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open", arg.ReceiptNum))
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package variables
 
 import (
 	"github.com/JohnnyKahiu/speedsales/poserver/database"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/barcode"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
 	"github.com/go-redis/redis"
 )
 
@@ -36,6 +38,23 @@ type PosSettings struct {
 	AuthValidity       int     `json:"auth_validity"`
 	MpesaExpiry        int     `json:"mpesa_expiry"`
 	ManualAddMpesa     bool    `json:"manual_add_mpesa"`
+	// DiscountLimits holds each role's maximum discount percent without approval
+	DiscountLimits map[string]float64 `json:"discount_limits"`
+	// VatExclusive prices are before vat, VatRounding is "line" or "receipt"
+	VatExclusive   bool     `json:"vat_exclusive"`
+	VatRounding    string   `json:"vat_rounding"`
+	VatExemptCodes []string `json:"vat_exempt_codes"`
+	// CashRounding is the step cash totals are rounded to, 1 for the nearest shilling
+	// RoundingVat is the vat code of the rounding line, the first exempt code when unset
+	CashRounding money.Amount `json:"cash_rounding"`
+	RoundingVat  string       `json:"rounding_vat"`
+	// BarcodeRules parse scale labels and case codes, the usual layouts apply when unset and [] turns them off
+	BarcodeRules []barcode.Rule `json:"barcode_rules"`
+	// RestrictedFrom and RestrictedTo ("22:00") are the hours age restricted items can't be sold, they can run past midnight
+	RestrictedFrom string `json:"restricted_from"`
+	RestrictedTo   string `json:"restricted_to"`
+	// DeliveryVat is the vat code of delivery fee lines, the standard rate A when unset
+	DeliveryVat string `json:"delivery_vat"`
 }
 
 // DocHead holds company's information for printed documents
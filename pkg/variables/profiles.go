@@ -0,0 +1,113 @@
+package variables
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/database"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/jackc/pgx/v5"
+)
+
+// industry modes
+const (
+	General     = "general"
+	Supermarket = "supermarket"
+	Restaurant  = "restaurant"
+	Pharmacy    = "pharmacy"
+)
+
+// features a profile switches on
+const (
+	// FeatureOrders is the order and kitchen flow
+	FeatureOrders = "orders"
+	FeatureTables = "tables"
+	// FeatureWeighedItems reads weight and price scale labels
+	FeatureWeighedItems = "weighed_items"
+	// FeatureBatches captures batch numbers and expiry dates
+	FeatureBatches       = "batches"
+	FeaturePrescriptions = "prescriptions"
+)
+
+// Profile is an industry mode's features and its default receipt layout
+type Profile struct {
+	Mode          string          `json:"mode"`
+	Features      map[string]bool `json:"features"`
+	ReceiptLayout string          `json:"receipt_layout"`
+}
+
+// Has reports whether the profile switches feature on
+func (p Profile) Has(feature string) bool {
+	return p.Features[feature]
+}
+
+// Profiles lists the industry modes a branch can run in
+// general keeps everything but batch and prescription capture on for servers without a mode
+var Profiles = map[string]Profile{
+	General: {Mode: General, ReceiptLayout: "retail", Features: map[string]bool{
+		FeatureOrders: true, FeatureTables: true, FeatureWeighedItems: true,
+	}},
+	Supermarket: {Mode: Supermarket, ReceiptLayout: "retail", Features: map[string]bool{
+		FeatureWeighedItems: true,
+	}},
+	Restaurant: {Mode: Restaurant, ReceiptLayout: "restaurant", Features: map[string]bool{
+		FeatureOrders: true, FeatureTables: true,
+	}},
+	Pharmacy: {Mode: Pharmacy, ReceiptLayout: "pharmacy", Features: map[string]bool{
+		FeatureBatches: true, FeaturePrescriptions: true,
+	}},
+}
+
+// IndustryMode is the server's mode, used by branches without their own
+var IndustryMode = General
+
+// SetIndustryMode sets the server's mode from the config file
+// returns an error if the mode isn't a profile
+func SetIndustryMode(mode string) error {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode == "" {
+		return nil
+	}
+	if _, ok := Profiles[mode]; !ok {
+		return fmt.Errorf("unknown industry mode %q", mode)
+	}
+	IndustryMode = mode
+	return nil
+}
+
+// BranchProfile fetches the profile the branch runs in
+func BranchProfile(ctx context.Context, branch string) (Profile, error) {
+	sql := `SELECT industry_mode FROM branches WHERE branch_name = $1`
+
+	mode := ""
+	if err := database.PgPool.QueryRow(ctx, sql, branch).Scan(&mode); err != nil && err != pgx.ErrNoRows {
+		log.Println("sql error. BranchProfile()    err =", err)
+		return Profiles[IndustryMode], err
+	}
+
+	if p, ok := Profiles[mode]; ok {
+		return p, nil
+	}
+	return Profiles[IndustryMode], nil
+}
+
+// SetBranchMode selects the branch's profile, an empty mode follows the server's
+func SetBranchMode(ctx context.Context, branch, mode string) error {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if _, ok := Profiles[mode]; !ok && mode != "" {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("unknown industry mode %q", mode))
+	}
+
+	sql := `UPDATE branches SET industry_mode = $1, branch_updated_at = now() WHERE branch_name = $2`
+	tag, err := database.PgPool.Exec(ctx, sql, mode, branch)
+	if err != nil {
+		log.Println("sql error. SetBranchMode()    err =", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.New(apperr.NotFound, fmt.Sprintf("branch %v not found", branch))
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"flag"
@@ -12,10 +13,16 @@ import (
 	"os"
 
 	"github.com/JohnnyKahiu/speedsales/poserver/api"
+	"github.com/JohnnyKahiu/speedsales/poserver/api/rpc"
 	"github.com/JohnnyKahiu/speedsales/poserver/database"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/authentication"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/grpc"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/idempotency"
 	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
 	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
 	"github.com/joho/godotenv"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 type dbConf struct {
@@ -89,6 +96,10 @@ func initTbls() {
 	if err := variables.GenBranchTable(); err != nil {
 		log.Println("error creating branch table    err =", err)
 	}
+
+	if err := idempotency.GenTable(); err != nil {
+		log.Println("error creating idempotency keys table    err =", err)
+	}
 }
 
 func main() {
@@ -135,6 +146,12 @@ func main() {
 	}
 
 	// get configuration files
+	var cfg ConfigFile
+	if err := cfg.readConfFile(); err == nil {
+		if err := variables.SetIndustryMode(cfg.IndustryMode); err != nil {
+			log.Println("ignoring config industry mode    err =", err)
+		}
+	}
 
 	address := getRunningIPAddress()
 	if os.Getenv("listen_on") != "card" {
@@ -143,7 +160,55 @@ func main() {
 
 	port := os.Getenv("PORT")
 
-	r := api.NewRouter()
+	// share one connection per grpc service
+	reg, err := grpc.NewRegistryFromEnv()
+	if err != nil {
+		log.Fatalln("\t failed to configure grpc services.    err =", err)
+	}
+	defer reg.Close()
+	grpc.SetDefault(reg)
+
+	// verify tokens locally against the auth service's signing keys
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	authentication.Start(ctx)
+
+	svc := sales.NewService(database.PgPool)
+
+	r, err := api.NewRouter(svc)
+	if err != nil {
+		log.Fatalln("\t refusing to serve routes.    err =", err)
+	}
+
+	// serve the pos operations to other services over grpc
+	if grpcPort := os.Getenv("GRPC_PORT"); grpcPort != "" {
+		var opts []ggrpc.ServerOption
+		if *isTLS {
+			creds, err := credentials.NewServerTLSFromFile(*certFile, *keyFile)
+			if err != nil {
+				log.Fatalln("\t failed to load grpc tls credentials.    err =", err)
+			}
+			opts = append(opts, ggrpc.Creds(creds))
+		}
+
+		srv, err := rpc.NewGRPCServer(svc, rpc.TokenAuthenticator, opts...)
+		if err != nil {
+			log.Fatalln("\t refusing to serve grpc.    err =", err)
+		}
+
+		lis, err := net.Listen("tcp", address+":"+grpcPort)
+		if err != nil {
+			log.Fatalln("\t failed to listen for grpc.    err =", err)
+		}
+		defer srv.GracefulStop()
+
+		fmt.Printf("\tgrpc://%v:%v\n", address, grpcPort)
+		go func() {
+			if err := srv.Serve(lis); err != nil {
+				log.Println("grpc server stopped    err =", err)
+			}
+		}()
+	}
 
 	if *isTLS {
 		fmt.Printf("\thttps://%v:%v\n", address, port)
@@ -0,0 +1,4 @@
+// Package authkeys holds the generated grpc code of the login service's key service
+package authkeys
+
+//go:generate protoc -I . --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative keys.proto
@@ -0,0 +1,343 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: keys.proto
+
+package authkeys
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SigningKeysRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SigningKeysRequest) Reset() {
+	*x = SigningKeysRequest{}
+	mi := &file_keys_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SigningKeysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SigningKeysRequest) ProtoMessage() {}
+
+func (x *SigningKeysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SigningKeysRequest.ProtoReflect.Descriptor instead.
+func (*SigningKeysRequest) Descriptor() ([]byte, []int) {
+	return file_keys_proto_rawDescGZIP(), []int{0}
+}
+
+// SigningKey is a token signing secret named by the kid in the token header
+type SigningKey struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Kid    string                 `protobuf:"bytes,1,opt,name=kid,proto3" json:"kid,omitempty"`
+	Secret []byte                 `protobuf:"bytes,2,opt,name=secret,proto3" json:"secret,omitempty"`
+	// expires_at is the unix time the key stops verifying tokens, zero when it doesn't expire
+	ExpiresAt     int64 `protobuf:"varint,3,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SigningKey) Reset() {
+	*x = SigningKey{}
+	mi := &file_keys_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SigningKey) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SigningKey) ProtoMessage() {}
+
+func (x *SigningKey) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SigningKey.ProtoReflect.Descriptor instead.
+func (*SigningKey) Descriptor() ([]byte, []int) {
+	return file_keys_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SigningKey) GetKid() string {
+	if x != nil {
+		return x.Kid
+	}
+	return ""
+}
+
+func (x *SigningKey) GetSecret() []byte {
+	if x != nil {
+		return x.Secret
+	}
+	return nil
+}
+
+func (x *SigningKey) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+type SigningKeysResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Keys          []*SigningKey          `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SigningKeysResponse) Reset() {
+	*x = SigningKeysResponse{}
+	mi := &file_keys_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SigningKeysResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SigningKeysResponse) ProtoMessage() {}
+
+func (x *SigningKeysResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SigningKeysResponse.ProtoReflect.Descriptor instead.
+func (*SigningKeysResponse) Descriptor() ([]byte, []int) {
+	return file_keys_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SigningKeysResponse) GetKeys() []*SigningKey {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+type RevokedSessionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Since         int64                  `protobuf:"varint,1,opt,name=since,proto3" json:"since,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RevokedSessionsRequest) Reset() {
+	*x = RevokedSessionsRequest{}
+	mi := &file_keys_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RevokedSessionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RevokedSessionsRequest) ProtoMessage() {}
+
+func (x *RevokedSessionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RevokedSessionsRequest.ProtoReflect.Descriptor instead.
+func (*RevokedSessionsRequest) Descriptor() ([]byte, []int) {
+	return file_keys_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RevokedSessionsRequest) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+// RevokedSessionsResponse lists the revoked session ids, current as of as_of
+type RevokedSessionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sessions      []string               `protobuf:"bytes,1,rep,name=sessions,proto3" json:"sessions,omitempty"`
+	AsOf          int64                  `protobuf:"varint,2,opt,name=as_of,json=asOf,proto3" json:"as_of,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RevokedSessionsResponse) Reset() {
+	*x = RevokedSessionsResponse{}
+	mi := &file_keys_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RevokedSessionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RevokedSessionsResponse) ProtoMessage() {}
+
+func (x *RevokedSessionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_keys_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RevokedSessionsResponse.ProtoReflect.Descriptor instead.
+func (*RevokedSessionsResponse) Descriptor() ([]byte, []int) {
+	return file_keys_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RevokedSessionsResponse) GetSessions() []string {
+	if x != nil {
+		return x.Sessions
+	}
+	return nil
+}
+
+func (x *RevokedSessionsResponse) GetAsOf() int64 {
+	if x != nil {
+		return x.AsOf
+	}
+	return 0
+}
+
+var File_keys_proto protoreflect.FileDescriptor
+
+const file_keys_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"keys.proto\x12\bauthkeys\"\x14\n" +
+	"\x12SigningKeysRequest\"U\n" +
+	"\n" +
+	"SigningKey\x12\x10\n" +
+	"\x03kid\x18\x01 \x01(\tR\x03kid\x12\x16\n" +
+	"\x06secret\x18\x02 \x01(\fR\x06secret\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x03 \x01(\x03R\texpiresAt\"?\n" +
+	"\x13SigningKeysResponse\x12(\n" +
+	"\x04keys\x18\x01 \x03(\v2\x14.authkeys.SigningKeyR\x04keys\".\n" +
+	"\x16RevokedSessionsRequest\x12\x14\n" +
+	"\x05since\x18\x01 \x01(\x03R\x05since\"J\n" +
+	"\x17RevokedSessionsResponse\x12\x1a\n" +
+	"\bsessions\x18\x01 \x03(\tR\bsessions\x12\x13\n" +
+	"\x05as_of\x18\x02 \x01(\x03R\x04asOf2\xb0\x01\n" +
+	"\n" +
+	"KeyService\x12J\n" +
+	"\vSigningKeys\x12\x1c.authkeys.SigningKeysRequest\x1a\x1d.authkeys.SigningKeysResponse\x12V\n" +
+	"\x0fRevokedSessions\x12 .authkeys.RevokedSessionsRequest\x1a!.authkeys.RevokedSessionsResponseB;Z9github.com/JohnnyKahiu/speedsales/poserver/proto/authkeysb\x06proto3"
+
+var (
+	file_keys_proto_rawDescOnce sync.Once
+	file_keys_proto_rawDescData []byte
+)
+
+func file_keys_proto_rawDescGZIP() []byte {
+	file_keys_proto_rawDescOnce.Do(func() {
+		file_keys_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_keys_proto_rawDesc), len(file_keys_proto_rawDesc)))
+	})
+	return file_keys_proto_rawDescData
+}
+
+var file_keys_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_keys_proto_goTypes = []any{
+	(*SigningKeysRequest)(nil),      // 0: authkeys.SigningKeysRequest
+	(*SigningKey)(nil),              // 1: authkeys.SigningKey
+	(*SigningKeysResponse)(nil),     // 2: authkeys.SigningKeysResponse
+	(*RevokedSessionsRequest)(nil),  // 3: authkeys.RevokedSessionsRequest
+	(*RevokedSessionsResponse)(nil), // 4: authkeys.RevokedSessionsResponse
+}
+var file_keys_proto_depIdxs = []int32{
+	1, // 0: authkeys.SigningKeysResponse.keys:type_name -> authkeys.SigningKey
+	0, // 1: authkeys.KeyService.SigningKeys:input_type -> authkeys.SigningKeysRequest
+	3, // 2: authkeys.KeyService.RevokedSessions:input_type -> authkeys.RevokedSessionsRequest
+	2, // 3: authkeys.KeyService.SigningKeys:output_type -> authkeys.SigningKeysResponse
+	4, // 4: authkeys.KeyService.RevokedSessions:output_type -> authkeys.RevokedSessionsResponse
+	3, // [3:5] is the sub-list for method output_type
+	1, // [1:3] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_keys_proto_init() }
+func file_keys_proto_init() {
+	if File_keys_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_keys_proto_rawDesc), len(file_keys_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_keys_proto_goTypes,
+		DependencyIndexes: file_keys_proto_depIdxs,
+		MessageInfos:      file_keys_proto_msgTypes,
+	}.Build()
+	File_keys_proto = out.File
+	file_keys_proto_goTypes = nil
+	file_keys_proto_depIdxs = nil
+}
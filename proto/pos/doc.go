@@ -0,0 +1,4 @@
+// Package pos holds the generated grpc code of the till's pos service
+package pos
+
+//go:generate protoc -I . --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pos.proto
@@ -0,0 +1,964 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: pos.proto
+
+package pos
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type OpenTillRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Approver      string                 `protobuf:"bytes,1,opt,name=approver,proto3" json:"approver,omitempty"`
+	ApToken       string                 `protobuf:"bytes,2,opt,name=ap_token,json=apToken,proto3" json:"ap_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OpenTillRequest) Reset() {
+	*x = OpenTillRequest{}
+	mi := &file_pos_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OpenTillRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpenTillRequest) ProtoMessage() {}
+
+func (x *OpenTillRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pos_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpenTillRequest.ProtoReflect.Descriptor instead.
+func (*OpenTillRequest) Descriptor() ([]byte, []int) {
+	return file_pos_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *OpenTillRequest) GetApprover() string {
+	if x != nil {
+		return x.Approver
+	}
+	return ""
+}
+
+func (x *OpenTillRequest) GetApToken() string {
+	if x != nil {
+		return x.ApToken
+	}
+	return ""
+}
+
+type OpenTillResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TillNum       int64                  `protobuf:"varint,1,opt,name=till_num,json=tillNum,proto3" json:"till_num,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OpenTillResponse) Reset() {
+	*x = OpenTillResponse{}
+	mi := &file_pos_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OpenTillResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpenTillResponse) ProtoMessage() {}
+
+func (x *OpenTillResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pos_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpenTillResponse.ProtoReflect.Descriptor instead.
+func (*OpenTillResponse) Descriptor() ([]byte, []int) {
+	return file_pos_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *OpenTillResponse) GetTillNum() int64 {
+	if x != nil {
+		return x.TillNum
+	}
+	return 0
+}
+
+type CreateReceiptRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateReceiptRequest) Reset() {
+	*x = CreateReceiptRequest{}
+	mi := &file_pos_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateReceiptRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateReceiptRequest) ProtoMessage() {}
+
+func (x *CreateReceiptRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pos_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateReceiptRequest.ProtoReflect.Descriptor instead.
+func (*CreateReceiptRequest) Descriptor() ([]byte, []int) {
+	return file_pos_proto_rawDescGZIP(), []int{2}
+}
+
+type Line struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ItemCode      string                 `protobuf:"bytes,1,opt,name=item_code,json=itemCode,proto3" json:"item_code,omitempty"`
+	ItemName      string                 `protobuf:"bytes,2,opt,name=item_name,json=itemName,proto3" json:"item_name,omitempty"`
+	Quantity      float64                `protobuf:"fixed64,3,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	Price         float64                `protobuf:"fixed64,4,opt,name=price,proto3" json:"price,omitempty"`
+	Total         float64                `protobuf:"fixed64,5,opt,name=total,proto3" json:"total,omitempty"`
+	ReceiptItem   string                 `protobuf:"bytes,6,opt,name=receipt_item,json=receiptItem,proto3" json:"receipt_item,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Line) Reset() {
+	*x = Line{}
+	mi := &file_pos_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Line) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Line) ProtoMessage() {}
+
+func (x *Line) ProtoReflect() protoreflect.Message {
+	mi := &file_pos_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Line.ProtoReflect.Descriptor instead.
+func (*Line) Descriptor() ([]byte, []int) {
+	return file_pos_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Line) GetItemCode() string {
+	if x != nil {
+		return x.ItemCode
+	}
+	return ""
+}
+
+func (x *Line) GetItemName() string {
+	if x != nil {
+		return x.ItemName
+	}
+	return ""
+}
+
+func (x *Line) GetQuantity() float64 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *Line) GetPrice() float64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *Line) GetTotal() float64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *Line) GetReceiptItem() string {
+	if x != nil {
+		return x.ReceiptItem
+	}
+	return ""
+}
+
+type Receipt struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ReceiptNum    int64                  `protobuf:"varint,1,opt,name=receipt_num,json=receiptNum,proto3" json:"receipt_num,omitempty"`
+	TillNum       int64                  `protobuf:"varint,2,opt,name=till_num,json=tillNum,proto3" json:"till_num,omitempty"`
+	Branch        string                 `protobuf:"bytes,3,opt,name=branch,proto3" json:"branch,omitempty"`
+	Poster        string                 `protobuf:"bytes,4,opt,name=poster,proto3" json:"poster,omitempty"`
+	State         string                 `protobuf:"bytes,5,opt,name=state,proto3" json:"state,omitempty"`
+	Total         float64                `protobuf:"fixed64,6,opt,name=total,proto3" json:"total,omitempty"`
+	Tendered      float64                `protobuf:"fixed64,7,opt,name=tendered,proto3" json:"tendered,omitempty"`
+	Change        float64                `protobuf:"fixed64,8,opt,name=change,proto3" json:"change,omitempty"`
+	Lines         []*Line                `protobuf:"bytes,9,rep,name=lines,proto3" json:"lines,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Receipt) Reset() {
+	*x = Receipt{}
+	mi := &file_pos_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Receipt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Receipt) ProtoMessage() {}
+
+func (x *Receipt) ProtoReflect() protoreflect.Message {
+	mi := &file_pos_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Receipt.ProtoReflect.Descriptor instead.
+func (*Receipt) Descriptor() ([]byte, []int) {
+	return file_pos_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Receipt) GetReceiptNum() int64 {
+	if x != nil {
+		return x.ReceiptNum
+	}
+	return 0
+}
+
+func (x *Receipt) GetTillNum() int64 {
+	if x != nil {
+		return x.TillNum
+	}
+	return 0
+}
+
+func (x *Receipt) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *Receipt) GetPoster() string {
+	if x != nil {
+		return x.Poster
+	}
+	return ""
+}
+
+func (x *Receipt) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Receipt) GetTotal() float64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *Receipt) GetTendered() float64 {
+	if x != nil {
+		return x.Tendered
+	}
+	return 0
+}
+
+func (x *Receipt) GetChange() float64 {
+	if x != nil {
+		return x.Change
+	}
+	return 0
+}
+
+func (x *Receipt) GetLines() []*Line {
+	if x != nil {
+		return x.Lines
+	}
+	return nil
+}
+
+type AddLineRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ReceiptNum    int64                  `protobuf:"varint,1,opt,name=receipt_num,json=receiptNum,proto3" json:"receipt_num,omitempty"`
+	ItemCode      string                 `protobuf:"bytes,2,opt,name=item_code,json=itemCode,proto3" json:"item_code,omitempty"`
+	Quantity      float64                `protobuf:"fixed64,3,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddLineRequest) Reset() {
+	*x = AddLineRequest{}
+	mi := &file_pos_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddLineRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddLineRequest) ProtoMessage() {}
+
+func (x *AddLineRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pos_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddLineRequest.ProtoReflect.Descriptor instead.
+func (*AddLineRequest) Descriptor() ([]byte, []int) {
+	return file_pos_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *AddLineRequest) GetReceiptNum() int64 {
+	if x != nil {
+		return x.ReceiptNum
+	}
+	return 0
+}
+
+func (x *AddLineRequest) GetItemCode() string {
+	if x != nil {
+		return x.ItemCode
+	}
+	return ""
+}
+
+func (x *AddLineRequest) GetQuantity() float64 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+type AddLineResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Line          *Line                  `protobuf:"bytes,1,opt,name=line,proto3" json:"line,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddLineResponse) Reset() {
+	*x = AddLineResponse{}
+	mi := &file_pos_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddLineResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddLineResponse) ProtoMessage() {}
+
+func (x *AddLineResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pos_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddLineResponse.ProtoReflect.Descriptor instead.
+func (*AddLineResponse) Descriptor() ([]byte, []int) {
+	return file_pos_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AddLineResponse) GetLine() *Line {
+	if x != nil {
+		return x.Line
+	}
+	return nil
+}
+
+type ApplyPaymentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ReceiptNum    int64                  `protobuf:"varint,1,opt,name=receipt_num,json=receiptNum,proto3" json:"receipt_num,omitempty"`
+	Paymode       string                 `protobuf:"bytes,2,opt,name=paymode,proto3" json:"paymode,omitempty"`
+	Amount        float64                `protobuf:"fixed64,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	Reference     string                 `protobuf:"bytes,4,opt,name=reference,proto3" json:"reference,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyPaymentRequest) Reset() {
+	*x = ApplyPaymentRequest{}
+	mi := &file_pos_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyPaymentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyPaymentRequest) ProtoMessage() {}
+
+func (x *ApplyPaymentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pos_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyPaymentRequest.ProtoReflect.Descriptor instead.
+func (*ApplyPaymentRequest) Descriptor() ([]byte, []int) {
+	return file_pos_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ApplyPaymentRequest) GetReceiptNum() int64 {
+	if x != nil {
+		return x.ReceiptNum
+	}
+	return 0
+}
+
+func (x *ApplyPaymentRequest) GetPaymode() string {
+	if x != nil {
+		return x.Paymode
+	}
+	return ""
+}
+
+func (x *ApplyPaymentRequest) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *ApplyPaymentRequest) GetReference() string {
+	if x != nil {
+		return x.Reference
+	}
+	return ""
+}
+
+type ApplyPaymentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ReceiptNum    int64                  `protobuf:"varint,1,opt,name=receipt_num,json=receiptNum,proto3" json:"receipt_num,omitempty"`
+	Balance       float64                `protobuf:"fixed64,2,opt,name=balance,proto3" json:"balance,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyPaymentResponse) Reset() {
+	*x = ApplyPaymentResponse{}
+	mi := &file_pos_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyPaymentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyPaymentResponse) ProtoMessage() {}
+
+func (x *ApplyPaymentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pos_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyPaymentResponse.ProtoReflect.Descriptor instead.
+func (*ApplyPaymentResponse) Descriptor() ([]byte, []int) {
+	return file_pos_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ApplyPaymentResponse) GetReceiptNum() int64 {
+	if x != nil {
+		return x.ReceiptNum
+	}
+	return 0
+}
+
+func (x *ApplyPaymentResponse) GetBalance() float64 {
+	if x != nil {
+		return x.Balance
+	}
+	return 0
+}
+
+type PostReceiptRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ReceiptNum    int64                  `protobuf:"varint,1,opt,name=receipt_num,json=receiptNum,proto3" json:"receipt_num,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PostReceiptRequest) Reset() {
+	*x = PostReceiptRequest{}
+	mi := &file_pos_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PostReceiptRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PostReceiptRequest) ProtoMessage() {}
+
+func (x *PostReceiptRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pos_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PostReceiptRequest.ProtoReflect.Descriptor instead.
+func (*PostReceiptRequest) Descriptor() ([]byte, []int) {
+	return file_pos_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *PostReceiptRequest) GetReceiptNum() int64 {
+	if x != nil {
+		return x.ReceiptNum
+	}
+	return 0
+}
+
+type FetchReceiptRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ReceiptNum    int64                  `protobuf:"varint,1,opt,name=receipt_num,json=receiptNum,proto3" json:"receipt_num,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FetchReceiptRequest) Reset() {
+	*x = FetchReceiptRequest{}
+	mi := &file_pos_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FetchReceiptRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FetchReceiptRequest) ProtoMessage() {}
+
+func (x *FetchReceiptRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pos_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FetchReceiptRequest.ProtoReflect.Descriptor instead.
+func (*FetchReceiptRequest) Descriptor() ([]byte, []int) {
+	return file_pos_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *FetchReceiptRequest) GetReceiptNum() int64 {
+	if x != nil {
+		return x.ReceiptNum
+	}
+	return 0
+}
+
+// StreamTillEventsRequest narrows the stream to a till or a receipt, the whole branch when both are zero
+type StreamTillEventsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TillNum       int64                  `protobuf:"varint,1,opt,name=till_num,json=tillNum,proto3" json:"till_num,omitempty"`
+	ReceiptNum    int64                  `protobuf:"varint,2,opt,name=receipt_num,json=receiptNum,proto3" json:"receipt_num,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamTillEventsRequest) Reset() {
+	*x = StreamTillEventsRequest{}
+	mi := &file_pos_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamTillEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamTillEventsRequest) ProtoMessage() {}
+
+func (x *StreamTillEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pos_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamTillEventsRequest.ProtoReflect.Descriptor instead.
+func (*StreamTillEventsRequest) Descriptor() ([]byte, []int) {
+	return file_pos_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *StreamTillEventsRequest) GetTillNum() int64 {
+	if x != nil {
+		return x.TillNum
+	}
+	return 0
+}
+
+func (x *StreamTillEventsRequest) GetReceiptNum() int64 {
+	if x != nil {
+		return x.ReceiptNum
+	}
+	return 0
+}
+
+// TillEvent is an update to a cart, bill or order, data holds the updated record as json
+type TillEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Branch        string                 `protobuf:"bytes,2,opt,name=branch,proto3" json:"branch,omitempty"`
+	TillNum       int64                  `protobuf:"varint,3,opt,name=till_num,json=tillNum,proto3" json:"till_num,omitempty"`
+	ReceiptNum    int64                  `protobuf:"varint,4,opt,name=receipt_num,json=receiptNum,proto3" json:"receipt_num,omitempty"`
+	OrderNum      int64                  `protobuf:"varint,5,opt,name=order_num,json=orderNum,proto3" json:"order_num,omitempty"`
+	State         string                 `protobuf:"bytes,6,opt,name=state,proto3" json:"state,omitempty"`
+	Data          string                 `protobuf:"bytes,7,opt,name=data,proto3" json:"data,omitempty"`
+	Time          int64                  `protobuf:"varint,8,opt,name=time,proto3" json:"time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TillEvent) Reset() {
+	*x = TillEvent{}
+	mi := &file_pos_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TillEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TillEvent) ProtoMessage() {}
+
+func (x *TillEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_pos_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TillEvent.ProtoReflect.Descriptor instead.
+func (*TillEvent) Descriptor() ([]byte, []int) {
+	return file_pos_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *TillEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *TillEvent) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *TillEvent) GetTillNum() int64 {
+	if x != nil {
+		return x.TillNum
+	}
+	return 0
+}
+
+func (x *TillEvent) GetReceiptNum() int64 {
+	if x != nil {
+		return x.ReceiptNum
+	}
+	return 0
+}
+
+func (x *TillEvent) GetOrderNum() int64 {
+	if x != nil {
+		return x.OrderNum
+	}
+	return 0
+}
+
+func (x *TillEvent) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *TillEvent) GetData() string {
+	if x != nil {
+		return x.Data
+	}
+	return ""
+}
+
+func (x *TillEvent) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+var File_pos_proto protoreflect.FileDescriptor
+
+const file_pos_proto_rawDesc = "" +
+	"\n" +
+	"\tpos.proto\x12\x03pos\"H\n" +
+	"\x0fOpenTillRequest\x12\x1a\n" +
+	"\bapprover\x18\x01 \x01(\tR\bapprover\x12\x19\n" +
+	"\bap_token\x18\x02 \x01(\tR\aapToken\"-\n" +
+	"\x10OpenTillResponse\x12\x19\n" +
+	"\btill_num\x18\x01 \x01(\x03R\atillNum\"\x16\n" +
+	"\x14CreateReceiptRequest\"\xab\x01\n" +
+	"\x04Line\x12\x1b\n" +
+	"\titem_code\x18\x01 \x01(\tR\bitemCode\x12\x1b\n" +
+	"\titem_name\x18\x02 \x01(\tR\bitemName\x12\x1a\n" +
+	"\bquantity\x18\x03 \x01(\x01R\bquantity\x12\x14\n" +
+	"\x05price\x18\x04 \x01(\x01R\x05price\x12\x14\n" +
+	"\x05total\x18\x05 \x01(\x01R\x05total\x12!\n" +
+	"\freceipt_item\x18\x06 \x01(\tR\vreceiptItem\"\xf6\x01\n" +
+	"\aReceipt\x12\x1f\n" +
+	"\vreceipt_num\x18\x01 \x01(\x03R\n" +
+	"receiptNum\x12\x19\n" +
+	"\btill_num\x18\x02 \x01(\x03R\atillNum\x12\x16\n" +
+	"\x06branch\x18\x03 \x01(\tR\x06branch\x12\x16\n" +
+	"\x06poster\x18\x04 \x01(\tR\x06poster\x12\x14\n" +
+	"\x05state\x18\x05 \x01(\tR\x05state\x12\x14\n" +
+	"\x05total\x18\x06 \x01(\x01R\x05total\x12\x1a\n" +
+	"\btendered\x18\a \x01(\x01R\btendered\x12\x16\n" +
+	"\x06change\x18\b \x01(\x01R\x06change\x12\x1f\n" +
+	"\x05lines\x18\t \x03(\v2\t.pos.LineR\x05lines\"j\n" +
+	"\x0eAddLineRequest\x12\x1f\n" +
+	"\vreceipt_num\x18\x01 \x01(\x03R\n" +
+	"receiptNum\x12\x1b\n" +
+	"\titem_code\x18\x02 \x01(\tR\bitemCode\x12\x1a\n" +
+	"\bquantity\x18\x03 \x01(\x01R\bquantity\"0\n" +
+	"\x0fAddLineResponse\x12\x1d\n" +
+	"\x04line\x18\x01 \x01(\v2\t.pos.LineR\x04line\"\x86\x01\n" +
+	"\x13ApplyPaymentRequest\x12\x1f\n" +
+	"\vreceipt_num\x18\x01 \x01(\x03R\n" +
+	"receiptNum\x12\x18\n" +
+	"\apaymode\x18\x02 \x01(\tR\apaymode\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x01R\x06amount\x12\x1c\n" +
+	"\treference\x18\x04 \x01(\tR\treference\"Q\n" +
+	"\x14ApplyPaymentResponse\x12\x1f\n" +
+	"\vreceipt_num\x18\x01 \x01(\x03R\n" +
+	"receiptNum\x12\x18\n" +
+	"\abalance\x18\x02 \x01(\x01R\abalance\"5\n" +
+	"\x12PostReceiptRequest\x12\x1f\n" +
+	"\vreceipt_num\x18\x01 \x01(\x03R\n" +
+	"receiptNum\"6\n" +
+	"\x13FetchReceiptRequest\x12\x1f\n" +
+	"\vreceipt_num\x18\x01 \x01(\x03R\n" +
+	"receiptNum\"U\n" +
+	"\x17StreamTillEventsRequest\x12\x19\n" +
+	"\btill_num\x18\x01 \x01(\x03R\atillNum\x12\x1f\n" +
+	"\vreceipt_num\x18\x02 \x01(\x03R\n" +
+	"receiptNum\"\xce\x01\n" +
+	"\tTillEvent\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x16\n" +
+	"\x06branch\x18\x02 \x01(\tR\x06branch\x12\x19\n" +
+	"\btill_num\x18\x03 \x01(\x03R\atillNum\x12\x1f\n" +
+	"\vreceipt_num\x18\x04 \x01(\x03R\n" +
+	"receiptNum\x12\x1b\n" +
+	"\torder_num\x18\x05 \x01(\x03R\borderNum\x12\x14\n" +
+	"\x05state\x18\x06 \x01(\tR\x05state\x12\x12\n" +
+	"\x04data\x18\a \x01(\tR\x04data\x12\x12\n" +
+	"\x04time\x18\b \x01(\x03R\x04time2\xac\x03\n" +
+	"\n" +
+	"PosService\x127\n" +
+	"\bOpenTill\x12\x14.pos.OpenTillRequest\x1a\x15.pos.OpenTillResponse\x128\n" +
+	"\rCreateReceipt\x12\x19.pos.CreateReceiptRequest\x1a\f.pos.Receipt\x124\n" +
+	"\aAddLine\x12\x13.pos.AddLineRequest\x1a\x14.pos.AddLineResponse\x12C\n" +
+	"\fApplyPayment\x12\x18.pos.ApplyPaymentRequest\x1a\x19.pos.ApplyPaymentResponse\x124\n" +
+	"\vPostReceipt\x12\x17.pos.PostReceiptRequest\x1a\f.pos.Receipt\x126\n" +
+	"\fFetchReceipt\x12\x18.pos.FetchReceiptRequest\x1a\f.pos.Receipt\x12B\n" +
+	"\x10StreamTillEvents\x12\x1c.pos.StreamTillEventsRequest\x1a\x0e.pos.TillEvent0\x01B6Z4github.com/JohnnyKahiu/speedsales/poserver/proto/posb\x06proto3"
+
+var (
+	file_pos_proto_rawDescOnce sync.Once
+	file_pos_proto_rawDescData []byte
+)
+
+func file_pos_proto_rawDescGZIP() []byte {
+	file_pos_proto_rawDescOnce.Do(func() {
+		file_pos_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pos_proto_rawDesc), len(file_pos_proto_rawDesc)))
+	})
+	return file_pos_proto_rawDescData
+}
+
+var file_pos_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_pos_proto_goTypes = []any{
+	(*OpenTillRequest)(nil),         // 0: pos.OpenTillRequest
+	(*OpenTillResponse)(nil),        // 1: pos.OpenTillResponse
+	(*CreateReceiptRequest)(nil),    // 2: pos.CreateReceiptRequest
+	(*Line)(nil),                    // 3: pos.Line
+	(*Receipt)(nil),                 // 4: pos.Receipt
+	(*AddLineRequest)(nil),          // 5: pos.AddLineRequest
+	(*AddLineResponse)(nil),         // 6: pos.AddLineResponse
+	(*ApplyPaymentRequest)(nil),     // 7: pos.ApplyPaymentRequest
+	(*ApplyPaymentResponse)(nil),    // 8: pos.ApplyPaymentResponse
+	(*PostReceiptRequest)(nil),      // 9: pos.PostReceiptRequest
+	(*FetchReceiptRequest)(nil),     // 10: pos.FetchReceiptRequest
+	(*StreamTillEventsRequest)(nil), // 11: pos.StreamTillEventsRequest
+	(*TillEvent)(nil),               // 12: pos.TillEvent
+}
+var file_pos_proto_depIdxs = []int32{
+	3,  // 0: pos.Receipt.lines:type_name -> pos.Line
+	3,  // 1: pos.AddLineResponse.line:type_name -> pos.Line
+	0,  // 2: pos.PosService.OpenTill:input_type -> pos.OpenTillRequest
+	2,  // 3: pos.PosService.CreateReceipt:input_type -> pos.CreateReceiptRequest
+	5,  // 4: pos.PosService.AddLine:input_type -> pos.AddLineRequest
+	7,  // 5: pos.PosService.ApplyPayment:input_type -> pos.ApplyPaymentRequest
+	9,  // 6: pos.PosService.PostReceipt:input_type -> pos.PostReceiptRequest
+	10, // 7: pos.PosService.FetchReceipt:input_type -> pos.FetchReceiptRequest
+	11, // 8: pos.PosService.StreamTillEvents:input_type -> pos.StreamTillEventsRequest
+	1,  // 9: pos.PosService.OpenTill:output_type -> pos.OpenTillResponse
+	4,  // 10: pos.PosService.CreateReceipt:output_type -> pos.Receipt
+	6,  // 11: pos.PosService.AddLine:output_type -> pos.AddLineResponse
+	8,  // 12: pos.PosService.ApplyPayment:output_type -> pos.ApplyPaymentResponse
+	4,  // 13: pos.PosService.PostReceipt:output_type -> pos.Receipt
+	4,  // 14: pos.PosService.FetchReceipt:output_type -> pos.Receipt
+	12, // 15: pos.PosService.StreamTillEvents:output_type -> pos.TillEvent
+	9,  // [9:16] is the sub-list for method output_type
+	2,  // [2:9] is the sub-list for method input_type
+	2,  // [2:2] is the sub-list for extension type_name
+	2,  // [2:2] is the sub-list for extension extendee
+	0,  // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_pos_proto_init() }
+func file_pos_proto_init() {
+	if File_pos_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pos_proto_rawDesc), len(file_pos_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pos_proto_goTypes,
+		DependencyIndexes: file_pos_proto_depIdxs,
+		MessageInfos:      file_pos_proto_msgTypes,
+	}.Build()
+	File_pos_proto = out.File
+	file_pos_proto_goTypes = nil
+	file_pos_proto_depIdxs = nil
+}
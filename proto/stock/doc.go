@@ -0,0 +1,4 @@
+// Package stock holds the generated grpc code of the inventory service's stock service
+package stock
+
+//go:generate protoc -I . --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative stock.proto
@@ -0,0 +1,89 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/api"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/authentication"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/proto/authkeys"
+	"github.com/dgrijalva/jwt-go"
+)
+
+type keySource struct {
+	keys []*authkeys.SigningKey
+}
+
+func (k keySource) SigningKeys(ctx context.Context) ([]*authkeys.SigningKey, error) {
+	return k.keys, nil
+}
+
+func (k keySource) RevokedSessions(ctx context.Context, since int64) ([]string, int64, error) {
+	return nil, time.Now().Unix(), nil
+}
+
+func (k keySource) ValidateUserToken(ctx context.Context, token string) (string, bool) {
+	return "", false
+}
+
+func TestJwtMiddlewareRole(t *testing.T) {
+	secret := []byte("k1-secret")
+	v := authentication.NewVerifier(keySource{keys: []*authkeys.SigningKey{{Kid: "k1", Secret: secret}}})
+	if err := v.RefreshKeys(context.Background()); err != nil {
+		t.Fatalf("failed to refresh keys: %s", err)
+	}
+	authentication.SetVerifier(v)
+
+	whoami := api.Typed("GET", "/whoami", "", func(ctx context.Context, user logins.Users, req struct{}) (logins.Users, error) {
+		return user, nil
+	})
+
+	exp := time.Now().Add(time.Hour).Unix()
+	tests := []struct {
+		name     string
+		claims   jwt.MapClaims
+		role     string
+		branch   string
+		settings bool
+	}{
+		{"role in rights", jwt.MapClaims{"session": "s1", "exp": exp, "rights": map[string]any{"username": "JTELLER", "make_sales": true, "role": "supervisor"}}, "supervisor", "", false},
+		{"role beside rights", jwt.MapClaims{"session": "s1", "exp": exp, "role": "cashier", "rights": map[string]any{"username": "JTELLER", "make_sales": true}}, "cashier", "", false},
+		{"branch settings", jwt.MapClaims{"session": "s1", "exp": exp, "rights": map[string]any{"username": "JTELLER", "make_sales": true, "branch": "Main", "pos_settings": true}}, "", "Main", true},
+		{"no role", jwt.MapClaims{"session": "s1", "exp": exp, "rights": map[string]any{"username": "JTELLER", "make_sales": true}}, "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, tt.claims)
+			token.Header["kid"] = "k1"
+			tokenStr, err := token.SignedString(secret)
+			if err != nil {
+				t.Fatalf("failed to sign token: %s", err)
+			}
+
+			req := httptest.NewRequest("GET", "/whoami", nil)
+			req.Header.Set("token", tokenStr)
+			w := httptest.NewRecorder()
+			api.JwtMiddleware(whoami.Handler).ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %v: %s", w.Code, w.Body.String())
+			}
+
+			var user logins.Users
+			json.Unmarshal(w.Body.Bytes(), &user)
+			if user.Username != "JTELLER" || user.Role != tt.role {
+				t.Errorf("expected JTELLER with role %q, got %v with role %q", tt.role, user.Username, user.Role)
+			}
+
+			if user.Branch != tt.branch || user.PosSettings != tt.settings {
+				t.Errorf("expected branch %q with pos_settings %v, got %q with %v", tt.branch, tt.settings, user.Branch, user.PosSettings)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/api"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/events"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+)
+
+func TestEventsStream(t *testing.T) {
+	hub := events.NewHub()
+
+	srv := httptest.NewServer(api.EventsGet(hub))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"?receipt_num=1001", nil)
+	juser, _ := json.Marshal(logins.Users{Username: "WAITER", Branch: "Main"})
+	req.Header.Set("user_details", string(juser))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to connect to event stream: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %v", ct)
+	}
+
+	rd := bufio.NewReader(resp.Body)
+	if line, _ := rd.ReadString('\n'); !strings.HasPrefix(line, ": connected") {
+		t.Fatalf("expected a connected comment, got %q", line)
+	}
+	rd.ReadString('\n')
+
+	hub.Publish(events.Event{Type: events.CartItemAdded, Branch: "Main", ReceiptNum: 2002})
+	hub.Publish(events.Event{Type: events.BillClosed, Branch: "Main", ReceiptNum: 1001})
+
+	line, _ := rd.ReadString('\n')
+	if line != "event: bill.closed\n" {
+		t.Fatalf("expected only the subscribed receipt's event, got %q", line)
+	}
+
+	line, _ = rd.ReadString('\n')
+	var e events.Event
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e); err != nil {
+		t.Fatalf("failed to unmarshal event data: %s", err)
+	}
+	if e.ReceiptNum != 1001 {
+		t.Errorf("expected receipt 1001, got %v", e.ReceiptNum)
+	}
+}
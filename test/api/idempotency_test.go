@@ -0,0 +1,172 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/api"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/idempotency"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+)
+
+// memIdempotency is an in-memory idempotency store
+type memIdempotency map[string]idempotency.Record
+
+func (m memIdempotency) Reserve(ctx context.Context, rec *idempotency.Record, ttl time.Duration) (bool, error) {
+	if old, ok := m[rec.Key]; ok {
+		*rec = old
+		return false, nil
+	}
+	m[rec.Key] = *rec
+	return true, nil
+}
+
+func (m memIdempotency) Save(ctx context.Context, rec idempotency.Record, ttl time.Duration) error {
+	m[rec.Key] = rec
+	return nil
+}
+
+func (m memIdempotency) Release(ctx context.Context, key string) error {
+	if m[key].Status == 0 {
+		delete(m, key)
+	}
+	return nil
+}
+
+// leaseStore notes how long keys are claimed and kept for
+type leaseStore struct {
+	memIdempotency
+	lease, kept time.Duration
+}
+
+func (s *leaseStore) Reserve(ctx context.Context, rec *idempotency.Record, lease time.Duration) (bool, error) {
+	s.lease = lease
+	return s.memIdempotency.Reserve(ctx, rec, lease)
+}
+
+func (s *leaseStore) Save(ctx context.Context, rec idempotency.Record, ttl time.Duration) error {
+	s.kept = ttl
+	return s.memIdempotency.Save(ctx, rec, ttl)
+}
+
+func idempotentRequest(username, key, body string) *http.Request {
+	req := httptest.NewRequest("POST", "/sales/cash/add-cart", strings.NewReader(body))
+	juser, _ := json.Marshal(logins.Users{Username: username})
+	req.Header.Set("user_details", string(juser))
+	if key != "" {
+		req.Header.Set(api.IdempotencyHeader, key)
+	}
+	return req
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	calls := 0
+	handler := api.Idempotent(memIdempotency{}, time.Hour, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		api.WriteJSON(w, http.StatusOK, map[string]any{"response": "success", "call": calls})
+	})
+
+	first := httptest.NewRecorder()
+	handler(first, idempotentRequest("JTELLER", "abc", `{"item_code": "1001", "quantity": 1}`))
+
+	retry := httptest.NewRecorder()
+	handler(retry, idempotentRequest("JTELLER", "abc", `{"item_code": "1001", "quantity": 1}`))
+
+	if calls != 1 {
+		t.Fatalf("expected handler to run once, ran %d times", calls)
+	}
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+		t.Errorf("expected replayed response %v %s, got %v %s", first.Code, first.Body, retry.Code, retry.Body)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("expected replayed header on retry")
+	}
+
+	// keys are scoped per user
+	other := httptest.NewRecorder()
+	handler(other, idempotentRequest("CASHIER", "abc", `{"item_code": "1001", "quantity": 1}`))
+	if calls != 2 {
+		t.Errorf("expected another user's key to run the handler, ran %d times", calls)
+	}
+}
+
+func TestIdempotentLease(t *testing.T) {
+	store := &leaseStore{memIdempotency: memIdempotency{}}
+	handler := api.Idempotent(store, 24*time.Hour, func(w http.ResponseWriter, r *http.Request) {
+		api.WriteJSON(w, http.StatusOK, map[string]any{"response": "success"})
+	})
+
+	handler(httptest.NewRecorder(), idempotentRequest("JTELLER", "abc", `{}`))
+
+	// a request that dies mid-handler only holds its key for the lease
+	if store.lease <= 0 || store.lease > 5*time.Minute {
+		t.Errorf("expected the key claimed for a short lease, got %v", store.lease)
+	}
+	if store.kept != 24*time.Hour {
+		t.Errorf("expected the stored response kept for 24h, got %v", store.kept)
+	}
+
+	// a late release leaves the stored response
+	key := "idempotency:JTELLER:abc"
+	store.Release(context.Background(), key)
+	if store.memIdempotency[key].Status != http.StatusOK {
+		t.Error("expected release to keep the stored response")
+	}
+}
+
+func TestIdempotentKeyReused(t *testing.T) {
+	handler := api.Idempotent(memIdempotency{}, time.Hour, func(w http.ResponseWriter, r *http.Request) {
+		api.WriteJSON(w, http.StatusOK, map[string]any{"response": "success"})
+	})
+
+	handler(httptest.NewRecorder(), idempotentRequest("JTELLER", "abc", `{"item_code": "1001"}`))
+
+	w := httptest.NewRecorder()
+	handler(w, idempotentRequest("JTELLER", "abc", `{"item_code": "1002"}`))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422, got %v", w.Code)
+	}
+}
+
+func TestIdempotentServerErrorReleasesKey(t *testing.T) {
+	calls := 0
+	handler := api.Idempotent(memIdempotency{}, time.Hour, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			api.WriteError(w, fmt.Errorf("database down"))
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, map[string]any{"response": "success"})
+	})
+
+	handler(httptest.NewRecorder(), idempotentRequest("JTELLER", "abc", `{}`))
+
+	w := httptest.NewRecorder()
+	handler(w, idempotentRequest("JTELLER", "abc", `{}`))
+
+	if calls != 2 || w.Code != http.StatusOK {
+		t.Errorf("expected retry after a server error to run, calls = %d status = %v", calls, w.Code)
+	}
+}
+
+func TestWithoutIdempotencyKey(t *testing.T) {
+	calls := 0
+	handler := api.Idempotent(memIdempotency{}, time.Hour, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		api.WriteJSON(w, http.StatusOK, map[string]any{"response": "success"})
+	})
+
+	handler(httptest.NewRecorder(), idempotentRequest("JTELLER", "", `{}`))
+	handler(httptest.NewRecorder(), idempotentRequest("JTELLER", "", `{}`))
+
+	if calls != 2 {
+		t.Errorf("expected requests without a key to always run, ran %d times", calls)
+	}
+}
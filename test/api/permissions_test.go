@@ -0,0 +1,103 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/api"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+)
+
+func TestEndpointsDeclarePermissions(t *testing.T) {
+	if err := api.ValidatePermissions(api.Endpoints(&sales.Service{})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidatePermissions(t *testing.T) {
+	undeclared := api.Endpoint{Method: http.MethodPost, Path: "/sales/cash/void"}
+	if err := api.ValidatePermissions([]api.Endpoint{undeclared}); err == nil {
+		t.Error("expected an error for a route without permissions")
+	}
+
+	unknown := undeclared.Require("void_sales")
+	if err := api.ValidatePermissions([]api.Endpoint{unknown}); err == nil {
+		t.Error("expected an error for an unknown right")
+	}
+
+	public := api.Endpoint{Method: http.MethodGet, Path: "/status", Public: true}
+	if err := api.ValidatePermissions([]api.Endpoint{public}); err != nil {
+		t.Errorf("expected public routes to need no permission, got %s", err)
+	}
+}
+
+func TestRoutePermissions(t *testing.T) {
+	cashier := &logins.Users{Username: "CASHIER", AcceptPayment: true}
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"POST", "/sales/order/complete", `{"order_num": 1}`, http.StatusForbidden},
+		{"DELETE", "/sales/order/order-item", `{"auto_id": "1", "order_num": "1"}`, http.StatusForbidden},
+		{"POST", "/sales/cash/open-till", `{}`, http.StatusBadRequest},
+		{"POST", "/branch/profile", `{"mode": "restaurant"}`, http.StatusForbidden},
+		{"POST", "/sales/returns", `{"return_trace": 1, "lines": [{"receipt_item": "1-1", "quantity": 1}]}`, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w, resp := serve(endpoint(t, tt.method, tt.path), cashier, tt.body)
+			if w.Code != tt.status {
+				t.Errorf("expected status %v, got %v %v", tt.status, w.Code, resp.Message)
+			}
+		})
+	}
+}
+
+func TestReturnsNeedTheTill(t *testing.T) {
+	returns := &logins.Users{Username: "RETURNS", SalesReturns: true}
+
+	w, resp := serve(endpoint(t, "POST", "/sales/returns"), returns, `{"return_trace": 1, "lines": [{"receipt_item": "1-1", "quantity": 1}]}`)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected a refund without accept_payment forbidden, got %v %v", w.Code, resp.Message)
+	}
+}
+
+func TestProfileSetOwnBranch(t *testing.T) {
+	admin := &logins.Users{Username: "ADMIN", Branch: "Main", PosSettings: true}
+
+	w, resp := serve(endpoint(t, "POST", "/branch/profile"), admin, `{"branch": "Westlands", "mode": "restaurant"}`)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected another branch's profile forbidden, got %v %v", w.Code, resp.Message)
+	}
+}
+
+func TestHasRight(t *testing.T) {
+	user := logins.Users{Username: "JTELLER", MakeSales: true}
+
+	if !user.HasRight(logins.RightMakeSales) {
+		t.Error("expected make_sales right")
+	}
+	if user.HasRight(logins.RightAcceptPayment) {
+		t.Error("expected no accept_payment right")
+	}
+	if !user.HasRight(logins.RightAuthenticated) {
+		t.Error("expected a logged in user to be authenticated")
+	}
+	if user.HasRight("first_name") {
+		t.Error("expected non boolean fields not to be rights")
+	}
+}
+
+func TestRightTitle(t *testing.T) {
+	if got := logins.RightGrantPriceChange.Title(); got != "Grant Price Change" {
+		t.Errorf("expected 'Grant Price Change', got %q", got)
+	}
+	if !logins.ValidRight(logins.RightGrantApproveSales) || !logins.ValidRight(logins.RightPosSettings) {
+		t.Error("expected the approver and settings rights to name Users fields")
+	}
+}
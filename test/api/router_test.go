@@ -0,0 +1,177 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/api"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+)
+
+func endpoint(t *testing.T, method, path string) api.Endpoint {
+	for _, ep := range api.Endpoints(&sales.Service{}) {
+		if ep.Method == method && ep.Path == path {
+			return ep
+		}
+	}
+	t.Fatalf("endpoint %v %v not found", method, path)
+	return api.Endpoint{}
+}
+
+func serve(ep api.Endpoint, user *logins.Users, body string) (*httptest.ResponseRecorder, api.ErrorResponse) {
+	req := httptest.NewRequest(ep.Method, ep.Path, strings.NewReader(body))
+	if user != nil {
+		juser, _ := json.Marshal(user)
+		req.Header.Set("user_details", string(juser))
+	}
+
+	w := httptest.NewRecorder()
+	api.RequireRights(ep.Rights, ep.Handler)(w, req)
+
+	var resp api.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w, resp
+}
+
+func TestEndpointErrors(t *testing.T) {
+	teller := &logins.Users{Username: "JTELLER", TillNum: 1, MakeSales: true}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		user   *logins.Users
+		body   string
+		status int
+		code   string
+	}{
+		{"missing user", "POST", "/sales/cash/add-cart", nil, `{}`, http.StatusUnauthorized, "UNAUTHORIZED"},
+		{"forbidden", "POST", "/sales/cash/add-cart", &logins.Users{Username: "GUEST"}, `{"item_code": "1001", "quantity": 1}`, http.StatusForbidden, "FORBIDDEN"},
+		{"bad json", "POST", "/sales/cash/add-cart", teller, `{"item_code": `, http.StatusBadRequest, "BAD_REQUEST"},
+		{"missing item", "POST", "/sales/cash/add-cart", teller, `{"quantity": 1}`, http.StatusBadRequest, "VALIDATION_FAILED"},
+		{"null receipt", "POST", "/sales/cash/close_bill", teller, `{}`, http.StatusBadRequest, "VALIDATION_FAILED"},
+		{"bad order num", "DELETE", "/sales/order/order-item", teller, `{"auto_id": "1", "order_num": "x"}`, http.StatusBadRequest, "VALIDATION_FAILED"},
+		{"till not open", "GET", "/sales/cash/active-carts", &logins.Users{Username: "JTELLER", MakeSales: true}, ``, http.StatusConflict, "TILL_NOT_OPEN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, resp := serve(endpoint(t, tt.method, tt.path), tt.user, tt.body)
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %v, got %v", tt.status, w.Code)
+			}
+			if resp.Response != "error" || string(resp.Code) != tt.code {
+				t.Errorf("expected error code %v, got %v %v", tt.code, resp.Response, resp.Code)
+			}
+		})
+	}
+}
+
+func TestQueryValidation(t *testing.T) {
+	ep := endpoint(t, "GET", "/sales/order/cart")
+
+	req := httptest.NewRequest("GET", "/sales/order/cart?order_num=abc", nil)
+	juser, _ := json.Marshal(logins.Users{Username: "WAITER", MakeSales: true})
+	req.Header.Set("user_details", string(juser))
+
+	w := httptest.NewRecorder()
+	ep.Handler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %v", w.Code)
+	}
+}
+
+func TestUnknownRoute(t *testing.T) {
+	r, err := api.NewRouter(&sales.Service{})
+	if err != nil {
+		t.Fatalf("failed to create router: %s", err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/sales/cash/unknown", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %v", w.Code)
+	}
+
+	var resp api.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Code != "NOT_FOUND" {
+		t.Errorf("expected NOT_FOUND, got %v", resp.Code)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	r, err := api.NewRouter(&sales.Service{})
+	if err != nil {
+		t.Fatalf("failed to create router: %s", err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %v", w.Code)
+	}
+
+	doc := struct {
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("failed to unmarshal openapi document: %s", err)
+	}
+
+	if _, ok := doc.Paths["/sales/cash/add-cart"]["post"]; !ok {
+		t.Error("expected add-cart in openapi paths")
+	}
+	if _, ok := doc.Paths["/sales/order/order-item"]["delete"]; !ok {
+		t.Error("expected order-item delete in openapi paths")
+	}
+	if _, ok := doc.Components.Schemas["CashAddCartRequest"]; !ok {
+		t.Error("expected CashAddCartRequest schema")
+	}
+	if _, ok := doc.Components.Schemas["ApiErrorResponse"]; !ok {
+		t.Error("expected ApiErrorResponse schema")
+	}
+}
+
+func TestPublicEndpoints(t *testing.T) {
+	r, err := api.NewRouter(&sales.Service{})
+	if err != nil {
+		t.Fatalf("failed to create router: %s", err)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{"GET", "/status", http.StatusOK},
+		{"OPTIONS", "/status", http.StatusNoContent},
+		{"GET", "/openapi.json", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			// declared like every other route, so they pass the permission check
+			if ep := endpoint(t, tt.method, tt.path); !ep.Public {
+				t.Errorf("expected %v %v declared public", tt.method, tt.path)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.status {
+				t.Errorf("expected status %v without a token, got %v", tt.status, w.Code)
+			}
+		})
+	}
+}
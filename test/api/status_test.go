@@ -0,0 +1,56 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/api"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/grpc"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestStatus(t *testing.T) {
+	lis := bufconn.Listen(1024 * 1024)
+	hs := health.NewServer()
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	s := ggrpc.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	reg, err := grpc.NewRegistry(grpc.Config{
+		Name:    grpc.ServiceInventory,
+		Address: "passthrough:///bufnet",
+		Dialer: func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create registry: %s", err)
+	}
+	defer reg.Close()
+
+	w := httptest.NewRecorder()
+	api.StatusGet(reg)(w, httptest.NewRequest("GET", "/status", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %v", w.Code)
+	}
+
+	var resp api.StatusResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Status != "degraded" {
+		t.Errorf("expected degraded status, got %v", resp.Status)
+	}
+	if h := resp.Services[grpc.ServiceInventory]; h.Serving != "NOT_SERVING" {
+		t.Errorf("expected inventory NOT_SERVING, got %+v", h)
+	}
+}
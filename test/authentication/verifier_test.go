@@ -0,0 +1,160 @@
+package authentication_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/authentication"
+	"github.com/JohnnyKahiu/speedsales/poserver/proto/authkeys"
+	"github.com/dgrijalva/jwt-go"
+)
+
+type fakeSource struct {
+	keys     []*authkeys.SigningKey
+	revoked  []string
+	keyCalls int
+	remote   int
+}
+
+func (f *fakeSource) SigningKeys(ctx context.Context) ([]*authkeys.SigningKey, error) {
+	f.keyCalls++
+	return f.keys, nil
+}
+
+func (f *fakeSource) RevokedSessions(ctx context.Context, since int64) ([]string, int64, error) {
+	return f.revoked, time.Now().Unix(), nil
+}
+
+func (f *fakeSource) ValidateUserToken(ctx context.Context, token string) (string, bool) {
+	f.remote++
+	return `{"username": "REMOTE"}`, true
+}
+
+func sign(t *testing.T, kid string, secret []byte, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = kid
+
+	tokenStr, err := token.SignedString(secret)
+	if err != nil {
+		t.Fatalf("failed to sign token: %s", err)
+	}
+	return tokenStr
+}
+
+func claims(session string, exp interface{}) jwt.MapClaims {
+	return jwt.MapClaims{
+		"username": "JTELLER",
+		"session":  session,
+		"exp":      exp,
+		"rights":   map[string]any{"username": "JTELLER", "make_sales": true},
+	}
+}
+
+func newVerifier(t *testing.T, src *fakeSource) *authentication.Verifier {
+	v := authentication.NewVerifier(src)
+	if err := v.RefreshKeys(context.Background()); err != nil {
+		t.Fatalf("failed to refresh keys: %s", err)
+	}
+	if err := v.RefreshRevoked(context.Background()); err != nil {
+		t.Fatalf("failed to refresh revoked sessions: %s", err)
+	}
+	return v
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("k1-secret")
+	src := &fakeSource{
+		keys:    []*authkeys.SigningKey{{Kid: "k1", Secret: secret}},
+		revoked: []string{"revoked-session"},
+	}
+	v := newVerifier(t, src)
+
+	later := time.Now().Add(time.Hour)
+	earlier := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"numeric expiry", sign(t, "k1", secret, claims("s1", later.Unix())), nil},
+		{"text expiry", sign(t, "k1", secret, claims("s1", later.Format("2006-01-02 15:04"))), nil},
+		{"expired", sign(t, "k1", secret, claims("s1", earlier.Unix())), authentication.ErrTokenExpired},
+		{"expired text", sign(t, "k1", secret, claims("s1", earlier.Format("2006-01-02 15:04"))), authentication.ErrTokenExpired},
+		{"revoked", sign(t, "k1", secret, claims("revoked-session", later.Unix())), authentication.ErrSessionRevoked},
+		{"bad signature", sign(t, "k1", []byte("wrong"), claims("s1", later.Unix())), authentication.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := v.Verify(context.Background(), tt.token)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if err == nil && (user.Username != "JTELLER" || !user.MakeSales) {
+				t.Errorf("expected JTELLER with make_sales, got %+v", user)
+			}
+		})
+	}
+
+	if src.remote != 0 {
+		t.Errorf("expected no remote validation, got %v", src.remote)
+	}
+}
+
+func TestVerifyRotatedKey(t *testing.T) {
+	src := &fakeSource{keys: []*authkeys.SigningKey{{Kid: "k1", Secret: []byte("k1-secret")}}}
+	v := newVerifier(t, src)
+
+	// the auth service rotates to k2 after the keys were cached
+	src.keys = append(src.keys, &authkeys.SigningKey{Kid: "k2", Secret: []byte("k2-secret")})
+
+	token := sign(t, "k2", []byte("k2-secret"), claims("s1", time.Now().Add(time.Hour).Unix()))
+	if _, err := v.Verify(context.Background(), token); err != nil {
+		t.Fatalf("expected rotated key to verify, got %v", err)
+	}
+	if src.keyCalls != 2 {
+		t.Errorf("expected keys refreshed once on unknown kid, got %v fetches", src.keyCalls)
+	}
+
+	// repeated unknown kids don't hammer the auth service
+	token = sign(t, "k3", []byte("k3-secret"), claims("s1", time.Now().Add(time.Hour).Unix()))
+	for i := 0; i < 3; i++ {
+		if _, err := v.Verify(context.Background(), token); !errors.Is(err, authentication.ErrUnknownKey) {
+			t.Fatalf("expected unknown key, got %v", err)
+		}
+	}
+	if src.keyCalls != 2 {
+		t.Errorf("expected no refresh within the retry window, got %v fetches", src.keyCalls)
+	}
+}
+
+func TestVerifyPushedRevocation(t *testing.T) {
+	secret := []byte("k1-secret")
+	v := newVerifier(t, &fakeSource{keys: []*authkeys.SigningKey{{Kid: "k1", Secret: secret}}})
+
+	token := sign(t, "k1", secret, claims("s1", time.Now().Add(time.Hour).Unix()))
+	if _, err := v.Verify(context.Background(), token); err != nil {
+		t.Fatalf("expected token to verify, got %v", err)
+	}
+
+	v.Revoke("s1")
+	if _, err := v.Verify(context.Background(), token); !errors.Is(err, authentication.ErrSessionRevoked) {
+		t.Errorf("expected revoked session, got %v", err)
+	}
+}
+
+func TestVerifyWithoutKeys(t *testing.T) {
+	src := &fakeSource{}
+	v := authentication.NewVerifier(src)
+
+	user, err := v.Verify(context.Background(), "opaque")
+	if err != nil {
+		t.Fatalf("expected remote validation, got %v", err)
+	}
+	if user.Username != "REMOTE" || src.remote != 1 {
+		t.Errorf("expected remote user, got %+v after %v calls", user, src.remote)
+	}
+}
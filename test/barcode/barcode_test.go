@@ -0,0 +1,38 @@
+package barcode_test
+
+import (
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/barcode"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+)
+
+func TestParse(t *testing.T) {
+	trimmed := []barcode.Rule{{Prefix: "25", Length: 13, Kind: barcode.KindPrice, PluStart: 2, PluLen: 5, ValueStart: 7, ValueLen: 5, Decimals: 2, TrimZeros: true}}
+
+	tests := []struct {
+		name  string
+		code  string
+		rules []barcode.Rule
+		ok    bool
+		want  barcode.Scan
+	}{
+		{"weight label", "2012345012509", barcode.Defaults(), true, barcode.Scan{Code: "12345", Kind: barcode.KindWeight, Weight: 1.25}},
+		{"price label", "2501234019991", barcode.Defaults(), true, barcode.Scan{Code: "01234", Kind: barcode.KindPrice, Price: money.New(19.99)}},
+		{"trimmed plu", "2501234019991", trimmed, true, barcode.Scan{Code: "1234", Kind: barcode.KindPrice, Price: money.New(19.99)}},
+		{"case code", "16004005000127", barcode.Defaults(), true, barcode.Scan{Code: "6004005000120", Kind: barcode.KindPack}},
+		{"wrong check digit", "2012345012508", barcode.Defaults(), false, barcode.Scan{}},
+		{"ordinary ean", "5000123456789", barcode.Defaults(), false, barcode.Scan{}},
+		{"item code", "1001", barcode.Defaults(), false, barcode.Scan{}},
+		{"parsing off", "2012345012509", []barcode.Rule{}, false, barcode.Scan{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := barcode.Parse(tt.code, tt.rules)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("expected %+v %v, got %+v %v", tt.want, tt.ok, got, ok)
+			}
+		})
+	}
+}
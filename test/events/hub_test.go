@@ -0,0 +1,50 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/events"
+)
+
+func TestHubScope(t *testing.T) {
+	hub := events.NewHub()
+
+	till, cancelTill := hub.Subscribe(events.Scope{Branch: "Main", TillNum: 1})
+	defer cancelTill()
+	receipt, cancelReceipt := hub.Subscribe(events.Scope{Branch: "Main", ReceiptNum: 1001})
+	defer cancelReceipt()
+	other, cancelOther := hub.Subscribe(events.Scope{Branch: "Westlands"})
+	defer cancelOther()
+
+	hub.Publish(events.Event{Type: events.CartItemAdded, Branch: "Main", TillNum: 1, ReceiptNum: 1001})
+	hub.Publish(events.Event{Type: events.OrderState, Branch: "Main", TillNum: 2, ReceiptNum: 1001})
+
+	if n := len(till); n != 1 {
+		t.Errorf("expected 1 event for the till, got %d", n)
+	}
+	if n := len(receipt); n != 2 {
+		t.Errorf("expected 2 events for the receipt, got %d", n)
+	}
+	if n := len(other); n != 0 {
+		t.Errorf("expected no events for another branch, got %d", n)
+	}
+}
+
+func TestHubCancel(t *testing.T) {
+	hub := events.NewHub()
+
+	ch, cancel := hub.Subscribe(events.Scope{})
+	cancel()
+	cancel()
+
+	hub.Publish(events.Event{Type: events.BillClosed})
+
+	if _, ok := <-ch; ok {
+		t.Error("expected the channel to be closed after cancel")
+	}
+}
+
+func TestNilHub(t *testing.T) {
+	var hub *events.Hub
+	hub.Publish(events.Event{Type: events.BillClosed})
+}
@@ -0,0 +1,144 @@
+package grpc_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/grpc"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeHealth fails the first failures checks with code
+type fakeHealth struct {
+	healthpb.UnimplementedHealthServer
+	failures int64
+	code     codes.Code
+	delay    time.Duration
+	calls    atomic.Int64
+}
+
+func (f *fakeHealth) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	n := f.calls.Add(1)
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if f.failures < 0 || n <= f.failures {
+		return nil, status.Error(f.code, "fake failure")
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func serve(t *testing.T, srv healthpb.HealthServer, cfg grpc.Config) *grpc.Registry {
+	lis := bufconn.Listen(1024 * 1024)
+	s := ggrpc.NewServer()
+	healthpb.RegisterHealthServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	cfg.Name = "fake"
+	cfg.Address = "passthrough:///bufnet"
+	cfg.Dialer = func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}
+
+	reg, err := grpc.NewRegistry(cfg)
+	if err != nil {
+		t.Fatalf("failed to create registry: %s", err)
+	}
+	t.Cleanup(func() { reg.Close() })
+	return reg
+}
+
+func check(t *testing.T, reg *grpc.Registry) error {
+	conn, err := reg.Conn("fake")
+	if err != nil {
+		t.Fatalf("failed to get connection: %s", err)
+	}
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestRetryUnavailable(t *testing.T) {
+	srv := &fakeHealth{failures: 2, code: codes.Unavailable}
+	reg := serve(t, srv, grpc.Config{Retries: 3, Backoff: time.Millisecond})
+
+	if err := check(t, reg); err != nil {
+		t.Fatalf("expected call to succeed after retries, got %v", err)
+	}
+	if n := srv.calls.Load(); n != 3 {
+		t.Errorf("expected 3 attempts, got %v", n)
+	}
+}
+
+func TestNoRetryOnOtherErrors(t *testing.T) {
+	srv := &fakeHealth{failures: -1, code: codes.InvalidArgument}
+	reg := serve(t, srv, grpc.Config{Retries: 3, Backoff: time.Millisecond})
+
+	if err := check(t, reg); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+	if n := srv.calls.Load(); n != 1 {
+		t.Errorf("expected 1 attempt, got %v", n)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	srv := &fakeHealth{delay: time.Second}
+	reg := serve(t, srv, grpc.Config{Timeout: 20 * time.Millisecond})
+
+	if err := check(t, reg); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	srv := &fakeHealth{failures: 2, code: codes.Unavailable}
+	reg := serve(t, srv, grpc.Config{BreakerFailures: 2, BreakerCooldown: 50 * time.Millisecond})
+
+	for i := 0; i < 2; i++ {
+		if err := check(t, reg); status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected Unavailable, got %v", err)
+		}
+	}
+
+	// the open breaker fails fast without calling the service
+	if err := check(t, reg); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable from open breaker, got %v", err)
+	}
+	if n := srv.calls.Load(); n != 2 {
+		t.Errorf("expected 2 calls to reach the service, got %v", n)
+	}
+	if h := reg.Health(context.Background())["fake"]; h.Breaker != grpc.BreakerOpen {
+		t.Errorf("expected open breaker, got %v", h.Breaker)
+	}
+
+	// a probe after the cooldown closes it again
+	time.Sleep(60 * time.Millisecond)
+	if err := check(t, reg); err != nil {
+		t.Fatalf("expected probe to succeed, got %v", err)
+	}
+	if h := reg.Health(context.Background())["fake"]; h.Breaker != grpc.BreakerClosed || h.Serving != "SERVING" {
+		t.Errorf("expected closed breaker and SERVING, got %+v", h)
+	}
+}
+
+func TestUnregisteredService(t *testing.T) {
+	reg, err := grpc.NewRegistry()
+	if err != nil {
+		t.Fatalf("failed to create registry: %s", err)
+	}
+	if _, err := grpc.NewInventoryService(reg); err == nil {
+		t.Error("expected error for unregistered inventory service")
+	}
+}
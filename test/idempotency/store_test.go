@@ -0,0 +1,70 @@
+package idempotency_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/idempotency"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestPgStoreReserve(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	rec := idempotency.Record{Key: "idempotency:JTELLER:abc", ReqHash: "hash"}
+
+	mock.ExpectExec(`INSERT INTO idempotency_keys`).
+		WithArgs(rec.Key, rec.ReqHash, float64(3600)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	claimed, err := idempotency.NewPgStore(mock).Reserve(context.Background(), &rec, time.Hour)
+	if err != nil {
+		t.Fatalf("error was not expected while reserving key: %s", err)
+	}
+	if !claimed {
+		t.Error("expected a new key to be claimed")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPgStoreReserveExisting(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	rec := idempotency.Record{Key: "idempotency:JTELLER:abc", ReqHash: "hash"}
+	expires := time.Now().Add(time.Hour)
+
+	mock.ExpectExec(`INSERT INTO idempotency_keys`).
+		WithArgs(rec.Key, rec.ReqHash, float64(3600)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	mock.ExpectQuery(`SELECT req_hash, status, body, expires_at FROM idempotency_keys WHERE key = \$1`).
+		WithArgs(rec.Key).
+		WillReturnRows(mock.NewRows([]string{"req_hash", "status", "body", "expires_at"}).
+			AddRow("hash", 200, `{"response": "success"}`, expires))
+
+	claimed, err := idempotency.NewPgStore(mock).Reserve(context.Background(), &rec, time.Hour)
+	if err != nil {
+		t.Fatalf("error was not expected while reserving key: %s", err)
+	}
+	if claimed {
+		t.Error("expected an existing key not to be claimed")
+	}
+	if rec.Status != 200 || rec.Body != `{"response": "success"}` {
+		t.Errorf("expected the stored response, got %v %v", rec.Status, rec.Body)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
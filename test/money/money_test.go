@@ -0,0 +1,84 @@
+package money_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestAmountJSON(t *testing.T) {
+	var line struct {
+		Price money.Amount `json:"price"`
+		Total money.Amount `json:"total"`
+	}
+	// carts saved as floats keep their value to the cent
+	if err := json.Unmarshal([]byte(`{"price": 0.1, "total": 116.00000000001}`), &line); err != nil {
+		t.Fatalf("error unmarshalling amounts: %s", err)
+	}
+	if line.Price != 10 || line.Total != 11600 {
+		t.Errorf("expected 10 and 11600 minor units, got %d and %d", line.Price, line.Total)
+	}
+
+	line.Price = -1230
+	b, err := json.Marshal(line)
+	if err != nil {
+		t.Fatalf("error marshalling amounts: %s", err)
+	}
+	if string(b) != `{"price":-12.30,"total":116.00}` {
+		t.Errorf("unexpected json %s", b)
+	}
+}
+
+func TestAmountArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  money.Amount
+		want money.Amount
+	}{
+		{"new rounds half away from zero", money.New(0.005), 1},
+		{"float sums don't drift", money.New(0.1) + money.New(0.2), money.New(0.3)},
+		{"mul", money.New(19.99).Mul(3), money.New(59.97)},
+		{"percent", money.New(200).Percent(16), money.New(32)},
+		{"round to shilling down", money.New(59.49).Round(money.New(1)), money.New(59)},
+		{"round to shilling up", money.New(59.5).Round(money.New(1)), money.New(60)},
+		{"round to 5 cents", money.New(10.42).Round(5), money.New(10.40)},
+		{"round negative", money.New(-10.6).Round(money.New(1)), money.New(-11)},
+		{"no rounding step", money.New(10.42).Round(0), money.New(10.42)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, tt.got)
+			}
+		})
+	}
+}
+
+func TestAmountNumeric(t *testing.T) {
+	var a money.Amount
+	if err := a.ScanNumeric(pgtype.Numeric{Int: big.NewInt(123456), Exp: -3, Valid: true}); err != nil {
+		t.Fatalf("error scanning numeric: %s", err)
+	}
+	if a != 12346 {
+		t.Errorf("expected 12346 minor units, got %d", a)
+	}
+
+	if err := a.ScanFloat64(pgtype.Float8{Float64: 59.7, Valid: true}); err != nil {
+		t.Fatalf("error scanning float: %s", err)
+	}
+	if a != 5970 {
+		t.Errorf("expected 5970 minor units, got %d", a)
+	}
+
+	n, err := money.New(59.7).NumericValue()
+	if err != nil {
+		t.Fatalf("error encoding numeric: %s", err)
+	}
+	if n.Int.Int64() != 5970 || n.Exp != -2 {
+		t.Errorf("expected 5970e-2, got %ve%v", n.Int, n.Exp)
+	}
+}
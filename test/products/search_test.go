@@ -0,0 +1,73 @@
+package products_test
+
+import (
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+)
+
+var catalog = []products.StockMaster{
+	{ItemCode: "1001", ItemName: "Bread White 400g", Category: "Bakery", Barcodes: []string{"6161101600012"}},
+	{ItemCode: "1002", ItemName: "Brown Bread 600g", Category: "Bakery"},
+	{ItemCode: "2001", ItemName: "Milk Fresh 500ml", Category: "Dairy"},
+	{ItemCode: "2002", ItemName: "Yoghurt Strawberry", Category: "Dairy"},
+	{ItemCode: "3001", ItemName: "Sugar 1kg", Category: "Grocery"},
+}
+
+func codes(res products.SearchResult) []string {
+	var c []string
+	for _, p := range res.Products {
+		c = append(c, p.ItemCode)
+	}
+	return c
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		q     products.SearchQuery
+		codes []string
+		total int
+	}{
+		{"prefix before word match", products.SearchQuery{Query: "bread"}, []string{"1001", "1002"}, 2},
+		{"item code prefix", products.SearchQuery{Query: "200"}, []string{"2001", "2002"}, 2},
+		{"typo", products.SearchQuery{Query: "yogurt"}, []string{"2002"}, 1},
+		{"partly typed with a typo", products.SearchQuery{Query: "strwb"}, []string{"2002"}, 1},
+		{"barcode", products.SearchQuery{Barcode: "6161101600012"}, []string{"1001"}, 1},
+		{"item code as barcode", products.SearchQuery{Barcode: "3001"}, []string{"3001"}, 1},
+		{"category page", products.SearchQuery{Category: "dairy", Limit: 1, Offset: 1}, []string{"2002"}, 2},
+		{"no match", products.SearchQuery{Query: "xyz"}, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := products.Filter(catalog, tt.q)
+			got := codes(res)
+			if len(got) != len(tt.codes) || res.Total != tt.total {
+				t.Fatalf("expected %v of %v, got %v of %v", tt.codes, tt.total, got, res.Total)
+			}
+			for i := range got {
+				if got[i] != tt.codes[i] {
+					t.Errorf("expected %v, got %v", tt.codes, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterCategories(t *testing.T) {
+	res := products.Filter(catalog, products.SearchQuery{Limit: 1})
+	if len(res.Products) != 1 || res.Total != len(catalog) {
+		t.Errorf("expected a page of 1 of %v, got %v of %v", len(catalog), len(res.Products), res.Total)
+	}
+	if len(res.Categories) != 3 || res.Categories[0] != "Bakery" || res.Categories[2] != "Grocery" {
+		t.Errorf("expected the categories of all matches, got %v", res.Categories)
+	}
+}
+
+func TestPriceAt(t *testing.T) {
+	p := products.StockMaster{TillPrice: 100, BranchPrices: map[string]float64{"NRB": 110, "MSA": 0}}
+	if p.PriceAt("NRB") != 110 || p.PriceAt("MSA") != 100 || p.PriceAt("KSM") != 100 {
+		t.Errorf("expected the branch price or the till price, got %v %v %v", p.PriceAt("NRB"), p.PriceAt("MSA"), p.PriceAt("KSM"))
+	}
+}
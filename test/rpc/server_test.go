@@ -0,0 +1,177 @@
+package rpc_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/api/rpc"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/events"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	pb "github.com/JohnnyKahiu/speedsales/poserver/proto/pos"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeReceipts serves receipts from a map
+// methods the tests don't use panic through the nil embedded interface
+type fakeReceipts struct {
+	sales.ReceiptRepository
+	receipts map[int64]sales.ReceiptLog
+}
+
+func (f fakeReceipts) Fetch(ctx context.Context, rcpt *sales.ReceiptLog) error {
+	r, ok := f.receipts[rcpt.ReceiptNum]
+	if !ok {
+		return fmt.Errorf("receipt %v not found", rcpt.ReceiptNum)
+	}
+	*rcpt = r
+	return nil
+}
+
+var users = map[string]logins.Users{
+	"teller": {Username: "JTELLER", Branch: "Main", TillNum: 1, MakeSales: true},
+	"guest":  {Username: "GUEST", Branch: "Main"},
+}
+
+func authenticate(ctx context.Context, token string) (logins.Users, error) {
+	user, ok := users[token]
+	if !ok {
+		return user, apperr.New(apperr.Unauthorized, "unauthorized")
+	}
+	return user, nil
+}
+
+func newServer() *rpc.Server {
+	svc := &sales.Service{
+		Receipts: fakeReceipts{receipts: map[int64]sales.ReceiptLog{
+			1001: {ReceiptNum: 1001, Branch: "Main", State: "pending", Total: money.New(120), Cart: []sales.Sales{{ItemCode: "1001", Quantity: 2, Price: money.New(60), Total: money.New(120)}}},
+			2001: {ReceiptNum: 2001, Branch: "Westlands", State: "pending"},
+		}},
+		Events: events.NewHub(),
+	}
+	return rpc.NewServer(svc)
+}
+
+func call(token, method string, fn func(ctx context.Context) (any, error)) (any, error) {
+	ctx := context.Background()
+	if token != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(rpc.TokenKey, token))
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+	return rpc.UnaryInterceptor(authenticate)(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+		return fn(ctx)
+	})
+}
+
+func TestFetchReceipt(t *testing.T) {
+	srv := newServer()
+
+	tests := []struct {
+		name    string
+		token   string
+		method  string
+		receipt int64
+		code    codes.Code
+		errCode string
+	}{
+		{"ok", "teller", pb.PosService_FetchReceipt_FullMethodName, 1001, codes.OK, ""},
+		{"no token", "", pb.PosService_FetchReceipt_FullMethodName, 1001, codes.Unauthenticated, "UNAUTHORIZED"},
+		{"forbidden", "guest", pb.PosService_FetchReceipt_FullMethodName, 1001, codes.PermissionDenied, "FORBIDDEN"},
+		{"undeclared method", "teller", "/pos.PosService/Refund", 1001, codes.PermissionDenied, "FORBIDDEN"},
+		{"null receipt", "teller", pb.PosService_FetchReceipt_FullMethodName, 0, codes.InvalidArgument, "VALIDATION_FAILED"},
+		{"other branch", "teller", pb.PosService_FetchReceipt_FullMethodName, 2001, codes.NotFound, "NOT_FOUND"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := call(tt.token, tt.method, func(ctx context.Context) (any, error) {
+				return srv.FetchReceipt(ctx, &pb.FetchReceiptRequest{ReceiptNum: tt.receipt})
+			})
+
+			if status.Code(err) != tt.code {
+				t.Fatalf("expected code %v, got %v", tt.code, err)
+			}
+			if tt.errCode != "" && !strings.HasPrefix(status.Convert(err).Message(), tt.errCode+":") {
+				t.Errorf("expected message prefixed with %v, got %v", tt.errCode, status.Convert(err).Message())
+			}
+			if err != nil {
+				return
+			}
+
+			rcpt := resp.(*pb.Receipt)
+			if rcpt.ReceiptNum != 1001 || len(rcpt.Lines) != 1 || rcpt.Total != 120 {
+				t.Errorf("unexpected receipt %+v", rcpt)
+			}
+		})
+	}
+}
+
+func TestValidatePermissions(t *testing.T) {
+	if err := rpc.ValidatePermissions(); err != nil {
+		t.Errorf("expected pos methods to declare permissions, got %v", err)
+	}
+}
+
+// eventStream collects the events sent on a till events stream
+type eventStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *pb.TillEvent
+}
+
+func (s *eventStream) Context() context.Context { return s.ctx }
+
+func (s *eventStream) Send(e *pb.TillEvent) error {
+	s.sent <- e
+	return nil
+}
+
+func TestStreamTillEvents(t *testing.T) {
+	srv := newServer()
+
+	ctx, cancel := context.WithCancel(metadata.NewIncomingContext(context.Background(), metadata.Pairs(rpc.TokenKey, "teller")))
+	defer cancel()
+
+	stream := &eventStream{ctx: ctx, sent: make(chan *pb.TillEvent, 1)}
+	info := &grpc.StreamServerInfo{FullMethod: pb.PosService_StreamTillEvents_FullMethodName}
+
+	done := make(chan error)
+	go func() {
+		done <- rpc.StreamInterceptor(authenticate)(srv, stream, info, func(srv any, ss grpc.ServerStream) error {
+			// generated handlers wrap ss to add Send, keeping the authorized context
+			out := &eventStream{ServerStream: ss, ctx: ss.Context(), sent: stream.sent}
+			return srv.(*rpc.Server).StreamTillEvents(&pb.StreamTillEventsRequest{TillNum: 1}, out)
+		})
+	}()
+
+	// publish until the subscription is in place
+	var e *pb.TillEvent
+	for e == nil {
+		srv.Events.Publish(events.Event{Type: events.CartItemAdded, Branch: "Westlands", TillNum: 1})
+		srv.Events.Publish(events.Event{Type: events.CartItemAdded, Branch: "Main", TillNum: 1, ReceiptNum: 1001})
+		select {
+		case e = <-stream.sent:
+		case err := <-done:
+			t.Fatalf("stream ended early: %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if e.Branch != "Main" || e.ReceiptNum != 1001 {
+		t.Errorf("expected event for receipt 1001 in Main, got %+v", e)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected stream to end cleanly, got %v", err)
+	}
+}
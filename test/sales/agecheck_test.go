@@ -0,0 +1,108 @@
+package sales_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
+)
+
+func TestAgeRestrictedCart(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	super := approver("SUPER")
+	super.GrantApproveSales = true
+	store.users["SUPER"] = super
+	store.products["8001"] = products.StockMaster{ItemCode: "8001", ItemName: "Lager 500ml", TillPrice: 250, VatAlpha: "A", MinAge: 18}
+
+	now := time.Now()
+	adult, minor := now.AddDate(-30, 0, 0), now.AddDate(-17, 0, 0)
+	birthday := now.AddDate(-18, 0, 0)
+
+	tests := []struct {
+		name     string
+		check    *sales.AgeCheck
+		approver string
+		err      apperr.Code
+	}{
+		{name: "no check", err: apperr.AgeCheckRequired},
+		{name: "of age", check: &sales.AgeCheck{BirthDate: &adult}},
+		{name: "eighteenth birthday", check: &sales.AgeCheck{BirthDate: &birthday}},
+		{name: "under age", check: &sales.AgeCheck{BirthDate: &minor}, err: apperr.Forbidden},
+		{name: "override", check: &sales.AgeCheck{Approver: "SUPER", ApToken: "1234"}, approver: "SUPER"},
+		{name: "wrong token", check: &sales.AgeCheck{Approver: "SUPER", ApToken: "0000"}, err: apperr.ApprovalRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := sales.Sales{ItemCode: "8001", Quantity: 1, AgeCheck: tt.check}
+			err := svc.AddCart(context.Background(), teller("JTELLER"), &item)
+			if tt.err != "" {
+				if !apperr.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error adding to cart: %s", err)
+			}
+
+			chk := item.AgeCheck
+			if chk.MinAge != 18 || chk.VerifiedBy != "JTELLER" || chk.Approver != tt.approver || chk.VerifiedAt.IsZero() {
+				t.Errorf("expected the check kept on the line, got %+v", chk)
+			}
+		})
+	}
+
+	// the restricted hours block the sale even with approval
+	svc.Settings = func() (variables.PosSettings, error) {
+		return variables.PosSettings{
+			AllowNegSale:   true,
+			RestrictedFrom: now.Add(-time.Hour).Format("15:04"),
+			RestrictedTo:   now.Add(time.Hour).Format("15:04"),
+		}, nil
+	}
+	item := sales.Sales{ItemCode: "8001", Quantity: 1, AgeCheck: &sales.AgeCheck{BirthDate: &adult}}
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &item); !apperr.Is(err, apperr.Forbidden) {
+		t.Errorf("expected FORBIDDEN during restricted hours, got %v", err)
+	}
+}
+
+func TestAgeRestrictedReport(t *testing.T) {
+	svc, store := newTestService()
+
+	posted := time.Date(2026, 10, 19, 20, 0, 0, 0, time.Local)
+	beer := func(approver string) sales.Sales {
+		return sales.Sales{ItemCode: "8001", Quantity: 1, Total: money.New(250), State: "pending", AgeCheck: &sales.AgeCheck{MinAge: 18, Approver: approver}}
+	}
+	store.receipts[1001] = &sales.ReceiptLog{ReceiptNum: 1001, Branch: "Main", Poster: "JTELLER", State: "POSTED", TransDate: posted,
+		Cart: []sales.Sales{beer(""), beer("SUPER"), {ItemCode: "1001", Total: money.New(50), State: "pending"}}}
+	store.receipts[1002] = &sales.ReceiptLog{ReceiptNum: 1002, Branch: "Main", Poster: "ATELLER", State: "POSTED", TransDate: posted,
+		Cart: []sales.Sales{beer("")}}
+	store.receipts[1003] = &sales.ReceiptLog{ReceiptNum: 1003, Branch: "Main", Poster: "ATELLER", State: "pending", TransDate: posted,
+		Cart: []sales.Sales{beer("SUPER")}}
+
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local)
+	report, err := svc.AgeRestrictedReport(context.Background(), "Main", day, day)
+	if err != nil {
+		t.Fatalf("error fetching report: %s", err)
+	}
+
+	want := []sales.AgeSales{
+		{Poster: "ATELLER", Lines: 1, Verified: 1, Total: money.New(250)},
+		{Poster: "JTELLER", Lines: 2, Verified: 1, Overrides: 1, Total: money.New(500)},
+	}
+	if len(report) != len(want) {
+		t.Fatalf("expected %+v, got %+v", want, report)
+	}
+	for i := range want {
+		if report[i] != want[i] {
+			t.Errorf("expected %+v, got %+v", want[i], report[i])
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package sales_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestReceiptRepositoryCloseBill(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	rcpt := sales.ReceiptLog{ReceiptNum: 1202610190012}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM salesorders\s+WHERE receipt_num = \$1 AND state = 'pending'`).
+		WithArgs(rcpt.ReceiptNum).
+		WillReturnRows(mock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE state in ('dispatched') AND receipt_num = $1`)).
+		WithArgs(rcpt.ReceiptNum).
+		WillReturnRows(mock.NewRows([]string{"order_items"}).
+			AddRow(`[{"item_code": "2001", "quantity": 2, "price": 150, "state": "pending"}]`).
+			AddRow(`[{"item_code": "2002", "quantity": 1, "price": 50, "state": "DELETED"}]`))
+	mock.ExpectQuery(`UPDATE salestrace`).
+		WithArgs(pgxmock.AnyArg(), money.New(300), "pending payment", rcpt.ReceiptNum).
+		WillReturnRows(mock.NewRows([]string{"poster"}).AddRow("WAITER"))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE salesorders SET state = 'paying' WHERE receipt_num = $1 AND state = 'dispatched'`)).
+		WithArgs(rcpt.ReceiptNum).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+	mock.ExpectCommit()
+	mock.ExpectRollback()
+
+	err = sales.NewReceiptRepository(mock).CloseBill(context.Background(), &rcpt)
+	if err != nil {
+		t.Fatalf("error was not expected while closing bill: %s", err)
+	}
+
+	if rcpt.Total != money.New(300) {
+		t.Errorf("expected total 300, got %v", rcpt.Total)
+	}
+	if rcpt.Poster != "WAITER" {
+		t.Errorf("expected poster WAITER, got %v", rcpt.Poster)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReceiptRepositoryCloseBillPendingOrders(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	rcpt := sales.ReceiptLog{ReceiptNum: 1202610190012}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM salesorders\s+WHERE receipt_num = \$1 AND state = 'pending'`).
+		WithArgs(rcpt.ReceiptNum).
+		WillReturnRows(mock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	err = sales.NewReceiptRepository(mock).CloseBill(context.Background(), &rcpt)
+	if err == nil {
+		t.Fatal("expected an error closing a bill with pending orders")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
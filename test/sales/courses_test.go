@@ -0,0 +1,209 @@
+package sales_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/events"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func burger() products.StockMaster {
+	return products.StockMaster{ItemCode: "3001", ItemName: "Burger", TillPrice: 350, ModifierGroups: []products.ModifierGroup{
+		{Name: "Cook", Min: 1, Max: 1, Options: []products.Modifier{{Name: "Medium"}, {Name: "Well done"}}},
+		{Name: "Extras", Options: []products.Modifier{{Name: "Cheese", Price: 50}, {Name: "No onions"}}},
+	}}
+}
+
+func TestOrderModifiers(t *testing.T) {
+	svc, store := newTestService()
+	store.products["3001"] = burger()
+
+	tests := []struct {
+		name      string
+		modifiers []sales.Modifier
+		price     money.Amount
+		err       apperr.Code
+	}{
+		{name: "priced extras", modifiers: []sales.Modifier{{Group: "cook", Name: "well done"}, {Group: "Extras", Name: "Cheese", Price: 1}, {Group: "Extras", Name: "No onions"}}, price: money.New(400)},
+		{name: "required group", modifiers: []sales.Modifier{{Group: "Extras", Name: "Cheese"}}, err: apperr.ValidationFailed},
+		{name: "too many", modifiers: []sales.Modifier{{Group: "Cook", Name: "Medium"}, {Group: "Cook", Name: "Well done"}}, err: apperr.ValidationFailed},
+		{name: "unknown option", modifiers: []sales.Modifier{{Group: "Cook", Name: "Raw"}}, err: apperr.ValidationFailed},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ord := sales.Order{ReceiptNum: int64(2000 + i), Poster: "WAITER", TillNum: 1}
+			item := sales.Sales{ItemCode: "3001", Quantity: 2, Note: "table by the window", Course: 2, Modifiers: tt.modifiers}
+			cart, _, err := svc.AddToOrder(context.Background(), &ord, item)
+			if tt.err != "" {
+				if !apperr.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error adding to order: %s", err)
+			}
+
+			line := cart[len(cart)-1]
+			if line.Price != tt.price || line.Total != tt.price.Mul(2) {
+				t.Errorf("expected price %v, got %v totalling %v", tt.price, line.Price, line.Total)
+			}
+			if line.Modifiers[1].Price != money.New(50) {
+				t.Errorf("expected cheese priced from the product, got %v", line.Modifiers[1].Price)
+			}
+			if line.Note != item.Note || line.Course != 2 {
+				t.Errorf("expected the note and course kept, got %q course %d", line.Note, line.Course)
+			}
+		})
+	}
+}
+
+func TestFireCourses(t *testing.T) {
+	svc, store := newTestService()
+	store.products["2001"] = products.StockMaster{ItemCode: "2001", ItemName: "Soup", TillPrice: 150}
+	store.products["3001"] = burger()
+
+	ord := sales.Order{ReceiptNum: 1001, Poster: "WAITER", Branch: "Main", TillNum: 1}
+	for _, item := range []sales.Sales{
+		{ItemCode: "2001", Quantity: 1},
+		{ItemCode: "3001", Quantity: 1, Course: 2, Modifiers: []sales.Modifier{{Group: "Cook", Name: "Medium"}}},
+	} {
+		if _, _, err := svc.AddToOrder(context.Background(), &ord, item); err != nil {
+			t.Fatalf("error adding to order: %s", err)
+		}
+	}
+
+	// courses are fired once the order is sent
+	if _, err := svc.FireCourse(context.Background(), &sales.Order{OrderNum: ord.OrderNum}, 2); !apperr.Is(err, apperr.PendingOrders) {
+		t.Fatalf("expected PENDING_ORDERS, got %v", err)
+	}
+
+	kitchen := func() sales.Order {
+		var sent sales.Order
+		if err := json.Unmarshal(store.published[fmt.Sprintf("sales_orders/%v", ord.OrderNum)], &sent); err != nil {
+			t.Fatalf("error reading kitchen message: %s", err)
+		}
+		return sent
+	}
+
+	// an order the kitchen didn't get isn't completed
+	state := store.orders[ord.OrderNum].State
+	store.publishErr = errors.New("broker down")
+	if _, err := svc.CompleteOrder(context.Background(), &sales.Order{OrderNum: ord.OrderNum}); err == nil {
+		t.Fatal("expected the failed publish to fail the order")
+	}
+	if o := store.orders[ord.OrderNum]; o.State != state || o.OrderItems[0].FiredAt != nil {
+		t.Fatalf("expected the order left %v with the soup held, got %v fired at %v", state, o.State, o.OrderItems[0].FiredAt)
+	}
+	store.publishErr = nil
+
+	if _, err := svc.CompleteOrder(context.Background(), &sales.Order{OrderNum: ord.OrderNum}); err != nil {
+		t.Fatalf("error completing order: %s", err)
+	}
+	if sent := kitchen(); len(sent.OrderItems) != 1 || sent.OrderItems[0].ItemCode != "2001" || sent.Course != sales.FirstCourse {
+		t.Fatalf("expected only the soup sent first, got %+v", sent)
+	}
+
+	// a course the kitchen didn't get stays held
+	store.publishErr = errors.New("broker down")
+	if _, err := svc.FireCourse(context.Background(), &sales.Order{OrderNum: ord.OrderNum}, 2); err == nil {
+		t.Fatal("expected the failed publish to fail the course")
+	}
+	if line := store.orders[ord.OrderNum].OrderItems[1]; line.FiredAt != nil {
+		t.Fatalf("expected the burger still held, got fired at %v", line.FiredAt)
+	}
+	store.publishErr = nil
+
+	ch, cancel := svc.Events.Subscribe(events.Scope{Branch: "Main"})
+	defer cancel()
+
+	fired, err := svc.FireCourse(context.Background(), &sales.Order{OrderNum: ord.OrderNum}, 2)
+	if err != nil {
+		t.Fatalf("error firing course: %s", err)
+	}
+	if len(fired) != 1 || fired[0].ItemCode != "3001" || fired[0].FiredAt == nil {
+		t.Errorf("expected the burger fired, got %+v", fired)
+	}
+	if sent := kitchen(); len(sent.OrderItems) != 1 || sent.OrderItems[0].Modifiers[0].Name != "Medium" || sent.Course != 2 {
+		t.Errorf("expected the burger and its modifiers sent, got %+v", sent)
+	}
+	if e := <-ch; e.Type != events.CourseFired || e.OrderNum != ord.OrderNum {
+		t.Errorf("expected %v event for order %v, got %v", events.CourseFired, ord.OrderNum, e)
+	}
+
+	// nothing is left to fire
+	if _, err := svc.FireCourse(context.Background(), &sales.Order{OrderNum: ord.OrderNum}, 3); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED, got %v", err)
+	}
+}
+
+func TestOrderFireSendFails(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	items := []sales.Sales{{ItemCode: "2001", Quantity: 1, State: "pending"}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM salesorders`).
+		WithArgs(int64(501)).
+		WillReturnRows(mock.NewRows([]string{"order_items", "state", "receipt_num", "branch", "till_num"}).AddRow(items, "ordered", int64(1001), "Main", int64(1)))
+	mock.ExpectExec(`UPDATE salesorders SET order_items`).
+		WithArgs(pgxmock.AnyArg(), int64(501)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectRollback()
+
+	ord := sales.Order{OrderNum: 501}
+	send := func([]sales.Sales) error { return errors.New("broker down") }
+	if _, err := ord.Fire(context.Background(), mock, sales.FirstCourse, send); err == nil {
+		t.Fatal("expected the failed send to fail the fire")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestOrderSendFails(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	items := []sales.Sales{{ItemCode: "2001", Quantity: 1, State: "pending"}}
+
+	// the order's new state is rolled back with its lines when the kitchen doesn't get them
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE salesorders\s+SET\s+state = \$2`).
+		WithArgs(int64(501), "ordered").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectQuery(`FROM salesorders`).
+		WithArgs(int64(501)).
+		WillReturnRows(mock.NewRows([]string{"order_items", "state", "receipt_num", "branch", "till_num"}).AddRow(items, "ordered", int64(1001), "Main", int64(1)))
+	mock.ExpectExec(`UPDATE salesorders SET order_items`).
+		WithArgs(pgxmock.AnyArg(), int64(501)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectRollback()
+
+	ord := sales.Order{OrderNum: 501, State: "ordered"}
+	send := func([]sales.Sales) error { return errors.New("broker down") }
+	if _, err := ord.Send(context.Background(), mock, send); err == nil {
+		t.Fatal("expected the failed send to fail the order")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,169 @@
+package sales_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/events"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestCustomerValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		customer sales.Customer
+		phone    string
+		pin      string
+		err      bool
+	}{
+		{name: "local phone", customer: sales.Customer{Name: " Jane ", Phone: "0712 345-678", KraPin: "a123456789z"}, phone: "254712345678", pin: "A123456789Z"},
+		{name: "international phone", customer: sales.Customer{Name: "Jane", Phone: "+254 712 345678"}, phone: "254712345678"},
+		{name: "no name", customer: sales.Customer{Phone: "0712345678"}, err: true},
+		{name: "short phone", customer: sales.Customer{Name: "Jane", Phone: "0712"}, err: true},
+		{name: "bad pin", customer: sales.Customer{Name: "Jane", Phone: "0712345678", KraPin: "12345"}, err: true},
+		{name: "bad email", customer: sales.Customer{Name: "Jane", Phone: "0712345678", Email: "jane.example.com"}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.customer.Validate()
+			if tt.err {
+				if !apperr.Is(err, apperr.ValidationFailed) {
+					t.Fatalf("expected VALIDATION_FAILED, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tt.customer.Phone != tt.phone || tt.customer.KraPin != tt.pin {
+				t.Errorf("expected phone %v and pin %q, got %v and %q", tt.phone, tt.pin, tt.customer.Phone, tt.customer.KraPin)
+			}
+		})
+	}
+}
+
+func TestAttachCustomer(t *testing.T) {
+	svc, store := newTestService()
+	store.receipts[1001] = &sales.ReceiptLog{ReceiptNum: 1001, TillNum: 1, Branch: "Main", State: "pending",
+		Cart: []sales.Sales{{ItemCode: "1001", Quantity: 1, Price: money.New(116), Total: money.New(116), VatAlpha: "A", VatPercent: 16, State: "pending"}}}
+
+	// quick-create from the till finds customers already on file by phone
+	jane := sales.Customer{Name: "Jane Wanjiru", Phone: "0712345678", KraPin: "A123456789Z"}
+	if err := svc.SaveCustomer(context.Background(), teller("JTELLER"), &jane, "", ""); err != nil {
+		t.Fatalf("error saving customer: %s", err)
+	}
+	again := sales.Customer{Name: "Jane W", Phone: "+254712345678"}
+	if err := svc.SaveCustomer(context.Background(), teller("JTELLER"), &again, "", ""); err != nil || again.ID != jane.ID || again.CreatedBy != "JTELLER" {
+		t.Fatalf("expected the existing customer %v, got %+v %v", jane.ID, again, err)
+	}
+	found, err := svc.SearchCustomers(context.Background(), "a123456789z", 0)
+	if err != nil || len(found) != 1 || found[0].ID != jane.ID {
+		t.Fatalf("expected jane found by PIN, got %+v %v", found, err)
+	}
+
+	ch, cancel := svc.Events.Subscribe(events.Scope{ReceiptNum: 1001})
+	defer cancel()
+
+	rcpt := sales.ReceiptLog{ReceiptNum: 1001}
+	if _, err := svc.AttachCustomer(context.Background(), teller("JTELLER"), &rcpt, jane.ID, "", ""); err != nil {
+		t.Fatalf("error attaching customer: %s", err)
+	}
+	if r := store.receipts[1001]; r.CustomerID != jane.ID || r.Etr.CustomerPin != "A123456789Z" || r.VatExempt {
+		t.Errorf("expected jane's PIN on the receipt, got customer %v pin %q exempt %v", r.CustomerID, r.Etr.CustomerPin, r.VatExempt)
+	}
+	select {
+	case e := <-ch:
+		if e.Type != events.CustomerAttached {
+			t.Errorf("expected %v event, got %v", events.CustomerAttached, e.Type)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected a customer attached event")
+	}
+
+	// a customer with an exemption certificate exempts the sale
+	sup := approver("SUPER")
+	sup.GrantVatExempt = true
+	store.users["SUPER"] = sup
+	exempter := teller("JTELLER")
+	exempter.VatExempt = true
+
+	school := sales.Customer{Name: "Hill School", Phone: "0722000111", ExemptCert: "EX-2026-01"}
+	if err := svc.SaveCustomer(context.Background(), teller("JTELLER"), &school, "", ""); !apperr.Is(err, apperr.ApprovalRequired) {
+		t.Fatalf("expected APPROVAL_REQUIRED setting a certificate, got %v", err)
+	}
+	if err := svc.SaveCustomer(context.Background(), teller("JTELLER"), &school, "SUPER", "1234"); err != nil {
+		t.Fatalf("error saving customer: %s", err)
+	}
+	if _, err := svc.AttachCustomer(context.Background(), teller("JTELLER"), &rcpt, school.ID, "", ""); !apperr.Is(err, apperr.ApprovalRequired) {
+		t.Fatalf("expected APPROVAL_REQUIRED applying the certificate, got %v", err)
+	}
+	if r := store.receipts[1001]; r.CustomerID != jane.ID || r.VatExempt {
+		t.Errorf("expected jane left on the receipt without exemption, got customer %v exempt %v", r.CustomerID, r.VatExempt)
+	}
+	if _, err := svc.AttachCustomer(context.Background(), exempter, &rcpt, school.ID, "", ""); err != nil {
+		t.Fatalf("error attaching customer: %s", err)
+	}
+	if r := store.receipts[1001]; r.CustomerID != school.ID || r.Etr.CustomerPin != "" || !r.VatExempt || r.VatExemptRef != "EX-2026-01" {
+		t.Errorf("expected the school's exemption on the receipt, got %+v", r)
+	}
+
+	if _, err := svc.AttachCustomer(context.Background(), teller("JTELLER"), &rcpt, 99, "", ""); !apperr.Is(err, apperr.NotFound) {
+		t.Errorf("expected NOT_FOUND for an unknown customer, got %v", err)
+	}
+
+	// the school's exemption goes with the school
+	if _, err := svc.AttachCustomer(context.Background(), teller("JTELLER"), &rcpt, jane.ID, "", ""); err != nil {
+		t.Fatalf("error changing customer: %s", err)
+	}
+	if r := store.receipts[1001]; r.CustomerID != jane.ID || r.VatExempt || r.VatExemptRef != "" {
+		t.Errorf("expected the exemption lifted for jane, got customer %v exempt %v %q", r.CustomerID, r.VatExempt, r.VatExemptRef)
+	}
+
+	if _, err := svc.AttachCustomer(context.Background(), teller("JTELLER"), &rcpt, school.ID, "SUPER", "1234"); err != nil {
+		t.Fatalf("error attaching customer: %s", err)
+	}
+	if _, err := svc.AttachCustomer(context.Background(), teller("JTELLER"), &rcpt, 0, "", ""); err != nil || store.receipts[1001].CustomerID != 0 {
+		t.Errorf("expected the customer detached, got %v", err)
+	}
+	if r := store.receipts[1001]; r.VatExempt {
+		t.Errorf("expected the exemption lifted with the school detached, got %q", r.VatExemptRef)
+	}
+
+	// an exemption given at the till stays when a customer is attached
+	if err := svc.SetVatExempt(context.Background(), exempter, &rcpt, true, "EX-TILL", "", ""); err != nil {
+		t.Fatalf("error exempting receipt: %s", err)
+	}
+	if _, err := svc.AttachCustomer(context.Background(), teller("JTELLER"), &rcpt, jane.ID, "", ""); err != nil {
+		t.Fatalf("error attaching customer: %s", err)
+	}
+	if r := store.receipts[1001]; !r.VatExempt || r.VatExemptRef != "EX-TILL" {
+		t.Errorf("expected the till's exemption kept, got %v %q", r.VatExempt, r.VatExemptRef)
+	}
+}
+
+func TestCustomerRepositoryDuplicatePhone(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	mock.ExpectQuery(`INSERT INTO customers`).
+		WithArgs("Jane", "254712345678", "", "", "", "JTELLER").
+		WillReturnError(&pgconn.PgError{Code: "23505"})
+
+	c := sales.Customer{Name: "Jane", Phone: "254712345678", CreatedBy: "JTELLER"}
+	if err := sales.NewCustomerRepository(mock).Save(context.Background(), &c); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED for a phone on file, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
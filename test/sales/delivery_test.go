@@ -0,0 +1,197 @@
+package sales_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+)
+
+func TestDeliveryCashOnDelivery(t *testing.T) {
+	svc, store := newTestService()
+	store.users["WAITER"] = teller("WAITER")
+	store.users["RIDER"] = teller("RIDER")
+	store.products["2001"] = products.StockMaster{ItemCode: "2001", ItemName: "Chips", TillPrice: 150, VatAlpha: "A"}
+
+	rcpt := sales.ReceiptLog{TillNum: 1, Branch: "Main", Poster: "WAITER", SaleType: "Cash Sale"}
+	if err := svc.GenReceipt(context.Background(), &rcpt); err != nil {
+		t.Fatalf("error generating receipt: %s", err)
+	}
+
+	if _, _, err := svc.AddToOrder(context.Background(), &sales.Order{ReceiptNum: rcpt.ReceiptNum, Poster: "WAITER", Channel: "drive_thru"}, sales.Sales{ItemCode: "2001", Quantity: 1}); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Fatalf("expected VALIDATION_FAILED for an unknown channel, got %v", err)
+	}
+	ord := sales.Order{ReceiptNum: rcpt.ReceiptNum, Poster: "WAITER", TillNum: 1, Channel: sales.ChannelTakeaway}
+	if _, _, err := svc.AddToOrder(context.Background(), &ord, sales.Sales{ItemCode: "2001", Quantity: 2}); err != nil {
+		t.Fatalf("error adding to order: %s", err)
+	}
+
+	if err := svc.BookDelivery(context.Background(), teller("WAITER"), &sales.Delivery{ReceiptNum: rcpt.ReceiptNum, Phone: "0722 000 111"}); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED without an address, got %v", err)
+	}
+	d := sales.Delivery{ReceiptNum: rcpt.ReceiptNum, Phone: "0722 000 111", Address: "Plot 4, Riverside", Fee: money.New(200)}
+	if err := svc.BookDelivery(context.Background(), teller("WAITER"), &d); err != nil {
+		t.Fatalf("error booking delivery: %s", err)
+	}
+	if d.Phone != "254722000111" || store.orders[ord.OrderNum].Channel != sales.ChannelDelivery {
+		t.Errorf("expected the phone tidied and the order out for delivery, got %v %v", d.Phone, store.orders[ord.OrderNum].Channel)
+	}
+
+	if _, err := svc.DeliveryStatus(context.Background(), teller("WAITER"), rcpt.ReceiptNum, sales.DeliveryPickedUp); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED picking up without a rider, got %v", err)
+	}
+	if _, err := svc.AssignRider(context.Background(), teller("WAITER"), rcpt.ReceiptNum, "NOBODY"); !apperr.Is(err, apperr.NotFound) {
+		t.Errorf("expected NOT_FOUND for an unknown rider, got %v", err)
+	}
+	away := teller("AWAY")
+	away.Branch = "Westlands"
+	store.users["AWAY"] = away
+	store.users["GUEST"] = logins.Users{Username: "GUEST", Branch: "Main"}
+	for _, rider := range []string{"AWAY", "GUEST"} {
+		if _, err := svc.AssignRider(context.Background(), teller("WAITER"), rcpt.ReceiptNum, rider); !apperr.Is(err, apperr.ValidationFailed) {
+			t.Errorf("expected VALIDATION_FAILED assigning %v, got %v", rider, err)
+		}
+	}
+	if _, err := svc.AssignRider(context.Background(), teller("WAITER"), rcpt.ReceiptNum, "RIDER"); err != nil {
+		t.Fatalf("error assigning rider: %s", err)
+	}
+
+	// the kitchen hasn't sent the order yet
+	if _, err := svc.DeliveryStatus(context.Background(), teller("WAITER"), rcpt.ReceiptNum, sales.DeliveryPickedUp); !apperr.Is(err, apperr.PendingOrders) {
+		t.Errorf("expected PENDING_ORDERS picking up an unsent order, got %v", err)
+	}
+	if _, err := svc.CompleteOrder(context.Background(), &sales.Order{OrderNum: ord.OrderNum}); err != nil {
+		t.Fatalf("error completing order: %s", err)
+	}
+
+	d, err := svc.DeliveryStatus(context.Background(), teller("WAITER"), rcpt.ReceiptNum, sales.DeliveryPickedUp)
+	if err != nil {
+		t.Fatalf("error picking up delivery: %s", err)
+	}
+	if d.CashDue != money.New(500) || d.PickedUpAt == nil {
+		t.Errorf("expected the rider to collect 500, got %v", d.CashDue)
+	}
+	if r := store.receipts[rcpt.ReceiptNum]; len(r.Cart) != 2 || r.Cart[1].ItemCode != sales.DeliveryFeeCode || r.Cart[1].Vat == 0 {
+		t.Errorf("expected the taxed delivery fee on the closed bill, got %+v", r.Cart)
+	}
+
+	cashier := teller("CASHIER")
+	cashier.TillNum = 2
+	cod := []sales.Payment{{Paymode: "cash", Amount: money.New(500)}}
+	if _, _, err := svc.SettleDelivery(context.Background(), cashier, rcpt.ReceiptNum, cod); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED settling before delivery, got %v", err)
+	}
+	if _, err := svc.DeliveryStatus(context.Background(), teller("WAITER"), rcpt.ReceiptNum, sales.DeliveryDelivered); err != nil {
+		t.Fatalf("error delivering: %s", err)
+	}
+	if open, _ := svc.OpenDeliveries(context.Background(), "Main"); len(open) != 1 {
+		t.Errorf("expected the delivery open until settled, got %v", len(open))
+	}
+
+	posted, d, err := svc.SettleDelivery(context.Background(), cashier, rcpt.ReceiptNum, cod)
+	if err != nil {
+		t.Fatalf("error settling delivery: %s", err)
+	}
+	if posted.State != "POSTED" || store.receipts[rcpt.ReceiptNum].PayTill != 2 || d.SettledTill != 2 {
+		t.Errorf("expected the receipt posted into till 2, got %v till %v", posted.State, store.receipts[rcpt.ReceiptNum].PayTill)
+	}
+	if open, _ := svc.OpenDeliveries(context.Background(), "Main"); len(open) != 0 {
+		t.Errorf("expected no open deliveries once settled, got %v", len(open))
+	}
+	if _, _, err := svc.SettleDelivery(context.Background(), cashier, rcpt.ReceiptNum, cod); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED settling twice, got %v", err)
+	}
+}
+
+func TestPrepaidDelivery(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.users["RIDER"] = teller("RIDER")
+	store.products["5001"] = cement()
+
+	item := sales.Sales{ItemCode: "5001", Quantity: 1}
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &item); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+
+	d := sales.Delivery{ReceiptNum: item.ReceiptNum, Phone: "254722000111", Address: "Site 9, Ruaka", Fee: money.New(300)}
+	if err := svc.BookDelivery(context.Background(), teller("JTELLER"), &d); err != nil {
+		t.Fatalf("error booking delivery: %s", err)
+	}
+	if err := svc.BookDelivery(context.Background(), teller("JTELLER"), &sales.Delivery{ReceiptNum: item.ReceiptNum, Phone: "254722000111", Address: "Site 9"}); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED booking twice, got %v", err)
+	}
+
+	// a bill without orders is charged the fee when booked
+	rcpt := sales.ReceiptLog{ReceiptNum: item.ReceiptNum}
+	if _, err := svc.ApplyPayment(context.Background(), &rcpt, sales.Payment{Paymode: "cash", Amount: money.New(1000)}); err != nil {
+		t.Fatalf("error paying: %s", err)
+	}
+	if err := svc.PostReceipt(context.Background(), &rcpt); err != nil {
+		t.Fatalf("error posting receipt: %s", err)
+	}
+	if rcpt.Total != money.New(1000) {
+		t.Errorf("expected 700 and the 300 fee paid, got %v", rcpt.Total)
+	}
+
+	if _, err := svc.AssignRider(context.Background(), teller("JTELLER"), item.ReceiptNum, "RIDER"); err != nil {
+		t.Fatalf("error assigning rider: %s", err)
+	}
+	d, err := svc.DeliveryStatus(context.Background(), teller("JTELLER"), item.ReceiptNum, sales.DeliveryPickedUp)
+	if err != nil {
+		t.Fatalf("error picking up delivery: %s", err)
+	}
+	if d.CashDue != 0 {
+		t.Errorf("expected nothing to collect on a prepaid delivery, got %v", d.CashDue)
+	}
+	if d, err = svc.DeliveryStatus(context.Background(), teller("JTELLER"), item.ReceiptNum, sales.DeliveryDelivered); err != nil || d.SettledAt == nil {
+		t.Errorf("expected the prepaid delivery settled when delivered, got %v %v", d.SettledAt, err)
+	}
+	if _, err := svc.DeliveryStatus(context.Background(), teller("JTELLER"), item.ReceiptNum, sales.DeliveryReturned); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED returning a delivered order, got %v", err)
+	}
+}
+
+func TestReturnedDelivery(t *testing.T) {
+	svc, store := newTestService()
+	store.users["WAITER"] = teller("WAITER")
+	store.users["RIDER"] = teller("RIDER")
+	store.products["2001"] = products.StockMaster{ItemCode: "2001", ItemName: "Chips", TillPrice: 150, VatAlpha: "A"}
+
+	rcpt := sales.ReceiptLog{TillNum: 1, Branch: "Main", Poster: "WAITER", SaleType: "Cash Sale"}
+	if err := svc.GenReceipt(context.Background(), &rcpt); err != nil {
+		t.Fatalf("error generating receipt: %s", err)
+	}
+	ord := sales.Order{ReceiptNum: rcpt.ReceiptNum, Poster: "WAITER", TillNum: 1}
+	if _, _, err := svc.AddToOrder(context.Background(), &ord, sales.Sales{ItemCode: "2001", Quantity: 2}); err != nil {
+		t.Fatalf("error adding to order: %s", err)
+	}
+	if err := svc.BookDelivery(context.Background(), teller("WAITER"), &sales.Delivery{ReceiptNum: rcpt.ReceiptNum, Phone: "0722000111", Address: "Plot 4", Fee: money.New(200)}); err != nil {
+		t.Fatalf("error booking delivery: %s", err)
+	}
+	if _, err := svc.CompleteOrder(context.Background(), &sales.Order{OrderNum: ord.OrderNum}); err != nil {
+		t.Fatalf("error completing order: %s", err)
+	}
+	if _, err := svc.AssignRider(context.Background(), teller("WAITER"), rcpt.ReceiptNum, "RIDER"); err != nil {
+		t.Fatalf("error assigning rider: %s", err)
+	}
+	if _, err := svc.DeliveryStatus(context.Background(), teller("WAITER"), rcpt.ReceiptNum, sales.DeliveryPickedUp); err != nil {
+		t.Fatalf("error picking up delivery: %s", err)
+	}
+
+	// the bill the customer didn't take is voided, not left open
+	d, err := svc.DeliveryStatus(context.Background(), teller("WAITER"), rcpt.ReceiptNum, sales.DeliveryReturned)
+	if err != nil {
+		t.Fatalf("error returning delivery: %s", err)
+	}
+	if d.CashDue != 0 || d.ReturnedAt == nil {
+		t.Errorf("expected nothing due on the returned delivery, got %v", d.CashDue)
+	}
+	if state := store.receipts[rcpt.ReceiptNum].State; state != "VOIDED" {
+		t.Errorf("expected the returned bill voided, got %v", state)
+	}
+}
@@ -0,0 +1,103 @@
+package sales_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
+)
+
+func TestDispensingChecks(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["5001"] = products.StockMaster{ItemCode: "5001", ItemName: "Amoxicillin 500mg", TillPrice: 300, VatAlpha: "A", TrackBatches: true, PrescriptionOnly: true}
+
+	now := time.Now()
+	today, lastWeek, nextYear := now, now.AddDate(0, 0, -7), now.AddDate(1, 0, 0)
+	rx := func() *sales.Prescription {
+		return &sales.Prescription{Number: "RX-1", Prescriber: "Dr. Otieno", Patient: "Jane Wanjiru"}
+	}
+
+	tests := []struct {
+		name   string
+		mode   string
+		batch  string
+		expiry *time.Time
+		rx     *sales.Prescription
+		err    apperr.Code
+	}{
+		{name: "dispensed", batch: "B12", expiry: &nextYear, rx: rx()},
+		{name: "expires today", batch: "B12", expiry: &today, rx: rx()},
+		{name: "no batch", expiry: &nextYear, rx: rx(), err: apperr.ValidationFailed},
+		{name: "no expiry", batch: "B12", rx: rx(), err: apperr.ValidationFailed},
+		{name: "expired", batch: "B09", expiry: &lastWeek, rx: rx(), err: apperr.BatchExpired},
+		{name: "no prescription", batch: "B12", expiry: &nextYear, err: apperr.ValidationFailed},
+		{name: "no patient", batch: "B12", expiry: &nextYear, rx: &sales.Prescription{Number: "RX-1", Prescriber: "Dr. Otieno"}, err: apperr.ValidationFailed},
+		{name: "not captured by the profile", mode: variables.Supermarket},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc.Profile = nil
+			if tt.mode != "" {
+				svc.Profile = func(ctx context.Context, branch string) (variables.Profile, error) {
+					return variables.Profiles[tt.mode], nil
+				}
+			}
+
+			item := sales.Sales{ItemCode: "5001", Quantity: 1, Batch: tt.batch, Expiry: tt.expiry, Prescription: tt.rx}
+			err := svc.AddCart(context.Background(), teller("JTELLER"), &item)
+			if tt.err != "" {
+				if !apperr.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error adding to cart: %s", err)
+			}
+		})
+	}
+}
+
+func TestDispensingRegister(t *testing.T) {
+	svc, store := newTestService()
+
+	expiry := time.Date(2027, 3, 31, 0, 0, 0, 0, time.Local)
+	posted := time.Date(2026, 10, 19, 10, 30, 0, 0, time.Local)
+	store.receipts[1001] = &sales.ReceiptLog{ReceiptNum: 1001, Branch: "Main", Poster: "JTELLER", State: "POSTED", TransDate: posted, Cart: []sales.Sales{
+		{ItemCode: "5001", ItemName: "Amoxicillin 500mg", Quantity: 2, State: "pending", Batch: "B12", Expiry: &expiry,
+			Prescription: &sales.Prescription{Number: "RX-1", Prescriber: "Dr. Otieno", Patient: "Jane Wanjiru"}},
+		{ItemCode: "1001", ItemName: "Bread", Quantity: 1, State: "pending"},
+	}}
+	store.receipts[1002] = &sales.ReceiptLog{ReceiptNum: 1002, Branch: "Main", State: "POSTED", TransDate: posted.AddDate(0, 0, 2),
+		Cart: []sales.Sales{{ItemCode: "5002", Quantity: 1, State: "pending", Batch: "C01", Expiry: &expiry}}}
+
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local)
+	register, err := svc.DispensingRegister(context.Background(), "Main", day, day)
+	if err != nil {
+		t.Fatalf("error fetching register: %s", err)
+	}
+	if len(register) != 1 || register[0].Batch != "B12" || register[0].Patient != "Jane Wanjiru" {
+		t.Fatalf("expected the amoxicillin line only, got %+v", register)
+	}
+
+	var buf bytes.Buffer
+	if err := sales.WriteRegister(&buf, register); err != nil {
+		t.Fatalf("error writing register: %s", err)
+	}
+	want := "2026-10-19 10:30:00,1001,Main,JTELLER,5001,Amoxicillin 500mg,2,B12,2027-03-31,RX-1,Dr. Otieno,Jane Wanjiru"
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 2 || lines[1] != want {
+		t.Errorf("expected csv row %q, got %q", want, buf.String())
+	}
+
+	if _, err := svc.DispensingRegister(context.Background(), "Main", day, day.AddDate(0, 0, -1)); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED for a register ending before it starts, got %v", err)
+	}
+}
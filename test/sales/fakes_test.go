@@ -0,0 +1,982 @@
+package sales_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/events"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
+)
+
+// memStore is an in-memory backing store for the sales repositories
+type memStore struct {
+	receipts    map[int64]*sales.ReceiptLog
+	orders      map[int64]*sales.Order
+	tills       []sales.Till
+	vouchers    map[string]sales.GiftVoucher
+	users       map[string]logins.Users
+	products    map[string]products.StockMaster
+	registered  map[string]int64
+	published   map[string][]byte
+	publishErr  error
+	payments    map[int64]map[string]money.Amount
+	promotions  []sales.Promotion
+	overrides   []sales.PriceOverride
+	discounts   map[int64]money.Amount
+	returns     map[int64]money.Amount
+	reserved    map[string]products.Reservation
+	tables      map[int64]*sales.DiningTable
+	floors      map[int64]*sales.Floor
+	customers   map[int64]*sales.Customer
+	quotes      map[int64]*sales.Quotation
+	deliveries  map[int64]*sales.Delivery
+	nextReceipt int64
+	nextOrder   int64
+}
+
+func newTestService() (*sales.Service, *memStore) {
+	m := &memStore{
+		receipts:    map[int64]*sales.ReceiptLog{},
+		orders:      map[int64]*sales.Order{},
+		vouchers:    map[string]sales.GiftVoucher{},
+		users:       map[string]logins.Users{},
+		products:    map[string]products.StockMaster{},
+		registered:  map[string]int64{},
+		published:   map[string][]byte{},
+		payments:    map[int64]map[string]money.Amount{},
+		discounts:   map[int64]money.Amount{},
+		returns:     map[int64]money.Amount{},
+		reserved:    map[string]products.Reservation{},
+		tables:      map[int64]*sales.DiningTable{},
+		floors:      map[int64]*sales.Floor{},
+		customers:   map[int64]*sales.Customer{},
+		quotes:      map[int64]*sales.Quotation{},
+		deliveries:  map[int64]*sales.Delivery{},
+		nextReceipt: 1000,
+		nextOrder:   500,
+	}
+
+	svc := &sales.Service{
+		Receipts:   fakeReceipts{m},
+		Orders:     fakeOrders{m},
+		Tills:      fakeTills{m},
+		Vouchers:   fakeVouchers{m},
+		Promotions: fakePromotions{m},
+		Catalog:    fakeCatalog{m},
+		Users:      fakeUsers{m},
+		Registrar:  fakeRegistrar{m},
+		Publisher:  fakePublisher{m},
+		Overrides:  fakeOverrides{m},
+		Stock:      fakeStock{m},
+		Tables:     fakeTables{m},
+		Customers:  fakeCustomers{m},
+		Quotes:     fakeQuotes{m},
+		Deliveries: fakeDeliveries{m},
+		Events:     events.NewHub(),
+		Settings: func() (variables.PosSettings, error) {
+			return variables.PosSettings{ApproveSales: true, Rollup: 10000, AllowNegSale: true}, nil
+		},
+		Taxes: func() (sales.TaxTable, error) {
+			return sales.TaxTable{Rates: map[string]float64{"A": 16, "C": 0, "E": 0}, Exempt: []string{"E"}}, nil
+		},
+	}
+	return svc, m
+}
+
+func teller(username string) logins.Users {
+	return logins.Users{Username: username, Branch: "Main", TillNum: 1, StkLocation: "shop", MakeSales: true, AcceptPayment: true}
+}
+
+func approver(username string) logins.Users {
+	return logins.Users{Username: username, CashRollups: true, Token: "1234", TokenDate: time.Now().Add(time.Hour)}
+}
+
+type fakeReceipts struct{ m *memStore }
+
+func (f fakeReceipts) Pending(ctx context.Context, rcpt *sales.ReceiptLog) error {
+	rcpt.ReceiptNum = 0
+	for num, r := range f.m.receipts {
+		if r.TillNum == rcpt.TillNum && (r.State == "pending" || r.State == "paying") && num > rcpt.ReceiptNum {
+			rcpt.ReceiptNum = num
+		}
+	}
+	return nil
+}
+
+func (f fakeReceipts) Create(ctx context.Context, rcpt *sales.ReceiptLog) error {
+	f.m.nextReceipt++
+	rcpt.ReceiptNum = f.m.nextReceipt
+	rcpt.State = "pending"
+	r := *rcpt
+	f.m.receipts[rcpt.ReceiptNum] = &r
+	return nil
+}
+
+func (f fakeReceipts) Fetch(ctx context.Context, rcpt *sales.ReceiptLog) error {
+	r, ok := f.m.receipts[rcpt.ReceiptNum]
+	if !ok {
+		return fmt.Errorf("receipt %v not found", rcpt.ReceiptNum)
+	}
+	*rcpt = *r
+	rcpt.Total = 0
+	rcpt.Cart = nil
+	for _, itm := range r.Cart {
+		if itm.State == "pending" {
+			rcpt.Total += itm.Total
+			rcpt.Cart = append(rcpt.Cart, itm)
+		}
+	}
+	rcpt.Promotions = sales.PromotionNames(rcpt.Cart)
+	rcpt.TaxSummary = sales.SummarizeTax(rcpt.Cart)
+	return nil
+}
+
+func (f fakeReceipts) FetchAll(ctx context.Context, rcpt *sales.ReceiptLog) error {
+	return f.Fetch(ctx, rcpt)
+}
+
+func (f fakeReceipts) ActiveCarts(ctx context.Context, tillNum int64) ([]sales.ReceiptLog, error) {
+	var vals []sales.ReceiptLog
+	for _, r := range f.m.receipts {
+		if r.TillNum == tillNum && r.State != "VOIDED" && r.State != "POSTED" {
+			vals = append(vals, *r)
+		}
+	}
+	return vals, nil
+}
+
+func (f fakeReceipts) AddItem(ctx context.Context, rcpt *sales.ReceiptLog, item sales.Sales) error {
+	r, ok := f.m.receipts[rcpt.ReceiptNum]
+	if !ok || (r.State != "pending" && r.State != "paying") {
+		return fmt.Errorf("receipt %v is not open", rcpt.ReceiptNum)
+	}
+	r.Cart = append(r.Cart, item)
+	r.Total += item.Total
+	return nil
+}
+
+func (f fakeReceipts) Reprice(ctx context.Context, rcpt *sales.ReceiptLog, fn func([]sales.Sales) []sales.Sales) error {
+	r, ok := f.m.receipts[rcpt.ReceiptNum]
+	if !ok || (r.State != "pending" && r.State != "paying" && r.State != "pending payment") {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open", rcpt.ReceiptNum))
+	}
+	rcpt.VatExempt = r.VatExempt
+	r.Cart = fn(r.Cart)
+	r.Total = 0
+	for _, itm := range r.Cart {
+		if itm.State == "pending" {
+			r.Total += itm.Total
+		}
+	}
+	rcpt.Cart = r.Cart
+	rcpt.Total = r.Total
+	rcpt.Promotions = sales.PromotionNames(r.Cart)
+	rcpt.TaxSummary = sales.SummarizeTax(r.Cart)
+	r.TaxSummary = rcpt.TaxSummary
+	return nil
+}
+
+func (f fakeReceipts) SetPayTill(ctx context.Context, rcpt *sales.ReceiptLog) error {
+	r, ok := f.m.receipts[rcpt.ReceiptNum]
+	if !ok || (r.State != "pending" && r.State != "paying" && r.State != "pending payment") {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open for payment", rcpt.ReceiptNum))
+	}
+	r.PayTill = rcpt.PayTill
+	return nil
+}
+
+func (f fakeReceipts) SetVatExempt(ctx context.Context, rcpt *sales.ReceiptLog) error {
+	r, ok := f.m.receipts[rcpt.ReceiptNum]
+	if !ok || (r.State != "pending" && r.State != "paying" && r.State != "pending payment") {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open", rcpt.ReceiptNum))
+	}
+	r.VatExempt = rcpt.VatExempt
+	r.VatExemptRef = rcpt.VatExemptRef
+	return nil
+}
+
+func (f fakeReceipts) SetCustomer(ctx context.Context, rcpt *sales.ReceiptLog) error {
+	r, ok := f.m.receipts[rcpt.ReceiptNum]
+	if !ok || !slices.Contains([]string{"pending", "suspend", "paying", "pending payment"}, r.State) {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open", rcpt.ReceiptNum))
+	}
+	r.CustomerID = rcpt.CustomerID
+	r.Etr.CustomerPin = rcpt.Etr.CustomerPin
+	return nil
+}
+
+func (f fakeReceipts) OpenQuantity(ctx context.Context, branch, itemCode string) (float64, error) {
+	qty := float64(0)
+	for _, r := range f.m.receipts {
+		if r.Branch != branch || (r.State != "pending" && r.State != "paying" && r.State != "pending payment" && r.State != "suspend") {
+			continue
+		}
+		for _, item := range r.Cart {
+			if item.ItemCode == itemCode && item.State == "pending" {
+				qty += item.Quantity
+			}
+		}
+	}
+	return qty, nil
+}
+
+func (f fakeReceipts) Dispensed(ctx context.Context, branch string, from, to time.Time) ([]sales.Dispensed, error) {
+	register := []sales.Dispensed{}
+	for _, r := range f.m.receipts {
+		if r.Branch != branch || r.State != "POSTED" || r.TransDate.Before(from) || !r.TransDate.Before(to) {
+			continue
+		}
+		for _, item := range r.Cart {
+			if item.State == "DELETED" || (item.Batch == "" && item.Prescription == nil) {
+				continue
+			}
+			d := sales.Dispensed{TransDate: r.TransDate, ReceiptNum: r.ReceiptNum, Branch: r.Branch, Poster: r.Poster,
+				ItemCode: item.ItemCode, ItemName: item.ItemName, Quantity: item.Quantity, Batch: item.Batch, Expiry: item.Expiry}
+			if item.Prescription != nil {
+				d.Prescription = *item.Prescription
+			}
+			register = append(register, d)
+		}
+	}
+	return register, nil
+}
+
+func (f fakeReceipts) SerialSales(ctx context.Context, serial string) ([]sales.SerialSale, error) {
+	var history []sales.SerialSale
+	for _, r := range f.m.receipts {
+		if r.State != "POSTED" {
+			continue
+		}
+		for _, item := range r.Cart {
+			if item.State != "DELETED" && slices.Contains(item.Serials, serial) {
+				history = append(history, sales.SerialSale{TransDate: r.TransDate, ReceiptNum: r.ReceiptNum, ReturnTrace: r.ReturnTrace,
+					Branch: r.Branch, Poster: r.Poster, ItemCode: item.ItemCode, ItemName: item.ItemName, Price: item.Price})
+			}
+		}
+	}
+	slices.SortFunc(history, func(a, b sales.SerialSale) int { return b.TransDate.Compare(a.TransDate) })
+	return history, nil
+}
+
+func (f fakeReceipts) Returned(ctx context.Context, receiptNum int64) (map[string]float64, error) {
+	returned := map[string]float64{}
+	for _, r := range f.m.receipts {
+		if r.ReturnTrace != receiptNum || r.State != "POSTED" {
+			continue
+		}
+		for _, item := range r.Cart {
+			returned[item.ReceiptItem] -= item.Quantity
+		}
+	}
+	return returned, nil
+}
+
+func (f fakeReceipts) Return(ctx context.Context, rcpt *sales.ReceiptLog) error {
+	orig, ok := f.m.receipts[rcpt.ReturnTrace]
+	if !ok || orig.State != "POSTED" {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("receipt %v isn't a posted sale", rcpt.ReturnTrace))
+	}
+	returned, _ := f.Returned(ctx, rcpt.ReturnTrace)
+	if err := sales.CheckReturnable(orig.Cart, returned, rcpt.Cart); err != nil {
+		return err
+	}
+
+	f.m.nextReceipt++
+	rcpt.ReceiptNum = f.m.nextReceipt
+	rcpt.State = "POSTED"
+	r := *rcpt
+	f.m.receipts[rcpt.ReceiptNum] = &r
+	f.m.returns[rcpt.TillNum] -= rcpt.Total
+	return nil
+}
+
+func (f fakeReceipts) AgeRestricted(ctx context.Context, branch string, from, to time.Time) ([]sales.AgeSales, error) {
+	byPoster := map[string]*sales.AgeSales{}
+	for _, r := range f.m.receipts {
+		if r.Branch != branch || r.State != "POSTED" || r.TransDate.Before(from) || !r.TransDate.Before(to) {
+			continue
+		}
+		for _, item := range r.Cart {
+			if item.State == "DELETED" || item.AgeCheck == nil {
+				continue
+			}
+			a, ok := byPoster[r.Poster]
+			if !ok {
+				a = &sales.AgeSales{Poster: r.Poster}
+				byPoster[r.Poster] = a
+			}
+			a.Lines++
+			if item.AgeCheck.Approver != "" {
+				a.Overrides++
+			} else {
+				a.Verified++
+			}
+			a.Total += item.Total
+		}
+	}
+
+	report := []sales.AgeSales{}
+	for _, a := range byPoster {
+		report = append(report, *a)
+	}
+	slices.SortFunc(report, func(a, b sales.AgeSales) int { return strings.Compare(a.Poster, b.Poster) })
+	return report, nil
+}
+
+func (f fakeReceipts) Suspend(ctx context.Context, tillNum int64) error {
+	for _, r := range f.m.receipts {
+		if r.TillNum == tillNum && r.State == "pending" && r.Cart != nil {
+			r.State = "suspend"
+		}
+	}
+	return nil
+}
+
+func (f fakeReceipts) NewBill(ctx context.Context, tillNum int64) error {
+	return f.Suspend(ctx, tillNum)
+}
+
+func (f fakeReceipts) Resume(ctx context.Context, rcpt *sales.ReceiptLog) error {
+	if r, ok := f.m.receipts[rcpt.ReceiptNum]; ok {
+		r.State = "pending"
+	}
+	return nil
+}
+
+func (f fakeReceipts) Merge(ctx context.Context, rcpt *sales.ReceiptLog, receipts []int64) error {
+	for _, num := range receipts {
+		for _, ord := range f.m.orders {
+			if ord.ReceiptNum == num {
+				ord.ReceiptNum = rcpt.ReceiptNum
+			}
+		}
+		if r, ok := f.m.receipts[num]; ok {
+			r.State = "VOIDED"
+		}
+	}
+	return nil
+}
+
+func (f fakeReceipts) Split(ctx context.Context, rcpt *sales.ReceiptLog, split sales.Split) ([]sales.ReceiptLog, error) {
+	r, ok := f.m.receipts[rcpt.ReceiptNum]
+	if !ok || (r.State != "pending" && r.State != "pending payment") || len(f.m.payments[r.ReceiptNum]) > 0 {
+		return nil, apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open", rcpt.ReceiptNum))
+	}
+
+	d, err := split.Divide(r.Cart)
+	if err != nil {
+		return nil, err
+	}
+
+	stay, parts := d.Apply(r.Cart)
+	r.Cart = stay
+	r.Total = sales.OrderTotal(stay)
+
+	receipts := make([]sales.ReceiptLog, len(parts))
+	for i, part := range parts {
+		n := sales.ReceiptLog{TillNum: r.TillNum, Branch: r.Branch, Poster: r.Poster}
+		f.Create(ctx, &n)
+		f.m.receipts[n.ReceiptNum].State = r.State
+		f.m.receipts[n.ReceiptNum].Cart = part
+		f.Fetch(ctx, &n)
+		receipts[i] = n
+	}
+
+	for _, ord := range f.orders(r.ReceiptNum) {
+		stay, parts := d.Apply(ord.OrderItems)
+		ord.OrderItems = stay
+		for i, part := range parts {
+			if len(part) > 0 {
+				f.m.nextOrder++
+				f.m.orders[f.m.nextOrder] = &sales.Order{OrderNum: f.m.nextOrder, ReceiptNum: receipts[i].ReceiptNum, State: ord.State, OrderItems: part}
+			}
+		}
+	}
+
+	return receipts, f.Fetch(ctx, rcpt)
+}
+
+func (f fakeReceipts) orders(receiptNum int64) []*sales.Order {
+	var vals []*sales.Order
+	for _, ord := range f.m.orders {
+		if ord.ReceiptNum == receiptNum {
+			vals = append(vals, ord)
+		}
+	}
+	return vals
+}
+
+func (f fakeReceipts) CloseBill(ctx context.Context, rcpt *sales.ReceiptLog) error {
+	r, ok := f.m.receipts[rcpt.ReceiptNum]
+	if !ok {
+		return fmt.Errorf("receipt %v not found", rcpt.ReceiptNum)
+	}
+
+	for _, ord := range f.m.orders {
+		if ord.ReceiptNum == rcpt.ReceiptNum && ord.State == "pending" {
+			return sales.ErrPendingOrders
+		}
+	}
+
+	r.Cart = nil
+	for _, ord := range f.m.orders {
+		if ord.ReceiptNum == rcpt.ReceiptNum && ord.State == "dispatched" {
+			r.Cart = append(r.Cart, ord.OrderItems...)
+			ord.State = "paying"
+		}
+	}
+	if r.Cart == nil {
+		return errors.New("error. ReceiptLog->UpdateCart()    null cart")
+	}
+
+	r.State = "pending payment"
+	r.Total = sales.OrderTotal(r.Cart)
+	*rcpt = *r
+	return nil
+}
+
+func (f fakeReceipts) Void(ctx context.Context, rcpt *sales.ReceiptLog) error {
+	r, ok := f.m.receipts[rcpt.ReceiptNum]
+	if !ok || slices.Contains([]string{"POSTED", "DEBITED", "CREDITED", "PAID", "AWAITING RECEIPT", "VOIDED"}, r.State) {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open", rcpt.ReceiptNum))
+	}
+	r.State = "VOIDED"
+	return nil
+}
+
+func (f fakeReceipts) Pay(ctx context.Context, rcpt *sales.ReceiptLog, pay sales.Payment) error {
+	r, ok := f.m.receipts[rcpt.ReceiptNum]
+	if !ok || (r.State != "pending" && r.State != "paying" && r.State != "pending payment") {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open for payment", rcpt.ReceiptNum))
+	}
+	if f.m.payments[r.ReceiptNum] == nil {
+		f.m.payments[r.ReceiptNum] = map[string]money.Amount{}
+	}
+	f.m.payments[r.ReceiptNum][pay.Paymode] += pay.Amount
+	if pay.Paymode == "cash" {
+		r.Cash += pay.Amount
+	}
+	r.State = "paying"
+	return nil
+}
+
+func (f fakeReceipts) Tendered(ctx context.Context, receiptNum int64) (money.Amount, error) {
+	tendered := money.Amount(0)
+	for _, amount := range f.m.payments[receiptNum] {
+		tendered += amount
+	}
+	return tendered, nil
+}
+
+func (f fakeReceipts) Post(ctx context.Context, rcpt *sales.ReceiptLog) error {
+	r, ok := f.m.receipts[rcpt.ReceiptNum]
+	if !ok || (r.State != "pending" && r.State != "paying" && r.State != "pending payment") {
+		return apperr.New(apperr.ReceiptNotOpen, fmt.Sprintf("receipt %v is not open for payment", rcpt.ReceiptNum))
+	}
+	r.State = "POSTED"
+	r.Total = rcpt.Total
+	r.Change = rcpt.Change
+	r.Rounding = rcpt.Rounding
+	r.TaxSummary = sales.SummarizeTax(rcpt.Cart)
+	for _, item := range rcpt.Cart {
+		if item.ItemCode == sales.RoundingCode {
+			r.Cart = append(r.Cart, item)
+		}
+	}
+	rcpt.State = "POSTED"
+	return nil
+}
+
+type fakeOrders struct{ m *memStore }
+
+func (f fakeOrders) Items(ctx context.Context, ord *sales.Order) error {
+	o, ok := f.m.orders[ord.OrderNum]
+	if !ok {
+		ord.OrderItems = []sales.Sales{}
+		return nil
+	}
+	ord.OrderItems = append([]sales.Sales{}, o.OrderItems...)
+	ord.ReceiptNum = o.ReceiptNum
+	ord.Branch = o.Branch
+	ord.TillNum = o.TillNum
+	return nil
+}
+
+func (f fakeOrders) AddItem(ctx context.Context, ord *sales.Order, item sales.Sales) ([]sales.Sales, money.Amount, error) {
+	var o *sales.Order
+	for _, v := range f.m.orders {
+		if v.State == "pending" && v.ReceiptNum == ord.ReceiptNum && v.Poster == ord.Poster {
+			o = v
+		}
+	}
+	if o == nil {
+		f.m.nextOrder++
+		o = &sales.Order{OrderNum: f.m.nextOrder, ReceiptNum: ord.ReceiptNum, Poster: ord.Poster, Branch: ord.Branch, TillNum: ord.TillNum, State: "pending", Channel: ord.Channel}
+		f.m.orders[o.OrderNum] = o
+	}
+	ord.OrderNum = o.OrderNum
+
+	item.ReceiptItem = fmt.Sprintf("%v-%v", o.OrderNum, len(o.OrderItems)+1)
+	if item.State == "" {
+		item.State = "pending"
+	}
+	o.OrderItems = append(o.OrderItems, item)
+	return o.OrderItems, sales.OrderTotal(o.OrderItems), nil
+}
+
+func (f fakeOrders) DeleteItem(ctx context.Context, orderNum int64, receiptItem string) ([]sales.Sales, money.Amount, error) {
+	o, ok := f.m.orders[orderNum]
+	if !ok {
+		return nil, 0, fmt.Errorf("order %v not found", orderNum)
+	}
+	for i, itm := range o.OrderItems {
+		if itm.ReceiptItem == receiptItem && itm.State == "pending" {
+			o.OrderItems[i].State = "DELETED"
+		}
+	}
+	return o.OrderItems, sales.OrderTotal(o.OrderItems), nil
+}
+
+func (f fakeOrders) Complete(ctx context.Context, ord *sales.Order, send func([]sales.Sales) error) ([]sales.Sales, error) {
+	o, ok := f.m.orders[ord.OrderNum]
+	if !ok {
+		return nil, fmt.Errorf("order %v not found", ord.OrderNum)
+	}
+	state := o.State
+	o.State = ord.State
+	fired, err := f.Fire(ctx, ord, sales.FirstCourse, send)
+	if err != nil {
+		o.State = state
+	}
+	return fired, err
+}
+
+func (f fakeOrders) Fire(ctx context.Context, ord *sales.Order, course int, send func([]sales.Sales) error) ([]sales.Sales, error) {
+	o, ok := f.m.orders[ord.OrderNum]
+	if !ok {
+		return nil, apperr.New(apperr.NotFound, fmt.Sprintf("order %v not found", ord.OrderNum))
+	}
+	if o.State == "pending" {
+		return nil, apperr.New(apperr.PendingOrders, fmt.Sprintf("order %v hasn't been completed", ord.OrderNum))
+	}
+	ord.ReceiptNum, ord.Branch, ord.TillNum = o.ReceiptNum, o.Branch, o.TillNum
+
+	// lines are marked fired only once they're sent
+	items := slices.Clone(o.OrderItems)
+	fired := sales.FireLines(items, course, time.Now())
+	if len(fired) == 0 {
+		return nil, nil
+	}
+	if err := send(fired); err != nil {
+		return nil, err
+	}
+	o.OrderItems = items
+	return fired, nil
+}
+
+func (f fakeOrders) Voucher(ctx context.Context, orderNum int64) ([]sales.OrderItem, error) {
+	var vals []sales.OrderItem
+	for _, itm := range f.m.orders[orderNum].OrderItems {
+		if itm.State == "pending" {
+			vals = append(vals, sales.OrderItem{ItemName: itm.ItemName, Quantity: itm.Quantity, Price: itm.Price, Total: itm.Price.Mul(itm.Quantity)})
+		}
+	}
+	return vals, nil
+}
+
+func (f fakeOrders) OrdersInBill(ctx context.Context, receiptNum int64) ([]sales.Order, money.Amount, error) {
+	var vals []sales.Order
+	total := money.Amount(0)
+	for _, o := range f.m.orders {
+		if o.ReceiptNum == receiptNum {
+			vals = append(vals, *o)
+			total += sales.OrderTotal(o.OrderItems)
+		}
+	}
+	return vals, total, nil
+}
+
+func (f fakeOrders) SetChannel(ctx context.Context, receiptNum int64, channel string) error {
+	for _, o := range f.m.orders {
+		if o.ReceiptNum == receiptNum {
+			o.Channel = channel
+		}
+	}
+	return nil
+}
+
+func (f fakeOrders) ActiveOrders(ctx context.Context, poster string) ([]sales.Order, error) {
+	var vals []sales.Order
+	for _, o := range f.m.orders {
+		if o.Poster == poster {
+			vals = append(vals, *o)
+		}
+	}
+	return vals, nil
+}
+
+type fakeTills struct{ m *memStore }
+
+func (f fakeTills) Open(ctx context.Context, till *sales.Till) error {
+	for _, t := range f.m.tills {
+		if t.Teller == till.Teller {
+			till.TillNO = t.TillNO
+			return nil
+		}
+	}
+	till.TillNO = int64(len(f.m.tills) + 1)
+	f.m.tills = append(f.m.tills, *till)
+	return nil
+}
+
+func (f fakeTills) CashInTill(ctx context.Context, tillNum int64) (money.Amount, error) {
+	return 0, nil
+}
+
+func (f fakeTills) AddDiscount(ctx context.Context, tillNum int64, amount money.Amount) error {
+	f.m.discounts[tillNum] += amount
+	return nil
+}
+
+type fakeOverrides struct{ m *memStore }
+
+func (f fakeOverrides) Apply(ctx context.Context, rcpt *sales.ReceiptLog, o *sales.PriceOverride, fn func([]sales.Sales) []sales.Sales) error {
+	if err := (fakeReceipts{f.m}).Reprice(ctx, rcpt, fn); err != nil {
+		return err
+	}
+	o.ID = int64(len(f.m.overrides) + 1)
+	f.m.overrides = append(f.m.overrides, *o)
+	return nil
+}
+
+type fakeVouchers struct{ m *memStore }
+
+func (f fakeVouchers) Create(ctx context.Context, v *sales.GiftVoucher) error {
+	f.m.vouchers[v.Serial] = *v
+	return nil
+}
+
+func (f fakeVouchers) Fetch(ctx context.Context, serial string) (sales.GiftVoucher, error) {
+	v, ok := f.m.vouchers[serial]
+	if !ok {
+		return sales.GiftVoucher{}, fmt.Errorf("gift voucher %v not found", serial)
+	}
+	return v, nil
+}
+
+type fakePromotions struct{ m *memStore }
+
+func (f fakePromotions) Active(ctx context.Context, branch string, at time.Time) ([]sales.Promotion, error) {
+	return f.m.promotions, nil
+}
+
+type fakeCatalog struct{ m *memStore }
+
+func (f fakeCatalog) Fetch(ctx context.Context, itemCode string) (products.StockMaster, error) {
+	p, ok := f.m.products[itemCode]
+	if !ok {
+		return products.StockMaster{}, fmt.Errorf("product %v not found", itemCode)
+	}
+	return p, nil
+}
+
+func (f fakeCatalog) Search(ctx context.Context, q products.SearchQuery) (products.SearchResult, error) {
+	var catalog []products.StockMaster
+	for _, p := range f.m.products {
+		catalog = append(catalog, p)
+	}
+	return products.Filter(catalog, q), nil
+}
+
+type fakeStock struct{ m *memStore }
+
+func (f fakeStock) Reserve(ctx context.Context, r products.Reservation) (bool, error) {
+	if _, ok := f.m.reserved[r.Reference]; ok {
+		return false, nil
+	}
+	f.m.reserved[r.Reference] = r
+	return true, nil
+}
+
+func (f fakeStock) Release(ctx context.Context, reference string, lines []products.StockLine) error {
+	r, ok := f.m.reserved[reference]
+	if !ok || len(lines) == 0 {
+		delete(f.m.reserved, reference)
+		return nil
+	}
+	for _, l := range lines {
+		for i := range r.Lines {
+			if r.Lines[i].ItemCode == l.ItemCode {
+				r.Lines[i].Quantity -= l.Quantity
+			}
+		}
+	}
+	f.m.reserved[reference] = r
+	return nil
+}
+
+type fakeTables struct{ m *memStore }
+
+func (f fakeTables) SaveFloor(ctx context.Context, fl *sales.Floor) error {
+	if fl.ID == 0 {
+		fl.ID = int64(len(f.m.floors) + 1)
+	}
+	c := *fl
+	f.m.floors[fl.ID] = &c
+	return nil
+}
+
+func (f fakeTables) SaveTable(ctx context.Context, t *sales.DiningTable) error {
+	if _, ok := f.m.floors[t.FloorID]; !ok {
+		return apperr.New(apperr.NotFound, fmt.Sprintf("floor %v not found", t.FloorID))
+	}
+	if t.ID == 0 {
+		t.ID = int64(len(f.m.tables) + 1)
+		t.SeatedAt = time.Now()
+	}
+	c := *t
+	f.m.tables[t.ID] = &c
+	return nil
+}
+
+func (f fakeTables) Floors(ctx context.Context, branch string) ([]sales.Floor, error) {
+	var floors []sales.Floor
+	for _, fl := range f.m.floors {
+		if fl.Branch != branch {
+			continue
+		}
+		c := *fl
+		for _, t := range f.m.tables {
+			if t.FloorID == fl.ID {
+				c.Tables = append(c.Tables, *t)
+			}
+		}
+		floors = append(floors, c)
+	}
+	return floors, nil
+}
+
+func (f fakeTables) Table(ctx context.Context, id int64) (sales.DiningTable, error) {
+	t, ok := f.m.tables[id]
+	if !ok {
+		return sales.DiningTable{}, apperr.New(apperr.NotFound, fmt.Sprintf("table %v not found", id))
+	}
+	return *t, nil
+}
+
+func (f fakeTables) Seat(ctx context.Context, t *sales.DiningTable) error {
+	cur := f.m.tables[t.ID]
+	if r, ok := f.m.receipts[cur.ReceiptNum]; ok && cur.ReceiptNum != 0 && r.State != "POSTED" && r.State != "VOIDED" {
+		return apperr.New(apperr.TableOccupied, fmt.Sprintf("table %v is occupied", t.Name))
+	}
+	t.SeatedAt = time.Now()
+	cur.ReceiptNum, cur.Waiter, cur.Guests, cur.SeatedAt = t.ReceiptNum, t.Waiter, t.Guests, t.SeatedAt
+	return nil
+}
+
+func (f fakeTables) Transfer(ctx context.Context, t *sales.DiningTable, waiter string) error {
+	f.m.tables[t.ID].Waiter = waiter
+	if r, ok := f.m.receipts[t.ReceiptNum]; ok {
+		r.Poster = waiter
+	}
+	t.Waiter = waiter
+	return nil
+}
+
+func (f fakeTables) Release(ctx context.Context, receipts []int64) ([]sales.DiningTable, error) {
+	var freed []sales.DiningTable
+	for _, t := range f.m.tables {
+		if t.ReceiptNum != 0 && slices.Contains(receipts, t.ReceiptNum) {
+			t.ReceiptNum, t.Waiter, t.Guests = 0, "", 0
+			freed = append(freed, *t)
+		}
+	}
+	return freed, nil
+}
+
+func (f fakeTables) FloorState(ctx context.Context, branch string, floorID int64) ([]sales.TableState, error) {
+	var states []sales.TableState
+	for _, t := range f.m.tables {
+		if t.Branch != branch || (floorID != 0 && t.FloorID != floorID) {
+			continue
+		}
+		st := sales.TableState{DiningTable: *t}
+		if r, ok := f.m.receipts[t.ReceiptNum]; ok && t.ReceiptNum != 0 {
+			st.BillState = r.State
+			st.BillUpdated = r.LastUpdated
+		}
+		for _, o := range f.m.orders {
+			if o.ReceiptNum == t.ReceiptNum && t.ReceiptNum != 0 && o.State == "ordered" {
+				if st.KitchenSince == nil || o.CompleteTime.Before(*st.KitchenSince) {
+					st.KitchenSince = &o.CompleteTime
+				}
+			}
+		}
+		states = append(states, st)
+	}
+	return states, nil
+}
+
+type fakeUsers struct{ m *memStore }
+
+func (f fakeUsers) FetchUser(ctx context.Context, username string) (logins.Users, error) {
+	u, ok := f.m.users[username]
+	if !ok {
+		return logins.Users{}, fmt.Errorf("user %v not found", username)
+	}
+	return u, nil
+}
+
+type fakeRegistrar struct{ m *memStore }
+
+func (f fakeRegistrar) UpdateTill(ctx context.Context, username string, tillNum int64) error {
+	f.m.registered[username] = tillNum
+	return nil
+}
+
+type fakePublisher struct{ m *memStore }
+
+func (f fakePublisher) Publish(ctx context.Context, topic, key string, payload []byte) error {
+	if f.m.publishErr != nil {
+		return f.m.publishErr
+	}
+	f.m.published[topic+"/"+key] = payload
+	return nil
+}
+
+type fakeCustomers struct{ m *memStore }
+
+func (f fakeCustomers) Save(ctx context.Context, c *sales.Customer) error {
+	for _, other := range f.m.customers {
+		if other.Phone == c.Phone && other.ID != c.ID {
+			return apperr.New(apperr.ValidationFailed, fmt.Sprintf("phone %v belongs to another customer", c.Phone))
+		}
+	}
+	if c.ID == 0 {
+		c.ID = int64(len(f.m.customers) + 1)
+		c.CreatedAt = time.Now()
+	} else if _, ok := f.m.customers[c.ID]; !ok {
+		return apperr.New(apperr.NotFound, fmt.Sprintf("customer %v not found", c.ID))
+	}
+	c.UpdatedAt = time.Now()
+	saved := *c
+	f.m.customers[c.ID] = &saved
+	return nil
+}
+
+func (f fakeCustomers) Fetch(ctx context.Context, id int64, phone string) (sales.Customer, error) {
+	for _, c := range f.m.customers {
+		if c.ID == id || (id == 0 && c.Phone == phone) {
+			return *c, nil
+		}
+	}
+	return sales.Customer{}, apperr.New(apperr.NotFound, "customer not found")
+}
+
+func (f fakeCustomers) Search(ctx context.Context, q string, limit int) ([]sales.Customer, error) {
+	customers := []sales.Customer{}
+	for _, c := range f.m.customers {
+		if strings.Contains(strings.ToLower(c.Name), strings.ToLower(q)) || c.KraPin == strings.ToUpper(q) {
+			customers = append(customers, *c)
+		}
+	}
+	slices.SortFunc(customers, func(a, b sales.Customer) int { return strings.Compare(a.Name, b.Name) })
+	return customers[:min(limit, len(customers))], nil
+}
+
+type fakeQuotes struct{ m *memStore }
+
+func (f fakeQuotes) Create(ctx context.Context, q *sales.Quotation) error {
+	q.QuoteNum = int64(len(f.m.quotes) + 1)
+	q.TransDate = time.Now()
+	q.State = sales.QuoteOpen
+	q.Items = []sales.Sales{}
+	saved := *q
+	f.m.quotes[q.QuoteNum] = &saved
+	return nil
+}
+
+func (f fakeQuotes) Fetch(ctx context.Context, q *sales.Quotation) error {
+	saved, ok := f.m.quotes[q.QuoteNum]
+	if !ok {
+		return apperr.New(apperr.NotFound, fmt.Sprintf("quotation %v not found", q.QuoteNum))
+	}
+	*q = *saved
+	q.Items = slices.Clone(saved.Items)
+	return nil
+}
+
+func (f fakeQuotes) Edit(ctx context.Context, q *sales.Quotation, fn func([]sales.Sales) []sales.Sales) error {
+	saved, ok := f.m.quotes[q.QuoteNum]
+	if !ok || saved.State != sales.QuoteOpen {
+		return apperr.New(apperr.QuoteClosed, fmt.Sprintf("quotation %v is not open", q.QuoteNum))
+	}
+	saved.Items = fn(slices.Clone(saved.Items))
+	saved.Total = 0
+	for _, item := range saved.Items {
+		if item.State == "pending" {
+			saved.Total += item.Total
+		}
+	}
+	saved.TaxSummary = sales.SummarizeTax(saved.Items)
+	q.Items, q.Total, q.TaxSummary = slices.Clone(saved.Items), saved.Total, saved.TaxSummary
+	return nil
+}
+
+func (f fakeQuotes) Close(ctx context.Context, q *sales.Quotation) error {
+	saved, ok := f.m.quotes[q.QuoteNum]
+	if !ok || saved.State != sales.QuoteOpen {
+		return apperr.New(apperr.QuoteClosed, fmt.Sprintf("quotation %v is not open", q.QuoteNum))
+	}
+	saved.State, saved.ReceiptNum = q.State, q.ReceiptNum
+	return nil
+}
+
+type fakeDeliveries struct{ m *memStore }
+
+func (f fakeDeliveries) Create(ctx context.Context, d *sales.Delivery) error {
+	if _, ok := f.m.deliveries[d.ReceiptNum]; ok {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("receipt %v is already booked for delivery", d.ReceiptNum))
+	}
+	d.State = sales.DeliveryPending
+	d.CreatedAt = time.Now()
+	saved := *d
+	f.m.deliveries[d.ReceiptNum] = &saved
+	return nil
+}
+
+func (f fakeDeliveries) Fetch(ctx context.Context, receiptNum int64) (sales.Delivery, error) {
+	d, ok := f.m.deliveries[receiptNum]
+	if !ok {
+		return sales.Delivery{}, apperr.New(apperr.NotFound, fmt.Sprintf("receipt %v is not booked for delivery", receiptNum))
+	}
+	return *d, nil
+}
+
+func (f fakeDeliveries) Update(ctx context.Context, d *sales.Delivery, from string) error {
+	saved, ok := f.m.deliveries[d.ReceiptNum]
+	if !ok || saved.State != from {
+		return apperr.New(apperr.ValidationFailed, fmt.Sprintf("the delivery of receipt %v is no longer %v", d.ReceiptNum, from))
+	}
+	*saved = *d
+	return nil
+}
+
+func (f fakeDeliveries) Open(ctx context.Context, branch string) ([]sales.Delivery, error) {
+	vals := []sales.Delivery{}
+	for _, d := range f.m.deliveries {
+		if d.Branch == branch && (d.State != sales.DeliveryReturned && (d.State != sales.DeliveryDelivered || d.SettledAt == nil)) {
+			vals = append(vals, *d)
+		}
+	}
+	return vals, nil
+}
@@ -1,8 +1,11 @@
 package sales_test
 
 import (
+	"context"
+	"regexp"
 	"testing"
 
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
 	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
 	"github.com/pashagolub/pgxmock/v4"
 )
@@ -23,12 +26,18 @@ func TestOpenTill(t *testing.T) {
 	}
 
 	// expectation
-	mock.ExpectQuery(`INSERT INTO sales_till(open_float, teller, supervisor, branch) VALUES ($1, $2, $3, $4) RETURNING till_no`).
-		WithArgs(arg.OpenFloat, arg.Teller, arg.Supervisor, arg.Branch).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT daily_id, till_no FROM sales_till WHERE teller = $1 AND close_time IS NULL`)).
+		WithArgs(arg.Teller).
+		WillReturnRows(mock.NewRows([]string{"daily_id", "till_no"}))
+	mock.ExpectQuery(`FROM sales_till`).
+		WithArgs(arg.Branch).
+		WillReturnRows(mock.NewRows([]string{"daily_id"}).AddRow(int64(1)))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO sales_till (till_no, open_float, teller, supervisor, branch)`)).
+		WithArgs(pgxmock.AnyArg(), arg.OpenFloat, arg.Teller, arg.Supervisor, arg.Branch).
 		WillReturnRows(mock.NewRows([]string{"till_no"}).AddRow(int64(1)))
 
 	// execution
-	err = arg.OpenTill(mock)
+	err = sales.NewTillRepository(mock).Open(context.Background(), &arg)
 
 	// validation
 	if err != nil {
@@ -44,3 +53,59 @@ func TestOpenTill(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestOpenTillExisting(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	arg := sales.Till{Teller: "JTELLER", Supervisor: "Admin", Branch: "Main"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT daily_id, till_no FROM sales_till WHERE teller = $1 AND close_time IS NULL`)).
+		WithArgs(arg.Teller).
+		WillReturnRows(mock.NewRows([]string{"daily_id", "till_no"}).AddRow(int64(2), int64(2026101902)))
+
+	err = sales.NewTillRepository(mock).Open(context.Background(), &arg)
+	if err != nil {
+		t.Errorf("error was not expected while open till: %s", err)
+	}
+
+	if arg.TillNO != 2026101902 {
+		t.Errorf("expected existing till number, got %d", arg.TillNO)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestServiceOpenTill(t *testing.T) {
+	svc, store := newTestService()
+	store.users["Admin"] = approver("Admin")
+
+	teller := teller("JTELLER")
+	till, err := svc.OpenTill(context.Background(), teller, "Admin", "1234")
+	if err != nil {
+		t.Fatalf("error was not expected while open till: %s", err)
+	}
+
+	if store.registered["JTELLER"] != till.TillNO {
+		t.Errorf("expected till %d registered to teller, got %d", till.TillNO, store.registered["JTELLER"])
+	}
+}
+
+func TestServiceOpenTillBadToken(t *testing.T) {
+	svc, store := newTestService()
+	store.users["Admin"] = approver("Admin")
+
+	_, err := svc.OpenTill(context.Background(), teller("JTELLER"), "Admin", "wrong")
+	if !apperr.Is(err, apperr.ApprovalRequired) {
+		t.Fatalf("expected an approval error for a wrong approval token, got %v", err)
+	}
+
+	if len(store.tills) != 0 {
+		t.Errorf("expected no till opened, got %d", len(store.tills))
+	}
+}
@@ -0,0 +1,208 @@
+package sales_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/logins"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// cashierCart starts a receipt with 2 loaves at 100 and a cashier allowed 10% discounts
+func cashierCart(t *testing.T) (*sales.Service, *memStore, logins.Users, sales.Sales) {
+	svc, store := newTestService()
+	svc.Settings = func() (variables.PosSettings, error) {
+		return variables.PosSettings{DiscountLimits: map[string]float64{"cashier": 10}, AllowNegSale: true}, nil
+	}
+
+	user := teller("JTELLER")
+	user.Role = "cashier"
+	user.PriceChange = true
+	store.users["JTELLER"] = user
+
+	sup := approver("SUPER")
+	sup.GrantPriceChange = true
+	store.users["SUPER"] = sup
+
+	store.products["1001"] = products.StockMaster{ItemCode: "1001", ItemName: "Bread", TillPrice: 100, VatPercent: 16}
+
+	item := sales.Sales{ItemCode: "1001", Quantity: 2}
+	if err := svc.AddCart(context.Background(), user, &item); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+	return svc, store, user, item
+}
+
+func TestDiscountLineLimits(t *testing.T) {
+	svc, store, user, item := cashierCart(t)
+
+	rcpt := sales.ReceiptLog{ReceiptNum: item.ReceiptNum}
+	err := svc.DiscountLine(context.Background(), user, &rcpt, sales.Override{ReceiptItem: item.ReceiptItem, Percent: 5, Reason: "damaged"})
+	if err != nil {
+		t.Fatalf("error discounting line: %s", err)
+	}
+	if rcpt.Total != money.New(190) {
+		t.Errorf("expected total 190, got %v", rcpt.Total)
+	}
+
+	// above the cashier's limit
+	err = svc.DiscountLine(context.Background(), user, &rcpt, sales.Override{ReceiptItem: item.ReceiptItem, Amount: money.New(50), Reason: "damaged"})
+	if !apperr.Is(err, apperr.ApprovalRequired) {
+		t.Fatalf("expected APPROVAL_REQUIRED, got %v", err)
+	}
+
+	err = svc.DiscountLine(context.Background(), user, &rcpt, sales.Override{ReceiptItem: item.ReceiptItem, Amount: money.New(50), Reason: "damaged", Approver: "SUPER", ApToken: "0000"})
+	if !apperr.Is(err, apperr.ApprovalRequired) {
+		t.Fatalf("expected APPROVAL_REQUIRED for a wrong token, got %v", err)
+	}
+
+	err = svc.DiscountLine(context.Background(), user, &rcpt, sales.Override{ReceiptItem: item.ReceiptItem, Amount: money.New(50), Reason: "damaged", Approver: "SUPER", ApToken: "1234"})
+	if err != nil {
+		t.Fatalf("error discounting line: %s", err)
+	}
+	if rcpt.Total != money.New(150) || rcpt.Cart[0].Discount != money.New(50) {
+		t.Errorf("expected total 150 with discount 50, got %v with %v", rcpt.Total, rcpt.Cart[0].Discount)
+	}
+
+	if len(store.overrides) != 2 {
+		t.Fatalf("expected 2 overrides recorded, got %v", len(store.overrides))
+	}
+	o := store.overrides[1]
+	if o.Kind != sales.OverrideLineDiscount || o.Poster != "JTELLER" || o.Approver != "SUPER" || o.Reason != "damaged" || o.Percent != 25 {
+		t.Errorf("unexpected override record %+v", o)
+	}
+}
+
+func TestOverridePrice(t *testing.T) {
+	svc, store, user, item := cashierCart(t)
+
+	rcpt := sales.ReceiptLog{ReceiptNum: item.ReceiptNum}
+	err := svc.OverridePrice(context.Background(), user, &rcpt, sales.Override{ReceiptItem: item.ReceiptItem, Price: money.New(80), Reason: "price match"})
+	if !apperr.Is(err, apperr.ApprovalRequired) {
+		t.Fatalf("expected APPROVAL_REQUIRED for a 20%% cut, got %v", err)
+	}
+
+	err = svc.OverridePrice(context.Background(), user, &rcpt, sales.Override{ReceiptItem: item.ReceiptItem, Price: money.New(95), Reason: "price match"})
+	if err != nil {
+		t.Fatalf("error overriding price: %s", err)
+	}
+
+	line := rcpt.Cart[0]
+	if line.Price != money.New(95) || line.OriginalPrice != money.New(100) || line.Total != money.New(190) {
+		t.Errorf("expected price 95 from 100 and total 190, got %v from %v and %v", line.Price, line.OriginalPrice, line.Total)
+	}
+
+	o := store.overrides[0]
+	if o.Kind != sales.OverridePrice || o.OriginalPrice != money.New(100) || o.Price != money.New(95) || o.ItemCode != "1001" {
+		t.Errorf("unexpected override record %+v", o)
+	}
+}
+
+func TestStackedDiscountLimits(t *testing.T) {
+	svc, _, user, item := cashierCart(t)
+
+	rcpt := sales.ReceiptLog{ReceiptNum: item.ReceiptNum}
+	if err := svc.OverridePrice(context.Background(), user, &rcpt, sales.Override{ReceiptItem: item.ReceiptItem, Price: money.New(95), Reason: "price match"}); err != nil {
+		t.Fatalf("error overriding price: %s", err)
+	}
+
+	// 8% alone is within the limit but not on top of the 5% price cut
+	err := svc.DiscountLine(context.Background(), user, &rcpt, sales.Override{ReceiptItem: item.ReceiptItem, Percent: 8, Reason: "damaged"})
+	if !apperr.Is(err, apperr.ApprovalRequired) {
+		t.Fatalf("expected APPROVAL_REQUIRED for a stacked line discount, got %v", err)
+	}
+	if err := svc.DiscountLine(context.Background(), user, &rcpt, sales.Override{ReceiptItem: item.ReceiptItem, Percent: 4, Reason: "damaged"}); err != nil {
+		t.Fatalf("error discounting line: %s", err)
+	}
+
+	// 17.60 is off the till price of 200, 5 more takes it past 10%
+	err = svc.DiscountReceipt(context.Background(), user, &rcpt, sales.Override{Amount: money.New(5), Reason: "loyal customer"})
+	if !apperr.Is(err, apperr.ApprovalRequired) {
+		t.Fatalf("expected APPROVAL_REQUIRED for a stacked receipt discount, got %v", err)
+	}
+	err = svc.DiscountReceipt(context.Background(), user, &rcpt, sales.Override{Amount: money.New(5), Reason: "loyal customer", Approver: "SUPER", ApToken: "1234"})
+	if err != nil {
+		t.Fatalf("error discounting receipt: %s", err)
+	}
+
+	// an 8% price cut is within the limit but not under the discounts already given
+	err = svc.OverridePrice(context.Background(), user, &rcpt, sales.Override{ReceiptItem: item.ReceiptItem, Price: money.New(92), Reason: "price match"})
+	if !apperr.Is(err, apperr.ApprovalRequired) {
+		t.Fatalf("expected APPROVAL_REQUIRED for a price override under the discounts, got %v", err)
+	}
+}
+
+func TestReceiptDiscountReachesTill(t *testing.T) {
+	svc, store, user, item := cashierCart(t)
+
+	store.products["2002"] = products.StockMaster{ItemCode: "2002", ItemName: "Milk", TillPrice: 50, VatPercent: 16}
+	if err := svc.AddCart(context.Background(), user, &sales.Sales{ItemCode: "2002", Quantity: 2, ReceiptNum: item.ReceiptNum}); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+
+	rcpt := sales.ReceiptLog{ReceiptNum: item.ReceiptNum}
+	err := svc.DiscountReceipt(context.Background(), user, &rcpt, sales.Override{Amount: money.New(30), Reason: "loyal customer"})
+	if err != nil {
+		t.Fatalf("error discounting receipt: %s", err)
+	}
+	if rcpt.Total != money.New(270) {
+		t.Errorf("expected total 270, got %v", rcpt.Total)
+	}
+	if rcpt.Cart[0].Discount != money.New(20) || rcpt.Cart[1].Discount != money.New(10) {
+		t.Errorf("expected discounts 20 and 10, got %v and %v", rcpt.Cart[0].Discount, rcpt.Cart[1].Discount)
+	}
+
+	if _, err := svc.ApplyPayment(context.Background(), &sales.ReceiptLog{ReceiptNum: item.ReceiptNum}, sales.Payment{Paymode: "cash", Amount: money.New(270)}); err != nil {
+		t.Fatalf("error applying payment: %s", err)
+	}
+	if err := svc.PostReceipt(context.Background(), &sales.ReceiptLog{ReceiptNum: item.ReceiptNum}); err != nil {
+		t.Fatalf("error posting receipt: %s", err)
+	}
+
+	if store.discounts[user.TillNum] != money.New(30) {
+		t.Errorf("expected till discount 30, got %v", store.discounts[user.TillNum])
+	}
+}
+
+func TestOverrideRepositoryApply(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	cart := []sales.Sales{billLine("a", 2, 100, 0)}
+	rcpt := sales.ReceiptLog{ReceiptNum: 1202610190001}
+	o := sales.PriceOverride{ReceiptNum: rcpt.ReceiptNum, ReceiptItem: "a", Kind: sales.OverridePrice, Reason: "damaged", Poster: "JTELLER"}
+	cut := func(cart []sales.Sales) []sales.Sales {
+		cart[0].Price = 80
+		return cart
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT coalesce\(cart, '\[\]'::jsonb\), vat_exempt FROM salestrace`).
+		WithArgs(rcpt.ReceiptNum).
+		WillReturnRows(mock.NewRows([]string{"cart", "vat_exempt"}).AddRow(cart, false))
+	mock.ExpectExec(`UPDATE salestrace SET cart = \$1`).
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), rcpt.ReceiptNum).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectQuery(`INSERT INTO price_overrides`).
+		WithArgs(rcpt.ReceiptNum, "a", "", sales.OverridePrice, money.Amount(0), money.Amount(0), money.Amount(0), float64(0), "damaged", "JTELLER", "", "", int64(0)).
+		WillReturnError(errors.New("insert failed"))
+	mock.ExpectRollback()
+
+	// the repriced cart is rolled back with the failed audit record
+	if err := sales.NewOverrideRepository(mock).Apply(context.Background(), &rcpt, &o, cut); err == nil {
+		t.Fatal("expected the failed override record to fail the override")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,193 @@
+package sales_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/events"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestPayAndPostReceipt(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["1001"] = products.StockMaster{ItemCode: "1001", ItemName: "Bread", TillPrice: 60}
+
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &sales.Sales{ItemCode: "1001", Quantity: 2}); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+
+	var num int64
+	for n := range store.receipts {
+		num = n
+	}
+
+	ch, cancel := svc.Events.Subscribe(events.Scope{Branch: "Main", ReceiptNum: num})
+	defer cancel()
+
+	balance, err := svc.ApplyPayment(context.Background(), &sales.ReceiptLog{ReceiptNum: num}, sales.Payment{Paymode: "cash", Amount: money.New(100)})
+	if err != nil {
+		t.Fatalf("error applying payment: %s", err)
+	}
+	if balance != money.New(20) {
+		t.Errorf("expected balance 20, got %v", balance)
+	}
+
+	if err := svc.PostReceipt(context.Background(), &sales.ReceiptLog{ReceiptNum: num}); !apperr.Is(err, apperr.InsufficientPayment) {
+		t.Fatalf("expected INSUFFICIENT_PAYMENT, got %v", err)
+	}
+
+	if _, err := svc.ApplyPayment(context.Background(), &sales.ReceiptLog{ReceiptNum: num}, sales.Payment{Paymode: "mpesa", Amount: money.New(50), Reference: "QX12"}); err != nil {
+		t.Fatalf("error applying payment: %s", err)
+	}
+
+	rcpt := sales.ReceiptLog{ReceiptNum: num}
+	if err := svc.PostReceipt(context.Background(), &rcpt); err != nil {
+		t.Fatalf("error posting receipt: %s", err)
+	}
+	if rcpt.State != "POSTED" || rcpt.Change != money.New(30) {
+		t.Errorf("expected POSTED with change 30, got %v with change %v", rcpt.State, rcpt.Change)
+	}
+
+	if e := <-ch; e.Type != events.PaymentCompleted {
+		t.Errorf("expected %v event, got %v", events.PaymentCompleted, e.Type)
+	}
+
+	// a posted receipt takes no more payments
+	_, err = svc.ApplyPayment(context.Background(), &sales.ReceiptLog{ReceiptNum: num}, sales.Payment{Paymode: "cash", Amount: money.New(10)})
+	if !apperr.Is(err, apperr.ReceiptNotOpen) {
+		t.Errorf("expected RECEIPT_NOT_OPEN, got %v", err)
+	}
+}
+
+func TestPayValidation(t *testing.T) {
+	svc, _ := newTestService()
+
+	_, err := svc.ApplyPayment(context.Background(), &sales.ReceiptLog{ReceiptNum: 1}, sales.Payment{Paymode: "cash"})
+	if !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED, got %v", err)
+	}
+
+	err = svc.PostReceipt(context.Background(), &sales.ReceiptLog{ReceiptNum: 404})
+	if !apperr.Is(err, apperr.NotFound) {
+		t.Errorf("expected NOT_FOUND, got %v", err)
+	}
+}
+
+func TestCashRounding(t *testing.T) {
+	svc, store := newTestService()
+	svc.Settings = func() (variables.PosSettings, error) {
+		return variables.PosSettings{CashRounding: money.New(1), AllowNegSale: true}, nil
+	}
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["1001"] = products.StockMaster{ItemCode: "1001", ItemName: "Bread", TillPrice: 59.7}
+
+	item := sales.Sales{ItemCode: "1001", Quantity: 1}
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &item); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+
+	// card payments aren't rounded
+	balance, err := svc.ApplyPayment(context.Background(), &sales.ReceiptLog{ReceiptNum: item.ReceiptNum}, sales.Payment{Paymode: "ecard", Amount: money.New(20)})
+	if err != nil {
+		t.Fatalf("error applying payment: %s", err)
+	}
+	if balance != money.New(39.7) {
+		t.Errorf("expected balance 39.70, got %v", balance)
+	}
+
+	balance, err = svc.ApplyPayment(context.Background(), &sales.ReceiptLog{ReceiptNum: item.ReceiptNum}, sales.Payment{Paymode: "cash", Amount: money.New(40)})
+	if err != nil {
+		t.Fatalf("error applying payment: %s", err)
+	}
+	if balance != 0 {
+		t.Errorf("expected the cash total rounded to 60, got balance %v", balance)
+	}
+
+	rcpt := sales.ReceiptLog{ReceiptNum: item.ReceiptNum}
+	if err := svc.PostReceipt(context.Background(), &rcpt); err != nil {
+		t.Fatalf("error posting receipt: %s", err)
+	}
+	if rcpt.Total != money.New(60) || rcpt.Rounding != money.New(0.3) || rcpt.Change != 0 {
+		t.Errorf("expected total 60.00 with rounding 0.30 and no change, got %v, %v and %v", rcpt.Total, rcpt.Rounding, rcpt.Change)
+	}
+	if store.receipts[item.ReceiptNum].Rounding != money.New(0.3) {
+		t.Errorf("expected rounding 0.30 recorded on the receipt, got %v", store.receipts[item.ReceiptNum].Rounding)
+	}
+
+	// the rounding is a line of its own on the stored cart, without vat
+	cart := store.receipts[item.ReceiptNum].Cart
+	last := cart[len(cart)-1]
+	if last.ItemCode != sales.RoundingCode || last.Total != money.New(0.3) || last.Vat != 0 || !last.VatExempt {
+		t.Fatalf("expected a 0.30 rounding line without vat, got %+v", last)
+	}
+
+	_, err = svc.ReturnSale(context.Background(), teller("JTELLER"), sales.SalesReturn{
+		ReturnTrace: item.ReceiptNum,
+		Lines:       []sales.ReturnLine{{ReceiptItem: last.ReceiptItem, Quantity: 1}},
+	})
+	if !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED returning the rounding, got %v", err)
+	}
+
+	// the stored tax summary has the rounding's band and adds up to the cart
+	gross := money.Amount(0)
+	exempt := false
+	for _, b := range store.receipts[item.ReceiptNum].TaxSummary {
+		gross += b.Gross
+		exempt = exempt || (b.Exempt && b.Gross == money.New(0.3))
+	}
+	if gross != money.New(60) || !exempt {
+		t.Errorf("expected the tax summary to add up to 60.00 with the rounding band, got %+v", store.receipts[item.ReceiptNum].TaxSummary)
+	}
+}
+
+// taxSummaryOf matches a tax summary argument whose bands add up to gross
+type taxSummaryOf money.Amount
+
+func (g taxSummaryOf) Match(v any) bool {
+	var bands []sales.TaxBand
+	s, ok := v.(string)
+	if !ok || json.Unmarshal([]byte(s), &bands) != nil {
+		return false
+	}
+	sum := money.Amount(0)
+	for _, b := range bands {
+		sum += b.Gross
+	}
+	return sum == money.Amount(g)
+}
+
+func TestReceiptPostTaxSummary(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	rcpt := sales.ReceiptLog{ReceiptNum: 1001, Total: money.New(60), Rounding: money.New(0.3), Cart: []sales.Sales{
+		{ItemCode: "1001", Quantity: 1, Price: money.New(59.7), Total: money.New(59.7), VatAlpha: "A", VatPercent: 16, Vat: money.New(8.23), State: "pending"},
+		{ItemCode: sales.RoundingCode, Quantity: 1, Price: money.New(0.3), Total: money.New(0.3), VatExempt: true, State: "pending"},
+	}}
+
+	mock.ExpectExec(`UPDATE salestrace`).
+		WithArgs(rcpt.ReceiptNum, rcpt.Total, money.Amount(0), rcpt.Rounding, pgxmock.AnyArg(), taxSummaryOf(money.New(60))).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	if err := sales.NewReceiptRepository(mock).Post(context.Background(), &rcpt); err != nil {
+		t.Fatalf("error was not expected while posting receipt: %s", err)
+	}
+	if len(rcpt.TaxSummary) != 2 {
+		t.Errorf("expected the rounding in a band of its own, got %+v", rcpt.TaxSummary)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
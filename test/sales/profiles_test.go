@@ -0,0 +1,107 @@
+package sales_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
+)
+
+func TestIndustryProfiles(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["12345"] = products.StockMaster{ItemCode: "12345", ItemName: "Beef", TillPrice: 800, VatAlpha: "A", VatPercent: 16}
+
+	mode := variables.Pharmacy
+	svc.Profile = func(ctx context.Context, branch string) (variables.Profile, error) {
+		return variables.Profiles[mode], nil
+	}
+
+	tests := []struct {
+		mode   string
+		orders bool
+		tables bool
+		scale  bool
+	}{
+		{mode: variables.General, orders: true, tables: true, scale: true},
+		{mode: variables.Supermarket, scale: true},
+		{mode: variables.Restaurant, orders: true, tables: true},
+		{mode: variables.Pharmacy},
+	}
+
+	check := func(t *testing.T, what string, on bool, err error) {
+		t.Helper()
+		if on && err != nil {
+			t.Errorf("expected %v on, got %v", what, err)
+		}
+		if !on && err == nil {
+			t.Errorf("expected %v off", what)
+		}
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			mode = tt.mode
+
+			ord := sales.Order{ReceiptNum: int64(3000 + i), Poster: "WAITER", Branch: "Main", TillNum: 1}
+			_, _, err := svc.AddToOrder(context.Background(), &ord, sales.Sales{ItemCode: "12345", Quantity: 1})
+			if !tt.orders && !apperr.Is(err, apperr.Forbidden) {
+				t.Errorf("expected FORBIDDEN taking an order, got %v", err)
+			}
+			check(t, "orders", tt.orders, err)
+
+			_, err = svc.FloorState(context.Background(), "Main", 0)
+			if !tt.tables && !apperr.Is(err, apperr.Forbidden) {
+				t.Errorf("expected FORBIDDEN loading the floor, got %v", err)
+			}
+			check(t, "tables", tt.tables, err)
+
+			// scale labels are looked up as plain codes when weighed items are off
+			item := sales.Sales{ItemCode: "2012345012509", Quantity: 1}
+			check(t, "weighed items", tt.scale, svc.AddCart(context.Background(), teller("JTELLER"), &item))
+		})
+	}
+
+	// an order taken before orders were turned off can't be sent
+	mode = variables.General
+	ord := sales.Order{ReceiptNum: 3100, Poster: "WAITER", Branch: "Main", TillNum: 1}
+	if _, _, err := svc.AddToOrder(context.Background(), &ord, sales.Sales{ItemCode: "12345", Quantity: 1}); err != nil {
+		t.Fatalf("error adding to order: %s", err)
+	}
+	mode = variables.Supermarket
+	if _, err := svc.CompleteOrder(context.Background(), &sales.Order{OrderNum: ord.OrderNum}); !apperr.Is(err, apperr.Forbidden) {
+		t.Errorf("expected FORBIDDEN completing an order, got %v", err)
+	}
+
+	// the server's mode applies when the branch's profile can't be loaded
+	defer func(mode string) { variables.IndustryMode = mode }(variables.IndustryMode)
+	svc.Profile = func(ctx context.Context, branch string) (variables.Profile, error) {
+		return variables.Profile{}, errors.New("connection refused")
+	}
+	variables.IndustryMode = variables.Supermarket
+	if _, err := svc.FloorState(context.Background(), "Main", 0); !apperr.Is(err, apperr.Forbidden) {
+		t.Errorf("expected tables off in supermarket mode, got %v", err)
+	}
+	variables.IndustryMode = variables.General
+	if _, err := svc.FloorState(context.Background(), "Main", 0); err != nil {
+		t.Errorf("expected tables on in general mode, got %v", err)
+	}
+}
+
+func TestSetIndustryMode(t *testing.T) {
+	defer func(mode string) { variables.IndustryMode = mode }(variables.IndustryMode)
+
+	if err := variables.SetIndustryMode(" Restaurant "); err != nil || variables.IndustryMode != variables.Restaurant {
+		t.Errorf("expected restaurant mode, got %v %v", variables.IndustryMode, err)
+	}
+	if err := variables.SetIndustryMode("garage"); err == nil || variables.IndustryMode != variables.Restaurant {
+		t.Errorf("expected unknown modes to be refused, got %v %v", variables.IndustryMode, err)
+	}
+	if err := variables.SetIndustryMode(""); err != nil || variables.IndustryMode != variables.Restaurant {
+		t.Errorf("expected an empty mode to keep the server's, got %v %v", variables.IndustryMode, err)
+	}
+}
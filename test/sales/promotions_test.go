@@ -0,0 +1,169 @@
+package sales_test
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+)
+
+func line(code string, qty, price float64) sales.Sales {
+	return sales.Sales{ItemCode: code, Quantity: qty, Price: money.New(price), Total: money.New(qty * price), VatAlpha: "A", State: "pending", ReceiptItem: code}
+}
+
+func cartTotal(cart []sales.Sales) float64 {
+	total := money.Amount(0)
+	for _, item := range cart {
+		total += item.Total
+	}
+	return total.Float()
+}
+
+func TestApplyPromotions(t *testing.T) {
+	now := time.Date(2026, 3, 2, 16, 30, 0, 0, time.UTC)
+	since := now.Add(-24 * time.Hour)
+
+	tests := []struct {
+		name   string
+		cart   []sales.Sales
+		promos []sales.Promotion
+		branch string
+		total  float64
+	}{
+		{
+			name:   "buy 2 get 1",
+			cart:   []sales.Sales{line("SODA", 3, 50)},
+			promos: []sales.Promotion{{Name: "3 for 2", Kind: sales.PromoBuyXGetY, ItemCodes: []string{"SODA"}, BuyQty: 2, GetQty: 1, StartsAt: since, Active: true}},
+			total:  100,
+		},
+		{
+			name:   "mix and match",
+			cart:   []sales.Sales{line("CHIPS", 1, 80), line("SODA", 1, 50)},
+			promos: []sales.Promotion{{Name: "meal deal", Kind: sales.PromoMixMatch, ItemCodes: []string{"CHIPS", "SODA"}, BuyQty: 2, Amount: money.New(100), StartsAt: since, Active: true}},
+			total:  100,
+		},
+		{
+			name:   "buy 2 get 1 across lines",
+			cart:   []sales.Sales{line("A", 2, 60), line("B", 2, 50)},
+			promos: []sales.Promotion{{Name: "3 for 2", Kind: sales.PromoBuyXGetY, ItemCodes: []string{"A", "B"}, BuyQty: 2, GetQty: 1, StartsAt: since, Active: true}},
+			total:  170,
+		},
+		{
+			name:   "buy 2 get 1 huge quantity",
+			cart:   []sales.Sales{line("SODA", 3e12, 1)},
+			promos: []sales.Promotion{{Name: "3 for 2", Kind: sales.PromoBuyXGetY, ItemCodes: []string{"SODA"}, BuyQty: 2, GetQty: 1, StartsAt: since, Active: true}},
+			total:  2e12,
+		},
+		{
+			name:   "mix and match across lines",
+			cart:   []sales.Sales{line("CHIPS", 3, 80), line("SODA", 1, 50)},
+			promos: []sales.Promotion{{Name: "meal deal", Kind: sales.PromoMixMatch, ItemCodes: []string{"CHIPS", "SODA"}, BuyQty: 2, Amount: money.New(100), StartsAt: since, Active: true}},
+			total:  200,
+		},
+		{
+			name:   "mix and match huge quantity",
+			cart:   []sales.Sales{line("CHIPS", 2e12+1, 80)},
+			promos: []sales.Promotion{{Name: "meal deal", Kind: sales.PromoMixMatch, ItemCodes: []string{"CHIPS"}, BuyQty: 2, Amount: money.New(100), StartsAt: since, Active: true}},
+			total:  1e14 + 80,
+		},
+		{
+			name:   "quantity break",
+			cart:   []sales.Sales{line("SUGAR", 6, 200)},
+			promos: []sales.Promotion{{Name: "bulk sugar", Kind: sales.PromoQuantityBreak, ItemCodes: []string{"SUGAR"}, MinQty: 6, Percent: 10, StartsAt: since, Active: true}},
+			total:  1080,
+		},
+		{
+			name:   "quantity break not reached",
+			cart:   []sales.Sales{line("SUGAR", 5, 200)},
+			promos: []sales.Promotion{{Name: "bulk sugar", Kind: sales.PromoQuantityBreak, ItemCodes: []string{"SUGAR"}, MinQty: 6, Percent: 10, StartsAt: since, Active: true}},
+			total:  1000,
+		},
+		{
+			name:   "fixed off cart",
+			cart:   []sales.Sales{line("A", 1, 100), line("B", 1, 100), line("C", 1, 100)},
+			promos: []sales.Promotion{{Name: "10 off", Kind: sales.PromoFixed, Amount: money.New(10), StartsAt: since, Active: true}},
+			total:  290,
+		},
+		{
+			name:   "happy hour",
+			cart:   []sales.Sales{line("BEER", 2, 300)},
+			promos: []sales.Promotion{{Name: "happy hour", Kind: sales.PromoPercent, ItemCodes: []string{"BEER"}, Percent: 50, DailyStart: "16:00", DailyEnd: "18:00", StartsAt: since, Active: true}},
+			total:  300,
+		},
+		{
+			name:   "outside happy hour",
+			cart:   []sales.Sales{line("BEER", 2, 300)},
+			promos: []sales.Promotion{{Name: "happy hour", Kind: sales.PromoPercent, ItemCodes: []string{"BEER"}, Percent: 50, DailyStart: "18:00", DailyEnd: "20:00", StartsAt: since, Active: true}},
+			total:  600,
+		},
+		{
+			name:   "other branch",
+			cart:   []sales.Sales{line("BEER", 2, 300)},
+			promos: []sales.Promotion{{Name: "westside", Kind: sales.PromoPercent, Percent: 50, Branches: []string{"West"}, StartsAt: since, Active: true}},
+			branch: "Main",
+			total:  600,
+		},
+		{
+			name: "units take one promotion",
+			cart: []sales.Sales{line("SODA", 3, 50)},
+			promos: []sales.Promotion{
+				{Name: "3 for 2", Kind: sales.PromoBuyXGetY, ItemCodes: []string{"SODA"}, BuyQty: 2, GetQty: 1, Priority: 10, StartsAt: since, Active: true},
+				{Name: "half price", Kind: sales.PromoPercent, Percent: 50, StartsAt: since, Active: true},
+			},
+			total: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cart := sales.ApplyPromotions(tt.cart, tt.promos, tt.branch, now)
+			cart = sales.TaxTable{Rates: map[string]float64{"A": 16}}.Apply(cart, false)
+			if got := cartTotal(cart); got != tt.total {
+				t.Errorf("expected total %v, got %v", tt.total, got)
+			}
+
+			for _, item := range cart {
+				if math.Abs(item.Vat.Float()-item.Total.Float()*16/116) > 0.01 {
+					t.Errorf("expected vat on the discounted total %v, got %v", item.Total, item.Vat)
+				}
+			}
+		})
+	}
+}
+
+func TestAddCartAppliesPromotions(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["SODA"] = products.StockMaster{ItemCode: "SODA", ItemName: "Soda", TillPrice: 50, VatPercent: 16, VatAlpha: "A"}
+	store.promotions = []sales.Promotion{{Name: "3 for 2", Kind: sales.PromoBuyXGetY, ItemCodes: []string{"SODA"}, BuyQty: 2, GetQty: 1, StartsAt: time.Now().Add(-time.Hour), Active: true}}
+
+	item := sales.Sales{ItemCode: "SODA", Quantity: 2}
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &item); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+	if item.Discount != 0 {
+		t.Errorf("expected no discount on 2 units, got %v", item.Discount)
+	}
+
+	// the third unit completes the offer
+	item = sales.Sales{ItemCode: "SODA", Quantity: 1, ReceiptNum: item.ReceiptNum}
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &item); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+
+	rcpt := sales.ReceiptLog{ReceiptNum: item.ReceiptNum}
+	if err := svc.Receipt(context.Background(), &rcpt); err != nil {
+		t.Fatalf("error fetching receipt: %s", err)
+	}
+	if rcpt.Total != money.New(100) {
+		t.Errorf("expected total 100, got %v", rcpt.Total)
+	}
+	if !slices.Contains(rcpt.Promotions, "3 for 2") {
+		t.Errorf("expected promotion on receipt, got %v", rcpt.Promotions)
+	}
+}
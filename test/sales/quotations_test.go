@@ -0,0 +1,158 @@
+package sales_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
+)
+
+func cement() products.StockMaster {
+	return products.StockMaster{ItemCode: "5001", ItemName: "Cement 50kg", TillPrice: 700, VatAlpha: "A"}
+}
+
+func TestConvertQuotation(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["5001"] = cement()
+	store.products["7001"] = phone()
+
+	q := sales.Quotation{}
+	if err := svc.NewQuotation(context.Background(), teller("JTELLER"), &q); err != nil {
+		t.Fatalf("error creating quotation: %s", err)
+	}
+	if days := time.Until(q.ValidUntil).Hours() / 24; days < sales.QuoteDays-1 || days > sales.QuoteDays {
+		t.Errorf("expected the quotation valid for %v days, got %v", sales.QuoteDays, q.ValidUntil)
+	}
+
+	for _, item := range []sales.Sales{{ItemCode: "5001", Quantity: 2}, {ItemCode: "7001", Quantity: 1}} {
+		if err := svc.AddQuoteItem(context.Background(), &q, &item); err != nil {
+			t.Fatalf("error adding %v to quotation: %s", item.ItemCode, err)
+		}
+	}
+	if q.Total != money.New(16400) || len(q.TaxSummary) != 1 {
+		t.Errorf("expected 16400 quoted in band A, got %v %+v", q.Total, q.TaxSummary)
+	}
+	if len(store.receipts) != 0 {
+		t.Errorf("expected no receipt for a quotation, got %v", len(store.receipts))
+	}
+
+	var doc bytes.Buffer
+	if err := sales.WriteQuotation(&doc, q, variables.DocHead{CompanyName: "Hardware Ltd"}); err != nil {
+		t.Fatalf("error printing quotation: %s", err)
+	}
+	if !strings.Contains(doc.String(), "Hardware Ltd") || !strings.Contains(doc.String(), "Cement 50kg") {
+		t.Errorf("expected the heading and lines printed, got %s", doc.String())
+	}
+
+	// the quoted price holds after the price changes
+	p := cement()
+	p.TillPrice = 800
+	store.products["5001"] = p
+
+	rcpt, held, err := svc.ConvertQuotation(context.Background(), teller("JTELLER"), &sales.Quotation{QuoteNum: q.QuoteNum}, false, "", "")
+	if err != nil {
+		t.Fatalf("error converting quotation: %s", err)
+	}
+	if rcpt.Total != money.New(1400) || len(rcpt.Cart) != 1 || rcpt.Cart[0].ReceiptNum != rcpt.ReceiptNum {
+		t.Errorf("expected cement at the quoted 1400 on the receipt, got %v %+v", rcpt.Total, rcpt.Cart)
+	}
+	if len(held) != 1 || held[0].Line.ItemCode != "7001" {
+		t.Errorf("expected the phone held for its serial, got %+v", held)
+	}
+	if saved := store.quotes[q.QuoteNum]; saved.State != sales.QuoteConverted || saved.ReceiptNum != rcpt.ReceiptNum {
+		t.Errorf("expected the quotation converted into %v, got %v %v", rcpt.ReceiptNum, saved.State, saved.ReceiptNum)
+	}
+
+	if _, _, err := svc.ConvertQuotation(context.Background(), teller("JTELLER"), &sales.Quotation{QuoteNum: q.QuoteNum}, false, "", ""); !apperr.Is(err, apperr.QuoteClosed) {
+		t.Errorf("expected QUOTE_CLOSED converting twice, got %v", err)
+	}
+}
+
+func TestConvertExpiredQuotation(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["5001"] = cement()
+
+	q := sales.Quotation{}
+	if err := svc.NewQuotation(context.Background(), teller("JTELLER"), &q); err != nil {
+		t.Fatalf("error creating quotation: %s", err)
+	}
+	if err := svc.AddQuoteItem(context.Background(), &q, &sales.Sales{ItemCode: "5001", Quantity: 1}); err != nil {
+		t.Fatalf("error adding to quotation: %s", err)
+	}
+
+	store.quotes[q.QuoteNum].ValidUntil = time.Now().Add(-time.Hour)
+	p := cement()
+	p.TillPrice = 800
+	store.products["5001"] = p
+
+	if err := svc.AddQuoteItem(context.Background(), &sales.Quotation{QuoteNum: q.QuoteNum}, &sales.Sales{ItemCode: "5001", Quantity: 1}); !apperr.Is(err, apperr.QuoteClosed) {
+		t.Errorf("expected QUOTE_CLOSED adding to an expired quotation, got %v", err)
+	}
+	if _, _, err := svc.ConvertQuotation(context.Background(), teller("JTELLER"), &sales.Quotation{QuoteNum: q.QuoteNum}, false, "", ""); !apperr.Is(err, apperr.QuoteClosed) {
+		t.Errorf("expected QUOTE_CLOSED at the expired prices, got %v", err)
+	}
+
+	noTill := teller("JTELLER")
+	noTill.TillNum = 0
+	if _, _, err := svc.ConvertQuotation(context.Background(), noTill, &sales.Quotation{QuoteNum: q.QuoteNum}, true, "", ""); !apperr.Is(err, apperr.TillNotOpen) {
+		t.Errorf("expected TILL_NOT_OPEN without a till, got %v", err)
+	}
+
+	rcpt, held, err := svc.ConvertQuotation(context.Background(), teller("JTELLER"), &sales.Quotation{QuoteNum: q.QuoteNum}, true, "", "")
+	if err != nil {
+		t.Fatalf("error converting quotation: %s", err)
+	}
+	if rcpt.Total != money.New(800) || len(held) != 0 {
+		t.Errorf("expected the cement at today's 800, got %v held %+v", rcpt.Total, held)
+	}
+}
+
+func TestQuotationExemptCustomer(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["5001"] = cement()
+	store.customers[1] = &sales.Customer{ID: 1, Name: "Hill School", Phone: "254722000111", ExemptCert: "EX-2026-01"}
+
+	q := sales.Quotation{CustomerID: 1}
+	if err := svc.NewQuotation(context.Background(), teller("JTELLER"), &q); err != nil {
+		t.Fatalf("error creating quotation: %s", err)
+	}
+	if err := svc.AddQuoteItem(context.Background(), &q, &sales.Sales{ItemCode: "5001", Quantity: 1}); err != nil {
+		t.Fatalf("error adding to quotation: %s", err)
+	}
+	if !q.VatExempt || q.Items[0].Vat != 0 {
+		t.Errorf("expected the school quoted without vat, got exempt %v vat %v", q.VatExempt, q.Items[0].Vat)
+	}
+
+	// the teller can't apply the exemption alone
+	if _, _, err := svc.ConvertQuotation(context.Background(), teller("JTELLER"), &sales.Quotation{QuoteNum: q.QuoteNum}, false, "", ""); !apperr.Is(err, apperr.ApprovalRequired) {
+		t.Fatalf("expected APPROVAL_REQUIRED converting an exempt quotation, got %v", err)
+	}
+	if len(store.receipts) != 0 {
+		t.Errorf("expected no receipt opened without approval, got %v", len(store.receipts))
+	}
+
+	sup := approver("SUPER")
+	sup.GrantVatExempt = true
+	store.users["SUPER"] = sup
+	rcpt, _, err := svc.ConvertQuotation(context.Background(), teller("JTELLER"), &sales.Quotation{QuoteNum: q.QuoteNum}, false, "SUPER", "1234")
+	if err != nil {
+		t.Fatalf("error converting quotation: %s", err)
+	}
+	if r := store.receipts[rcpt.ReceiptNum]; r.CustomerID != 1 || !r.VatExempt {
+		t.Errorf("expected the school's exemption on the receipt, got customer %v exempt %v", r.CustomerID, r.VatExempt)
+	}
+
+	if err := svc.NewQuotation(context.Background(), teller("JTELLER"), &sales.Quotation{ValidUntil: time.Now().Add(-time.Hour)}); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED for a past validity, got %v", err)
+	}
+}
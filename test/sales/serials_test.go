@@ -0,0 +1,239 @@
+package sales_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func phone() products.StockMaster {
+	return products.StockMaster{ItemCode: "7001", ItemName: "Phone X1", TillPrice: 15000, VatAlpha: "A", Serialized: true}
+}
+
+func soldPhone(receiptNum, returnTrace int64, at time.Time, serials ...string) *sales.ReceiptLog {
+	return &sales.ReceiptLog{ReceiptNum: receiptNum, ReturnTrace: returnTrace, Branch: "Main", Poster: "JTELLER", State: "POSTED", TransDate: at,
+		Cart: []sales.Sales{{ItemCode: "7001", ItemName: "Phone X1", Quantity: float64(len(serials)), State: "pending", Serials: serials}}}
+}
+
+func TestSerializedCart(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["7001"] = phone()
+
+	now := time.Now()
+	store.receipts[900] = soldPhone(900, 0, now.AddDate(0, -1, 0), "IMEI-SOLD", "IMEI-BACK")
+	store.receipts[901] = soldPhone(901, 900, now.AddDate(0, 0, -1), "IMEI-BACK")
+
+	tests := []struct {
+		name    string
+		qty     float64
+		serials []string
+		err     apperr.Code
+	}{
+		{name: "serial per unit", qty: 2, serials: []string{" imei-1 ", "IMEI-2"}},
+		{name: "returned serial resold", qty: 1, serials: []string{"imei-back"}},
+		{name: "missing serial", qty: 2, serials: []string{"IMEI-3"}, err: apperr.ValidationFailed},
+		{name: "entered twice", qty: 2, serials: []string{"IMEI-4", "imei-4"}, err: apperr.ValidationFailed},
+		{name: "part unit", qty: 0.5, serials: []string{"IMEI-5"}, err: apperr.ValidationFailed},
+		{name: "already sold", qty: 1, serials: []string{"IMEI-SOLD"}, err: apperr.SerialSold},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := sales.Sales{ItemCode: "7001", Quantity: tt.qty, Serials: tt.serials}
+			err := svc.AddCart(context.Background(), teller("JTELLER"), &item)
+			if tt.err != "" {
+				if !apperr.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error adding to cart: %s", err)
+			}
+		})
+	}
+}
+
+func TestSerialLookupAndReturn(t *testing.T) {
+	svc, store := newTestService()
+	store.products["7001"] = phone()
+	store.receipts[900] = soldPhone(900, 0, time.Now().AddDate(0, -2, 0), "IMEI-1", "IMEI-2")
+
+	sale, err := svc.LookupSerial(context.Background(), " imei-2")
+	if err != nil {
+		t.Fatalf("error looking up serial: %s", err)
+	}
+	if sale.ReceiptNum != 900 || sale.ItemCode != "7001" || sale.Returned {
+		t.Errorf("expected phone sold on receipt 900, got %+v", sale)
+	}
+	if _, err := svc.LookupSerial(context.Background(), "IMEI-9"); !apperr.Is(err, apperr.NotFound) {
+		t.Errorf("expected NOT_FOUND for an unsold serial, got %v", err)
+	}
+
+	phoneBack := func(serials ...string) sales.Sales {
+		return sales.Sales{ItemCode: "7001", Quantity: float64(len(serials)), Serials: serials}
+	}
+	if err := svc.CheckReturn(context.Background(), 900, phoneBack("IMEI-1")); err != nil {
+		t.Errorf("expected IMEI-1 returnable to receipt 900, got %v", err)
+	}
+	if err := svc.CheckReturn(context.Background(), 899, phoneBack("IMEI-1")); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED returning to another receipt, got %v", err)
+	}
+	if err := svc.CheckReturn(context.Background(), 900, phoneBack("IMEI-7")); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED for a serial not sold, got %v", err)
+	}
+
+	// once returned the serial can't be returned again
+	store.receipts[950] = soldPhone(950, 900, time.Now(), "IMEI-1")
+	if err := svc.CheckReturn(context.Background(), 900, phoneBack("IMEI-1")); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED for a serial already returned, got %v", err)
+	}
+	if sale, err := svc.LookupSerial(context.Background(), "IMEI-1"); err != nil || !sale.Returned {
+		t.Errorf("expected the sale marked returned, got %+v %v", sale, err)
+	}
+}
+
+func TestReturnSale(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["7001"] = phone()
+
+	item := sales.Sales{ItemCode: "7001", Quantity: 2, Serials: []string{"IMEI-1", "IMEI-2"}}
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &item); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+	if _, err := svc.ApplyPayment(context.Background(), &sales.ReceiptLog{ReceiptNum: item.ReceiptNum}, sales.Payment{Paymode: "cash", Amount: money.New(30000)}); err != nil {
+		t.Fatalf("error paying: %s", err)
+	}
+	if err := svc.PostReceipt(context.Background(), &sales.ReceiptLog{ReceiptNum: item.ReceiptNum}); err != nil {
+		t.Fatalf("error posting receipt: %s", err)
+	}
+	line := store.receipts[item.ReceiptNum].Cart[0].ReceiptItem
+
+	back := func(serials ...string) sales.SalesReturn {
+		return sales.SalesReturn{ReturnTrace: item.ReceiptNum, Lines: []sales.ReturnLine{{ReceiptItem: line, Quantity: float64(len(serials)), Serials: serials}}}
+	}
+	elsewhere := teller("JTELLER")
+	elsewhere.Branch = "Westlands"
+	if _, err := svc.ReturnSale(context.Background(), elsewhere, back("IMEI-1")); !apperr.Is(err, apperr.Forbidden) {
+		t.Errorf("expected FORBIDDEN returning another branch's sale, got %v", err)
+	}
+	if _, err := svc.ReturnSale(context.Background(), teller("JTELLER"), back("IMEI-9")); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED for a serial not on the sale, got %v", err)
+	}
+
+	ret, err := svc.ReturnSale(context.Background(), teller("JTELLER"), back("IMEI-1"))
+	if err != nil {
+		t.Fatalf("error returning sale: %s", err)
+	}
+	if ret.ReturnTrace != item.ReceiptNum || ret.Total != money.New(-15000) || ret.Cart[0].Quantity != -1 || store.returns[1] != money.New(15000) {
+		t.Errorf("expected 15000 refunded from till 1 against receipt %v, got %+v", item.ReceiptNum, ret)
+	}
+
+	// the serial is back on the shelf and can't come back twice
+	if sale, err := svc.LookupSerial(context.Background(), "IMEI-1"); err != nil || !sale.Returned {
+		t.Errorf("expected the sale marked returned, got %+v %v", sale, err)
+	}
+	if _, err := svc.ReturnSale(context.Background(), teller("JTELLER"), back("IMEI-1")); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED returning a serial twice, got %v", err)
+	}
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &sales.Sales{ItemCode: "7001", Quantity: 1, Serials: []string{"IMEI-1"}}); err != nil {
+		t.Errorf("expected the returned serial sold again, got %v", err)
+	}
+}
+
+func TestReturnSaleQuantities(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["1001"] = products.StockMaster{ItemCode: "1001", ItemName: "Bread", TillPrice: 50, VatAlpha: "A"}
+
+	item := sales.Sales{ItemCode: "1001", Quantity: 3}
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &item); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+	if _, err := svc.ApplyPayment(context.Background(), &sales.ReceiptLog{ReceiptNum: item.ReceiptNum}, sales.Payment{Paymode: "cash", Amount: money.New(150)}); err != nil {
+		t.Fatalf("error paying: %s", err)
+	}
+	back := func(qty float64) sales.SalesReturn {
+		line := store.receipts[item.ReceiptNum].Cart[0].ReceiptItem
+		return sales.SalesReturn{ReturnTrace: item.ReceiptNum, Lines: []sales.ReturnLine{{ReceiptItem: line, Quantity: qty}}}
+	}
+	if _, err := svc.ReturnSale(context.Background(), teller("JTELLER"), back(1)); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED returning an unposted sale, got %v", err)
+	}
+	if err := svc.PostReceipt(context.Background(), &sales.ReceiptLog{ReceiptNum: item.ReceiptNum}); err != nil {
+		t.Fatalf("error posting receipt: %s", err)
+	}
+
+	if ret, err := svc.ReturnSale(context.Background(), teller("JTELLER"), back(2)); err != nil || ret.Total != money.New(-100) {
+		t.Fatalf("expected 100 refunded for 2 loaves, got %v %v", ret.Total, err)
+	}
+	if _, err := svc.ReturnSale(context.Background(), teller("JTELLER"), back(2)); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Errorf("expected VALIDATION_FAILED returning more than is left, got %v", err)
+	}
+	if _, err := svc.ReturnSale(context.Background(), teller("JTELLER"), back(1)); err != nil {
+		t.Errorf("error returning the last loaf: %s", err)
+	}
+}
+
+func TestReceiptRepositoryReturn(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	sold := []sales.Sales{billLine("a", 3, 50, 0)}
+	ret := sales.ReceiptLog{TillNum: 7, Poster: "JTELLER", Branch: "Main", CompanyID: 1, SaleType: "Cash Sale", ReturnTrace: 1202610190012,
+		Cart: []sales.Sales{billLine("a", -2, 50, 0)}, Total: money.New(-100)}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT coalesce\(cart, '\[\]'::jsonb\) FROM salestrace WHERE receipt_num = \$1 AND state = 'POSTED' FOR UPDATE`).
+		WithArgs(ret.ReturnTrace).
+		WillReturnRows(mock.NewRows([]string{"cart"}).AddRow(sold))
+	mock.ExpectQuery(`WHERE s.return_trace = \$1 AND s.state = 'POSTED'`).
+		WithArgs(ret.ReturnTrace).
+		WillReturnRows(mock.NewRows([]string{"receipt_item", "quantity"}).AddRow("a", float64(2)))
+	mock.ExpectRollback()
+
+	// 2 of the 3 were returned before
+	if err := sales.NewReceiptRepository(mock).Return(context.Background(), &ret); !apperr.Is(err, apperr.ValidationFailed) {
+		t.Fatalf("expected VALIDATION_FAILED returning more than is left, got %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM salestrace WHERE receipt_num = \$1 AND state = 'POSTED' FOR UPDATE`).
+		WithArgs(ret.ReturnTrace).
+		WillReturnRows(mock.NewRows([]string{"cart"}).AddRow(sold))
+	mock.ExpectQuery(`WHERE s.return_trace = \$1 AND s.state = 'POSTED'`).
+		WithArgs(ret.ReturnTrace).
+		WillReturnRows(mock.NewRows([]string{"receipt_item", "quantity"}))
+	mock.ExpectQuery(`FROM salestrace WHERE trans_date::date`).
+		WillReturnRows(mock.NewRows([]string{"receipt_num", "daily_count"}).AddRow(int64(1202610190013), int32(13)))
+	mock.ExpectExec(`INSERT INTO salestrace`).
+		WithArgs(int64(7), int64(1202610190013), "JTELLER", int32(13), "Main", int64(1), "Cash Sale", int64(0), pgxmock.AnyArg(), money.New(-100), ret.ReturnTrace).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(`UPDATE sales_till`).
+		WithArgs(int64(7), money.New(100)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+	mock.ExpectRollback()
+
+	if err := sales.NewReceiptRepository(mock).Return(context.Background(), &ret); err != nil {
+		t.Fatalf("error was not expected while returning: %s", err)
+	}
+	if ret.ReceiptNum != 1202610190013 || ret.State != "POSTED" {
+		t.Errorf("expected return receipt 1202610190013 posted, got %v %v", ret.ReceiptNum, ret.State)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,219 @@
+package sales_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/events"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
+)
+
+func TestAddCart(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["1001"] = products.StockMaster{ItemCode: "1001", ItemName: "Milk 500ml", TillPrice: 60, ItemCost: 50, VatAlpha: "A", VatPercent: 16}
+
+	item := sales.Sales{ItemCode: "1001", Quantity: 2}
+	err := svc.AddCart(context.Background(), teller("JTELLER"), &item)
+	if err != nil {
+		t.Fatalf("error was not expected while adding to cart: %s", err)
+	}
+
+	if item.ReceiptNum == 0 {
+		t.Fatal("expected a receipt to be generated for the cart")
+	}
+
+	rcpt := sales.ReceiptLog{ReceiptNum: item.ReceiptNum}
+	if err := svc.Receipts.Fetch(context.Background(), &rcpt); err != nil {
+		t.Fatalf("error fetching receipt: %s", err)
+	}
+
+	if len(rcpt.Cart) != 1 {
+		t.Fatalf("expected 1 cart item, got %d", len(rcpt.Cart))
+	}
+	if rcpt.Total != money.New(120) {
+		t.Errorf("expected total 120, got %v", rcpt.Total)
+	}
+	if rcpt.Cart[0].Price != money.New(60) || rcpt.Cart[0].ItemName != "Milk 500ml" {
+		t.Errorf("expected price and name from inventory, got %v %v", rcpt.Cart[0].Price, rcpt.Cart[0].ItemName)
+	}
+}
+
+func TestAddCartUnknownProduct(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+
+	item := sales.Sales{ItemCode: "missing", Quantity: 1}
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &item); !apperr.Is(err, apperr.ProductNotFound) {
+		t.Fatalf("expected a product not found error, got %v", err)
+	}
+}
+
+func TestCloseBill(t *testing.T) {
+	svc, store := newTestService()
+	store.users["WAITER"] = teller("WAITER")
+	store.products["2001"] = products.StockMaster{ItemCode: "2001", ItemName: "Chips", TillPrice: 150}
+
+	rcpt := sales.ReceiptLog{TillNum: 1, Poster: "WAITER", SaleType: "Cash Sale"}
+	if err := svc.GenReceipt(context.Background(), &rcpt); err != nil {
+		t.Fatalf("error generating receipt: %s", err)
+	}
+
+	ord := sales.Order{ReceiptNum: rcpt.ReceiptNum, Poster: "WAITER", TillNum: 1}
+	if _, _, err := svc.AddToOrder(context.Background(), &ord, sales.Sales{ItemCode: "2001", Quantity: 2}); err != nil {
+		t.Fatalf("error adding to order: %s", err)
+	}
+
+	// pending orders block the bill
+	if err := svc.CloseBill(context.Background(), &sales.ReceiptLog{ReceiptNum: rcpt.ReceiptNum}); !apperr.Is(err, apperr.PendingOrders) {
+		t.Fatalf("expected a pending orders error, got %v", err)
+	}
+
+	voucher, err := svc.CompleteOrder(context.Background(), &sales.Order{OrderNum: ord.OrderNum})
+	if err != nil {
+		t.Fatalf("error completing order: %s", err)
+	}
+	if len(voucher) != 1 {
+		t.Errorf("expected 1 voucher line, got %d", len(voucher))
+	}
+	if _, ok := store.published[fmt.Sprintf("sales_orders/%v", ord.OrderNum)]; !ok {
+		t.Error("expected the order to be published to the kitchen")
+	}
+
+	closing := sales.ReceiptLog{ReceiptNum: rcpt.ReceiptNum}
+	if err := svc.CloseBill(context.Background(), &closing); err != nil {
+		t.Fatalf("error closing bill: %s", err)
+	}
+
+	if closing.Total != money.New(300) {
+		t.Errorf("expected bill total 300, got %v", closing.Total)
+	}
+	if store.orders[ord.OrderNum].State != "paying" {
+		t.Errorf("expected order to be paying, got %v", store.orders[ord.OrderNum].State)
+	}
+}
+
+func TestCompleteEmptyOrder(t *testing.T) {
+	svc, _ := newTestService()
+
+	if _, err := svc.CompleteOrder(context.Background(), &sales.Order{OrderNum: 1}); !apperr.Is(err, apperr.EmptyOrder) {
+		t.Fatalf("expected an empty order error, got %v", err)
+	}
+}
+
+func TestAddCartTillNotOpen(t *testing.T) {
+	svc, _ := newTestService()
+
+	user := teller("JTELLER")
+	user.TillNum = 0
+
+	item := sales.Sales{ItemCode: "1001", Quantity: 1}
+	if err := svc.AddCart(context.Background(), user, &item); !apperr.Is(err, apperr.TillNotOpen) {
+		t.Fatalf("expected a till not open error, got %v", err)
+	}
+}
+
+func TestServiceEvents(t *testing.T) {
+	svc, store := newTestService()
+	store.users["WAITER"] = teller("WAITER")
+	store.products["2001"] = products.StockMaster{ItemCode: "2001", ItemName: "Chips", TillPrice: 150}
+
+	rcpt := sales.ReceiptLog{TillNum: 1, Branch: "Main", Poster: "WAITER", SaleType: "Cash Sale"}
+	if err := svc.GenReceipt(context.Background(), &rcpt); err != nil {
+		t.Fatalf("error generating receipt: %s", err)
+	}
+
+	ch, cancel := svc.Events.Subscribe(events.Scope{Branch: "Main", ReceiptNum: rcpt.ReceiptNum})
+	defer cancel()
+
+	ord := sales.Order{ReceiptNum: rcpt.ReceiptNum, Branch: "Main", Poster: "WAITER", TillNum: 1}
+	if _, _, err := svc.AddToOrder(context.Background(), &ord, sales.Sales{ItemCode: "2001", Quantity: 1}); err != nil {
+		t.Fatalf("error adding to order: %s", err)
+	}
+	if _, err := svc.CompleteOrder(context.Background(), &sales.Order{OrderNum: ord.OrderNum}); err != nil {
+		t.Fatalf("error completing order: %s", err)
+	}
+
+	want := []string{events.OrderItemAdded, events.OrderState}
+	for _, typ := range want {
+		e := <-ch
+		if e.Type != typ || e.ReceiptNum != rcpt.ReceiptNum {
+			t.Errorf("expected %v event for receipt %v, got %v for %v", typ, rcpt.ReceiptNum, e.Type, e.ReceiptNum)
+		}
+	}
+}
+
+func TestAddCartScaleAndCaseBarcodes(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["12345"] = products.StockMaster{ItemCode: "12345", ItemName: "Beef", TillPrice: 800, VatAlpha: "A", VatPercent: 16}
+	store.products["01234"] = products.StockMaster{ItemCode: "01234", ItemName: "Cheese", TillPrice: 1200, VatAlpha: "A", VatPercent: 16}
+	store.products["6004005000120"] = products.StockMaster{ItemCode: "6004005000120", ItemName: "Soda 500ml", TillPrice: 50, PkgQty: 24, VatAlpha: "A", VatPercent: 16}
+
+	tests := []struct {
+		name     string
+		code     string
+		qty      float64
+		itemCode string
+		quantity float64
+		total    money.Amount
+	}{
+		{"weight label", "2012345012509", 1, "12345", 1.25, money.New(1000)},
+		{"price label", "2501234019991", 1, "01234", 1999.0 / 120000, money.New(19.99)},
+		{"two cases", "16004005000127", 2, "6004005000120", 48, money.New(2400)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := sales.Sales{ItemCode: tt.code, Quantity: tt.qty}
+			if err := svc.AddCart(context.Background(), teller("JTELLER"), &item); err != nil {
+				t.Fatalf("error adding to cart: %s", err)
+			}
+			if item.ItemCode != tt.itemCode || item.Quantity != tt.quantity || item.Total != tt.total {
+				t.Errorf("expected %v x %v = %v, got %v x %v = %v", tt.itemCode, tt.quantity, tt.total, item.ItemCode, item.Quantity, item.Total)
+			}
+		})
+	}
+}
+
+func TestSearchProducts(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["1001"] = products.StockMaster{ItemCode: "1001", ItemName: "Bread", TillPrice: 50, Category: "Bakery", BranchPrices: map[string]float64{"Main": 55}}
+	store.products["2001"] = products.StockMaster{ItemCode: "2001", ItemName: "Milk", TillPrice: 60, Category: "Dairy"}
+
+	if _, err := svc.SearchProducts(context.Background(), teller("JTELLER"), products.SearchQuery{Query: "bread"}); !apperr.Is(err, apperr.Forbidden) {
+		t.Fatalf("expected search to be forbidden when turned off, got %v", err)
+	}
+
+	svc.Settings = func() (variables.PosSettings, error) {
+		return variables.PosSettings{AllowProductSearch: true, AllowNegSale: true}, nil
+	}
+	res, err := svc.SearchProducts(context.Background(), teller("JTELLER"), products.SearchQuery{Query: "bread"})
+	if err != nil {
+		t.Fatalf("error searching products: %s", err)
+	}
+	if len(res.Products) != 1 || res.Products[0].TillPrice != 55 {
+		t.Errorf("expected bread at the branch price of 55, got %+v", res.Products)
+	}
+	if len(res.Categories) != 0 {
+		t.Errorf("expected no categories when they are turned off, got %v", res.Categories)
+	}
+	if _, err := svc.SearchProducts(context.Background(), teller("JTELLER"), products.SearchQuery{Category: "Dairy"}); err == nil {
+		t.Error("expected an error browsing categories when they are turned off")
+	}
+
+	// the branch price is charged on the cart too
+	item := sales.Sales{ItemCode: "1001", Quantity: 1}
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &item); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+	if item.Price != money.New(55) {
+		t.Errorf("expected the branch price of 55, got %v", item.Price)
+	}
+}
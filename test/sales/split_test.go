@@ -0,0 +1,213 @@
+package sales_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/events"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func billLine(item string, qty float64, price float64, seat int) sales.Sales {
+	p := money.New(price)
+	return sales.Sales{ReceiptItem: item, ItemCode: item, Quantity: qty, Price: p, Total: p.Mul(qty), VatAlpha: "A", Seat: seat, State: "pending"}
+}
+
+func TestSplitDivide(t *testing.T) {
+	cart := []sales.Sales{billLine("a", 3, 5, 1), billLine("b", 1, 10, 2), {ReceiptItem: "c", Quantity: 1, Price: 100, State: "DELETED"}}
+
+	tests := []struct {
+		name  string
+		split sales.Split
+		stay  []money.Amount
+		parts [][]money.Amount
+		err   apperr.Code
+	}{
+		{name: "part of a line", split: sales.Split{Lines: []sales.SplitLine{{ReceiptItem: "a", Quantity: 1}}},
+			stay: []money.Amount{1000, 1000}, parts: [][]money.Amount{{500}}},
+		{name: "whole line", split: sales.Split{Lines: []sales.SplitLine{{ReceiptItem: "b"}}},
+			stay: []money.Amount{1500}, parts: [][]money.Amount{{1000}}},
+		{name: "by seat", split: sales.Split{Seats: []int{2}},
+			stay: []money.Amount{1500}, parts: [][]money.Amount{{1000}}},
+		{name: "equal shares", split: sales.Split{Shares: 3},
+			stay: []money.Amount{500, 334}, parts: [][]money.Amount{{500, 333}, {500, 333}}},
+		{name: "unknown item", split: sales.Split{Lines: []sales.SplitLine{{ReceiptItem: "c"}}}, err: apperr.ValidationFailed},
+		{name: "too much", split: sales.Split{Lines: []sales.SplitLine{{ReceiptItem: "a", Quantity: 4}}}, err: apperr.ValidationFailed},
+		{name: "empty seat", split: sales.Split{Seats: []int{3}}, err: apperr.ValidationFailed},
+		{name: "whole bill", split: sales.Split{Seats: []int{1, 2}}, err: apperr.ValidationFailed},
+		{name: "two ways", split: sales.Split{Seats: []int{1}, Shares: 2}, err: apperr.ValidationFailed},
+		{name: "one share", split: sales.Split{Shares: 1}, err: apperr.ValidationFailed},
+	}
+
+	totals := func(items []sales.Sales) []money.Amount {
+		var vals []money.Amount
+		for _, item := range items {
+			if item.State == "pending" {
+				vals = append(vals, item.Total)
+				// the quantity left prices to the line's total
+				if item.Price.Mul(item.Quantity) != item.Total {
+					t.Errorf("line %v of %v at %v doesn't total %v", item.ReceiptItem, item.Quantity, item.Price, item.Total)
+				}
+			}
+		}
+		return vals
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := tt.split.Divide(cart)
+			if tt.err != "" {
+				if !apperr.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error dividing bill: %s", err)
+			}
+
+			stay, parts := d.Apply(cart)
+			if got := totals(stay); !equalAmounts(got, tt.stay) {
+				t.Errorf("expected bill to keep %v, got %v", tt.stay, got)
+			}
+			if len(stay) != len(tt.stay)+1 {
+				t.Errorf("expected deleted lines to stay on the bill, got %v", stay)
+			}
+			if len(parts) != len(tt.parts) {
+				t.Fatalf("expected %d new receipts, got %d", len(tt.parts), len(parts))
+			}
+			for i := range parts {
+				if got := totals(parts[i]); !equalAmounts(got, tt.parts[i]) {
+					t.Errorf("expected receipt %d to take %v, got %v", i, tt.parts[i], got)
+				}
+			}
+		})
+	}
+}
+
+func equalAmounts(a, b []money.Amount) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSplitBill(t *testing.T) {
+	svc, store := newTestService()
+	store.receipts[1001] = &sales.ReceiptLog{ReceiptNum: 1001, TillNum: 7, Branch: "Main", Poster: "WAITER", State: "pending payment",
+		Cart: []sales.Sales{billLine("a", 2, 5, 1), billLine("b", 1, 10, 2)}}
+	store.orders[500] = &sales.Order{OrderNum: 500, ReceiptNum: 1001, State: "paying", OrderItems: store.receipts[1001].Cart}
+	store.nextReceipt = 1001
+
+	ch, cancel := svc.Events.Subscribe(events.Scope{ReceiptNum: 1001})
+	defer cancel()
+
+	rcpt := sales.ReceiptLog{ReceiptNum: 1001}
+	receipts, err := svc.SplitBill(context.Background(), &rcpt, sales.Split{Seats: []int{2}})
+	if err != nil {
+		t.Fatalf("error splitting bill: %s", err)
+	}
+
+	if len(receipts) != 1 || receipts[0].Total != money.New(10) || receipts[0].State != "pending payment" {
+		t.Fatalf("expected a 10.00 receipt pending payment, got %+v", receipts)
+	}
+	if rcpt.Total != money.New(10) || len(rcpt.Cart) != 1 {
+		t.Errorf("expected the bill to keep 10.00 on seat 1, got %v %v", rcpt.Total, rcpt.Cart)
+	}
+	if len(store.receipts[1002].TaxSummary) == 0 {
+		t.Errorf("expected the new receipt to be repriced")
+	}
+
+	// the order's seat 2 item follows its receipt
+	var moved *sales.Order
+	for _, ord := range store.orders {
+		if ord.ReceiptNum == receipts[0].ReceiptNum {
+			moved = ord
+		}
+	}
+	if moved == nil || len(moved.OrderItems) != 1 || moved.OrderItems[0].ReceiptItem != "b" {
+		t.Errorf("expected an order for item b on receipt %v, got %+v", receipts[0].ReceiptNum, moved)
+	}
+
+	select {
+	case e := <-ch:
+		if e.Type != events.BillSplit {
+			t.Errorf("expected %v event, got %v", events.BillSplit, e.Type)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected a bill split event")
+	}
+
+	// bills with payments can't be split
+	store.payments[1001] = map[string]money.Amount{"cash": money.New(5)}
+	if _, err := svc.SplitBill(context.Background(), &sales.ReceiptLog{ReceiptNum: 1001}, sales.Split{Shares: 2}); !apperr.Is(err, apperr.ReceiptNotOpen) {
+		t.Errorf("expected RECEIPT_NOT_OPEN, got %v", err)
+	}
+}
+
+func TestReceiptRepositorySplitOpenBill(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	rcpt := sales.ReceiptLog{ReceiptNum: 1202610190012}
+	ordA := []sales.Sales{billLine("a", 1, 5, 1)}
+	ordB := []sales.Sales{billLine("b", 2, 10, 1)}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM salestrace\s+WHERE receipt_num = \$1 AND state IN \('pending', 'suspend', 'pending payment'\)\s+FOR UPDATE`).
+		WithArgs(rcpt.ReceiptNum).
+		WillReturnRows(mock.NewRows([]string{"state", "cart", "unpaid", "till_num", "pay_till", "branch", "poster", "company_id", "sale_type"}).
+			AddRow("pending", []sales.Sales{}, true, int64(7), int64(0), "Main", "WAITER", int64(1), "Cash Sale"))
+	mock.ExpectQuery(`FROM salesorders\s+WHERE receipt_num = \$1 AND state NOT IN`).
+		WithArgs(rcpt.ReceiptNum).
+		WillReturnRows(mock.NewRows([]string{"order_num", "state", "order_items"}).
+			AddRow(int64(2026101910110), "dispatched", ordA).
+			AddRow(int64(2026101910120), "dispatched", ordB))
+	mock.ExpectQuery(`FROM salestrace WHERE trans_date::date`).
+		WillReturnRows(mock.NewRows([]string{"receipt_num", "daily_count"}).AddRow(int64(1202610190013), int32(13)))
+	mock.ExpectQuery(`INSERT INTO salestrace`).
+		WithArgs(int64(7), int64(1202610190013), "WAITER", int32(13), "Main", int64(1), "Cash Sale", int64(0), int64(0)).
+		WillReturnRows(mock.NewRows([]string{"trans_date"}).AddRow(time.Now()))
+	// all of order a moves with its item
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE salesorders SET order_items = $1, receipt_num = $2 WHERE order_num = $3`)).
+		WithArgs(pgxmock.AnyArg(), int64(1202610190013), int64(2026101910110)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	// order b keeps one and copies the other to the next number in its series
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE salesorders SET order_items = $1, receipt_num = $2 WHERE order_num = $3`)).
+		WithArgs(pgxmock.AnyArg(), rcpt.ReceiptNum, int64(2026101910120)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectQuery(`SELECT max\(order_num\) FROM salesorders WHERE order_num BETWEEN \$1 AND \$2`).
+		WithArgs(int64(2026101910120), int64(2026101910129)).
+		WillReturnRows(mock.NewRows([]string{"max"}).AddRow(int64(2026101910120)))
+	mock.ExpectExec(`INSERT INTO salesorders`).
+		WithArgs(int64(2026101910121), pgxmock.AnyArg(), int64(1202610190013), int64(2026101910120)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	mock.ExpectRollback()
+
+	split := sales.Split{Lines: []sales.SplitLine{{ReceiptItem: "a"}, {ReceiptItem: "b", Quantity: 1}}}
+	receipts, err := sales.NewReceiptRepository(mock).Split(context.Background(), &rcpt, split)
+	if err != nil {
+		t.Fatalf("error was not expected while splitting bill: %s", err)
+	}
+	if len(receipts) != 1 || receipts[0].ReceiptNum != 1202610190013 {
+		t.Errorf("expected receipt 1202610190013, got %+v", receipts)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,154 @@
+package sales_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/variables"
+)
+
+func TestNegativeStockGuard(t *testing.T) {
+	svc, store := newTestService()
+	svc.Settings = func() (variables.PosSettings, error) {
+		return variables.PosSettings{ApproveSales: true}, nil
+	}
+	store.users["JTELLER"] = teller("JTELLER")
+	store.users["SUPER"] = approver("SUPER")
+	store.products["1001"] = products.StockMaster{ItemCode: "1001", ItemName: "Bread", TillPrice: 50, Bal: 9, LocationBal: map[string]float64{"shop": 5, "store": 4}}
+
+	first := sales.Sales{ItemCode: "1001", Quantity: 3}
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &first); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+
+	// another till's open cart already holds 3 of the 5
+	other := teller("JTELLER")
+	other.TillNum = 2
+	err := svc.AddCart(context.Background(), other, &sales.Sales{ItemCode: "1001", Quantity: 3})
+	if !apperr.Is(err, apperr.InsufficientStock) {
+		t.Fatalf("expected INSUFFICIENT_STOCK, got %v", err)
+	}
+	if err := svc.AddCart(context.Background(), other, &sales.Sales{ItemCode: "1001", Quantity: 2}); err != nil {
+		t.Fatalf("error adding the last 2 in stock: %s", err)
+	}
+
+	// an approver without the right can't allow it
+	cashier := teller("CASHIER")
+	store.users["CASHIER"] = cashier
+	err = svc.AddCart(context.Background(), other, &sales.Sales{ItemCode: "1001", Quantity: 1, StockApprover: "CASHIER", ApToken: cashier.Token})
+	if !apperr.Is(err, apperr.ApprovalRequired) {
+		t.Fatalf("expected APPROVAL_REQUIRED, got %v", err)
+	}
+
+	super := approver("SUPER")
+	super.GrantApproveSales = true
+	store.users["SUPER"] = super
+	item := sales.Sales{ItemCode: "1001", Quantity: 1, StockApprover: "SUPER", ApToken: super.Token}
+	if err := svc.AddCart(context.Background(), other, &item); err != nil {
+		t.Fatalf("error adding an approved line: %s", err)
+	}
+	if item.StockApprover != "SUPER" {
+		t.Errorf("expected the approver kept on the line, got %q", item.StockApprover)
+	}
+}
+
+func TestPostReservesStock(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["1001"] = products.StockMaster{ItemCode: "1001", ItemName: "Bread", TillPrice: 50}
+
+	item := sales.Sales{ItemCode: "1001", Quantity: 1}
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &item); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &sales.Sales{ItemCode: "1001", Quantity: 1, ReceiptNum: item.ReceiptNum}); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+	if _, err := svc.ApplyPayment(context.Background(), &sales.ReceiptLog{ReceiptNum: item.ReceiptNum}, sales.Payment{Paymode: "cash", Amount: money.New(100)}); err != nil {
+		t.Fatalf("error applying payment: %s", err)
+	}
+	if err := svc.PostReceipt(context.Background(), &sales.ReceiptLog{ReceiptNum: item.ReceiptNum}); err != nil {
+		t.Fatalf("error posting receipt: %s", err)
+	}
+
+	r, ok := store.reserved[fmt.Sprintf("receipt-%d", item.ReceiptNum)]
+	if !ok || len(r.Lines) != 1 || r.Lines[0].Quantity != 2 || r.Branch != "Main" || r.StkLocation != "shop" {
+		t.Errorf("expected 2 of 1001 reserved at Main's shop, got %+v", r)
+	}
+
+	// posting again is rejected and leaves the sale's stock taken
+	if err := svc.PostReceipt(context.Background(), &sales.ReceiptLog{ReceiptNum: item.ReceiptNum}); !apperr.Is(err, apperr.ReceiptNotOpen) {
+		t.Errorf("expected RECEIPT_NOT_OPEN posting twice, got %v", err)
+	}
+	if _, ok := store.reserved[fmt.Sprintf("receipt-%d", item.ReceiptNum)]; !ok {
+		t.Error("expected the posted sale's reservation kept")
+	}
+
+	// voiding the posted sale is rejected and doesn't put its stock back
+	if err := svc.VoidReceipt(context.Background(), &sales.ReceiptLog{ReceiptNum: item.ReceiptNum}); !apperr.Is(err, apperr.ReceiptNotOpen) {
+		t.Errorf("expected RECEIPT_NOT_OPEN voiding a posted sale, got %v", err)
+	}
+	if store.receipts[item.ReceiptNum].State != "POSTED" {
+		t.Errorf("expected the sale still posted, got %v", store.receipts[item.ReceiptNum].State)
+	}
+	if _, ok := store.reserved[fmt.Sprintf("receipt-%d", item.ReceiptNum)]; !ok {
+		t.Error("expected the posted sale's reservation kept after the void")
+	}
+}
+
+func TestVoidReleasesStock(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["1001"] = products.StockMaster{ItemCode: "1001", ItemName: "Bread", TillPrice: 50}
+
+	item := sales.Sales{ItemCode: "1001", Quantity: 1}
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &item); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+	ref := fmt.Sprintf("receipt-%d", item.ReceiptNum)
+	store.reserved[ref] = products.Reservation{Reference: ref}
+
+	rcpt := sales.ReceiptLog{ReceiptNum: item.ReceiptNum}
+	if err := svc.VoidReceipt(context.Background(), &rcpt); err != nil {
+		t.Fatalf("error voiding receipt: %s", err)
+	}
+	if store.receipts[item.ReceiptNum].State != "VOIDED" {
+		t.Errorf("expected the receipt voided, got %v", store.receipts[item.ReceiptNum].State)
+	}
+	if _, ok := store.reserved[ref]; ok {
+		t.Error("expected the reservation released")
+	}
+}
+
+func TestReturnReleasesStock(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["1001"] = products.StockMaster{ItemCode: "1001", ItemName: "Bread", TillPrice: 50}
+
+	item := sales.Sales{ItemCode: "1001", Quantity: 3}
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &item); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+	if _, err := svc.ApplyPayment(context.Background(), &sales.ReceiptLog{ReceiptNum: item.ReceiptNum}, sales.Payment{Paymode: "cash", Amount: money.New(150)}); err != nil {
+		t.Fatalf("error applying payment: %s", err)
+	}
+	if err := svc.PostReceipt(context.Background(), &sales.ReceiptLog{ReceiptNum: item.ReceiptNum}); err != nil {
+		t.Fatalf("error posting receipt: %s", err)
+	}
+
+	line := store.receipts[item.ReceiptNum].Cart[0].ReceiptItem
+	back := sales.SalesReturn{ReturnTrace: item.ReceiptNum, Lines: []sales.ReturnLine{{ReceiptItem: line, Quantity: 2}}}
+	if _, err := svc.ReturnSale(context.Background(), teller("JTELLER"), back); err != nil {
+		t.Fatalf("error returning sale: %s", err)
+	}
+
+	r, ok := store.reserved[fmt.Sprintf("receipt-%d", item.ReceiptNum)]
+	if !ok || len(r.Lines) != 1 || r.Lines[0].Quantity != 1 {
+		t.Errorf("expected the 2 returned given back and 1 still reserved, got %+v", r)
+	}
+}
@@ -0,0 +1,123 @@
+package sales_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+)
+
+func newFloor(t *testing.T, svc *sales.Service) sales.DiningTable {
+	t.Helper()
+
+	f := sales.Floor{Branch: "Main", Name: "Terrace"}
+	if err := svc.SaveFloor(context.Background(), &f); err != nil {
+		t.Fatalf("error saving floor: %s", err)
+	}
+	if !f.Active {
+		t.Error("expected a new floor to be active")
+	}
+
+	table := sales.DiningTable{FloorID: f.ID, Branch: "Main", Name: "T1", Seats: 4}
+	if err := svc.SaveTable(context.Background(), &table); err != nil {
+		t.Fatalf("error saving table: %s", err)
+	}
+	return table
+}
+
+func tableStatus(t *testing.T, svc *sales.Service, id int64) sales.TableState {
+	t.Helper()
+
+	states, err := svc.FloorState(context.Background(), "Main", 0)
+	if err != nil {
+		t.Fatalf("error fetching floor state: %s", err)
+	}
+	for _, st := range states {
+		if st.ID == id {
+			return st
+		}
+	}
+	t.Fatalf("table %v not on the floor", id)
+	return sales.TableState{}
+}
+
+func TestFloorState(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	table := newFloor(t, svc)
+
+	if st := tableStatus(t, svc, table.ID); st.Status != sales.TableFree {
+		t.Errorf("expected a free table, got %v", st.Status)
+	}
+
+	seated, err := svc.OpenTable(context.Background(), teller("JTELLER"), table.ID, 3)
+	if err != nil {
+		t.Fatalf("error opening table: %s", err)
+	}
+	if seated.ReceiptNum == 0 || seated.Waiter != "JTELLER" || seated.Guests != 3 {
+		t.Errorf("expected a bill for 3 served by JTELLER, got %+v", seated)
+	}
+	if st := tableStatus(t, svc, table.ID); st.Status != sales.TableOrdering {
+		t.Errorf("expected the table ordering, got %v", st.Status)
+	}
+
+	store.users["WAITER2"] = teller("WAITER2")
+	if _, err := svc.OpenTable(context.Background(), teller("WAITER2"), table.ID, 2); !apperr.Is(err, apperr.TableOccupied) {
+		t.Errorf("expected TABLE_OCCUPIED, got %v", err)
+	}
+
+	// an order sent to the kitchen two minutes ago
+	store.orders[501] = &sales.Order{
+		OrderNum:     501,
+		ReceiptNum:   seated.ReceiptNum,
+		State:        "ordered",
+		CompleteTime: time.Now().Add(-2 * time.Minute),
+		OrderItems:   []sales.Sales{{ItemCode: "1001", Quantity: 1, Price: money.New(50), Total: money.New(50), State: "pending"}},
+	}
+	st := tableStatus(t, svc, table.ID)
+	if st.Status != sales.TableWaiting || st.Elapsed < 120 {
+		t.Errorf("expected the table waiting for 2 minutes, got %v for %v seconds", st.Status, st.Elapsed)
+	}
+
+	store.orders[501].State = "dispatched"
+	if err := svc.CloseBill(context.Background(), &sales.ReceiptLog{ReceiptNum: seated.ReceiptNum}); err != nil {
+		t.Fatalf("error closing bill: %s", err)
+	}
+	if st := tableStatus(t, svc, table.ID); st.Status != sales.TableBillRequested {
+		t.Errorf("expected the bill requested, got %v", st.Status)
+	}
+
+	if err := svc.VoidReceipt(context.Background(), &sales.ReceiptLog{ReceiptNum: seated.ReceiptNum}); err != nil {
+		t.Fatalf("error voiding bill: %s", err)
+	}
+	if st := tableStatus(t, svc, table.ID); st.Status != sales.TableFree || st.ReceiptNum != 0 {
+		t.Errorf("expected the table freed with its bill, got %v with bill %v", st.Status, st.ReceiptNum)
+	}
+}
+
+func TestTransferTable(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.users["WAITER2"] = teller("WAITER2")
+	table := newFloor(t, svc)
+
+	seated, err := svc.OpenTable(context.Background(), teller("JTELLER"), table.ID, 2)
+	if err != nil {
+		t.Fatalf("error opening table: %s", err)
+	}
+
+	if _, err := svc.TransferTable(context.Background(), teller("WAITER2"), table.ID, "WAITER2"); !apperr.Is(err, apperr.Forbidden) {
+		t.Errorf("expected another waiter to be forbidden from taking the table, got %v", err)
+	}
+
+	moved, err := svc.TransferTable(context.Background(), teller("JTELLER"), table.ID, "WAITER2")
+	if err != nil {
+		t.Fatalf("error transferring table: %s", err)
+	}
+	if moved.Waiter != "WAITER2" || store.receipts[seated.ReceiptNum].Poster != "WAITER2" {
+		t.Errorf("expected WAITER2 to serve the table and its bill, got %v and %v", moved.Waiter, store.receipts[seated.ReceiptNum].Poster)
+	}
+}
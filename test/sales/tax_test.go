@@ -0,0 +1,114 @@
+package sales_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/money"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/products"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+)
+
+func taxLine(code string, qty, price float64) sales.Sales {
+	return sales.Sales{ItemCode: code, Quantity: qty, Price: money.New(price), VatAlpha: code, State: "pending"}
+}
+
+func TestTaxTableApply(t *testing.T) {
+	rates := map[string]float64{"A": 16, "B": 8, "C": 0, "E": 0}
+
+	tests := []struct {
+		name      string
+		table     sales.TaxTable
+		exempt    bool
+		line      sales.Sales
+		vat       float64
+		total     float64
+		vatExempt bool
+	}{
+		{"inclusive", sales.TaxTable{Rates: rates}, false, taxLine("A", 1, 116), 16, 116, false},
+		{"exclusive", sales.TaxTable{Rates: rates, Exclusive: true}, false, taxLine("A", 2, 50), 16, 116, false},
+		{"reduced band", sales.TaxTable{Rates: rates}, false, taxLine("B", 1, 108), 8, 108, false},
+		{"zero rated", sales.TaxTable{Rates: rates, Exempt: []string{"E"}}, false, taxLine("C", 1, 100), 0, 100, false},
+		{"exempt band", sales.TaxTable{Rates: rates, Exempt: []string{"E"}}, false, taxLine("E", 1, 100), 0, 100, true},
+		{"exempt customer", sales.TaxTable{Rates: rates}, true, taxLine("A", 1, 116), 0, 100, true},
+		{"unknown code keeps inventory rate", sales.TaxTable{Rates: rates}, false, sales.Sales{ItemCode: "X", Quantity: 1, Price: money.New(116), VatPercent: 16, State: "pending"}, 16, 116, false},
+		{"per line rounding", sales.TaxTable{Rates: rates}, false, taxLine("A", 1, 10), 1.38, 10, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cart := tt.table.Apply([]sales.Sales{tt.line}, tt.exempt)
+			if cart[0].Vat.Float() != tt.vat || cart[0].Total.Float() != tt.total {
+				t.Errorf("expected vat %v and total %v, got %v and %v", tt.vat, tt.total, cart[0].Vat, cart[0].Total)
+			}
+			if cart[0].VatExempt != tt.vatExempt {
+				t.Errorf("expected vat_exempt %v, got %v", tt.vatExempt, cart[0].VatExempt)
+			}
+		})
+	}
+}
+
+func TestSummarizeTaxPerReceiptRounding(t *testing.T) {
+	cart := []sales.Sales{taxLine("A", 1, 1), taxLine("A", 1, 1), taxLine("A", 1, 1), taxLine("C", 1, 25)}
+
+	perLine := sales.SummarizeTax(sales.TaxTable{Rates: map[string]float64{"A": 16, "C": 0}}.Apply(cart, false))
+	if len(perLine) != 2 || perLine[0].Code != "A" || perLine[0].Vat != money.New(0.42) {
+		t.Fatalf("expected band A vat 0.42 rounded per line, got %+v", perLine)
+	}
+
+	cart = []sales.Sales{taxLine("A", 1, 1), taxLine("A", 1, 1), taxLine("A", 1, 1), taxLine("C", 1, 25)}
+	perReceipt := sales.SummarizeTax(sales.TaxTable{Rates: map[string]float64{"A": 16, "C": 0}, Rounding: sales.RoundPerReceipt}.Apply(cart, false))
+	if perReceipt[0].Vat != money.New(0.41) || perReceipt[0].Gross != money.New(3) || perReceipt[0].Net != money.New(2.59) {
+		t.Errorf("expected band A 2.59 + 0.41 = 3 rounded per receipt, got %+v", perReceipt[0])
+	}
+	if perReceipt[1].Code != "C" || perReceipt[1].Vat != money.New(0) || perReceipt[1].Gross != money.New(25) {
+		t.Errorf("expected zero rated band C of 25, got %+v", perReceipt[1])
+	}
+}
+
+func TestVatExemptCustomer(t *testing.T) {
+	svc, store := newTestService()
+	store.users["JTELLER"] = teller("JTELLER")
+	store.products["1001"] = products.StockMaster{ItemCode: "1001", ItemName: "Bread", TillPrice: 116, VatAlpha: "A", VatPercent: 16}
+
+	item := sales.Sales{ItemCode: "1001", Quantity: 1}
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &item); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+	if item.Vat != money.New(16) {
+		t.Errorf("expected vat 16, got %v", item.Vat)
+	}
+
+	rcpt := sales.ReceiptLog{ReceiptNum: item.ReceiptNum}
+	if err := svc.SetVatExempt(context.Background(), teller("JTELLER"), &rcpt, true, "EX-001", "", ""); !apperr.Is(err, apperr.ApprovalRequired) {
+		t.Errorf("expected APPROVAL_REQUIRED exempting without the vat exempt right, got %v", err)
+	}
+
+	user := teller("JTELLER")
+	user.VatExempt = true
+	if err := svc.SetVatExempt(context.Background(), user, &rcpt, true, "", "", ""); err == nil {
+		t.Error("expected an error exempting without a certificate")
+	}
+
+	if err := svc.SetVatExempt(context.Background(), user, &rcpt, true, "EX-001", "", ""); err != nil {
+		t.Fatalf("error exempting customer: %s", err)
+	}
+	if rcpt.Total != money.New(100) {
+		t.Errorf("expected the exempt customer to pay 100, got %v", rcpt.Total)
+	}
+	if len(rcpt.TaxSummary) != 1 || !rcpt.TaxSummary[0].Exempt || rcpt.TaxSummary[0].Vat != money.New(0) {
+		t.Errorf("expected an exempt band in the tax summary, got %+v", rcpt.TaxSummary)
+	}
+
+	// later items are exempt too
+	if err := svc.AddCart(context.Background(), teller("JTELLER"), &sales.Sales{ItemCode: "1001", Quantity: 1, ReceiptNum: item.ReceiptNum}); err != nil {
+		t.Fatalf("error adding to cart: %s", err)
+	}
+	if err := svc.Receipt(context.Background(), &rcpt); err != nil {
+		t.Fatalf("error fetching receipt: %s", err)
+	}
+	if rcpt.Total != money.New(200) {
+		t.Errorf("expected total 200, got %v", rcpt.Total)
+	}
+}
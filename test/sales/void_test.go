@@ -0,0 +1,37 @@
+package sales_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/apperr"
+	"github.com/JohnnyKahiu/speedsales/poserver/pkg/sales"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestReceiptRepositoryVoidClosed(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close()
+
+	rcpt := sales.ReceiptLog{ReceiptNum: 1202610190012}
+
+	// a posted receipt matches no row, so its orders are left alone
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE salestrace SET state = 'VOIDED' WHERE receipt_num = $1 AND state <> ALL($2)`)).
+		WithArgs(rcpt.ReceiptNum, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectRollback()
+
+	err = sales.NewReceiptRepository(mock).Void(context.Background(), &rcpt)
+	if !apperr.Is(err, apperr.ReceiptNotOpen) {
+		t.Errorf("expected RECEIPT_NOT_OPEN, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}